@@ -0,0 +1,498 @@
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// boltHeaderSize is the size of the header preceding each value: the expiry
+// as big-endian Unix nanoseconds, 0 meaning never.
+const boltHeaderSize = 8
+
+// BoltStorageConfig represents configuration for BoltStorage.
+type BoltStorageConfig struct {
+	// DB is an already open database. If provided, Path is ignored and
+	// Close leaves the database open, so it can be shared with the rest of
+	// the application.
+	DB *bbolt.DB
+
+	// Path is the database file to open, created if it does not exist.
+	Path string
+
+	// OpenTimeout is how long to wait for the file lock held by another
+	// process when opening Path. Zero waits forever.
+	// Default: 1 second
+	OpenTimeout time.Duration
+
+	// KeyPrefix is the prefix for session keys. It names the bucket holding
+	// the sessions.
+	// Default: "session:"
+	KeyPrefix string
+
+	// GCInterval is how often expired sessions are swept.
+	// Set to 0 to disable the sweep; expired sessions are still removed on access.
+	// Default: 10 minutes
+	GCInterval time.Duration
+}
+
+// DefaultBoltStorageConfig returns a BoltStorageConfig with default values.
+func DefaultBoltStorageConfig() BoltStorageConfig {
+	return BoltStorageConfig{
+		OpenTimeout: time.Second,
+		KeyPrefix:   "session:",
+		GCInterval:  10 * time.Minute,
+	}
+}
+
+// WithDB sets an already open database.
+func (c BoltStorageConfig) WithDB(db *bbolt.DB) BoltStorageConfig {
+	c.DB = db
+	return c
+}
+
+// WithPath sets the database file path.
+func (c BoltStorageConfig) WithPath(path string) BoltStorageConfig {
+	c.Path = path
+	return c
+}
+
+// WithOpenTimeout sets how long to wait for the database file lock.
+func (c BoltStorageConfig) WithOpenTimeout(timeout time.Duration) BoltStorageConfig {
+	c.OpenTimeout = timeout
+	return c
+}
+
+// WithKeyPrefix sets the key prefix.
+func (c BoltStorageConfig) WithKeyPrefix(prefix string) BoltStorageConfig {
+	c.KeyPrefix = prefix
+	return c
+}
+
+// WithGCInterval sets the sweep interval.
+func (c BoltStorageConfig) WithGCInterval(interval time.Duration) BoltStorageConfig {
+	c.GCInterval = interval
+	return c
+}
+
+// BoltStorage implements Storage interface on a bbolt database, for durable
+// local sessions without cgo or an external service.
+//
+// Each key prefix gets its own bucket, and values are stored with a small
+// header holding the expiry. Expired sessions are removed lazily on Get and
+// by a periodic sweep. bbolt locks the database file, so only one process
+// can open it at a time.
+type BoltStorage struct {
+	db        *bbolt.DB
+	ownsDB    bool
+	bucket    []byte
+	keyPrefix string
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+	gcStop    chan struct{}
+	gcDone    chan struct{}
+}
+
+// NewBoltStorage creates a new bbolt storage, opening the database file at
+// path. If gcInterval is 0, the sweep is disabled.
+func NewBoltStorage(path, keyPrefix string, gcInterval time.Duration) (*BoltStorage, error) {
+	cfg := DefaultBoltStorageConfig().
+		WithPath(path).
+		WithKeyPrefix(keyPrefix).
+		WithGCInterval(gcInterval)
+	return NewBoltStorageWithConfig(cfg)
+}
+
+// NewBoltStorageFromDB creates a new bbolt storage on an already open
+// database, which Close leaves open. If gcInterval is 0, the sweep is disabled.
+func NewBoltStorageFromDB(db *bbolt.DB, keyPrefix string, gcInterval time.Duration) (*BoltStorage, error) {
+	cfg := DefaultBoltStorageConfig().
+		WithDB(db).
+		WithKeyPrefix(keyPrefix).
+		WithGCInterval(gcInterval)
+	return NewBoltStorageWithConfig(cfg)
+}
+
+// NewBoltStorageWithConfig creates a new bbolt storage using configuration,
+// opening the database unless cfg.DB is set, and creates the bucket for the
+// key prefix if it does not exist.
+func NewBoltStorageWithConfig(cfg BoltStorageConfig) (*BoltStorage, error) {
+	db, ownsDB := cfg.DB, false
+	if db == nil {
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("bolt database path cannot be empty")
+		}
+		var err error
+		db, err = bbolt.Open(cfg.Path, 0o600, &bbolt.Options{Timeout: cfg.OpenTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bolt database: %w", err)
+		}
+		ownsDB = true
+	}
+
+	keyPrefix := cfg.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = "session:"
+	} else if keyPrefix[len(keyPrefix)-1] != ':' {
+		keyPrefix += ":"
+	}
+
+	s := &BoltStorage{
+		db:        db,
+		ownsDB:    ownsDB,
+		bucket:    []byte(keyPrefix),
+		keyPrefix: keyPrefix,
+	}
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		if ownsDB {
+			_ = db.Close()
+		}
+		return nil, fmt.Errorf("failed to create bolt bucket: %w", err)
+	}
+
+	if cfg.GCInterval > 0 {
+		s.gcStop = make(chan struct{})
+		s.gcDone = make(chan struct{})
+		go s.runGC(cfg.GCInterval)
+	}
+
+	return s, nil
+}
+
+// GetKeyPrefix returns the key prefix used by this storage.
+func (s *BoltStorage) GetKeyPrefix() string {
+	return s.keyPrefix
+}
+
+// encodeBoltValue prepends the expiry header to val.
+func encodeBoltValue(val []byte, expiresAt time.Time) []byte {
+	raw := make([]byte, boltHeaderSize+len(val))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(raw, uint64(expiresAt.UnixNano()))
+	}
+	copy(raw[boltHeaderSize:], val)
+	return raw
+}
+
+// decodeBoltValue splits raw into the value and its expiry. The value
+// shares memory with raw.
+func decodeBoltValue(raw []byte) ([]byte, time.Time, error) {
+	if len(raw) < boltHeaderSize {
+		return nil, time.Time{}, fmt.Errorf("invalid bolt value")
+	}
+	var expiresAt time.Time
+	if nanos := int64(binary.BigEndian.Uint64(raw)); nanos != 0 {
+		expiresAt = time.Unix(0, nanos)
+	}
+	return raw[boltHeaderSize:], expiresAt, nil
+}
+
+// lookup returns a copy of the value for key and its expiry, or nil data
+// if the key does not exist.
+func (s *BoltStorage) lookup(key string) ([]byte, time.Time, error) {
+	var (
+		data      []byte
+		expiresAt time.Time
+	)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(s.bucket).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		val, exp, err := decodeBoltValue(raw)
+		if err != nil {
+			return err
+		}
+		// Values are only valid for the life of the transaction
+		data = append(make([]byte, 0, len(val)), val...)
+		expiresAt = exp
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get from bolt: %w", err)
+	}
+	return data, expiresAt, nil
+}
+
+// removeIfExpired deletes key if it is still expired within the write
+// transaction, since it may have been rewritten in the meantime.
+func (s *BoltStorage) removeIfExpired(key string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		if _, expiresAt, err := decodeBoltValue(raw); err != nil || !isExpiredAt(expiresAt) {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from bolt: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves the value for the given key.
+// Returns nil, nil if the key does not exist or has expired.
+func (s *BoltStorage) Get(key string) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	data, expiresAt, err := s.lookup(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if isExpiredAt(expiresAt) {
+		return nil, s.removeIfExpired(key)
+	}
+	return data, nil
+}
+
+// Set stores the given value for the given key along with an expiration value.
+// If expiration is 0, the value never expires.
+// Empty key or value will be ignored without an error.
+func (s *BoltStorage) Set(key string, val []byte, exp time.Duration) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	var expiresAt time.Time
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp)
+	}
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte(key), encodeBoltValue(val, expiresAt))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set in bolt: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent stores the given value for the given key unless the key
+// exists and has not expired, and reports whether it was stored. If exp is
+// 0, the value never expires.
+func (s *BoltStorage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+	if key == "" || len(val) == 0 {
+		return false, nil
+	}
+
+	var expiresAt time.Time
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp)
+	}
+	stored := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if raw := b.Get([]byte(key)); raw != nil {
+			if _, existing, err := decodeBoltValue(raw); err != nil || !isExpiredAt(existing) {
+				return err
+			}
+		}
+		stored = true
+		return b.Put([]byte(key), encodeBoltValue(val, expiresAt))
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to set in bolt: %w", err)
+	}
+	return stored, nil
+}
+
+// Delete removes the value for the given key.
+// It returns no error if the storage does not contain the key.
+func (s *BoltStorage) Delete(key string) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from bolt: %w", err)
+	}
+	return nil
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *BoltStorage) Reset() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(s.bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(s.bucket)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset bolt: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *BoltStorage) Exists(key string) (bool, error) {
+	data, err := s.Get(key)
+	return data != nil, err
+}
+
+// GetTTL returns the remaining TTL for a key.
+// Returns -2 if the key does not exist, -1 if the key has no expiration.
+func (s *BoltStorage) GetTTL(key string) (time.Duration, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	data, expiresAt, err := s.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	if data == nil || isExpiredAt(expiresAt) {
+		return -2, nil
+	}
+	if expiresAt.IsZero() {
+		return -1, nil
+	}
+	return time.Until(expiresAt), nil
+}
+
+// Expire sets a new expiration on a key.
+// If exp is 0, the expiration is removed.
+func (s *BoltStorage) Expire(key string, exp time.Duration) error {
+	_, err := s.Touch(key, exp)
+	return err
+}
+
+// Touch sets a new expiration on a key and reports whether the key existed.
+// If exp is 0, the expiration is removed.
+func (s *BoltStorage) Touch(key string, exp time.Duration) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+
+	var expiresAt time.Time
+	if exp > 0 {
+		expiresAt = time.Now().Add(exp)
+	}
+
+	touched := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		val, oldExpiresAt, err := decodeBoltValue(raw)
+		if err != nil {
+			return err
+		}
+		if isExpiredAt(oldExpiresAt) {
+			return b.Delete([]byte(key))
+		}
+		touched = true
+		return b.Put([]byte(key), encodeBoltValue(val, expiresAt))
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to set expiration in bolt: %w", err)
+	}
+	return touched, nil
+}
+
+// runGC sweeps expired sessions periodically until Close.
+func (s *BoltStorage) runGC(interval time.Duration) {
+	defer close(s.gcDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = s.gc()
+		case <-s.gcStop:
+			return
+		}
+	}
+}
+
+// gc removes expired sessions and returns how many it removed.
+func (s *BoltStorage) gc() (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+
+		// Deleting while iterating a cursor can skip keys, so collect first
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			if _, expiresAt, err := decodeBoltValue(v); err == nil && isExpiredAt(expiresAt) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions from bolt: %w", err)
+	}
+	return removed, nil
+}
+
+// Ping checks that the storage and its database are open.
+func (s *BoltStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
+// Close stops the sweep and closes the database if the storage opened it.
+// Close is idempotent.
+func (s *BoltStorage) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		if s.gcStop != nil {
+			close(s.gcStop)
+			<-s.gcDone
+		}
+		if s.ownsDB {
+			err = s.db.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,233 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func newTestBoltStorage(t *testing.T, prefix string) *BoltStorage {
+	t.Helper()
+	storage, err := NewBoltStorage(filepath.Join(t.TempDir(), "sessions.db"), prefix, 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestBoltStorageBasic(t *testing.T) {
+	storage := newTestBoltStorage(t, "test")
+
+	if storage.GetKeyPrefix() != "test:" {
+		t.Errorf("expected prefix 'test:', got %s", storage.GetKeyPrefix())
+	}
+	if got, err := storage.Get("key"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing key, got %q (%v)", got, err)
+	}
+
+	_ = storage.Set("key", []byte("value1"), time.Hour)
+	_ = storage.Set("key", []byte("value2"), time.Hour)
+	got, _ := storage.Get("key")
+	if string(got) != "value2" {
+		t.Errorf("expected value2, got %q", got)
+	}
+	got[0] = 'X'
+	if again, _ := storage.Get("key"); string(again) != "value2" {
+		t.Error("expected Get to return a copy")
+	}
+
+	// Empty key or value is ignored
+	_ = storage.Set("", []byte("value"), 0)
+	_ = storage.Set("empty", nil, 0)
+	if ok, _ := storage.Exists("empty"); ok {
+		t.Error("expected empty value to be ignored")
+	}
+
+	if err := storage.Delete("key"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if ok, _ := storage.Exists("key"); ok {
+		t.Error("expected key to be deleted")
+	}
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error on ping: %v", err)
+	}
+
+	_ = storage.Close()
+	if _, err := storage.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestBoltStorageTTL(t *testing.T) {
+	storage := newTestBoltStorage(t, "test:")
+
+	_ = storage.Set("forever", []byte("value"), 0)
+	_ = storage.Set("hour", []byte("value"), time.Hour)
+	_ = storage.Set("short", []byte("value"), 20*time.Millisecond)
+	_ = storage.Set("swept", []byte("value"), 20*time.Millisecond)
+
+	if ttl, _ := storage.GetTTL("forever"); ttl != -1 {
+		t.Errorf("expected -1 for no expiration, got %v", ttl)
+	}
+	if ttl, _ := storage.GetTTL("hour"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("expected about an hour, got %v", ttl)
+	}
+	if ttl, _ := storage.GetTTL("missing"); ttl != -2 {
+		t.Errorf("expected -2 for a missing key, got %v", ttl)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got, err := storage.Get("short"); got != nil || err != nil {
+		t.Errorf("expected expired key to be gone, got %q (%v)", got, err)
+	}
+	if ok, _ := storage.Touch("swept", time.Hour); ok {
+		t.Error("expected Touch not to revive an expired key")
+	}
+	_ = storage.Set("swept", []byte("value"), 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if ok, err := storage.Touch("forever", time.Hour); !ok || err != nil {
+		t.Errorf("expected Touch to succeed, got %v (%v)", ok, err)
+	}
+	if got, _ := storage.Get("forever"); string(got) != "value" {
+		t.Errorf("expected Touch to keep the value, got %q", got)
+	}
+	if err := storage.Expire("forever", 0); err != nil {
+		t.Fatalf("failed to expire: %v", err)
+	}
+	if ttl, _ := storage.GetTTL("forever"); ttl != -1 {
+		t.Errorf("expected Expire(0) to remove the expiration, got %v", ttl)
+	}
+
+	if n, err := storage.gc(); n != 1 || err != nil {
+		t.Errorf("expected 1 expired session swept, got %d (%v)", n, err)
+	}
+}
+
+func TestBoltStorageSetIfAbsent(t *testing.T) {
+	testSetIfAbsent(t, newTestBoltStorage(t, "test"))
+}
+
+func TestBoltStorageGCLoop(t *testing.T) {
+	storage, err := NewBoltStorage(filepath.Join(t.TempDir(), "sessions.db"), "test:", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 5*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int
+		_ = storage.db.View(func(tx *bbolt.Tx) error {
+			n = tx.Bucket(storage.bucket).Stats().KeyN
+			return nil
+		})
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sweep to remove the expired session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBoltStorageSharedDB(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	storage, err := NewBoltStorageFromDB(db, "a:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	other, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeBolt).WithBoltDB(db).WithKeyPrefix("b:"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	_ = storage.Set("key1", []byte("value"), time.Hour)
+	_ = storage.Set("key2", []byte("value"), 0)
+	_ = other.Set("key1", []byte("other"), time.Hour)
+
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if ok, _ := storage.Exists("key1"); ok {
+		t.Error("expected key1 to be reset")
+	}
+	if got, _ := other.Get("key1"); string(got) != "other" {
+		t.Errorf("expected other prefix to survive reset, got %q", got)
+	}
+
+	// Closing a storage built on a shared database leaves it open
+	_ = storage.Close()
+	_ = other.Close()
+	if err := db.View(func(tx *bbolt.Tx) error { return nil }); err != nil {
+		t.Errorf("expected the shared database to stay open, got %v", err)
+	}
+}
+
+func TestBoltStorageCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sessions.db")
+
+	storage, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeBolt).WithBoltPath(path).WithKeyPrefix("test:"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	// Copy the file while the database is still open, as a crash would leave it
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read database file: %v", err)
+	}
+	crashed := filepath.Join(dir, "crashed.db")
+	if err := os.WriteFile(crashed, raw, 0o600); err != nil {
+		t.Fatalf("failed to write database copy: %v", err)
+	}
+
+	reopened, err := NewBoltStorage(crashed, "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if got, _ := reopened.Get("key"); string(got) != "value" {
+		t.Errorf("expected committed session to survive, got %q", got)
+	}
+	if ttl, _ := reopened.GetTTL("key"); ttl <= 0 {
+		t.Errorf("expected the expiration to survive, got %v", ttl)
+	}
+}
+
+func TestNewBoltStorageValidation(t *testing.T) {
+	if _, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeBolt)); err == nil {
+		t.Error("expected error without a path")
+	}
+
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	storage, err := NewBoltStorage(path, "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	// The file is locked by the first storage
+	cfg := DefaultBoltStorageConfig().WithPath(path).WithOpenTimeout(50 * time.Millisecond)
+	if _, err := NewBoltStorageWithConfig(cfg); err == nil {
+		t.Error("expected error opening a locked database")
+	}
+}
@@ -0,0 +1,269 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a CircuitBreakerStorage.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every call with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to decide whether
+	// to close or reopen the circuit.
+	BreakerHalfOpen
+)
+
+// String returns a human-readable name for the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerOptions configures CircuitBreakerStorage.
+type BreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures that trips
+	// the circuit open.
+	// Default: 5
+	FailureThreshold int
+
+	// Cooldown is how long the circuit stays open before a probe is allowed.
+	// Default: 10 seconds
+	Cooldown time.Duration
+
+	// IsFailure reports whether an error counts as a failure of the backend.
+	// Other errors are passed through without affecting the circuit.
+	// If nil, IsTransientError is used.
+	IsFailure func(err error) bool
+
+	// OnStateChange is called after every state transition, e.g. to export
+	// the state as a metric. It is called outside the breaker's lock and may
+	// be called concurrently.
+	OnStateChange func(from, to BreakerState)
+}
+
+// DefaultBreakerOptions returns BreakerOptions with default values.
+func DefaultBreakerOptions() BreakerOptions {
+	return BreakerOptions{
+		FailureThreshold: 5,
+		Cooldown:         10 * time.Second,
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failures that trips the circuit.
+func (o BreakerOptions) WithFailureThreshold(n int) BreakerOptions {
+	o.FailureThreshold = n
+	return o
+}
+
+// WithCooldown sets how long the circuit stays open before probing.
+func (o BreakerOptions) WithCooldown(d time.Duration) BreakerOptions {
+	o.Cooldown = d
+	return o
+}
+
+// WithIsFailure sets the function that decides which errors count as failures.
+func (o BreakerOptions) WithIsFailure(fn func(err error) bool) BreakerOptions {
+	o.IsFailure = fn
+	return o
+}
+
+// WithOnStateChange sets the state transition callback.
+func (o BreakerOptions) WithOnStateChange(fn func(from, to BreakerState)) BreakerOptions {
+	o.OnStateChange = fn
+	return o
+}
+
+// CircuitBreakerStorage wraps a Storage and stops calling it after repeated
+// failures, so requests fail fast with ErrCircuitOpen instead of each waiting
+// for a dial timeout while the backend is down. After the cooldown a single
+// call is let through as a probe: if it succeeds the circuit closes again,
+// otherwise it stays open for another cooldown. Close is passed through unchanged.
+type CircuitBreakerStorage struct {
+	inner Storage
+	opts  BreakerOptions
+	now   func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerStorage wraps inner with a circuit breaker. Zero fields in
+// opts fall back to the values of DefaultBreakerOptions.
+func NewCircuitBreakerStorage(inner Storage, opts BreakerOptions) Storage {
+	defaults := DefaultBreakerOptions()
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaults.FailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaults.Cooldown
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = IsTransientError
+	}
+
+	return &CircuitBreakerStorage{
+		inner: inner,
+		opts:  opts,
+		now:   time.Now,
+	}
+}
+
+// State returns the current state of the circuit.
+func (s *CircuitBreakerStorage) State() BreakerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// setState changes the state and returns the transition to report, if any.
+// The caller must hold s.mu.
+func (s *CircuitBreakerStorage) setState(to BreakerState) func() {
+	from := s.state
+	if from == to {
+		return nil
+	}
+	s.state = to
+	if to == BreakerOpen {
+		s.openedAt = s.now()
+	}
+	if fn := s.opts.OnStateChange; fn != nil {
+		return func() { fn(from, to) }
+	}
+	return nil
+}
+
+// allow reports whether a call may proceed and marks the half-open probe
+// as taken when it does.
+func (s *CircuitBreakerStorage) allow() error {
+	s.mu.Lock()
+	var notify func()
+	switch s.state {
+	case BreakerOpen:
+		if s.now().Sub(s.openedAt) < s.opts.Cooldown {
+			s.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		notify = s.setState(BreakerHalfOpen)
+		s.probing = true
+	case BreakerHalfOpen:
+		if s.probing {
+			s.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		s.probing = true
+	}
+	s.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of a call let through by allow.
+func (s *CircuitBreakerStorage) record(err error) {
+	failed := err != nil && s.opts.IsFailure(err)
+
+	s.mu.Lock()
+	var notify func()
+	switch s.state {
+	case BreakerClosed:
+		if !failed {
+			s.failures = 0
+			break
+		}
+		s.failures++
+		if s.failures >= s.opts.FailureThreshold {
+			notify = s.setState(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		s.probing = false
+		s.failures = 0
+		if failed {
+			notify = s.setState(BreakerOpen)
+		} else {
+			notify = s.setState(BreakerClosed)
+		}
+	case BreakerOpen:
+		// A call started before the circuit tripped; its outcome is stale
+	}
+	s.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
+
+// Get retrieves the value for the given key unless the circuit is open.
+func (s *CircuitBreakerStorage) Get(key string) ([]byte, error) {
+	if err := s.allow(); err != nil {
+		return nil, err
+	}
+	data, err := s.inner.Get(key)
+	s.record(err)
+	return data, err
+}
+
+// Set stores the given value for the given key unless the circuit is open.
+func (s *CircuitBreakerStorage) Set(key string, val []byte, exp time.Duration) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := s.inner.Set(key, val, exp)
+	s.record(err)
+	return err
+}
+
+// Delete removes the value for the given key unless the circuit is open.
+func (s *CircuitBreakerStorage) Delete(key string) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := s.inner.Delete(key)
+	s.record(err)
+	return err
+}
+
+// Reset removes all keys from the wrapped storage unless the circuit is open.
+func (s *CircuitBreakerStorage) Reset() error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := s.inner.Reset()
+	s.record(err)
+	return err
+}
+
+// Ping pings the wrapped storage if it implements Pinger. It returns
+// ErrCircuitOpen while the circuit is open, and its outcome counts like any
+// other operation, so a successful ping can close a half-open circuit.
+func (s *CircuitBreakerStorage) Ping(ctx context.Context) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := pingStorage(ctx, s.inner)
+	s.record(err)
+	return err
+}
+
+// Close closes the wrapped storage.
+func (s *CircuitBreakerStorage) Close() error {
+	return s.inner.Close()
+}
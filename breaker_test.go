@@ -0,0 +1,270 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// switchStorage fails every call with a connection error while down is set.
+type switchStorage struct {
+	Storage
+	down  atomic.Bool
+	calls atomic.Int64
+}
+
+func (s *switchStorage) check() error {
+	s.calls.Add(1)
+	if s.down.Load() {
+		return syscall.ECONNREFUSED
+	}
+	return nil
+}
+
+func (s *switchStorage) Get(key string) ([]byte, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.Storage.Get(key)
+}
+
+func (s *switchStorage) Set(key string, val []byte, exp time.Duration) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.Storage.Set(key, val, exp)
+}
+
+func (s *switchStorage) Delete(key string) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.Storage.Delete(key)
+}
+
+// transitionRecorder collects state transitions reported by a breaker.
+type transitionRecorder struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (r *transitionRecorder) record(from, to BreakerState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, from.String()+"->"+to.String())
+}
+
+func (r *transitionRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.transitions...)
+}
+
+func TestCircuitBreakerStorageTransitions(t *testing.T) {
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	defer func() { _ = inner.Close() }()
+
+	rec := &transitionRecorder{}
+	opts := DefaultBreakerOptions().
+		WithFailureThreshold(3).
+		WithCooldown(time.Minute).
+		WithOnStateChange(rec.record)
+	storage := NewCircuitBreakerStorage(inner, opts).(*CircuitBreakerStorage)
+
+	now := time.Now()
+	storage.now = func() time.Time { return now }
+
+	// Closed: failures below the threshold are passed through
+	inner.down.Store(true)
+	for i := 0; i < 3; i++ {
+		if _, err := storage.Get("key"); !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Fatalf("expected backend error, got %v", err)
+		}
+	}
+	if storage.State() != BreakerOpen {
+		t.Fatalf("expected circuit to open after 3 failures, got %s", storage.State())
+	}
+
+	// Open: calls fail fast without reaching the backend
+	calls := inner.calls.Load()
+	if _, err := storage.Get("key"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if inner.calls.Load() != calls {
+		t.Error("expected open circuit to not call the backend")
+	}
+
+	// Half-open: a failed probe reopens the circuit for another cooldown
+	now = now.Add(time.Minute)
+	if _, err := storage.Get("key"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected probe to reach the backend, got %v", err)
+	}
+	if storage.State() != BreakerOpen {
+		t.Fatalf("expected failed probe to reopen the circuit, got %s", storage.State())
+	}
+	if err := storage.Set("key", []byte("value"), 0); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen after failed probe, got %v", err)
+	}
+
+	// Half-open: a successful probe closes the circuit
+	inner.down.Store(false)
+	now = now.Add(time.Minute)
+	if err := storage.Set("key", []byte("value"), 0); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+	if storage.State() != BreakerClosed {
+		t.Fatalf("expected circuit to close, got %s", storage.State())
+	}
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Errorf("expected value, got %s", string(got))
+	}
+
+	want := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	got := rec.get()
+	if len(got) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transition %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCircuitBreakerStorageIgnoresLogicalErrors(t *testing.T) {
+	errLogical := errors.New("logical")
+	scripted := &scriptedStorage{Storage: NewMemoryStorage("test:", 0), failures: 100, err: errLogical}
+	defer func() { _ = scripted.Close() }()
+
+	storage := NewCircuitBreakerStorage(scripted, DefaultBreakerOptions().WithFailureThreshold(1)).(*CircuitBreakerStorage)
+
+	for i := 0; i < 3; i++ {
+		if err := storage.Delete("key"); !errors.Is(err, errLogical) {
+			t.Fatalf("expected logical error, got %v", err)
+		}
+	}
+	if storage.State() != BreakerClosed {
+		t.Errorf("expected logical errors to not trip the circuit, got %s", storage.State())
+	}
+}
+
+func TestCircuitBreakerStorageSuccessResetsFailures(t *testing.T) {
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	defer func() { _ = inner.Close() }()
+
+	storage := NewCircuitBreakerStorage(inner, DefaultBreakerOptions().WithFailureThreshold(2)).(*CircuitBreakerStorage)
+
+	for i := 0; i < 5; i++ {
+		inner.down.Store(true)
+		_, _ = storage.Get("key")
+		inner.down.Store(false)
+		_, _ = storage.Get("key")
+	}
+	if storage.State() != BreakerClosed {
+		t.Errorf("expected non-consecutive failures to keep the circuit closed, got %s", storage.State())
+	}
+}
+
+func TestCircuitBreakerStorageSingleProbe(t *testing.T) {
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	defer func() { _ = inner.Close() }()
+
+	storage := NewCircuitBreakerStorage(inner, DefaultBreakerOptions().WithFailureThreshold(1)).(*CircuitBreakerStorage)
+	now := time.Now()
+	storage.now = func() time.Time { return now }
+
+	inner.down.Store(true)
+	_, _ = storage.Get("key")
+	now = now.Add(time.Hour)
+
+	// Hold the probe in flight while another caller arrives
+	if err := storage.allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if err := storage.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected only one probe while half-open, got %v", err)
+	}
+	storage.record(nil)
+	if storage.State() != BreakerClosed {
+		t.Errorf("expected circuit to close after successful probe, got %s", storage.State())
+	}
+}
+
+func TestCircuitBreakerStorageConcurrent(t *testing.T) {
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	defer func() { _ = inner.Close() }()
+
+	var transitions atomic.Int64
+	opts := DefaultBreakerOptions().
+		WithFailureThreshold(5).
+		WithCooldown(time.Millisecond).
+		WithOnStateChange(func(from, to BreakerState) { transitions.Add(1) })
+	storage := NewCircuitBreakerStorage(inner, opts)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if g == 0 && i%50 == 0 {
+					inner.down.Store(!inner.down.Load())
+				}
+				_ = storage.Set("key", []byte("value"), 0)
+				_, _ = storage.Get("key")
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	inner.down.Store(false)
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, _ = storage.Get("key")
+	}
+	if state := storage.(*CircuitBreakerStorage).State(); state != BreakerClosed {
+		t.Errorf("expected circuit to recover, got %s", state)
+	}
+	if transitions.Load() == 0 {
+		t.Error("expected at least one transition")
+	}
+}
+
+func TestCircuitBreakerStoragePing(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	opts := DefaultBreakerOptions().
+		WithFailureThreshold(1).
+		WithCooldown(time.Minute).
+		WithIsFailure(func(err error) bool { return err != nil })
+	storage := NewCircuitBreakerStorage(inner, opts).(*CircuitBreakerStorage)
+
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Fatalf("expected ping to succeed, got %v", err)
+	}
+
+	_ = inner.Close()
+	if err := storage.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := storage.Ping(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen once the circuit tripped, got %v", err)
+	}
+}
+
+func TestBreakerStateString(t *testing.T) {
+	tests := map[BreakerState]string{
+		BreakerClosed:    "closed",
+		BreakerOpen:      "open",
+		BreakerHalfOpen:  "half-open",
+		BreakerState(99): "unknown",
+	}
+	for state, want := range tests {
+		if got := state.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
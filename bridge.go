@@ -0,0 +1,97 @@
+package session
+
+import (
+	"time"
+
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// bridgedKeys are the fiber session keys mapped to SessionData fields by
+// FromFiberSession and ApplyToFiberSession. Other keys go through Data.
+var bridgedKeys = map[string]bool{
+	KeyAuthenticated: true,
+	KeyUserID:        true,
+	KeyEmail:         true,
+	KeyPhone:         true,
+	KeyAMR:           true,
+	KeyScopes:        true,
+	KeyCreatedAt:     true,
+	KeyLastAccess:    true,
+	KeyIPAddress:     true,
+	KeyUserAgent:     true,
+}
+
+// FromFiberSession converts a fiber session to a SessionData, mapping the
+// keys set by the helpers of this package to the fields of the same name
+// and copying every other key, such as KeyAuthTime or KeyRoles, to Data.
+// ExpiresAt is left zero, as fiber sessions do not expose their expiration.
+func FromFiberSession(session *fibersession.Session) *SessionData {
+	data := &SessionData{
+		ID:             session.ID(),
+		UserID:         GetUserID(session),
+		Email:          GetEmail(session),
+		Phone:          GetPhone(session),
+		Authenticated:  IsAuthenticated(session),
+		Data:           make(map[string]interface{}),
+		CreatedAt:      GetCreatedAt(session),
+		LastAccessedAt: GetLastAccess(session),
+		AMR:            GetAMR(session),
+		Scopes:         GetScopes(session),
+		IPAddress:      GetIPAddress(session),
+		UserAgent:      GetUserAgent(session),
+	}
+	for _, key := range session.Keys() {
+		if !bridgedKeys[key] {
+			data.Data[key] = session.Get(key)
+		}
+	}
+	return data
+}
+
+// ApplyToFiberSession writes data into a fiber session and saves it, the
+// reverse of FromFiberSession: fields are written to their keys, deleting
+// the keys of empty fields, Data entries are written as keys, and keys not
+// in Data are deleted. A non-zero ExpiresAt in the future becomes the
+// session expiration. The session ID is not changed. Like Save, it releases
+// the session.
+//
+// Data values must be encodable by the fiber session, so values of custom
+// types must be registered with gob.Register.
+func ApplyToFiberSession(data *SessionData, session *fibersession.Session) error {
+	for _, key := range session.Keys() {
+		if _, ok := data.Data[key]; !ok && !bridgedKeys[key] {
+			session.Delete(key)
+		}
+	}
+	for key, value := range data.Data {
+		if !bridgedKeys[key] {
+			session.Set(key, value)
+		}
+	}
+
+	setOrDelete(session, KeyAuthenticated, data.Authenticated, data.Authenticated)
+	setOrDelete(session, KeyUserID, data.UserID, data.UserID != "")
+	setOrDelete(session, KeyEmail, data.Email, data.Email != "")
+	setOrDelete(session, KeyPhone, data.Phone, data.Phone != "")
+	setOrDelete(session, KeyAMR, data.AMR, len(data.AMR) > 0)
+	setOrDelete(session, KeyScopes, data.Scopes, len(data.Scopes) > 0)
+	setOrDelete(session, KeyCreatedAt, data.CreatedAt.Unix(), !data.CreatedAt.IsZero())
+	setOrDelete(session, KeyLastAccess, data.LastAccessedAt.Unix(), !data.LastAccessedAt.IsZero())
+	setOrDelete(session, KeyIPAddress, data.IPAddress, data.IPAddress != "")
+	setOrDelete(session, KeyUserAgent, data.UserAgent, data.UserAgent != "")
+
+	if ttl := time.Until(data.ExpiresAt); !data.ExpiresAt.IsZero() && ttl > 0 {
+		session.SetExpiry(ttl)
+	}
+	return saveFiberSession(session)
+}
+
+// setOrDelete sets key to value in session if set is true, and deletes it
+// otherwise.
+func setOrDelete[T any](session *fibersession.Session, key string, value T, set bool) {
+	if set {
+		SetKey(session, key, value)
+	} else {
+		session.Delete(key)
+	}
+}
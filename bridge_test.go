@@ -0,0 +1,152 @@
+package session
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestFiberSessionBridgeRoundTrip(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	now := time.Unix(time.Now().Unix(), 0)
+	want := &SessionData{
+		UserID:         "user-1",
+		Email:          "user@example.com",
+		Phone:          "+15550100",
+		Authenticated:  true,
+		Data:           map[string]interface{}{"theme": "dark", KeyRoles: []string{"admin"}, KeyAuthTime: now.Unix()},
+		CreatedAt:      now.Add(-time.Hour),
+		LastAccessedAt: now,
+		AMR:            []string{"pwd", "otp"},
+		Scopes:         []string{"read", "write"},
+		IPAddress:      "203.0.113.7",
+		UserAgent:      "test-agent/1.0",
+		ExpiresAt:      now.Add(2 * time.Hour),
+	}
+
+	var got, again *SessionData
+	app.Get("/apply", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("stale", "value")
+		want.ID = sess.ID()
+		return ApplyToFiberSession(want, sess)
+	})
+	app.Get("/convert", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		got = FromFiberSession(sess)
+		return ApplyToFiberSession(got, sess)
+	})
+	app.Get("/again", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		again = FromFiberSession(sess)
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/apply", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("failed to apply: %v %s", err, body)
+	}
+	cookies := resp.Cookies()
+	if ttl, _ := storage.GetTTL(want.ID); ttl <= time.Hour {
+		t.Errorf("expected ExpiresAt to set the expiration, got %v", ttl)
+	}
+	for _, path := range []string{"/convert", "/again"} {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		if resp, err := app.Test(req); err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("failed to request %s: %v", path, err)
+		}
+	}
+
+	expected := *want
+	expected.ExpiresAt = time.Time{}
+	if !reflect.DeepEqual(got, &expected) {
+		t.Errorf("round-trip lost data:\n got  %+v\n want %+v", got, &expected)
+	}
+	if !reflect.DeepEqual(again, got) {
+		t.Errorf("applying a converted session changed it:\n got  %+v\n want %+v", again, got)
+	}
+}
+
+func TestApplyToFiberSessionClearsEmptyFields(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if err := AuthenticateWithOptions(sess, AuthOptions{UserID: "user-1", AMR: []string{"pwd"}}); err != nil {
+			return err
+		}
+		return nil
+	})
+	app.Get("/clear", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return ApplyToFiberSession(&SessionData{ID: sess.ID()}, sess)
+	})
+	app.Get("/keys", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if keys := sess.Keys(); len(keys) != 0 {
+			return c.Status(fiber.StatusConflict).JSON(keys)
+		}
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+	for _, path := range []string{"/clear", "/keys"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to request %s: %v", path, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Errorf("expected every key to be deleted, got %s", body)
+		}
+	}
+}
@@ -7,16 +7,138 @@ import (
 	"time"
 )
 
+// Cookie name prefixes whose requirements browsers enforce, see
+// https://datatracker.ietf.org/doc/html/draft-ietf-httpbis-rfc6265bis.
+const (
+	// CookiePrefixSecure requires the cookie to be Secure.
+	CookiePrefixSecure = "__Secure-"
+
+	// CookiePrefixHost requires the cookie to be Secure, with Path "/" and
+	// no Domain, so it is only sent to the host that set it.
+	CookiePrefixHost = "__Host-"
+)
+
+// SameSite is a value of the SameSite cookie attribute.
+type SameSite string
+
+// SameSite values accepted by Config.
+const (
+	// SameSiteLax sends the cookie with same-site requests and top-level
+	// cross-site navigations.
+	SameSiteLax SameSite = "Lax"
+	// SameSiteStrict sends the cookie with same-site requests only.
+	SameSiteStrict SameSite = "Strict"
+	// SameSiteNone sends the cookie with every request. It requires Secure.
+	SameSiteNone SameSite = "None"
+	// SameSiteDisabled omits the SameSite attribute.
+	SameSiteDisabled SameSite = "Disabled"
+)
+
+// ParseSameSite returns the SameSite value named by s, matched
+// case-insensitively and ignoring surrounding spaces, such as SameSiteNone
+// for "none". It returns an error for any other value, including "".
+func ParseSameSite(s string) (SameSite, error) {
+	switch sameSite := SameSite(normalizeSameSite(s)); sameSite {
+	case SameSiteLax, SameSiteStrict, SameSiteNone, SameSiteDisabled:
+		return sameSite, nil
+	default:
+		return "", fmt.Errorf("invalid same-site value %q: expected Strict, Lax, None or Disabled", s)
+	}
+}
+
+// UnmarshalText parses text with ParseSameSite, leaving s empty for empty
+// text.
+func (s *SameSite) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*s = ""
+		return nil
+	}
+	sameSite, err := ParseSameSite(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sameSite
+	return nil
+}
+
+// CookieSecure returns whether a cookie with the given SameSite and Secure
+// settings must be sent Secure: browsers reject SameSite None cookies that
+// are not, so None forces Secure.
+func CookieSecure(sameSite SameSite, secure bool) bool {
+	return secure || SameSite(normalizeSameSite(string(sameSite))) == SameSiteNone
+}
+
 // Config represents session configuration options.
 type Config struct {
 	// Expiration is the session expiration duration.
 	// Default: 24 hours
 	Expiration time.Duration
 
-	// CookieName is the name of the session cookie.
+	// AnonymousExpiration, if set, is the expiration of sessions that are
+	// not authenticated, such as 2 hours for anonymous browsing while
+	// Expiration is 30 days. Manager.SaveSession switches a session to
+	// Expiration when it becomes authenticated. Fiber sessions use it when
+	// saved with Manager.SaveFiberSession.
+	// Default: 0 (Expiration for every session)
+	AnonymousExpiration time.Duration
+
+	// CookieName is the name of the session cookie. Names starting with
+	// CookiePrefixSecure or CookiePrefixHost must meet the requirements of
+	// the prefix; see WithHostPrefix.
 	// Default: "session_id"
 	CookieName string
 
+	// RememberCookieName is the name of the cookie holding the remember-me
+	// token issued by IssueRememberToken. The other cookie attributes are
+	// shared with the session cookie.
+	// Default: "remember_me"
+	RememberCookieName string
+
+	// CookieSigningKey, if set, makes the session cookie value the session
+	// ID followed by "." and its HMAC-SHA256 under this key, so forged
+	// cookies can be rejected without a storage lookup. See CreateCookie,
+	// ParseSignedCookieValue and NewSignedCookieMiddleware.
+	// Default: nil (unsigned cookies)
+	CookieSigningKey []byte
+
+	// CookiePreviousSigningKey is a retired signing key whose signatures are
+	// still accepted, for rotating CookieSigningKey without logging everyone
+	// out. New cookies are always signed with CookieSigningKey.
+	// Default: nil
+	CookiePreviousSigningKey []byte
+
+	// AllowUnsignedCookies accepts unsigned session cookies while
+	// CookieSigningKey is set, as a grace period when enabling signing:
+	// existing sessions keep working and get a signed cookie the next time
+	// they are saved. Disable it once the cookies issued before have expired.
+	// Default: false
+	AllowUnsignedCookies bool
+
+	// SessionOnlyCookie omits Max-Age and Expires from the session cookie,
+	// making it a browser-session cookie dropped when the browser closes.
+	// The session still expires server-side after Expiration.
+	// Default: false
+	SessionOnlyCookie bool
+
+	// Partitioned sets the Partitioned attribute (CHIPS) on the session
+	// cookie, so browsers blocking third-party cookies still keep it for an
+	// embedded site, in a jar keyed by the top-level site. It requires
+	// Secure and SameSite "None". fiber.Cookie cannot carry the attribute:
+	// write cookies with SetCookie, or register NewPartitionedCookieMiddleware
+	// for Fiber sessions.
+	// Default: false
+	Partitioned bool
+
+	// KeyLookup lists where requests carry the session ID, as "source:name"
+	// entries separated by commas and tried in order, the source being
+	// "cookie", "header" or "query": "header:X-Session-Token" serves mobile
+	// clients without cookies, and "header:X-Session-Token,cookie:session_id"
+	// reads either. A cookie entry must name CookieName. The session cookie
+	// is only written when a cookie entry is listed. Fiber sessions read the
+	// first entry, and the others through NewKeyLookupMiddleware.
+	// Default: "" ("cookie:" followed by CookieName)
+	KeyLookup string
+
 	// CookieDomain is the domain for the session cookie.
 	// If empty, the cookie will be set for the current domain only.
 	// Default: "" (empty)
@@ -30,31 +152,79 @@ type Config struct {
 	// Default: true
 	Secure bool
 
+	// TrustProxyHeader makes the X-Forwarded-Proto and Forwarded headers
+	// count as proof that the client used https when Secure is not set, for
+	// apps behind a TLS-terminating proxy; see ShouldSetSecure. Only enable
+	// it if a proxy always sets or strips these headers, as clients can
+	// spoof them otherwise.
+	// Default: false
+	TrustProxyHeader bool
+
 	// HTTPOnly indicates if the cookie should be inaccessible to JavaScript.
 	// Default: true
 	HTTPOnly bool
 
-	// SameSite controls the SameSite attribute of the cookie.
-	// Allowed values: "Strict", "Lax", "None", "Disabled"
-	// Default: "Lax"
-	SameSite string
+	// SameSite controls the SameSite attribute of the cookie. SameSiteNone
+	// makes the cookie Secure whatever Secure says, see CookieSecure.
+	// Default: SameSiteLax
+	SameSite SameSite
 
 	// KeyPrefix is the prefix for session keys in storage.
 	// Default: "session:"
 	KeyPrefix string
+
+	// SlidingExpiration extends a session's expiration by Expiration every
+	// time it is loaded through Manager.LoadSession.
+	// Default: false
+	SlidingExpiration bool
+
+	// TouchInterval rate-limits the writes made by Manager.TouchSession and
+	// sliding expiration: a session whose stored expiration is less than
+	// TouchInterval old, that is which expires in more than its expiration
+	// minus TouchInterval, only has LastAccessedAt updated in memory. An
+	// idle session therefore expires between its expiration minus
+	// TouchInterval and its expiration after the last request, never
+	// earlier, so TouchInterval must be lower than Expiration and
+	// AnonymousExpiration.
+	// Default: 0 (write on every touch)
+	TouchInterval time.Duration
+
+	// MaxSessionsPerUser limits the sessions of a user saved through
+	// Manager.SaveSession, tracked in an index stored next to the sessions.
+	// Beyond the limit, the oldest sessions of the user are deleted, or
+	// ErrTooManySessions is returned, as MaxSessionsPolicy says.
+	// Default: 0 (no limit)
+	MaxSessionsPerUser int
+
+	// MaxSessionsPolicy decides what happens when a user already has
+	// MaxSessionsPerUser sessions.
+	// Default: SessionLimitEvictOldest
+	MaxSessionsPolicy SessionLimitPolicy
+
+	// StorageTimeout bounds every storage operation made by Manager, so a
+	// slow storage backend cannot hold request handlers indefinitely. It is
+	// applied as a context deadline to storages implementing ContextStorage,
+	// such as RedisStorage. Manager stops waiting for operations without a
+	// context variant at the deadline, leaving them to finish in the
+	// background; the storage's own timeout (see RedisStorage.WithOpTimeout)
+	// still bounds them.
+	// It is unrelated to the timeout for dialing the backend.
+	// Default: 0 (no timeout)
+	StorageTimeout time.Duration
 }
 
 // DefaultConfig returns a Config with sensible default values.
 func DefaultConfig() Config {
 	return Config{
-		Expiration:   24 * time.Hour,
-		CookieName:   "session_id",
-		CookieDomain: "",
-		CookiePath:   "/",
-		Secure:       true,
-		HTTPOnly:     true,
-		SameSite:     "Lax",
-		KeyPrefix:    "session:",
+		Expiration:         24 * time.Hour,
+		CookieName:         "session_id",
+		RememberCookieName: "remember_me",
+		CookieDomain:       "",
+		CookiePath:         "/",
+		Secure:             true,
+		HTTPOnly:           true,
+		SameSite:           SameSiteLax,
+		KeyPrefix:          "session:",
 	}
 }
 
@@ -64,12 +234,78 @@ func (c Config) WithExpiration(exp time.Duration) Config {
 	return c
 }
 
+// WithAnonymousExpiration sets the expiration of sessions that are not
+// authenticated.
+func (c Config) WithAnonymousExpiration(exp time.Duration) Config {
+	c.AnonymousExpiration = exp
+	return c
+}
+
 // WithCookieName sets the session cookie name.
 func (c Config) WithCookieName(name string) Config {
 	c.CookieName = name
 	return c
 }
 
+// WithHostPrefix renames the session cookie with CookiePrefixHost, replacing
+// any prefix it already has, and sets the attributes the prefix requires:
+// Secure, Path "/" and no Domain.
+func (c Config) WithHostPrefix() Config {
+	name := strings.TrimPrefix(c.CookieName, CookiePrefixHost)
+	name = strings.TrimPrefix(name, CookiePrefixSecure)
+	c.CookieName = CookiePrefixHost + name
+	c.Secure = true
+	c.CookiePath = "/"
+	c.CookieDomain = ""
+	return c
+}
+
+// WithRememberCookieName sets the remember-me cookie name.
+func (c Config) WithRememberCookieName(name string) Config {
+	c.RememberCookieName = name
+	return c
+}
+
+// WithCookieSigningKey sets the key signing session cookie values.
+func (c Config) WithCookieSigningKey(key []byte) Config {
+	c.CookieSigningKey = key
+	return c
+}
+
+// WithCookiePreviousSigningKey sets the retired key whose cookie signatures
+// are still accepted.
+func (c Config) WithCookiePreviousSigningKey(key []byte) Config {
+	c.CookiePreviousSigningKey = key
+	return c
+}
+
+// WithAllowUnsignedCookies sets whether unsigned session cookies are
+// accepted while cookie signing is enabled.
+func (c Config) WithAllowUnsignedCookies(allow bool) Config {
+	c.AllowUnsignedCookies = allow
+	return c
+}
+
+// WithSessionOnlyCookie sets whether the session cookie is a
+// browser-session cookie.
+func (c Config) WithSessionOnlyCookie(sessionOnly bool) Config {
+	c.SessionOnlyCookie = sessionOnly
+	return c
+}
+
+// WithPartitioned sets whether the session cookie is partitioned.
+func (c Config) WithPartitioned(partitioned bool) Config {
+	c.Partitioned = partitioned
+	return c
+}
+
+// WithKeyLookup sets where requests carry the session ID, such as
+// "header:X-Session-Token".
+func (c Config) WithKeyLookup(lookup string) Config {
+	c.KeyLookup = lookup
+	return c
+}
+
 // WithCookieDomain sets the session cookie domain.
 func (c Config) WithCookieDomain(domain string) Config {
 	c.CookieDomain = domain
@@ -88,16 +324,24 @@ func (c Config) WithSecure(secure bool) Config {
 	return c
 }
 
+// WithTrustProxyHeader sets whether forwarded protocol headers decide if
+// the cookie is Secure.
+func (c Config) WithTrustProxyHeader(trust bool) Config {
+	c.TrustProxyHeader = trust
+	return c
+}
+
 // WithHTTPOnly sets whether the cookie should be inaccessible to JavaScript.
 func (c Config) WithHTTPOnly(httpOnly bool) Config {
 	c.HTTPOnly = httpOnly
 	return c
 }
 
-// WithSameSite sets the SameSite attribute of the cookie.
-// Allowed values: "Strict", "Lax", "None", "Disabled"
+// WithSameSite sets the SameSite attribute of the cookie, normalizing
+// sameSite as ParseSameSite does. Allowed values: "Strict", "Lax", "None",
+// "Disabled"; other values are kept as is and rejected by Validate.
 func (c Config) WithSameSite(sameSite string) Config {
-	c.SameSite = sameSite
+	c.SameSite = SameSite(normalizeSameSite(sameSite))
 	return c
 }
 
@@ -107,6 +351,37 @@ func (c Config) WithKeyPrefix(prefix string) Config {
 	return c
 }
 
+// WithSlidingExpiration sets whether loading a session extends its expiration.
+func (c Config) WithSlidingExpiration(sliding bool) Config {
+	c.SlidingExpiration = sliding
+	return c
+}
+
+// WithTouchInterval sets the minimum time between the storage writes
+// refreshing a session.
+func (c Config) WithTouchInterval(d time.Duration) Config {
+	c.TouchInterval = d
+	return c
+}
+
+// WithMaxSessionsPerUser sets the maximum number of sessions of a user.
+func (c Config) WithMaxSessionsPerUser(n int) Config {
+	c.MaxSessionsPerUser = n
+	return c
+}
+
+// WithMaxSessionsPolicy sets what happens when a user has too many sessions.
+func (c Config) WithMaxSessionsPolicy(policy SessionLimitPolicy) Config {
+	c.MaxSessionsPolicy = policy
+	return c
+}
+
+// WithStorageTimeout sets the timeout for each storage operation made by Manager.
+func (c Config) WithStorageTimeout(d time.Duration) Config {
+	c.StorageTimeout = d
+	return c
+}
+
 // Validate validates the configuration and returns an error if invalid.
 // Note: This method uses a value receiver, so it cannot modify the config.
 // Use DefaultConfig() with builder methods to ensure valid configuration.
@@ -124,22 +399,146 @@ func (c Config) Validate() error {
 	if c.CookiePath == "" {
 		return fmt.Errorf("cookie path cannot be empty")
 	}
+	if err := checkCookieName(c.CookieName); err != nil {
+		return err
+	}
+	if c.RememberCookieName != "" {
+		if err := checkCookieName(c.RememberCookieName); err != nil {
+			return err
+		}
+	}
+	if err := checkCookieDomain(c.CookieDomain); err != nil {
+		return err
+	}
+	if err := checkCookiePath(c.CookiePath); err != nil {
+		return err
+	}
 	if c.Expiration < 0 {
 		return fmt.Errorf("expiration must be >= 0")
 	}
+	if c.AnonymousExpiration < 0 {
+		return fmt.Errorf("anonymous expiration must be >= 0")
+	}
+	if c.StorageTimeout < 0 {
+		return fmt.Errorf("storage timeout must be >= 0")
+	}
+	if c.MaxSessionsPerUser < 0 {
+		return fmt.Errorf("max sessions per user must be >= 0")
+	}
+	if c.MaxSessionsPolicy != SessionLimitEvictOldest && c.MaxSessionsPolicy != SessionLimitReject {
+		return fmt.Errorf("invalid max sessions policy: %d", c.MaxSessionsPolicy)
+	}
+	if c.TouchInterval < 0 {
+		return fmt.Errorf("touch interval must be >= 0")
+	}
+	if c.TouchInterval > 0 && (c.TouchInterval >= c.Expiration ||
+		c.AnonymousExpiration > 0 && c.TouchInterval >= c.AnonymousExpiration) {
+		return fmt.Errorf("touch interval %v must be lower than the expiration", c.TouchInterval)
+	}
+	if err := c.checkCookiePrefix(c.CookieName); err != nil {
+		return err
+	}
+	if err := c.checkCookiePrefix(c.RememberCookieName); err != nil {
+		return err
+	}
+	if _, err := c.keyLookups(); err != nil {
+		return err
+	}
+	if len(c.CookiePreviousSigningKey) > 0 && len(c.CookieSigningKey) == 0 {
+		return fmt.Errorf("cookie previous signing key requires a signing key")
+	}
 
-	normalized := normalizeSameSite(c.SameSite)
-	switch normalized {
-	case "Strict", "Lax", "None", "Disabled", "":
-		// allow empty value to fall back to default behavior
-	default:
-		return fmt.Errorf("invalid same-site value: %s", c.SameSite)
+	// allow empty value to fall back to default behavior
+	normalized := SameSite(normalizeSameSite(string(c.SameSite)))
+	if c.SameSite != "" {
+		if _, err := ParseSameSite(string(c.SameSite)); err != nil {
+			return err
+		}
 	}
 
-	if normalized == "None" && !c.Secure {
+	if normalized == SameSiteNone && !c.Secure {
 		return fmt.Errorf("same-site None requires Secure=true")
 	}
+	if c.Partitioned && (normalized != SameSiteNone || !c.Secure) {
+		return fmt.Errorf("partitioned cookies require same-site None and Secure=true")
+	}
+
+	return nil
+}
+
+// checkCookiePrefix returns an error if a cookie named name with the
+// attributes of c does not meet the requirements of the prefix of name.
+func (c Config) checkCookiePrefix(name string) error {
+	switch {
+	case strings.HasPrefix(name, CookiePrefixHost):
+		if !c.Secure {
+			return fmt.Errorf("cookie %s requires Secure=true", name)
+		}
+		if c.CookiePath != "/" {
+			return fmt.Errorf("cookie %s requires path \"/\"", name)
+		}
+		if c.CookieDomain != "" {
+			return fmt.Errorf("cookie %s cannot have a domain", name)
+		}
+	case strings.HasPrefix(name, CookiePrefixSecure):
+		if !c.Secure {
+			return fmt.Errorf("cookie %s requires Secure=true", name)
+		}
+	}
+	return nil
+}
+
+// checkCookieName returns an error if name is not a token as required by
+// RFC 6265: browsers drop cookies whose name has control characters, spaces
+// or separators.
+func checkCookieName(name string) error {
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+			return fmt.Errorf("invalid cookie name %q: %q is not allowed", name, r)
+		}
+	}
+	return nil
+}
+
+// checkCookieDomain returns an error if domain is not a bare host name or
+// IP address, such as "https://example.com" or "example.com:8080", which
+// never match the request host. A leading dot is accepted, as browsers
+// ignore it.
+func checkCookieDomain(domain string) error {
+	if domain == "" {
+		return nil
+	}
+	host := strings.TrimPrefix(domain, ".")
+	if host == "" || len(host) > 253 {
+		return fmt.Errorf("invalid cookie domain %q", domain)
+	}
+	if strings.Contains(host, "://") {
+		return fmt.Errorf("invalid cookie domain %q: it must not have a scheme", domain)
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("invalid cookie domain %q: bad label %q", domain, label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("invalid cookie domain %q: %q is not allowed in a host name", domain, r)
+			}
+		}
+	}
+	return nil
+}
 
+// checkCookiePath returns an error if path does not start with "/" or has
+// characters that would end or corrupt the cookie attribute.
+func checkCookiePath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("invalid cookie path %q: it must start with \"/\"", path)
+	}
+	for _, r := range path {
+		if r < ' ' || r == 0x7f || r == ';' {
+			return fmt.Errorf("invalid cookie path %q: %q is not allowed", path, r)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,289 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration read from and written as text in
+// time.ParseDuration syntax, such as "24h" or "30m", so configuration files
+// can use human-readable durations. It implements encoding.TextUnmarshaler,
+// which YAML decoders use too. JSON numbers are accepted as nanoseconds.
+// Negative durations are rejected.
+type Duration time.Duration
+
+// UnmarshalText parses text in time.ParseDuration syntax.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return err
+	}
+	if parsed < 0 {
+		return fmt.Errorf("duration %s must be >= 0", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats d in time.Duration.String syntax.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalJSON accepts a string in time.ParseDuration syntax or a number
+// of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return d.UnmarshalText([]byte(text))
+	}
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("duration %s is neither a string nor an integer", data)
+	}
+	if n < 0 {
+		return fmt.Errorf("duration %d must be >= 0", n)
+	}
+	*d = Duration(n)
+	return nil
+}
+
+// flexBool is a bool read from a JSON boolean or a string in
+// strconv.ParseBool syntax, such as "true" or "1".
+type flexBool bool
+
+// UnmarshalJSON accepts a boolean or a string in strconv.ParseBool syntax.
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err == nil {
+		*b = flexBool(v)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("%s is not a boolean", data)
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(text))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", text)
+	}
+	*b = flexBool(v)
+	return nil
+}
+
+// JSONOptions controls how LoadConfigJSONWithOptions and
+// LoadStorageConfigJSONWithOptions decode configuration.
+type JSONOptions struct {
+	// DisallowUnknownFields rejects fields that do not match a
+	// configuration field, catching typos such as "expiraton".
+	DisallowUnknownFields bool
+}
+
+// configAlias is Config without its methods, so configJSON can embed it
+// without recursing into Config.UnmarshalJSON.
+type configAlias Config
+
+// configJSON is the JSON form of Config: its duration and bool fields
+// shadow those of the embedded Config to accept human-readable values.
+type configJSON struct {
+	*configAlias
+	Expiration           *Duration `json:",omitempty"`
+	AnonymousExpiration  *Duration `json:",omitempty"`
+	StorageTimeout       *Duration `json:",omitempty"`
+	TouchInterval        *Duration `json:",omitempty"`
+	AllowUnsignedCookies *flexBool `json:",omitempty"`
+	SessionOnlyCookie    *flexBool `json:",omitempty"`
+	Partitioned          *flexBool `json:",omitempty"`
+	Secure               *flexBool `json:",omitempty"`
+	TrustProxyHeader     *flexBool `json:",omitempty"`
+	HTTPOnly             *flexBool `json:",omitempty"`
+	SlidingExpiration    *flexBool `json:",omitempty"`
+}
+
+// UnmarshalJSON decodes a Config, accepting durations in
+// time.ParseDuration syntax such as "24h", and booleans as strings. Fields
+// missing from data are left as they are, so decoding into DefaultConfig
+// only overrides what data sets. Field names match case-insensitively.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	return c.decodeJSON(data, JSONOptions{})
+}
+
+// decodeJSON decodes data into c as described by UnmarshalJSON.
+func (c *Config) decodeJSON(data []byte, opts JSONOptions) error {
+	aux := configJSON{configAlias: (*configAlias)(c)}
+	if err := decodeJSON(data, &aux, opts); err != nil {
+		return err
+	}
+	setDuration(&c.Expiration, aux.Expiration)
+	setDuration(&c.AnonymousExpiration, aux.AnonymousExpiration)
+	setDuration(&c.StorageTimeout, aux.StorageTimeout)
+	setDuration(&c.TouchInterval, aux.TouchInterval)
+	setBool(&c.AllowUnsignedCookies, aux.AllowUnsignedCookies)
+	setBool(&c.SessionOnlyCookie, aux.SessionOnlyCookie)
+	setBool(&c.Partitioned, aux.Partitioned)
+	setBool(&c.Secure, aux.Secure)
+	setBool(&c.TrustProxyHeader, aux.TrustProxyHeader)
+	setBool(&c.HTTPOnly, aux.HTTPOnly)
+	setBool(&c.SlidingExpiration, aux.SlidingExpiration)
+	return nil
+}
+
+// MarshalJSON encodes c with durations in time.Duration.String syntax, in
+// the form read by UnmarshalJSON.
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(configJSON{
+		configAlias:          (*configAlias)(&c),
+		Expiration:           durationPtr(c.Expiration),
+		AnonymousExpiration:  durationPtr(c.AnonymousExpiration),
+		StorageTimeout:       durationPtr(c.StorageTimeout),
+		TouchInterval:        durationPtr(c.TouchInterval),
+		AllowUnsignedCookies: boolPtr(c.AllowUnsignedCookies),
+		SessionOnlyCookie:    boolPtr(c.SessionOnlyCookie),
+		Partitioned:          boolPtr(c.Partitioned),
+		Secure:               boolPtr(c.Secure),
+		TrustProxyHeader:     boolPtr(c.TrustProxyHeader),
+		HTTPOnly:             boolPtr(c.HTTPOnly),
+		SlidingExpiration:    boolPtr(c.SlidingExpiration),
+	})
+}
+
+// LoadConfigJSON reads a JSON Config from r on top of DefaultConfig, as
+// described by Config.UnmarshalJSON, and validates it.
+func LoadConfigJSON(r io.Reader) (Config, error) {
+	return LoadConfigJSONWithOptions(r, JSONOptions{})
+}
+
+// LoadConfigJSONWithOptions is LoadConfigJSON with options.
+func LoadConfigJSONWithOptions(r io.Reader, opts JSONOptions) (Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	cfg := DefaultConfig()
+	if err := cfg.decodeJSON(data, opts); err != nil {
+		return Config{}, fmt.Errorf("failed to decode config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// storageConfigAlias is StorageConfig without its methods.
+type storageConfigAlias StorageConfig
+
+// storageConfigJSON is the JSON form of StorageConfig, like configJSON.
+type storageConfigJSON struct {
+	*storageConfigAlias
+	Type              *string   `json:",omitempty"`
+	RedisDialTimeout  *Duration `json:",omitempty"`
+	RedisReadTimeout  *Duration `json:",omitempty"`
+	RedisWriteTimeout *Duration `json:",omitempty"`
+	MemoryGCInterval  *Duration `json:",omitempty"`
+}
+
+// UnmarshalJSON decodes a StorageConfig like Config.UnmarshalJSON. Type is
+// matched case-insensitively and must be a known StorageType. The fields
+// holding live objects, such as RedisClient, cannot be set from JSON.
+func (c *StorageConfig) UnmarshalJSON(data []byte) error {
+	return c.decodeJSON(data, JSONOptions{})
+}
+
+// decodeJSON decodes data into c as described by UnmarshalJSON.
+func (c *StorageConfig) decodeJSON(data []byte, opts JSONOptions) error {
+	aux := storageConfigJSON{storageConfigAlias: (*storageConfigAlias)(c)}
+	if err := decodeJSON(data, &aux, opts); err != nil {
+		return err
+	}
+	if aux.Type != nil {
+		switch t := StorageType(strings.ToLower(strings.TrimSpace(*aux.Type))); t {
+		case StorageTypeMemory, StorageTypeRedis, StorageTypeFile, StorageTypeSQLite, StorageTypeBolt:
+			c.Type = t
+		default:
+			return fmt.Errorf("unknown storage type %q", *aux.Type)
+		}
+	}
+	setDuration(&c.RedisDialTimeout, aux.RedisDialTimeout)
+	setDuration(&c.RedisReadTimeout, aux.RedisReadTimeout)
+	setDuration(&c.RedisWriteTimeout, aux.RedisWriteTimeout)
+	setDuration(&c.MemoryGCInterval, aux.MemoryGCInterval)
+	return nil
+}
+
+// MarshalJSON encodes c like Config.MarshalJSON.
+func (c StorageConfig) MarshalJSON() ([]byte, error) {
+	storageType := string(c.Type)
+	return json.Marshal(storageConfigJSON{
+		storageConfigAlias: (*storageConfigAlias)(&c),
+		Type:               &storageType,
+		RedisDialTimeout:   durationPtr(c.RedisDialTimeout),
+		RedisReadTimeout:   durationPtr(c.RedisReadTimeout),
+		RedisWriteTimeout:  durationPtr(c.RedisWriteTimeout),
+		MemoryGCInterval:   durationPtr(c.MemoryGCInterval),
+	})
+}
+
+// LoadStorageConfigJSON reads a JSON StorageConfig from r on top of
+// DefaultStorageConfig, as described by StorageConfig.UnmarshalJSON.
+func LoadStorageConfigJSON(r io.Reader) (StorageConfig, error) {
+	return LoadStorageConfigJSONWithOptions(r, JSONOptions{})
+}
+
+// LoadStorageConfigJSONWithOptions is LoadStorageConfigJSON with options.
+func LoadStorageConfigJSONWithOptions(r io.Reader, opts JSONOptions) (StorageConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return StorageConfig{}, fmt.Errorf("failed to read storage config: %w", err)
+	}
+	cfg := DefaultStorageConfig()
+	if err := cfg.decodeJSON(data, opts); err != nil {
+		return StorageConfig{}, fmt.Errorf("failed to decode storage config: %w", err)
+	}
+	return cfg, nil
+}
+
+// decodeJSON decodes the JSON object data into v, rejecting trailing data.
+func decodeJSON(data []byte, v interface{}, opts JSONOptions) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if opts.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after the JSON object")
+	}
+	return nil
+}
+
+// setDuration sets *dst to src, if set.
+func setDuration(dst *time.Duration, src *Duration) {
+	if src != nil {
+		*dst = time.Duration(*src)
+	}
+}
+
+// setBool sets *dst to src, if set.
+func setBool(dst *bool, src *flexBool) {
+	if src != nil {
+		*dst = bool(*src)
+	}
+}
+
+// durationPtr returns a pointer to d as a Duration.
+func durationPtr(d time.Duration) *Duration {
+	v := Duration(d)
+	return &v
+}
+
+// boolPtr returns a pointer to b as a flexBool.
+func boolPtr(b bool) *flexBool {
+	v := flexBool(b)
+	return &v
+}
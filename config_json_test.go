@@ -0,0 +1,127 @@
+package session
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigJSON(t *testing.T) {
+	cfg, err := LoadConfigJSON(strings.NewReader(`{
+		"expiration": "24h",
+		"anonymousExpiration": "2h30m",
+		"storageTimeout": 500000000,
+		"cookieName": "sid",
+		"secure": "false",
+		"sameSite": "Strict",
+		"slidingExpiration": true
+	}`))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Expiration != 24*time.Hour || cfg.AnonymousExpiration != 150*time.Minute || cfg.StorageTimeout != 500*time.Millisecond {
+		t.Errorf("unexpected durations %v, %v, %v", cfg.Expiration, cfg.AnonymousExpiration, cfg.StorageTimeout)
+	}
+	if cfg.CookieName != "sid" || cfg.Secure || cfg.SameSite != "Strict" || !cfg.SlidingExpiration {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.CookiePath != "/" || !cfg.HTTPOnly || cfg.KeyPrefix != "session:" {
+		t.Errorf("expected the defaults to be kept, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigJSONInvalid(t *testing.T) {
+	tests := map[string]string{
+		"duration in words":  `{"expiration": "24 hours"}`,
+		"negative duration":  `{"expiration": "-1h"}`,
+		"negative number":    `{"storageTimeout": -5}`,
+		"bad boolean":        `{"secure": "maybe"}`,
+		"invalid config":     `{"cookieName": ""}`,
+		"unknown field":      `{"expiraton": "1h"}`,
+		"trailing data":      `{"expiration": "1h"} {}`,
+		"not an object":      `["expiration"]`,
+		"same-site none":     `{"sameSite": "None", "secure": false}`,
+		"wrong type":         `{"cookieName": 1}`,
+		"fractional seconds": `{"expiration": 1.5}`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadConfigJSONWithOptions(strings.NewReader(input), JSONOptions{DisallowUnknownFields: true}); err == nil {
+				t.Errorf("expected an error for %s", input)
+			}
+		})
+	}
+
+	if _, err := LoadConfigJSON(strings.NewReader(`{"expiraton": "1h"}`)); err != nil {
+		t.Errorf("expected unknown fields to be ignored by default, got %v", err)
+	}
+}
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	cfg := DefaultConfig().WithExpiration(90 * time.Minute).WithStorageTimeout(time.Second).
+		WithCookieSigningKey([]byte("key")).WithSessionOnlyCookie(true).WithSecure(false)
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	if !strings.Contains(string(data), `"Expiration":"1h30m0s"`) {
+		t.Errorf("expected a human-readable duration, got %s", data)
+	}
+
+	var got Config
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if got.Expiration != cfg.Expiration || got.StorageTimeout != cfg.StorageTimeout || string(got.CookieSigningKey) != "key" ||
+		!got.SessionOnlyCookie || got.Secure || got.CookieName != cfg.CookieName || got.SameSite != cfg.SameSite {
+		t.Errorf("expected %+v, got %+v", cfg, got)
+	}
+}
+
+func TestLoadStorageConfigJSON(t *testing.T) {
+	cfg, err := LoadStorageConfigJSON(strings.NewReader(`{
+		"type": "Redis",
+		"redisAddr": "redis:6379",
+		"redisDialTimeout": "5s",
+		"memoryGCInterval": "1m"
+	}`))
+	if err != nil {
+		t.Fatalf("failed to load storage config: %v", err)
+	}
+	if cfg.Type != StorageTypeRedis || cfg.RedisAddr != "redis:6379" || cfg.RedisDialTimeout != 5*time.Second ||
+		cfg.MemoryGCInterval != time.Minute || cfg.KeyPrefix != "session:" {
+		t.Errorf("unexpected storage config %+v", cfg)
+	}
+
+	for _, input := range []string{`{"type": "mongo"}`, `{"redisReadTimeout": "-1s"}`, `{"redisClient": {}}`} {
+		if _, err := LoadStorageConfigJSONWithOptions(strings.NewReader(input), JSONOptions{DisallowUnknownFields: true}); err == nil {
+			t.Errorf("expected an error for %s", input)
+		}
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal storage config: %v", err)
+	}
+	var got StorageConfig
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal storage config: %v", err)
+	}
+	if got.Type != cfg.Type || got.RedisDialTimeout != cfg.RedisDialTimeout || got.MemoryGCInterval != cfg.MemoryGCInterval {
+		t.Errorf("expected %+v, got %+v", cfg, got)
+	}
+}
+
+func TestDurationUnmarshalText(t *testing.T) {
+	var d Duration
+	if err := d.UnmarshalText([]byte(" 24h ")); err != nil || time.Duration(d) != 24*time.Hour {
+		t.Errorf("expected 24h, got %v (%v)", time.Duration(d), err)
+	}
+	if err := d.UnmarshalText([]byte("24 hours")); err == nil {
+		t.Error("expected an error for a duration in words")
+	}
+	if text, _ := Duration(time.Minute).MarshalText(); string(text) != "1m0s" {
+		t.Errorf("expected 1m0s, got %s", text)
+	}
+}
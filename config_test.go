@@ -1,6 +1,7 @@
 package session
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,6 +15,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.CookieName != "session_id" {
 		t.Errorf("expected CookieName to be 'session_id', got %s", cfg.CookieName)
 	}
+	if cfg.RememberCookieName != "remember_me" {
+		t.Errorf("expected RememberCookieName to be 'remember_me', got %s", cfg.RememberCookieName)
+	}
 	if cfg.CookieDomain != "" {
 		t.Errorf("expected CookieDomain to be empty, got %s", cfg.CookieDomain)
 	}
@@ -38,12 +42,15 @@ func TestConfigWithMethods(t *testing.T) {
 	cfg := DefaultConfig().
 		WithExpiration(1 * time.Hour).
 		WithCookieName("my_session").
+		WithRememberCookieName("my_remember").
 		WithCookieDomain(".example.com").
 		WithCookiePath("/app").
 		WithSecure(false).
 		WithHTTPOnly(false).
 		WithSameSite("Strict").
-		WithKeyPrefix("myapp:session:")
+		WithKeyPrefix("myapp:session:").
+		WithSlidingExpiration(true).
+		WithStorageTimeout(time.Second)
 
 	if cfg.Expiration != 1*time.Hour {
 		t.Errorf("expected Expiration to be 1h, got %v", cfg.Expiration)
@@ -51,6 +58,9 @@ func TestConfigWithMethods(t *testing.T) {
 	if cfg.CookieName != "my_session" {
 		t.Errorf("expected CookieName to be 'my_session', got %s", cfg.CookieName)
 	}
+	if cfg.RememberCookieName != "my_remember" {
+		t.Errorf("expected RememberCookieName to be 'my_remember', got %s", cfg.RememberCookieName)
+	}
 	if cfg.CookieDomain != ".example.com" {
 		t.Errorf("expected CookieDomain to be '.example.com', got %s", cfg.CookieDomain)
 	}
@@ -69,6 +79,12 @@ func TestConfigWithMethods(t *testing.T) {
 	if cfg.KeyPrefix != "myapp:session:" {
 		t.Errorf("expected KeyPrefix to be 'myapp:session:', got %s", cfg.KeyPrefix)
 	}
+	if !cfg.SlidingExpiration {
+		t.Error("expected SlidingExpiration to be true")
+	}
+	if cfg.StorageTimeout != time.Second {
+		t.Errorf("expected StorageTimeout to be 1s, got %v", cfg.StorageTimeout)
+	}
 }
 
 func TestConfigValidate(t *testing.T) {
@@ -96,6 +112,12 @@ func TestConfigValidate(t *testing.T) {
 		t.Error("expected error for negative expiration, got nil")
 	}
 
+	// Negative storage timeout
+	invalidTimeout := DefaultConfig().WithStorageTimeout(-time.Second)
+	if err := invalidTimeout.Validate(); err == nil {
+		t.Error("expected error for negative storage timeout, got nil")
+	}
+
 	// Invalid same-site value (normalizeSameSite default branch)
 	invalidSameSite := DefaultConfig().WithSameSite("Invalid")
 	if err := invalidSameSite.Validate(); err == nil {
@@ -114,3 +136,125 @@ func TestConfigValidate(t *testing.T) {
 		t.Errorf("expected no error for SameSite None with Secure, got %v", err)
 	}
 }
+
+func TestConfigCookiePrefix(t *testing.T) {
+	host := DefaultConfig().WithCookieDomain(".example.com").WithCookiePath("/app").WithSecure(false).WithHostPrefix()
+	if host.CookieName != "__Host-session_id" || !host.Secure || host.CookiePath != "/" || host.CookieDomain != "" {
+		t.Errorf("unexpected config %+v", host)
+	}
+	if err := host.Validate(); err != nil {
+		t.Errorf("expected WithHostPrefix to give a valid config, got %v", err)
+	}
+	if again := host.WithCookieName("__Secure-sid").WithHostPrefix(); again.CookieName != "__Host-sid" {
+		t.Errorf("expected the prefix to be replaced, got %q", again.CookieName)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"host without secure", host.WithSecure(false)},
+		{"host with path", host.WithCookiePath("/app")},
+		{"host with domain", host.WithCookieDomain("example.com")},
+		{"secure without secure", DefaultConfig().WithCookieName("__Secure-sid").WithSecure(false)},
+		{"remember cookie", DefaultConfig().WithSecure(false).WithRememberCookieName("__Secure-rid")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+
+	secure := DefaultConfig().WithCookieName("__Secure-sid").WithCookieDomain("example.com").WithCookiePath("/app")
+	if err := secure.Validate(); err != nil {
+		t.Errorf("expected __Secure- to allow a domain and path, got %v", err)
+	}
+}
+
+func TestConfigValidateCookieAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"space in name", DefaultConfig().WithCookieName("session id"), `"session id"`},
+		{"semicolon in name", DefaultConfig().WithCookieName("sid;"), `"sid;"`},
+		{"equals in name", DefaultConfig().WithCookieName("sid=1"), `"sid=1"`},
+		{"control character in name", DefaultConfig().WithCookieName("sid\x01"), `"sid\x01"`},
+		{"non-ASCII name", DefaultConfig().WithCookieName("séance"), `"séance"`},
+		{"separator in remember name", DefaultConfig().WithRememberCookieName("remember/me"), `"remember/me"`},
+		{"domain with scheme", DefaultConfig().WithCookieDomain("https://example.com"), `"https://example.com"`},
+		{"domain with port", DefaultConfig().WithCookieDomain("example.com:8080"), `"example.com:8080"`},
+		{"domain with path", DefaultConfig().WithCookieDomain("example.com/app"), `"example.com/app"`},
+		{"domain with empty label", DefaultConfig().WithCookieDomain("example..com"), `"example..com"`},
+		{"domain with space", DefaultConfig().WithCookieDomain("example .com"), `"example .com"`},
+		{"only a dot", DefaultConfig().WithCookieDomain("."), `"."`},
+		{"label starting with a hyphen", DefaultConfig().WithCookieDomain("-example.com"), `"-example.com"`},
+		{"relative path", DefaultConfig().WithCookiePath("app"), `"app"`},
+		{"semicolon in path", DefaultConfig().WithCookiePath("/app;x"), `"/app;x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected the error to name %s, got %v", tt.want, err)
+			}
+		})
+	}
+
+	valid := []Config{
+		DefaultConfig().WithCookieName("__Host-sid"),
+		DefaultConfig().WithCookieName("my_session.v2-id!"),
+		DefaultConfig().WithCookieDomain("example.com"),
+		DefaultConfig().WithCookieDomain(".example.com"),
+		DefaultConfig().WithCookieDomain("127.0.0.1"),
+		DefaultConfig().WithCookiePath("/app/v1"),
+	}
+	for _, config := range valid {
+		if err := config.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", config, err)
+		}
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := map[string]SameSite{
+		"Lax":        SameSiteLax,
+		"lax":        SameSiteLax,
+		"STRICT":     SameSiteStrict,
+		" none ":     SameSiteNone,
+		"Disabled":   SameSiteDisabled,
+		"dIsAbLeD\t": SameSiteDisabled,
+	}
+	for input, want := range tests {
+		if got, err := ParseSameSite(input); err != nil || got != want {
+			t.Errorf("ParseSameSite(%q) = %q, %v; expected %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "Laxx", "no", "strict;"} {
+		if _, err := ParseSameSite(input); err == nil {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+
+	if cfg := DefaultConfig().WithSameSite("strict"); cfg.SameSite != SameSiteStrict {
+		t.Errorf("expected WithSameSite to normalize, got %q", cfg.SameSite)
+	}
+	if err := DefaultConfig().WithSameSite("Laxx").Validate(); err == nil || !strings.Contains(err.Error(), `"Laxx"`) {
+		t.Errorf("expected an error naming the invalid value, got %v", err)
+	}
+}
+
+func TestCookieSecure(t *testing.T) {
+	if !CookieSecure(SameSiteNone, false) || !CookieSecure("none", false) {
+		t.Error("expected SameSite None to force Secure")
+	}
+	if CookieSecure(SameSiteLax, false) || !CookieSecure(SameSiteStrict, true) {
+		t.Error("expected Secure to be kept for other SameSite values")
+	}
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ShouldSetSecure reports whether the session cookie set in response to r
+// must be Secure: if cfg says so, if r came over TLS, or, only if
+// cfg.TrustProxyHeader is set, if the X-Forwarded-Proto or Forwarded header
+// of r says the client used https.
+func ShouldSetSecure(cfg Config, r *http.Request) bool {
+	if cfg.Secure || r.TLS != nil {
+		return true
+	}
+	return cfg.TrustProxyHeader && forwardedHTTPS(r.Header.Get)
+}
+
+// ShouldSetSecureFiber is ShouldSetSecure for Fiber. Fiber sessions write
+// their cookie with the CookieSecure of their store, which is fixed, so for
+// Fiber behind a TLS-terminating proxy, keep Secure set and rely on
+// ShouldSetSecureFiber for cookies written with SetCookie.
+func ShouldSetSecureFiber(cfg Config, c *fiber.Ctx) bool {
+	if cfg.Secure || c.Context().IsTLS() {
+		return true
+	}
+	return cfg.TrustProxyHeader && forwardedHTTPS(func(key string) string { return c.Get(key) })
+}
+
+// forwardedHTTPS reports whether the X-Forwarded-Proto or Forwarded header
+// read with get says the first proxy received the request over https.
+func forwardedHTTPS(get func(key string) string) bool {
+	if proto := get("X-Forwarded-Proto"); proto != "" {
+		first, _, _ := strings.Cut(proto, ",")
+		return strings.EqualFold(strings.TrimSpace(first), "https")
+	}
+	forwarded := get("Forwarded")
+	if forwarded == "" {
+		return false
+	}
+	first, _, _ := strings.Cut(forwarded, ",")
+	for _, pair := range strings.Split(first, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(key, "proto") {
+			return strings.EqualFold(strings.Trim(value, `"`), "https")
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShouldSetSecure(t *testing.T) {
+	insecure := DefaultConfig().WithSecure(false)
+	tests := []struct {
+		name    string
+		config  Config
+		tls     bool
+		headers map[string]string
+		want    bool
+	}{
+		{"secure config", DefaultConfig(), false, nil, true},
+		{"plain http", insecure, false, nil, false},
+		{"direct TLS", insecure, true, nil, true},
+		{"forwarded proto", insecure.WithTrustProxyHeader(true), false, map[string]string{"X-Forwarded-Proto": "https"}, true},
+		{"forwarded proto chain", insecure.WithTrustProxyHeader(true), false, map[string]string{"X-Forwarded-Proto": "HTTPS, http"}, true},
+		{"forwarded http", insecure.WithTrustProxyHeader(true), false, map[string]string{"X-Forwarded-Proto": "http"}, false},
+		{"forwarded header", insecure.WithTrustProxyHeader(true), false, map[string]string{"Forwarded": `for=192.0.2.60;proto="https";by=203.0.113.43`}, true},
+		{"forwarded header http", insecure.WithTrustProxyHeader(true), false, map[string]string{"Forwarded": "for=192.0.2.60;proto=http, proto=https"}, false},
+		{"spoofed proto without trust", insecure, false, map[string]string{"X-Forwarded-Proto": "https"}, false},
+		{"spoofed forwarded without trust", insecure, false, map[string]string{"Forwarded": "proto=https"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if got := ShouldSetSecure(tt.config, req); got != tt.want {
+				t.Errorf("ShouldSetSecure = %v, expected %v", got, tt.want)
+			}
+
+			if tt.tls {
+				return
+			}
+			app := fiber.New()
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendString(strconv.FormatBool(ShouldSetSecureFiber(tt.config, c)))
+			})
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if got := string(body); got != strconv.FormatBool(tt.want) {
+				t.Errorf("ShouldSetSecureFiber = %s, expected %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteHTTPCookieSecure(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithSecure(false).WithTrustProxyHeader(true))
+
+	for proto, want := range map[string]bool{"https": true, "": false} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		rec := httptest.NewRecorder()
+		manager.WriteHTTPCookie(rec, req, "s1")
+		if got := strings.Contains(rec.Header().Get("Set-Cookie"), "Secure"); got != want {
+			t.Errorf("%q: expected Secure to be %v in %q", proto, want, rec.Header().Get("Set-Cookie"))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// SignCookieValue returns the session cookie value for sessionID: the ID
+// followed by "." and its signature if config.CookieSigningKey is set, and
+// the ID as is otherwise.
+func SignCookieValue(config Config, sessionID string) string {
+	if len(config.CookieSigningKey) == 0 {
+		return sessionID
+	}
+	return sessionID + "." + cookieSignature(config.CookieSigningKey, sessionID)
+}
+
+// ParseSignedCookieValue returns the session ID of a session cookie value
+// written by SignCookieValue, comparing signatures in constant time. If
+// config.CookieSigningKey is not set, value is returned as is. It returns
+// ErrInvalidCookieSignature if value is not signed with the signing key or
+// config.CookiePreviousSigningKey, or is unsigned while
+// config.AllowUnsignedCookies is not set.
+func ParseSignedCookieValue(config Config, value string) (string, error) {
+	if len(config.CookieSigningKey) == 0 {
+		return value, nil
+	}
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		if config.AllowUnsignedCookies && value != "" {
+			return value, nil
+		}
+		return "", ErrInvalidCookieSignature
+	}
+
+	sessionID, signature := value[:i], value[i+1:]
+	for _, key := range [][]byte{config.CookieSigningKey, config.CookiePreviousSigningKey} {
+		if len(key) > 0 && sessionID != "" && hmac.Equal([]byte(signature), []byte(cookieSignature(key, sessionID))) {
+			return sessionID, nil
+		}
+	}
+	return "", ErrInvalidCookieSignature
+}
+
+// cookieSignature returns the HMAC-SHA256 of sessionID under key in
+// unpadded URL-safe base64.
+func cookieSignature(key []byte, sessionID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sessionID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SessionIDFromRequest returns the session ID carried by r where
+// Config.KeyLookup says, the session cookie by default, verifying and
+// stripping the signature of a cookie if cookie signing is enabled. Headers
+// and query parameters carry the bare ID. It returns "" if r carries no
+// session ID, the cookie signature is invalid or KeyLookup is invalid.
+func (m *Manager) SessionIDFromRequest(r *http.Request) string {
+	lookups, err := m.config.keyLookups()
+	if err != nil {
+		return ""
+	}
+	value, fromCookie := sessionIDFromRequest(r, lookups)
+	if !fromCookie {
+		return value
+	}
+	sessionID, err := ParseSignedCookieValue(m.config, value)
+	if err != nil {
+		return ""
+	}
+	return sessionID
+}
+
+// NewSignedCookieMiddleware returns a middleware making Fiber sessions use
+// signed cookies as configured by config.CookieSigningKey. Register it
+// before anything using the session: it replaces the session cookie of the
+// request with the session ID it carries, dropping it if its signature is
+// invalid so the request gets a new session, and signs the session cookie
+// set by the response. If no signing key is set it does nothing.
+func NewSignedCookieMiddleware(config Config) fiber.Handler {
+	name := config.CookieName
+	return func(c *fiber.Ctx) error {
+		if len(config.CookieSigningKey) == 0 {
+			return c.Next()
+		}
+
+		if value := c.Cookies(name); value != "" {
+			if sessionID, err := ParseSignedCookieValue(config, value); err == nil {
+				c.Request().Header.SetCookie(name, sessionID)
+			} else {
+				c.Request().Header.DelCookie(name)
+			}
+		}
+
+		err := c.Next()
+
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		cookie.SetKey(name)
+		if c.Response().Header.Cookie(cookie) && len(cookie.Value()) > 0 {
+			cookie.SetValue(SignCookieValue(config, string(cookie.Value())))
+			c.Response().Header.SetCookie(cookie)
+		}
+		return err
+	}
+}
@@ -0,0 +1,146 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestParseSignedCookieValue(t *testing.T) {
+	oldKey, newKey := []byte("old-signing-key"), []byte("new-signing-key")
+	config := DefaultConfig().WithCookieSigningKey(newKey)
+	signed := SignCookieValue(config, "sess_abc")
+	if !strings.HasPrefix(signed, "sess_abc.") || signed == "sess_abc." {
+		t.Fatalf("expected a signed value, got %q", signed)
+	}
+	if cookie := CreateCookie(config, "sess_abc"); cookie.Value != signed {
+		t.Errorf("expected CreateCookie to sign the value, got %q", cookie.Value)
+	}
+	if got := SignCookieValue(DefaultConfig(), "sess_abc"); got != "sess_abc" {
+		t.Errorf("expected unsigned values without a key, got %q", got)
+	}
+	signedWithOld := SignCookieValue(DefaultConfig().WithCookieSigningKey(oldKey), "sess_abc")
+
+	tests := []struct {
+		name    string
+		config  Config
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "signed", config: config, value: signed, want: "sess_abc"},
+		{name: "tampered id", config: config, value: "sess_abd" + signed[len("sess_abc"):], wantErr: true},
+		{name: "tampered signature", config: config, value: signed[:len(signed)-1] + "A", wantErr: true},
+		{name: "empty id", config: config, value: signed[len("sess_abc"):], wantErr: true},
+		{name: "unsigned", config: config, value: "sess_abc", wantErr: true},
+		{name: "unsigned in grace", config: config.WithAllowUnsignedCookies(true), value: "sess_abc", want: "sess_abc"},
+		{name: "forged in grace", config: config.WithAllowUnsignedCookies(true), value: "sess_abc.forged", wantErr: true},
+		{name: "retired key", config: config, value: signedWithOld, wantErr: true},
+		{name: "previous key", config: config.WithCookiePreviousSigningKey(oldKey), value: signedWithOld, want: "sess_abc"},
+		{name: "signing disabled", config: DefaultConfig(), value: "sess_abc", want: "sess_abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSignedCookieValue(tt.config, tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCookieSignature) {
+					t.Errorf("expected ErrInvalidCookieSignature, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+
+	if err := DefaultConfig().WithCookiePreviousSigningKey(oldKey).Validate(); err == nil {
+		t.Error("expected a previous key without a signing key to be rejected")
+	}
+}
+
+func TestSignedCookieMiddleware(t *testing.T) {
+	config := DefaultConfig().WithCookieSigningKey([]byte("signing-key"))
+	storage := NewMemoryStorage("test:", 0)
+	t.Cleanup(func() { _ = storage.Close() })
+	manager := NewManager(storage, config)
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Use(NewSignedCookieMiddleware(config))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		SetUserID(sess, "user-1")
+		return Authenticate(sess)
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(GetUserID(sess))
+	})
+
+	request := func(path string, cookie *http.Cookie) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, _ := request("/login", nil)
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == config.CookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+	sessionID, err := ParseSignedCookieValue(config, cookie.Value)
+	if err != nil {
+		t.Fatalf("expected a signed cookie, got %q: %v", cookie.Value, err)
+	}
+	if data, _ := storage.Get(sessionID); data == nil {
+		t.Fatal("expected the session to be stored under the bare ID")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if got := manager.SessionIDFromRequest(req); got != sessionID {
+		t.Errorf("expected SessionIDFromRequest to strip the signature, got %q", got)
+	}
+
+	if _, body := request("/me", cookie); body != "user-1" {
+		t.Errorf("expected the signed cookie to load the session, got %q", body)
+	}
+	forged := &http.Cookie{Name: cookie.Name, Value: sessionID + ".forged"}
+	if _, body := request("/me", forged); body != "" {
+		t.Errorf("expected a forged cookie to be treated as no session, got %q", body)
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(forged)
+	if got := manager.SessionIDFromRequest(req); got != "" {
+		t.Errorf("expected no session ID for a forged cookie, got %q", got)
+	}
+	if _, body := request("/me", &http.Cookie{Name: cookie.Name, Value: sessionID}); body != "" {
+		t.Errorf("expected an unsigned cookie to be treated as no session, got %q", body)
+	}
+}
@@ -0,0 +1,133 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/valyala/fasthttp"
+)
+
+// SetCookie sets cookie, such as one created by CreateCookie, on the
+// response of c, adding the Partitioned attribute if config.Partitioned is
+// set since fiber.Cookie cannot carry it.
+func SetCookie(c *fiber.Ctx, config Config, cookie *fiber.Cookie) {
+	c.Cookie(cookie)
+	if config.Partitioned {
+		partitionCookie(c, cookie.Name)
+	}
+}
+
+// partitionedCookieKey is the fiber.Ctx Locals key of the name of the
+// session cookie partitioned by NewPartitionedCookieMiddleware.
+type partitionedCookieKey struct{}
+
+// NewPartitionedCookieMiddleware returns a middleware adding the
+// Partitioned attribute to the session cookie set by the response, for
+// Fiber sessions, which write their cookie themselves. Cookies deleting the
+// session, as set by Helper.Unauthenticate, DestroyCached and
+// NewMaxLifetimeMiddleware, are partitioned as soon as they are set. If
+// config.Partitioned is not set it does nothing.
+func NewPartitionedCookieMiddleware(config Config) fiber.Handler {
+	name := config.CookieName
+	return func(c *fiber.Ctx) error {
+		if !config.Partitioned {
+			return c.Next()
+		}
+		c.Locals(partitionedCookieKey{}, name)
+		err := c.Next()
+		partitionCookie(c, name)
+		return err
+	}
+}
+
+// partitioned reports whether NewPartitionedCookieMiddleware partitions the
+// cookie named name on the response of c.
+func partitioned(c *fiber.Ctx, name string) bool {
+	partitionedName, ok := c.Locals(partitionedCookieKey{}).(string)
+	return ok && partitionedName == name
+}
+
+// partitionCookie adds the Partitioned attribute to the cookie named name
+// set by the response of c, if any.
+func partitionCookie(c *fiber.Ctx, name string) {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey(name)
+	if !c.Response().Header.Cookie(cookie) {
+		return
+	}
+	// SetPartitioned also forces Path "/", which CHIPS does not require
+	path := string(cookie.Path())
+	cookie.SetPartitioned(true)
+	if path != "" {
+		cookie.SetPath(path)
+	}
+	// max-age=0 reads back as no max-age; empty session cookies delete it
+	if len(cookie.Value()) == 0 && cookie.MaxAge() == 0 {
+		cookie.SetMaxAge(-1)
+	}
+	c.Response().Header.SetCookie(cookie)
+}
+
+// expireStoreCookie sets the cookie deleting the session cookie of store on
+// the response of c, replacing the one set by fiber when destroying the
+// session, if store looks sessions up in a cookie. The cookie is
+// partitioned if NewPartitionedCookieMiddleware partitions the session
+// cookie, since browsers keep partitioned cookies apart and only a
+// partitioned cookie deletes one.
+func expireStoreCookie(c *fiber.Ctx, store *fibersession.Store) {
+	source, name, _ := strings.Cut(store.KeyLookup, ":")
+	if fibersession.Source(source) != fibersession.SourceCookie {
+		return
+	}
+	config := Config{
+		CookieName:   name,
+		CookieDomain: store.CookieDomain,
+		CookiePath:   store.CookiePath,
+		Secure:       store.CookieSecure,
+		HTTPOnly:     store.CookieHTTPOnly,
+		SameSite:     SameSite(normalizeSameSite(store.CookieSameSite)),
+		Partitioned:  partitioned(c, name),
+	}
+	SetCookie(c, config, CreateExpiredCookie(config))
+}
+
+// CreateHTTPCookie is CreateCookie for net/http: it returns the session
+// cookie for sessionID, to be set with http.SetCookie, including the
+// Partitioned attribute if config.Partitioned is set.
+func CreateHTTPCookie(config Config, sessionID string) *http.Cookie {
+	return httpCookie(config, CreateCookie(config, sessionID))
+}
+
+// CreateExpiredHTTPCookie is CreateExpiredCookie for net/http.
+func CreateExpiredHTTPCookie(config Config) *http.Cookie {
+	return httpCookie(config, CreateExpiredCookie(config))
+}
+
+// httpCookie converts cookie, created from config, to a net/http cookie.
+func httpCookie(config Config, cookie *fiber.Cookie) *http.Cookie {
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cookie.SameSite) {
+	case fiber.CookieSameSiteStrictMode:
+		sameSite = http.SameSiteStrictMode
+	case fiber.CookieSameSiteNoneMode:
+		sameSite = http.SameSiteNoneMode
+	case fiber.CookieSameSiteDisabled:
+		sameSite = 0
+	}
+
+	return &http.Cookie{
+		Name:        cookie.Name,
+		Value:       cookie.Value,
+		Path:        cookie.Path,
+		Domain:      cookie.Domain,
+		Expires:     cookie.Expires,
+		MaxAge:      cookie.MaxAge,
+		Secure:      cookie.Secure,
+		HttpOnly:    cookie.HTTPOnly,
+		SameSite:    sameSite,
+		Partitioned: config.Partitioned,
+	}
+}
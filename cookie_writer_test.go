@@ -0,0 +1,167 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+	"github.com/valyala/fasthttp"
+)
+
+func partitionedConfig() Config {
+	return DefaultConfig().WithSameSite("None").WithPartitioned(true).WithCookiePath("/app")
+}
+
+func TestConfigValidatePartitioned(t *testing.T) {
+	if err := partitionedConfig().Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := partitionedConfig().WithSameSite("Lax").Validate(); err == nil {
+		t.Error("expected an error for a partitioned cookie without SameSite None")
+	}
+	if err := partitionedConfig().WithSecure(false).Validate(); err == nil {
+		t.Error("expected an error for an insecure partitioned cookie")
+	}
+}
+
+func TestSetCookiePartitioned(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		config := partitionedConfig().WithPartitioned(c.Query("partitioned") != "")
+		SetCookie(c, config, CreateCookie(config, "session-123"))
+		return nil
+	})
+
+	for _, partitioned := range []bool{true, false} {
+		url := "/"
+		if partitioned {
+			url += "?partitioned=1"
+		}
+		resp, err := app.Test(httptest.NewRequest("GET", url, nil))
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		header := resp.Header.Get("Set-Cookie")
+		if strings.Contains(header, "Partitioned") != partitioned {
+			t.Errorf("expected Partitioned to be %v in %q", partitioned, header)
+		}
+		if !strings.Contains(header, "session_id=session-123") || !strings.Contains(header, "path=/app") {
+			t.Errorf("expected the cookie to be kept, got %q", header)
+		}
+	}
+}
+
+func TestPartitionedCookieMiddleware(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	config := partitionedConfig()
+	manager := NewManager(storage, config)
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Use(NewPartitionedCookieMiddleware(config))
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("k", "v")
+		return sess.Save()
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	if header := resp.Header.Get("Set-Cookie"); !strings.Contains(header, "Partitioned") {
+		t.Errorf("expected a partitioned session cookie, got %q", header)
+	}
+}
+
+func TestPartitionedCookieLogout(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	config := partitionedConfig()
+	manager := NewManager(storage, config)
+	store := fibersession.New(manager.FiberSessionConfig())
+	helper := NewHelper(store)
+
+	app := fiber.New()
+	app.Use(NewPartitionedCookieMiddleware(config))
+	app.Get("/logout", func(c *fiber.Ctx) error {
+		if err := helper.Unauthenticate(c); err != nil {
+			return err
+		}
+		// Partitioned right away, not only once the middleware returns
+		cookie := fasthttp.AcquireCookie()
+		defer fasthttp.ReleaseCookie(cookie)
+		cookie.SetKey(config.CookieName)
+		if !c.Response().Header.Cookie(cookie) || !cookie.Partitioned() {
+			t.Error("expected the deleting cookie to be partitioned when set")
+		}
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/logout", nil))
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	header := resp.Header.Get("Set-Cookie")
+	if !strings.Contains(header, "Partitioned") || !strings.Contains(header, "max-age=0") {
+		t.Errorf("expected a partitioned deleting cookie, got %q", header)
+	}
+	if !strings.Contains(header, "path=/app") {
+		t.Errorf("expected the cookie path to be kept, got %q", header)
+	}
+}
+
+func TestCreateHTTPCookie(t *testing.T) {
+	config := partitionedConfig().WithExpiration(time.Hour)
+	cookie := CreateHTTPCookie(config, "session-123")
+	if cookie.Name != "session_id" || cookie.Value != "session-123" || cookie.MaxAge != 3600 ||
+		!cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteNoneMode || !cookie.Partitioned {
+		t.Errorf("unexpected cookie %+v", cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	http.SetCookie(rec, cookie)
+	if header := rec.Header().Get("Set-Cookie"); !strings.Contains(header, "Partitioned") {
+		t.Errorf("expected the Partitioned attribute in %q", header)
+	}
+
+	if cookie := CreateHTTPCookie(DefaultConfig().WithSameSite("Strict"), "s"); cookie.SameSite != http.SameSiteStrictMode || cookie.Partitioned {
+		t.Errorf("unexpected cookie %+v", cookie)
+	}
+}
+
+func TestCreateExpiredCookie(t *testing.T) {
+	configs := []Config{
+		DefaultConfig().WithCookieDomain(".example.com").WithCookiePath("/app").WithSameSite("Strict"),
+		DefaultConfig().WithCookieName(CookiePrefixHost + "sid").WithCookieDomain(".example.com"),
+		partitionedConfig(),
+	}
+	for _, config := range configs {
+		cookie, expired := CreateCookie(config, "session-123"), CreateExpiredCookie(config)
+		if expired.Name != cookie.Name || expired.Domain != cookie.Domain || expired.Path != cookie.Path ||
+			expired.Secure != cookie.Secure || expired.HTTPOnly != cookie.HTTPOnly || expired.SameSite != cookie.SameSite {
+			t.Errorf("expected the attributes of %+v, got %+v", cookie, expired)
+		}
+		if expired.Value != "" || expired.MaxAge != -1 || !expired.Expires.Before(time.Now()) {
+			t.Errorf("expected a deleting cookie, got %+v", expired)
+		}
+
+		httpCookie, httpExpired := CreateHTTPCookie(config, "session-123"), CreateExpiredHTTPCookie(config)
+		if httpExpired.Name != httpCookie.Name || httpExpired.Domain != httpCookie.Domain || httpExpired.Path != httpCookie.Path ||
+			httpExpired.Secure != httpCookie.Secure || httpExpired.HttpOnly != httpCookie.HttpOnly ||
+			httpExpired.SameSite != httpCookie.SameSite || httpExpired.Partitioned != httpCookie.Partitioned {
+			t.Errorf("expected the attributes of %+v, got %+v", httpCookie, httpExpired)
+		}
+		if httpExpired.Value != "" || httpExpired.MaxAge != -1 {
+			t.Errorf("expected a deleting cookie, got %+v", httpExpired)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// encryptedVersion is the first byte of values stored by EncryptedStorage.
+const encryptedVersion byte = 1
+
+// encryptedKeyIDSize is the size of the key ID following the version byte.
+// It is derived from the key, so keys can be reordered without breaking
+// existing values.
+const encryptedKeyIDSize = 4
+
+// encryptionKey is one AES-GCM key of EncryptedStorage.
+type encryptionKey struct {
+	id   [encryptedKeyIDSize]byte
+	aead cipher.AEAD
+}
+
+// encryptionKeys seals values with the first key and opens them with any,
+// in the format described on EncryptedStorage. It is shared by
+// EncryptedStorage and RedisStore.
+type encryptionKeys []encryptionKey
+
+// newEncryptionKeys returns the AES-GCM keys for keys, each 16, 24 or 32
+// bytes long. It panics if keys is empty or a key has an invalid length,
+// naming owner in the message.
+func newEncryptionKeys(owner string, keys [][]byte) encryptionKeys {
+	if len(keys) == 0 {
+		panic("session: " + owner + " requires at least one key")
+	}
+
+	out := make(encryptionKeys, 0, len(keys))
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			panic(fmt.Sprintf("session: invalid encryption key %d: %v", i, err))
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(fmt.Sprintf("session: invalid encryption key %d: %v", i, err))
+		}
+
+		k := encryptionKey{aead: aead}
+		sum := sha256.Sum256(key)
+		copy(k.id[:], sum[:])
+		out = append(out, k)
+	}
+	return out
+}
+
+// seal encrypts val with the first key, authenticating aad along with it.
+func (keys encryptionKeys) seal(aad string, val []byte) ([]byte, error) {
+	k := keys[0]
+	headerSize := 1 + encryptedKeyIDSize + k.aead.NonceSize()
+
+	out := make([]byte, headerSize, headerSize+len(val)+k.aead.Overhead())
+	out[0] = encryptedVersion
+	copy(out[1:], k.id[:])
+	nonce := out[1+encryptedKeyIDSize : headerSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.aead.Seal(out, nonce, val, []byte(aad)), nil
+}
+
+// open decrypts a value sealed for aad, returning ErrDecryptionFailed if no
+// key can.
+func (keys encryptionKeys) open(aad string, data []byte) ([]byte, error) {
+	if len(data) < 1+encryptedKeyIDSize || data[0] != encryptedVersion {
+		return nil, ErrDecryptionFailed
+	}
+	id := data[1 : 1+encryptedKeyIDSize]
+
+	for _, k := range keys {
+		if string(k.id[:]) != string(id) {
+			continue
+		}
+		headerSize := 1 + encryptedKeyIDSize + k.aead.NonceSize()
+		if len(data) < headerSize {
+			return nil, ErrDecryptionFailed
+		}
+		val, err := k.aead.Open(nil, data[1+encryptedKeyIDSize:headerSize], data[headerSize:], []byte(aad))
+		if err != nil {
+			return nil, ErrDecryptionFailed
+		}
+		return val, nil
+	}
+	return nil, ErrDecryptionFailed
+}
+
+// EncryptedStorage wraps a Storage and encrypts values with AES-GCM, so
+// sessions are protected at rest even when the storage is used directly,
+// e.g. by the Fiber session middleware through Manager.FiberSessionConfig.
+//
+// Stored values consist of a version byte, a key ID, a random nonce, and the
+// ciphertext. The storage key is authenticated along with the value, so a
+// value copied under another key fails to decrypt. New values are encrypted
+// with the first key; the others are only used to decrypt, so keys can be
+// rotated by prepending a new one and removing the old one once every value
+// encrypted with it has expired. Values that fail to decrypt make Get
+// return ErrDecryptionFailed.
+//
+// It composes with other decorators in any order and, like
+// InstrumentedStorage, only forwards Ping; use Unwrap to reach the inner
+// storage.
+type EncryptedStorage struct {
+	inner Storage
+	keys  encryptionKeys
+}
+
+// NewEncryptedStorage wraps inner so values are encrypted with keys, each
+// 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256. The first
+// key encrypts new values. It panics if keys is empty or a key has an
+// invalid length, as keys are expected to come from configuration checked
+// at startup.
+func NewEncryptedStorage(inner Storage, keys [][]byte) Storage {
+	return &EncryptedStorage{inner: inner, keys: newEncryptionKeys("encrypted storage", keys)}
+}
+
+// Unwrap returns the inner storage.
+func (s *EncryptedStorage) Unwrap() Storage {
+	return s.inner
+}
+
+// encrypt seals val for key with the first key.
+func (s *EncryptedStorage) encrypt(key string, val []byte) ([]byte, error) {
+	return s.keys.seal(key, val)
+}
+
+// decrypt opens a value stored for key by encrypt.
+func (s *EncryptedStorage) decrypt(key string, data []byte) ([]byte, error) {
+	return s.keys.open(key, data)
+}
+
+// Get retrieves and decrypts the value for the given key.
+// Returns nil, nil if the key does not exist.
+func (s *EncryptedStorage) Get(key string) ([]byte, error) {
+	data, err := s.inner.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return s.decrypt(key, data)
+}
+
+// Set encrypts and stores the given value for the given key.
+// Empty key or value will be ignored without an error.
+func (s *EncryptedStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	data, err := s.encrypt(key, val)
+	if err != nil {
+		return err
+	}
+	return s.inner.Set(key, data, exp)
+}
+
+// Delete removes the value for the given key.
+func (s *EncryptedStorage) Delete(key string) error {
+	return s.inner.Delete(key)
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *EncryptedStorage) Reset() error {
+	return s.inner.Reset()
+}
+
+// Ping checks the inner storage. Storages that do not implement Pinger are
+// assumed to be healthy.
+func (s *EncryptedStorage) Ping(ctx context.Context) error {
+	return pingStorage(ctx, s.inner)
+}
+
+// Close closes the inner storage.
+func (s *EncryptedStorage) Close() error {
+	return s.inner.Close()
+}
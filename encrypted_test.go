@@ -0,0 +1,192 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+var (
+	testEncryptionKey1 = bytes.Repeat([]byte{1}, 32)
+	testEncryptionKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	storage := NewEncryptedStorage(inner, [][]byte{testEncryptionKey1})
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.Set("key", []byte("secret value"), time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	raw, _ := inner.Get("key")
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Error("expected the stored value to be encrypted")
+	}
+	if got, err := storage.Get("key"); string(got) != "secret value" || err != nil {
+		t.Errorf("expected round trip, got %q (%v)", got, err)
+	}
+	if got, err := storage.Get("missing"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing key, got %q (%v)", got, err)
+	}
+
+	// Every value gets its own nonce
+	_ = storage.Set("other", []byte("secret value"), time.Hour)
+	rawOther, _ := inner.Get("other")
+	if bytes.Equal(raw, rawOther) {
+		t.Error("expected different ciphertexts for equal values")
+	}
+}
+
+func TestEncryptedStorageTamperDetection(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	storage := NewEncryptedStorage(inner, [][]byte{testEncryptionKey1})
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	raw, _ := inner.Get("key")
+
+	flipped := append([]byte(nil), raw...)
+	flipped[len(flipped)-1] ^= 1
+	_ = inner.Set("key", flipped, time.Hour)
+	if _, err := storage.Get("key"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for a modified value, got %v", err)
+	}
+
+	// A value moved to another key does not authenticate
+	_ = inner.Set("moved", raw, time.Hour)
+	if _, err := storage.Get("moved"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for a moved value, got %v", err)
+	}
+
+	for _, bad := range [][]byte{[]byte("plaintext"), {encryptedVersion}, raw[:10]} {
+		_ = inner.Set("key", bad, time.Hour)
+		if _, err := storage.Get("key"); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("expected ErrDecryptionFailed for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestEncryptedStorageKeyRotation(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	old := NewEncryptedStorage(inner, [][]byte{testEncryptionKey1})
+	_ = old.Set("key", []byte("value"), time.Hour)
+
+	rotated := NewEncryptedStorage(inner, [][]byte{testEncryptionKey2, testEncryptionKey1})
+	if got, err := rotated.Get("key"); string(got) != "value" || err != nil {
+		t.Errorf("expected the old key to decrypt, got %q (%v)", got, err)
+	}
+	_ = rotated.Set("new", []byte("value"), time.Hour)
+
+	if _, err := old.Get("new"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected new values to use the new key, got %v", err)
+	}
+	retired := NewEncryptedStorage(inner, [][]byte{testEncryptionKey2})
+	if _, err := retired.Get("key"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected values of a retired key to fail, got %v", err)
+	}
+}
+
+func TestEncryptedStorageComposes(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	rec := &opRecorder{}
+	redisStorage := NewRedisStorage(client, "test:").WithCompression(1)
+	large := bytes.Repeat([]byte("session data "), 100)
+
+	for _, storage := range []Storage{
+		NewInstrumentedStorage(NewEncryptedStorage(redisStorage, [][]byte{testEncryptionKey1}), rec.observe),
+		NewEncryptedStorage(NewInstrumentedStorage(redisStorage, rec.observe), [][]byte{testEncryptionKey1}),
+	} {
+		if err := storage.Set("key", large, time.Hour); err != nil {
+			t.Fatalf("failed to set: %v", err)
+		}
+		if got, err := storage.Get("key"); !bytes.Equal(got, large) || err != nil {
+			t.Errorf("expected round trip, got %d bytes (%v)", len(got), err)
+		}
+	}
+	if len(rec.ops) != 4 {
+		t.Errorf("expected the instrumented layer to see every op, got %v", rec.ops)
+	}
+}
+
+func TestEncryptedStorageFiberSession(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	storage := NewEncryptedStorage(inner, [][]byte{testEncryptionKey1})
+	defer func() { _ = storage.Close() }()
+
+	manager := NewManager(storage, DefaultConfig().WithSecure(false))
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Get("/set", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("user", "alice")
+		return sess.Save()
+	})
+	app.Get("/get", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		user, _ := sess.Get("user").(string)
+		return c.SendString(user)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/set", nil))
+	if err != nil {
+		t.Fatalf("failed to set session: %v", err)
+	}
+	var sessionID string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			sessionID = cookie.Value
+		}
+	}
+	if sessionID == "" {
+		t.Fatal("expected a session cookie")
+	}
+
+	raw, _ := inner.Get(sessionID)
+	if raw == nil || bytes.Contains(raw, []byte("alice")) {
+		t.Errorf("expected an encrypted session to be stored, got %q", raw)
+	}
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	body := new(bytes.Buffer)
+	_, _ = body.ReadFrom(resp.Body)
+	if body.String() != "alice" {
+		t.Errorf("expected the session to round-trip, got %q", body.String())
+	}
+}
+
+func TestNewEncryptedStorageInvalidKeys(t *testing.T) {
+	for _, keys := range [][][]byte{nil, {[]byte("short")}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for keys %q", keys)
+				}
+			}()
+			NewEncryptedStorage(NewMemoryStorage("test:", 0), keys)
+		}()
+	}
+}
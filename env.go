@@ -0,0 +1,236 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envLoader reads prefixed environment variables into configuration fields,
+// keeping the first error. Unset or empty variables leave the field as is.
+type envLoader struct {
+	prefix string
+	err    error
+}
+
+// newEnvLoader returns a loader for variables named prefix + "_" + key.
+// An empty prefix reads the keys as is.
+func newEnvLoader(prefix string) *envLoader {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return &envLoader{prefix: prefix}
+}
+
+// lookup returns the value of the variable for key, if set and not empty.
+func (l *envLoader) lookup(key string) (string, string, bool) {
+	name := l.prefix + key
+	if l.err != nil {
+		return name, "", false
+	}
+	value, ok := os.LookupEnv(name)
+	return name, value, ok && value != ""
+}
+
+// fail records an error about the named variable.
+func (l *envLoader) fail(name string, err error) {
+	if l.err == nil {
+		l.err = fmt.Errorf("invalid %s: %w", name, err)
+	}
+}
+
+// str reads a string variable.
+func (l *envLoader) str(key string, dst *string) {
+	if _, value, ok := l.lookup(key); ok {
+		*dst = value
+	}
+}
+
+// list reads a comma-separated variable, dropping empty items.
+func (l *envLoader) list(key string, dst *[]string) {
+	_, value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+// boolean reads a variable in strconv.ParseBool syntax.
+func (l *envLoader) boolean(key string, dst *bool) {
+	name, value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(name, fmt.Errorf("%q is not a boolean", value))
+		return
+	}
+	*dst = b
+}
+
+// integer reads a decimal integer variable.
+func (l *envLoader) integer(key string, dst *int) {
+	name, value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(name, fmt.Errorf("%q is not an integer", value))
+		return
+	}
+	*dst = n
+}
+
+// duration reads a non-negative variable in time.ParseDuration syntax.
+func (l *envLoader) duration(key string, dst *time.Duration) {
+	name, value, ok := l.lookup(key)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		l.fail(name, err)
+		return
+	}
+	if d < 0 {
+		l.fail(name, fmt.Errorf("duration must be >= 0"))
+		return
+	}
+	*dst = d
+}
+
+// StorageConfigFromEnv returns DefaultStorageConfig overridden by the
+// environment variables below, each named prefix + "_" + the suffix, such as
+// SESSION_STORAGE_TYPE for the prefix "SESSION". Unset or empty variables
+// keep the defaults. Durations use Go syntax ("30s", "10m"), lists are
+// comma-separated.
+//
+//	STORAGE_TYPE          Type: memory, redis, file, sqlite or bolt
+//	KEY_PREFIX            KeyPrefix
+//	REDIS_URL             RedisURL
+//	REDIS_ADDR            RedisAddr
+//	REDIS_PASSWORD        RedisPassword
+//	REDIS_DB              RedisDB
+//	REDIS_CLUSTER_ADDRS   RedisClusterAddrs
+//	REDIS_MASTER_NAME     RedisMasterName
+//	REDIS_SENTINEL_ADDRS  RedisSentinelAddrs
+//	REDIS_POOL_SIZE       RedisPoolSize
+//	REDIS_DIAL_TIMEOUT    RedisDialTimeout
+//	REDIS_READ_TIMEOUT    RedisReadTimeout
+//	REDIS_WRITE_TIMEOUT   RedisWriteTimeout
+//	MEMORY_GC_INTERVAL    MemoryGCInterval
+//	FILE_DIR              FileDir
+//	SQLITE_DSN            SQLiteDSN
+//	BOLT_PATH             BoltPath
+//
+// Errors name the offending variable.
+func StorageConfigFromEnv(prefix string) (StorageConfig, error) {
+	cfg := DefaultStorageConfig()
+	env := newEnvLoader(prefix)
+
+	var storageType string
+	env.str("STORAGE_TYPE", &storageType)
+	if storageType != "" {
+		switch t := StorageType(strings.ToLower(strings.TrimSpace(storageType))); t {
+		case StorageTypeMemory, StorageTypeRedis, StorageTypeFile, StorageTypeSQLite, StorageTypeBolt:
+			cfg.Type = t
+		default:
+			env.fail(env.prefix+"STORAGE_TYPE", fmt.Errorf("unknown storage type %q", storageType))
+		}
+	}
+
+	env.str("KEY_PREFIX", &cfg.KeyPrefix)
+	env.str("REDIS_URL", &cfg.RedisURL)
+	env.str("REDIS_ADDR", &cfg.RedisAddr)
+	env.str("REDIS_PASSWORD", &cfg.RedisPassword)
+	env.integer("REDIS_DB", &cfg.RedisDB)
+	env.list("REDIS_CLUSTER_ADDRS", &cfg.RedisClusterAddrs)
+	env.str("REDIS_MASTER_NAME", &cfg.RedisMasterName)
+	env.list("REDIS_SENTINEL_ADDRS", &cfg.RedisSentinelAddrs)
+	env.integer("REDIS_POOL_SIZE", &cfg.RedisPoolSize)
+	env.duration("REDIS_DIAL_TIMEOUT", &cfg.RedisDialTimeout)
+	env.duration("REDIS_READ_TIMEOUT", &cfg.RedisReadTimeout)
+	env.duration("REDIS_WRITE_TIMEOUT", &cfg.RedisWriteTimeout)
+	env.duration("MEMORY_GC_INTERVAL", &cfg.MemoryGCInterval)
+	env.str("FILE_DIR", &cfg.FileDir)
+	env.str("SQLITE_DSN", &cfg.SQLiteDSN)
+	env.str("BOLT_PATH", &cfg.BoltPath)
+
+	if env.err != nil {
+		return StorageConfig{}, env.err
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv returns DefaultConfig overridden by the environment
+// variables below, named as for StorageConfigFromEnv, and validates it.
+//
+//	EXPIRATION            Expiration
+//	ANONYMOUS_EXPIRATION  AnonymousExpiration
+//	COOKIE_NAME           CookieName
+//	REMEMBER_COOKIE_NAME  RememberCookieName
+//	SESSION_ONLY_COOKIE   SessionOnlyCookie
+//	PARTITIONED           Partitioned
+//	COOKIE_DOMAIN         CookieDomain
+//	COOKIE_PATH           CookiePath
+//	SECURE                Secure
+//	TRUST_PROXY_HEADER    TrustProxyHeader
+//	HTTP_ONLY             HTTPOnly
+//	SAMESITE              SameSite: Strict, Lax, None or Disabled
+//	KEY_PREFIX            KeyPrefix
+//	SLIDING_EXPIRATION    SlidingExpiration
+//	STORAGE_TIMEOUT       StorageTimeout
+//	TOUCH_INTERVAL        TouchInterval
+//	MAX_SESSIONS_PER_USER MaxSessionsPerUser
+//
+// Errors name the offending variable.
+func ConfigFromEnv(prefix string) (Config, error) {
+	cfg := DefaultConfig()
+	env := newEnvLoader(prefix)
+
+	env.duration("EXPIRATION", &cfg.Expiration)
+	env.duration("ANONYMOUS_EXPIRATION", &cfg.AnonymousExpiration)
+	env.str("COOKIE_NAME", &cfg.CookieName)
+	env.str("REMEMBER_COOKIE_NAME", &cfg.RememberCookieName)
+	env.boolean("SESSION_ONLY_COOKIE", &cfg.SessionOnlyCookie)
+	env.boolean("PARTITIONED", &cfg.Partitioned)
+	env.str("COOKIE_DOMAIN", &cfg.CookieDomain)
+	env.str("COOKIE_PATH", &cfg.CookiePath)
+	env.boolean("SECURE", &cfg.Secure)
+	env.boolean("TRUST_PROXY_HEADER", &cfg.TrustProxyHeader)
+	env.boolean("HTTP_ONLY", &cfg.HTTPOnly)
+
+	var sameSite string
+	env.str("SAMESITE", &sameSite)
+	if sameSite != "" {
+		if parsed, err := ParseSameSite(sameSite); err == nil {
+			cfg.SameSite = parsed
+		} else {
+			env.fail(env.prefix+"SAMESITE", fmt.Errorf("%q is not one of Strict, Lax, None or Disabled", sameSite))
+		}
+	}
+
+	env.str("KEY_PREFIX", &cfg.KeyPrefix)
+	env.boolean("SLIDING_EXPIRATION", &cfg.SlidingExpiration)
+	env.duration("STORAGE_TIMEOUT", &cfg.StorageTimeout)
+	env.duration("TOUCH_INTERVAL", &cfg.TouchInterval)
+	env.integer("MAX_SESSIONS_PER_USER", &cfg.MaxSessionsPerUser)
+
+	if env.err != nil {
+		return Config{}, env.err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,204 @@
+package session
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStorageConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg StorageConfig)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg StorageConfig) {
+				if !reflect.DeepEqual(cfg, DefaultStorageConfig()) {
+					t.Errorf("expected defaults, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "memory",
+			env: map[string]string{
+				"SESSION_STORAGE_TYPE":       "memory",
+				"SESSION_MEMORY_GC_INTERVAL": "1m",
+			},
+			check: func(t *testing.T, cfg StorageConfig) {
+				if cfg.Type != StorageTypeMemory || cfg.MemoryGCInterval != time.Minute {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "redis",
+			env: map[string]string{
+				"SESSION_STORAGE_TYPE":         "Redis",
+				"SESSION_KEY_PREFIX":           "app:",
+				"SESSION_REDIS_ADDR":           "redis:6379",
+				"SESSION_REDIS_PASSWORD":       "secret",
+				"SESSION_REDIS_DB":             "3",
+				"SESSION_REDIS_SENTINEL_ADDRS": "s1:26379, s2:26379,",
+				"SESSION_REDIS_READ_TIMEOUT":   "250ms",
+			},
+			check: func(t *testing.T, cfg StorageConfig) {
+				if cfg.Type != StorageTypeRedis || cfg.KeyPrefix != "app:" || cfg.RedisAddr != "redis:6379" ||
+					cfg.RedisPassword != "secret" || cfg.RedisDB != 3 || cfg.RedisReadTimeout != 250*time.Millisecond {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+				if !reflect.DeepEqual(cfg.RedisSentinelAddrs, []string{"s1:26379", "s2:26379"}) {
+					t.Errorf("unexpected sentinel addresses %q", cfg.RedisSentinelAddrs)
+				}
+			},
+		},
+		{
+			name: "redis cluster",
+			env: map[string]string{
+				"SESSION_STORAGE_TYPE":        "redis",
+				"SESSION_REDIS_CLUSTER_ADDRS": "n1:6379,n2:6379",
+			},
+			check: func(t *testing.T, cfg StorageConfig) {
+				if !reflect.DeepEqual(cfg.RedisClusterAddrs, []string{"n1:6379", "n2:6379"}) {
+					t.Errorf("unexpected cluster addresses %q", cfg.RedisClusterAddrs)
+				}
+			},
+		},
+		{
+			name: "empty values keep defaults",
+			env:  map[string]string{"SESSION_REDIS_ADDR": ""},
+			check: func(t *testing.T, cfg StorageConfig) {
+				if cfg.RedisAddr != "localhost:6379" {
+					t.Errorf("expected default address, got %s", cfg.RedisAddr)
+				}
+			},
+		},
+		{
+			name:    "unknown type",
+			env:     map[string]string{"SESSION_STORAGE_TYPE": "mongo"},
+			wantErr: "SESSION_STORAGE_TYPE",
+		},
+		{
+			name:    "bad integer",
+			env:     map[string]string{"SESSION_REDIS_DB": "one"},
+			wantErr: "SESSION_REDIS_DB",
+		},
+		{
+			name:    "bad duration",
+			env:     map[string]string{"SESSION_REDIS_DIAL_TIMEOUT": "5"},
+			wantErr: "SESSION_REDIS_DIAL_TIMEOUT",
+		},
+		{
+			name:    "negative duration",
+			env:     map[string]string{"SESSION_MEMORY_GC_INTERVAL": "-1m"},
+			wantErr: "SESSION_MEMORY_GC_INTERVAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := StorageConfigFromEnv("SESSION")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error naming %s, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg Config)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg Config) {
+				if !reflect.DeepEqual(cfg, DefaultConfig()) {
+					t.Errorf("expected defaults, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"APP_COOKIE_NAME":          "sid",
+				"APP_REMEMBER_COOKIE_NAME": "rid",
+				"APP_EXPIRATION":           "2h",
+				"APP_ANONYMOUS_EXPIRATION": "30m",
+				"APP_SESSION_ONLY_COOKIE":  "true",
+				"APP_SAMESITE":             "strict",
+				"APP_SECURE":               "false",
+				"APP_HTTP_ONLY":            "0",
+				"APP_SLIDING_EXPIRATION":   "true",
+				"APP_STORAGE_TIMEOUT":      "100ms",
+			},
+			check: func(t *testing.T, cfg Config) {
+				if cfg.CookieName != "sid" || cfg.RememberCookieName != "rid" || cfg.Expiration != 2*time.Hour ||
+					cfg.AnonymousExpiration != 30*time.Minute || cfg.SameSite != "Strict" ||
+					cfg.Secure || cfg.HTTPOnly || !cfg.SlidingExpiration || cfg.StorageTimeout != 100*time.Millisecond ||
+					!cfg.SessionOnlyCookie {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name:    "bad duration",
+			env:     map[string]string{"APP_EXPIRATION": "1 day"},
+			wantErr: "APP_EXPIRATION",
+		},
+		{
+			name:    "bad boolean",
+			env:     map[string]string{"APP_SECURE": "yes"},
+			wantErr: "APP_SECURE",
+		},
+		{
+			name:    "bad samesite",
+			env:     map[string]string{"APP_SAMESITE": "Loose"},
+			wantErr: "APP_SAMESITE",
+		},
+		{
+			name:    "first error wins",
+			env:     map[string]string{"APP_EXPIRATION": "x", "APP_SECURE": "x"},
+			wantErr: "APP_EXPIRATION",
+		},
+		{
+			name:    "invalid combination",
+			env:     map[string]string{"APP_SAMESITE": "None", "APP_SECURE": "false"},
+			wantErr: "same-site None requires Secure=true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := ConfigFromEnv("APP_")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
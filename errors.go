@@ -0,0 +1,102 @@
+package session
+
+import "errors"
+
+var (
+	// ErrClosed is returned by storage operations performed after Close.
+	ErrClosed = errors.New("storage is closed")
+
+	// ErrCircuitOpen is returned by CircuitBreakerStorage while the circuit
+	// is open and calls are failing fast. It matches ErrStorageUnavailable.
+	ErrCircuitOpen = withKind(errors.New("storage circuit breaker is open"), ErrStorageUnavailable)
+
+	// ErrSessionNotFound is returned by operations that require an existing
+	// session, such as KVManager.Update, Manager.LoadSessionStrict and
+	// KVManager.GetStrict, when the session does not exist or has expired
+	// without the expiration being noticed.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrSessionExpired is returned by Manager.LoadSessionStrict when the
+	// session exists but has expired. It is then deleted.
+	ErrSessionExpired = errors.New("session expired")
+
+	// ErrInvalidSessionData is matched by the errors returned when a stored
+	// session cannot be decoded or decrypted, such as ErrDecryptionFailed.
+	ErrInvalidSessionData = errors.New("invalid session data")
+
+	// ErrStorageUnavailable is matched by the errors returned when the
+	// storage backend cannot be reached or fails, such as Redis network
+	// errors, ErrCircuitOpen and the storage errors of Manager.LoadSession.
+	ErrStorageUnavailable = errors.New("session storage unavailable")
+
+	// ErrReadOnly is returned by ReadOnlyStorage for every operation that
+	// would modify the storage.
+	ErrReadOnly = errors.New("storage is read-only")
+
+	// ErrAlreadyExists is returned by Store.CreateWithID when a session with
+	// the given ID already exists.
+	ErrAlreadyExists = errors.New("session already exists")
+
+	// ErrRevisionMismatch is returned by Store.SetCAS when the session was
+	// written since the expected revision was read.
+	ErrRevisionMismatch = errors.New("session revision mismatch")
+
+	// ErrTooManySessions is returned by KVManager.CreateScoped under the
+	// ScopeReject policy, and by Manager.SaveSession under the
+	// SessionLimitReject policy, when the subject already has the maximum
+	// number of sessions.
+	ErrTooManySessions = errors.New("too many sessions for subject")
+
+	// ErrDecryptionFailed is returned by EncryptedStorage when a stored value
+	// cannot be decrypted with any of its keys, e.g. because it was tampered
+	// with or encrypted with a key that has been retired. It matches
+	// ErrInvalidSessionData.
+	ErrDecryptionFailed = withKind(errors.New("failed to decrypt session value"), ErrInvalidSessionData)
+
+	// ErrSessionValueType is returned by LookupKey and GetJSON when a fiber
+	// session holds a value of another type under the requested key.
+	ErrSessionValueType = errors.New("session value has the wrong type")
+
+	// ErrRememberTokenInvalid is returned by RedeemRememberToken when the
+	// token does not exist, has expired or does not match.
+	ErrRememberTokenInvalid = errors.New("invalid remember-me token")
+
+	// ErrRememberTokenReused is returned by RedeemRememberToken when a token
+	// is presented again after being redeemed, a sign that it was stolen.
+	// All remember-me tokens of the user are revoked.
+	ErrRememberTokenReused = errors.New("remember-me token reused")
+
+	// ErrInvalidCookieSignature is returned by ParseSignedCookieValue when a
+	// session cookie is not signed with the signing key or the previous one.
+	ErrInvalidCookieSignature = errors.New("invalid session cookie signature")
+)
+
+// kindError is an error that also matches a sentinel classifying it, such as
+// ErrStorageUnavailable, without changing its message.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+
+// Unwrap returns both the error and its kind for errors.Is and errors.As.
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// withKind returns err also matching kind, or nil if err is nil.
+func withKind(err, kind error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &kindError{err: err, kind: kind}
+}
+
+// unavailable returns err also matching ErrStorageUnavailable.
+func unavailable(err error) error {
+	return withKind(err, ErrStorageUnavailable)
+}
+
+// invalidData returns err also matching ErrInvalidSessionData.
+func invalidData(err error) error {
+	return withKind(err, ErrInvalidSessionData)
+}
@@ -0,0 +1,156 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupUnretriedMiniRedis is setupMiniRedis with a client that does not
+// retry, so commands fail at once after the server is closed.
+func setupUnretriedMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+}
+
+// ttlDownStorage is an ExtendedStorage whose GetTTL always fails.
+type ttlDownStorage struct {
+	ExtendedStorage
+}
+
+func (ttlDownStorage) GetTTL(string) (time.Duration, error) {
+	return 0, syscall.ECONNREFUSED
+}
+
+func TestManagerLoadSessionStrict(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig())
+
+	if _, err := manager.LoadSessionStrict("missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	expired := manager.CreateSession("expired")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	_ = manager.SaveSession(expired)
+	if _, err := manager.LoadSessionStrict("expired"); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired, got %v", err)
+	}
+	if loaded, _ := storage.Get("expired"); loaded != nil {
+		t.Error("expected the expired session to be deleted")
+	}
+
+	_ = storage.Set("corrupt", []byte("{"), time.Hour)
+	if _, err := manager.LoadSessionStrict("corrupt"); !errors.Is(err, ErrInvalidSessionData) {
+		t.Errorf("expected ErrInvalidSessionData, got %v", err)
+	}
+
+	// LoadSession keeps returning nil, nil for missing and expired sessions
+	if loaded, err := manager.LoadSession("missing"); loaded != nil || err != nil {
+		t.Errorf("expected nil, nil, got %v, %v", loaded, err)
+	}
+}
+
+func TestSentinelErrorsAcrossLayers(t *testing.T) {
+	mr, client := setupUnretriedMiniRedis(t)
+	defer func() { _ = client.Close() }()
+	redisStorage := NewRedisStorage(client, "test:")
+
+	// A tampered value of an encrypted storage
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encrypted := NewEncryptedStorage(memory, [][]byte{key})
+	_ = memory.Set("tampered", []byte("not encrypted"), time.Hour)
+
+	// A tripped circuit breaker
+	down := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	down.down.Store(true)
+	breaker := NewCircuitBreakerStorage(down, BreakerOptions{FailureThreshold: 1, Cooldown: time.Hour})
+	_, _ = breaker.Get("trip")
+
+	mr.Close()
+
+	tests := []struct {
+		name    string
+		storage Storage
+		id      string
+		want    []error
+	}{
+		{"redis down", redisStorage, "s1", []error{ErrStorageUnavailable}},
+		{"retried redis down", NewRetryStorage(redisStorage, DefaultRetryOptions().WithMaxAttempts(2)), "s1", []error{ErrStorageUnavailable}},
+		{"circuit open", breaker, "s1", []error{ErrStorageUnavailable, ErrCircuitOpen}},
+		{"storage error", down, "s1", []error{ErrStorageUnavailable}},
+		{"decryption failed", encrypted, "tampered", []error{ErrInvalidSessionData, ErrDecryptionFailed}},
+		{"missing", encrypted, "missing", []error{ErrSessionNotFound}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(tt.storage, DefaultConfig())
+			_, err := manager.LoadSessionStrict(tt.id)
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("expected %v to match %v", err, want)
+				}
+			}
+
+			// LoadSessionWithTTL reports missing sessions as nil, 0, nil
+			_, _, err = manager.LoadSessionWithTTL(tt.id)
+			for _, want := range tt.want {
+				if want != ErrSessionNotFound && !errors.Is(err, want) {
+					t.Errorf("expected LoadSessionWithTTL error %v to match %v", err, want)
+				}
+			}
+		})
+	}
+
+	ttlDown := NewManager(ttlDownStorage{memory}, DefaultConfig())
+	_ = ttlDown.SaveSession(ttlDown.CreateSession("ttl"))
+	if _, _, err := ttlDown.LoadSessionWithTTL("ttl"); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("expected a GetTTL error to match ErrStorageUnavailable, got %v", err)
+	}
+
+	readOnly := NewManager(NewReadOnlyStorage(memory), DefaultConfig())
+	if err := readOnly.SaveSession(readOnly.CreateSession("s1")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+	if !errors.Is(ErrCircuitOpen, ErrCircuitOpen) || ErrCircuitOpen.Error() != "storage circuit breaker is open" {
+		t.Errorf("expected ErrCircuitOpen to keep its message, got %q", ErrCircuitOpen)
+	}
+}
+
+func TestRedisStoreSentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupUnretriedMiniRedis(t)
+	defer func() { _ = client.Close() }()
+	manager := NewKVManager(NewRedisStore(client, "kv:"), time.Hour)
+
+	if _, err := manager.GetStrict(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if rec, err := manager.Get(ctx, "missing"); rec != nil || err != nil {
+		t.Errorf("expected nil, nil, got %v, %v", rec, err)
+	}
+	_ = mr.Set("kv:corrupt", "{")
+	if _, err := manager.GetStrict(ctx, "corrupt"); !errors.Is(err, ErrInvalidSessionData) {
+		t.Errorf("expected ErrInvalidSessionData, got %v", err)
+	}
+
+	mr.Close()
+	if _, err := manager.GetStrict(ctx, "s1"); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("expected ErrStorageUnavailable, got %v", err)
+	}
+	if err := manager.Set(ctx, "s1", map[string]interface{}{"k": "v"}, time.Hour); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("expected ErrStorageUnavailable, got %v", err)
+	}
+}
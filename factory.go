@@ -1,10 +1,17 @@
 package session
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/redis/go-redis/v9"
+	rediskitclient "github.com/soulteary/redis-kit/client"
+	"go.etcd.io/bbolt"
 )
 
 // StorageType represents the type of storage backend.
@@ -15,6 +22,12 @@ const (
 	StorageTypeMemory StorageType = "memory"
 	// StorageTypeRedis uses Redis storage.
 	StorageTypeRedis StorageType = "redis"
+	// StorageTypeFile uses file storage.
+	StorageTypeFile StorageType = "file"
+	// StorageTypeSQLite uses SQLite storage.
+	StorageTypeSQLite StorageType = "sqlite"
+	// StorageTypeBolt uses bbolt storage.
+	StorageTypeBolt StorageType = "bolt"
 )
 
 // StorageConfig represents configuration for creating a storage backend.
@@ -34,13 +47,64 @@ type StorageConfig struct {
 	// RedisDB is the Redis database number (for Redis storage).
 	RedisDB int
 
+	// RedisURL is a redis:// or rediss:// URL (for Redis storage).
+	// If provided, it wins over RedisAddr, RedisPassword, and RedisDB.
+	RedisURL string
+
 	// RedisClient is an existing Redis client (for Redis storage).
-	// If provided, RedisAddr, RedisPassword, and RedisDB are ignored.
-	RedisClient *redis.Client
+	// If provided, every other Redis field is ignored.
+	RedisClient *redis.Client `json:"-"`
+
+	// RedisClusterAddrs are the seed node addresses of a Redis Cluster (for
+	// Redis storage with Cluster). Setting them selects Cluster, in which
+	// case RedisAddr is ignored and RedisDB must be 0.
+	RedisClusterAddrs []string
+
+	// RedisMasterName is the name of the Sentinel-managed master (for Redis
+	// storage with Sentinel). Setting it or RedisSentinelAddrs selects
+	// Sentinel, in which case both are required and RedisAddr is ignored.
+	RedisMasterName string
+
+	// RedisSentinelAddrs are the Sentinel addresses (for Redis storage with Sentinel).
+	RedisSentinelAddrs []string
+
+	// RedisTLSConfig enables TLS for connections to Redis (for Redis storage),
+	// as required by most managed Redis offerings. Nil means plain TCP.
+	RedisTLSConfig *tls.Config `json:"-"`
+
+	// RedisPoolSize is the maximum number of connections (for Redis storage).
+	// Zero keeps the client default.
+	RedisPoolSize int
+
+	// RedisDialTimeout is the timeout for establishing connections (for Redis storage).
+	// Zero keeps the client default.
+	RedisDialTimeout time.Duration
+
+	// RedisReadTimeout is the timeout for socket reads (for Redis storage).
+	// Zero keeps the client default.
+	RedisReadTimeout time.Duration
+
+	// RedisWriteTimeout is the timeout for socket writes (for Redis storage).
+	// Zero keeps the client default.
+	RedisWriteTimeout time.Duration
 
 	// MemoryGCInterval is the garbage collection interval for memory storage.
 	// Default: 10 minutes. Set to 0 to disable GC.
 	MemoryGCInterval time.Duration
+
+	// FileDir is the directory holding the session files (for file storage).
+	FileDir string
+
+	// SQLiteDSN is the data source name of the database (for SQLite storage).
+	// A SQLite driver must be registered; see SQLiteStorageConfig.DriverName.
+	SQLiteDSN string
+
+	// BoltPath is the database file (for bbolt storage).
+	BoltPath string
+
+	// BoltDB is an already open database (for bbolt storage). If provided,
+	// BoltPath is ignored and closing the storage leaves it open.
+	BoltDB *bbolt.DB `json:"-"`
 }
 
 // DefaultStorageConfig returns a StorageConfig with default values.
@@ -85,36 +149,355 @@ func (c StorageConfig) WithRedisDB(db int) StorageConfig {
 	return c
 }
 
+// WithRedisURL sets the Redis URL.
+func (c StorageConfig) WithRedisURL(rawURL string) StorageConfig {
+	c.RedisURL = rawURL
+	return c
+}
+
 // WithRedisClient sets an existing Redis client.
 func (c StorageConfig) WithRedisClient(client *redis.Client) StorageConfig {
 	c.RedisClient = client
 	return c
 }
 
+// WithRedisCluster sets the Redis Cluster seed node addresses.
+func (c StorageConfig) WithRedisCluster(addrs ...string) StorageConfig {
+	c.RedisClusterAddrs = addrs
+	return c
+}
+
+// WithRedisSentinel sets the Sentinel master name and addresses.
+func (c StorageConfig) WithRedisSentinel(masterName string, sentinelAddrs ...string) StorageConfig {
+	c.RedisMasterName = masterName
+	c.RedisSentinelAddrs = sentinelAddrs
+	return c
+}
+
+// WithRedisTLSConfig sets the TLS configuration for Redis connections.
+func (c StorageConfig) WithRedisTLSConfig(tlsConfig *tls.Config) StorageConfig {
+	c.RedisTLSConfig = tlsConfig
+	return c
+}
+
+// WithRedisPoolSize sets the maximum number of Redis connections.
+func (c StorageConfig) WithRedisPoolSize(size int) StorageConfig {
+	c.RedisPoolSize = size
+	return c
+}
+
+// WithRedisDialTimeout sets the Redis dial timeout.
+func (c StorageConfig) WithRedisDialTimeout(timeout time.Duration) StorageConfig {
+	c.RedisDialTimeout = timeout
+	return c
+}
+
+// WithRedisReadTimeout sets the Redis read timeout.
+func (c StorageConfig) WithRedisReadTimeout(timeout time.Duration) StorageConfig {
+	c.RedisReadTimeout = timeout
+	return c
+}
+
+// WithRedisWriteTimeout sets the Redis write timeout.
+func (c StorageConfig) WithRedisWriteTimeout(timeout time.Duration) StorageConfig {
+	c.RedisWriteTimeout = timeout
+	return c
+}
+
 // WithMemoryGCInterval sets the memory storage garbage collection interval.
 func (c StorageConfig) WithMemoryGCInterval(interval time.Duration) StorageConfig {
 	c.MemoryGCInterval = interval
 	return c
 }
 
+// WithFileDir sets the file storage directory.
+func (c StorageConfig) WithFileDir(dir string) StorageConfig {
+	c.FileDir = dir
+	return c
+}
+
+// WithSQLiteDSN sets the SQLite data source name.
+func (c StorageConfig) WithSQLiteDSN(dsn string) StorageConfig {
+	c.SQLiteDSN = dsn
+	return c
+}
+
+// WithBoltPath sets the bbolt database file.
+func (c StorageConfig) WithBoltPath(path string) StorageConfig {
+	c.BoltPath = path
+	return c
+}
+
+// WithBoltDB sets an already open bbolt database.
+func (c StorageConfig) WithBoltDB(db *bbolt.DB) StorageConfig {
+	c.BoltDB = db
+	return c
+}
+
+// Validate checks the configuration for the selected storage type and
+// returns an error describing the first problem found. Fields that the
+// selected backend ignores are not checked. NewStorage calls it.
+func (c StorageConfig) Validate() error {
+	for _, r := range c.KeyPrefix {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("key prefix cannot contain whitespace or control characters: %q", c.KeyPrefix)
+		}
+	}
+
+	switch c.Type {
+	case StorageTypeMemory:
+		if c.MemoryGCInterval < 0 {
+			return fmt.Errorf("memory gc interval must be >= 0 (0 disables GC)")
+		}
+	case StorageTypeRedis:
+		return c.validateRedis()
+	case StorageTypeFile:
+		if c.FileDir == "" {
+			return fmt.Errorf("file storage requires FileDir")
+		}
+	case StorageTypeSQLite:
+		if c.SQLiteDSN == "" {
+			return fmt.Errorf("sqlite storage requires SQLiteDSN")
+		}
+	case StorageTypeBolt:
+		if c.BoltPath == "" && c.BoltDB == nil {
+			return fmt.Errorf("bolt storage requires BoltPath or BoltDB")
+		}
+	default:
+		return fmt.Errorf("unknown storage type: %s", c.Type)
+	}
+	return nil
+}
+
+// redisConnection is the way NewStorage connects to Redis.
+type redisConnection string
+
+const (
+	redisConnectionClient   redisConnection = "client"
+	redisConnectionURL      redisConnection = "url"
+	redisConnectionCluster  redisConnection = "cluster"
+	redisConnectionSentinel redisConnection = "sentinel"
+	redisConnectionSingle   redisConnection = "single"
+)
+
+// redisConnection decides how to connect to Redis: with RedisClient if set,
+// otherwise with RedisURL, RedisClusterAddrs or Sentinel, at most one of
+// which may be set, and otherwise with RedisAddr.
+func (c StorageConfig) redisConnection() (redisConnection, error) {
+	if c.RedisClient != nil {
+		return redisConnectionClient, nil
+	}
+
+	var set []string
+	conn := redisConnectionSingle
+	if c.RedisURL != "" {
+		set = append(set, "RedisURL")
+		conn = redisConnectionURL
+	}
+	if len(c.RedisClusterAddrs) > 0 {
+		set = append(set, "RedisClusterAddrs")
+		conn = redisConnectionCluster
+	}
+	if c.RedisMasterName != "" || len(c.RedisSentinelAddrs) > 0 {
+		set = append(set, "Sentinel (RedisMasterName, RedisSentinelAddrs)")
+		conn = redisConnectionSentinel
+	}
+	if len(set) > 1 {
+		return "", fmt.Errorf("ambiguous redis configuration: %s cannot be combined", strings.Join(set, " and "))
+	}
+	return conn, nil
+}
+
+// validateRedis checks the Redis fields used by NewStorage, for the
+// connection chosen by redisConnection.
+func (c StorageConfig) validateRedis() error {
+	conn, err := c.redisConnection()
+	if err != nil {
+		return err
+	}
+	if conn == redisConnectionClient || conn == redisConnectionURL {
+		return nil
+	}
+
+	if c.RedisDB < 0 {
+		return fmt.Errorf("redis db must be >= 0")
+	}
+	if c.RedisPoolSize < 0 {
+		return fmt.Errorf("redis pool size must be >= 0")
+	}
+	if c.RedisDialTimeout < 0 {
+		return fmt.Errorf("redis dial timeout must be >= 0")
+	}
+
+	switch conn {
+	case redisConnectionCluster:
+		if c.RedisDB != 0 {
+			return fmt.Errorf("redis cluster only supports db 0")
+		}
+		for _, addr := range c.RedisClusterAddrs {
+			if addr == "" {
+				return fmt.Errorf("redis cluster address cannot be empty")
+			}
+		}
+	case redisConnectionSentinel:
+		return validateSentinelOptions(redisFailoverOptions(c))
+	default:
+		if c.RedisAddr == "" {
+			return fmt.Errorf("redis storage requires RedisAddr or RedisClient")
+		}
+	}
+	return nil
+}
+
 // NewStorage creates a new Storage instance based on the configuration.
 // It automatically selects the appropriate storage backend based on the Type field.
+// The configuration is checked with Validate first.
 func NewStorage(cfg StorageConfig) (Storage, error) {
+	return NewStorageWithOptions(cfg.Type, WithStorageConfig(cfg))
+}
+
+// newStorage creates the storage described by a validated cfg. clientOpts
+// are applied to the options of the Redis clients created from an address
+// or URL.
+func newStorage(cfg StorageConfig, clientOpts []func(*redis.Options)) (Storage, error) {
 	switch cfg.Type {
 	case StorageTypeMemory:
 		return NewMemoryStorage(cfg.KeyPrefix, cfg.MemoryGCInterval), nil
 
 	case StorageTypeRedis:
-		if cfg.RedisClient != nil {
+		conn, err := cfg.redisConnection()
+		if err != nil {
+			return nil, err
+		}
+		switch conn {
+		case redisConnectionClient:
 			return NewRedisStorage(cfg.RedisClient, cfg.KeyPrefix), nil
+		case redisConnectionURL:
+			return newRedisStorageFromURL(cfg.RedisURL, cfg.KeyPrefix, clientOpts)
+		case redisConnectionCluster:
+			return newRedisStorageFromCluster(redisClusterOptions(cfg), cfg.KeyPrefix)
+		case redisConnectionSentinel:
+			return newRedisStorageFromSentinel(redisFailoverOptions(cfg), cfg.KeyPrefix)
 		}
-		return NewRedisStorageFromConfig(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB, cfg.KeyPrefix)
+		if len(clientOpts) > 0 {
+			return newRedisStorageFromOptions(redisOptions(redisClientConfig(cfg), clientOpts), cfg.KeyPrefix)
+		}
+		return newRedisStorageFromClientConfig(redisClientConfig(cfg), cfg.KeyPrefix)
+
+	case StorageTypeFile:
+		return NewFileStorageWithConfig(DefaultFileStorageConfig().
+			WithDir(cfg.FileDir).
+			WithKeyPrefix(cfg.KeyPrefix))
+
+	case StorageTypeSQLite:
+		return NewSQLiteStorageWithConfig(DefaultSQLiteStorageConfig().
+			WithDSN(cfg.SQLiteDSN).
+			WithKeyPrefix(cfg.KeyPrefix))
+
+	case StorageTypeBolt:
+		return NewBoltStorageWithConfig(DefaultBoltStorageConfig().
+			WithDB(cfg.BoltDB).
+			WithPath(cfg.BoltPath).
+			WithKeyPrefix(cfg.KeyPrefix))
 
 	default:
 		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
 	}
 }
 
+// redisClientConfig builds the redis-kit client configuration for cfg.
+// Zero values keep the redis-kit defaults.
+func redisClientConfig(cfg StorageConfig) rediskitclient.Config {
+	clientCfg := rediskitclient.DefaultConfig().
+		WithAddr(cfg.RedisAddr).
+		WithPassword(cfg.RedisPassword).
+		WithDB(cfg.RedisDB)
+
+	if cfg.RedisPoolSize > 0 {
+		clientCfg = clientCfg.WithPoolSize(cfg.RedisPoolSize)
+	}
+	if cfg.RedisDialTimeout > 0 {
+		clientCfg = clientCfg.WithDialTimeout(cfg.RedisDialTimeout)
+	}
+	if cfg.RedisReadTimeout > 0 {
+		clientCfg = clientCfg.WithReadTimeout(cfg.RedisReadTimeout)
+	}
+	if cfg.RedisWriteTimeout > 0 {
+		clientCfg = clientCfg.WithWriteTimeout(cfg.RedisWriteTimeout)
+	}
+	if cfg.RedisTLSConfig != nil {
+		clientCfg.Dialer = tlsDialer(cfg.RedisTLSConfig, clientCfg.DialTimeout)
+	}
+
+	return clientCfg
+}
+
+// redisOptions converts the redis-kit client configuration to go-redis
+// options, as redis-kit does, and applies clientOpts to them.
+func redisOptions(cfg rediskitclient.Config, clientOpts []func(*redis.Options)) *redis.Options {
+	opts := &redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		MaxRetries:   cfg.MaxRetries,
+		PoolTimeout:  cfg.PoolTimeout,
+	}
+	if cfg.Dialer != nil {
+		opts.Dialer = cfg.Dialer
+	}
+	for _, fn := range clientOpts {
+		fn(opts)
+	}
+	return opts
+}
+
+// redisFailoverOptions builds the Sentinel failover client options for cfg.
+// Zero values keep the go-redis defaults.
+func redisFailoverOptions(cfg StorageConfig) *redis.FailoverOptions {
+	return &redis.FailoverOptions{
+		MasterName:    cfg.RedisMasterName,
+		SentinelAddrs: cfg.RedisSentinelAddrs,
+		Password:      cfg.RedisPassword,
+		DB:            cfg.RedisDB,
+		PoolSize:      cfg.RedisPoolSize,
+		DialTimeout:   cfg.RedisDialTimeout,
+		ReadTimeout:   cfg.RedisReadTimeout,
+		WriteTimeout:  cfg.RedisWriteTimeout,
+		TLSConfig:     cfg.RedisTLSConfig,
+	}
+}
+
+// redisClusterOptions builds the cluster client options for cfg.
+// Zero values keep the go-redis defaults.
+func redisClusterOptions(cfg StorageConfig) *redis.ClusterOptions {
+	return &redis.ClusterOptions{
+		Addrs:        cfg.RedisClusterAddrs,
+		Password:     cfg.RedisPassword,
+		PoolSize:     cfg.RedisPoolSize,
+		DialTimeout:  cfg.RedisDialTimeout,
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+		TLSConfig:    cfg.RedisTLSConfig,
+	}
+}
+
+// tlsDialer returns a dialer that opens TLS connections. The server name is
+// taken from the address unless tlsConfig sets one.
+func tlsDialer(tlsConfig *tls.Config, timeout time.Duration) rediskitclient.Dialer {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: timeout, KeepAlive: 5 * time.Minute},
+			Config:    tlsConfig,
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
 // NewStorageFromEnv creates a Storage based on environment-like configuration.
 // If redisEnabled is true, it creates a Redis storage; otherwise, it creates a memory storage.
 // This is a convenience function for common use cases.
@@ -0,0 +1,264 @@
+package session
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StorageOption configures the storage created by NewStorageWithOptions.
+type StorageOption func(*storageOptions)
+
+// storageOptions collects the settings of NewStorageWithOptions, along with
+// the names of the options used, to report conflicting options.
+type storageOptions struct {
+	cfg        StorageConfig
+	clientOpts []func(*redis.Options)
+	used       []string
+}
+
+// use records that the named option was used.
+func (o *storageOptions) use(name string) {
+	if !slices.Contains(o.used, name) {
+		o.used = append(o.used, name)
+	}
+}
+
+// has reports whether the named option was used.
+func (o *storageOptions) has(name string) bool {
+	return slices.Contains(o.used, name)
+}
+
+// WithStorageConfig uses every setting of cfg except Type, which is given to
+// NewStorageWithOptions. Later options override it. The settings of cfg
+// follow the precedence documented on StorageConfig rather than being
+// reported as conflicts, as NewStorage does.
+func WithStorageConfig(cfg StorageConfig) StorageOption {
+	return func(o *storageOptions) {
+		cfg.Type = o.cfg.Type
+		o.cfg = cfg
+	}
+}
+
+// WithPrefix sets the key prefix.
+func WithPrefix(prefix string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithPrefix")
+		o.cfg.KeyPrefix = prefix
+	}
+}
+
+// WithGCInterval sets the garbage collection interval of memory storage.
+// Set to 0 to disable GC.
+func WithGCInterval(interval time.Duration) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithGCInterval")
+		o.cfg.MemoryGCInterval = interval
+	}
+}
+
+// WithRedisAddr sets the Redis server address.
+func WithRedisAddr(addr string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisAddr")
+		o.cfg.RedisAddr = addr
+	}
+}
+
+// WithRedisPassword sets the Redis password.
+func WithRedisPassword(password string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisPassword")
+		o.cfg.RedisPassword = password
+	}
+}
+
+// WithRedisDB sets the Redis database number.
+func WithRedisDB(db int) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisDB")
+		o.cfg.RedisDB = db
+	}
+}
+
+// WithRedisURL sets a redis:// or rediss:// URL, which carries the address,
+// credentials, database and client settings. It cannot be combined with
+// options setting those separately.
+func WithRedisURL(rawURL string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisURL")
+		o.cfg.RedisURL = rawURL
+	}
+}
+
+// WithRedisClient uses an existing Redis client as is. It cannot be combined
+// with options configuring a new client.
+func WithRedisClient(client *redis.Client) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisClient")
+		o.cfg.RedisClient = client
+	}
+}
+
+// WithRedisCluster connects to the Redis Cluster with the given seed nodes.
+func WithRedisCluster(addrs ...string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisCluster")
+		o.cfg.RedisClusterAddrs = addrs
+	}
+}
+
+// WithRedisSentinel connects through Sentinel to the named master.
+func WithRedisSentinel(masterName string, sentinelAddrs ...string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisSentinel")
+		o.cfg.RedisMasterName = masterName
+		o.cfg.RedisSentinelAddrs = sentinelAddrs
+	}
+}
+
+// WithRedisClientOpt adjusts the go-redis options of the client created from
+// an address or URL, for settings without a dedicated option. Several
+// functions are applied in order, after every other option.
+func WithRedisClientOpt(fn func(*redis.Options)) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithRedisClientOpt")
+		if fn != nil {
+			o.clientOpts = append(o.clientOpts, fn)
+		}
+	}
+}
+
+// WithTLS enables TLS for connections to Redis.
+func WithTLS(tlsConfig *tls.Config) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithTLS")
+		o.cfg.RedisTLSConfig = tlsConfig
+	}
+}
+
+// WithPoolSize sets the maximum number of Redis connections.
+func WithPoolSize(size int) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithPoolSize")
+		o.cfg.RedisPoolSize = size
+	}
+}
+
+// WithFileDir sets the directory of file storage.
+func WithFileDir(dir string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithFileDir")
+		o.cfg.FileDir = dir
+	}
+}
+
+// WithSQLiteDSN sets the data source name of SQLite storage.
+func WithSQLiteDSN(dsn string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithSQLiteDSN")
+		o.cfg.SQLiteDSN = dsn
+	}
+}
+
+// WithBoltPath sets the database file of bbolt storage.
+func WithBoltPath(path string) StorageOption {
+	return func(o *storageOptions) {
+		o.use("WithBoltPath")
+		o.cfg.BoltPath = path
+	}
+}
+
+// storageOptionTypes lists the storage types each type-specific option
+// applies to.
+var storageOptionTypes = map[string]StorageType{
+	"WithGCInterval":     StorageTypeMemory,
+	"WithRedisAddr":      StorageTypeRedis,
+	"WithRedisPassword":  StorageTypeRedis,
+	"WithRedisDB":        StorageTypeRedis,
+	"WithRedisURL":       StorageTypeRedis,
+	"WithRedisClient":    StorageTypeRedis,
+	"WithRedisCluster":   StorageTypeRedis,
+	"WithRedisSentinel":  StorageTypeRedis,
+	"WithRedisClientOpt": StorageTypeRedis,
+	"WithTLS":            StorageTypeRedis,
+	"WithPoolSize":       StorageTypeRedis,
+	"WithFileDir":        StorageTypeFile,
+	"WithSQLiteDSN":      StorageTypeSQLite,
+	"WithBoltPath":       StorageTypeBolt,
+}
+
+// storageOptionConflicts lists, for options that select how to connect, the
+// options they cannot be combined with, in the order they are checked.
+var storageOptionConflicts = []struct {
+	option    string
+	conflicts []string
+	reason    string
+}{
+	{
+		option:    "WithRedisClient",
+		conflicts: []string{"WithRedisURL", "WithRedisCluster", "WithRedisSentinel", "WithRedisAddr", "WithRedisPassword", "WithRedisDB", "WithRedisClientOpt", "WithTLS", "WithPoolSize"},
+		reason:    "the client is used as is",
+	},
+	{
+		option:    "WithRedisURL",
+		conflicts: []string{"WithRedisCluster", "WithRedisSentinel", "WithRedisAddr", "WithRedisPassword", "WithRedisDB", "WithTLS", "WithPoolSize"},
+		reason:    "set it in the URL instead",
+	},
+	{
+		option:    "WithRedisCluster",
+		conflicts: []string{"WithRedisSentinel", "WithRedisAddr", "WithRedisClientOpt"},
+		reason:    "a cluster client is created from the seed nodes",
+	},
+	{
+		option:    "WithRedisSentinel",
+		conflicts: []string{"WithRedisAddr", "WithRedisClientOpt"},
+		reason:    "a failover client is created from the sentinels",
+	},
+}
+
+// check reports options that do not apply to the storage type or conflict
+// with each other.
+func (o *storageOptions) check() error {
+	for _, name := range o.used {
+		if typ, ok := storageOptionTypes[name]; ok && typ != o.cfg.Type {
+			return fmt.Errorf("%s requires storage type %s, got %s", name, typ, o.cfg.Type)
+		}
+	}
+	for _, c := range storageOptionConflicts {
+		if !o.has(c.option) {
+			continue
+		}
+		for _, name := range c.conflicts {
+			if o.has(name) {
+				return fmt.Errorf("%s cannot be combined with %s: %s", name, c.option, c.reason)
+			}
+		}
+	}
+	return nil
+}
+
+// NewStorageWithOptions creates a new Storage of the given type, starting
+// from DefaultStorageConfig and applying opts in order. Options that do not
+// apply to the type, or that conflict with each other such as WithRedisURL
+// and WithRedisAddr, are reported as errors; the resulting configuration is
+// then checked with StorageConfig.Validate.
+func NewStorageWithOptions(typ StorageType, opts ...StorageOption) (Storage, error) {
+	o := &storageOptions{cfg: DefaultStorageConfig().WithType(typ)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	if err := o.check(); err != nil {
+		return nil, fmt.Errorf("invalid storage options: %w", err)
+	}
+	if err := o.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid storage config: %w", err)
+	}
+	return newStorage(o.cfg, o.clientOpts)
+}
@@ -0,0 +1,205 @@
+package session
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewStorageWithOptionsDefaults(t *testing.T) {
+	storage, err := NewStorageWithOptions(StorageTypeMemory)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	mem, ok := storage.(*MemoryStorage)
+	if !ok {
+		t.Fatalf("expected *MemoryStorage, got %T", storage)
+	}
+	if mem.keyPrefix != "session:" {
+		t.Errorf("expected the default prefix, got %q", mem.keyPrefix)
+	}
+
+	storage, err = NewStorageWithOptions(StorageTypeMemory, WithPrefix("app"), WithGCInterval(0), nil)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+	if prefix := storage.(*MemoryStorage).keyPrefix; prefix != "app:" {
+		t.Errorf("expected prefix app:, got %q", prefix)
+	}
+}
+
+func TestNewStorageWithOptionsRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	var applied []string
+	storage, err := NewStorageWithOptions(StorageTypeRedis,
+		WithRedisAddr(mr.Addr()),
+		WithPrefix("test:"),
+		WithPoolSize(42),
+		WithRedisClientOpt(func(o *redis.Options) {
+			applied = append(applied, "first")
+			o.ClientName = "sessions"
+		}),
+		WithRedisClientOpt(func(o *redis.Options) {
+			applied = append(applied, "second")
+			if o.PoolSize != 42 {
+				t.Errorf("expected client options to see the pool size, got %d", o.PoolSize)
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	if strings.Join(applied, ",") != "first,second" {
+		t.Errorf("expected client options to apply in order, got %v", applied)
+	}
+	opts := storage.(*RedisStorage).GetClient().Options()
+	if opts.ClientName != "sessions" || opts.PoolSize != 42 {
+		t.Errorf("unexpected client options %+v", opts)
+	}
+	if err := storage.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if !mr.Exists("test:key") {
+		t.Error("expected the key in redis")
+	}
+
+	// Client options also apply to URL clients
+	storage, err = NewStorageWithOptions(StorageTypeRedis,
+		WithRedisURL("redis://"+mr.Addr()+"/1"),
+		WithRedisClientOpt(func(o *redis.Options) { o.ClientName = "from-url" }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create storage from URL: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+	opts = storage.(*RedisStorage).GetClient().Options()
+	if opts.ClientName != "from-url" || opts.DB != 1 {
+		t.Errorf("unexpected URL client options %+v", opts)
+	}
+}
+
+func TestNewStorageWithOptionsConflicts(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer func() { _ = client.Close() }()
+
+	tests := []struct {
+		name string
+		typ  StorageType
+		opts []StorageOption
+		want string
+	}{
+		{
+			name: "url and addr",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisURL("redis://localhost:6379"), WithRedisAddr("localhost:6380")},
+			want: "WithRedisAddr cannot be combined with WithRedisURL: set it in the URL instead",
+		},
+		{
+			name: "url and tls",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithTLS(&tls.Config{MinVersion: tls.VersionTLS12}), WithRedisURL("redis://localhost:6379")},
+			want: "WithTLS cannot be combined with WithRedisURL",
+		},
+		{
+			name: "client and pool size",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisClient(client), WithPoolSize(5)},
+			want: "WithPoolSize cannot be combined with WithRedisClient: the client is used as is",
+		},
+		{
+			name: "client and url",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisClient(client), WithRedisURL("redis://localhost:6379")},
+			want: "WithRedisURL cannot be combined with WithRedisClient",
+		},
+		{
+			name: "sentinel and client options",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisSentinel("mymaster", "localhost:26379"), WithRedisClientOpt(func(*redis.Options) {})},
+			want: "WithRedisClientOpt cannot be combined with WithRedisSentinel",
+		},
+		{
+			name: "cluster and sentinel",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisCluster("n1:6379"), WithRedisSentinel("mymaster", "s1:26379")},
+			want: "WithRedisSentinel cannot be combined with WithRedisCluster",
+		},
+		{
+			name: "redis option on memory",
+			typ:  StorageTypeMemory,
+			opts: []StorageOption{WithRedisAddr("localhost:6379")},
+			want: "WithRedisAddr requires storage type redis, got memory",
+		},
+		{
+			name: "gc interval on redis",
+			typ:  StorageTypeRedis,
+			opts: []StorageOption{WithRedisClient(client), WithGCInterval(time.Minute)},
+			want: "WithGCInterval requires storage type memory, got redis",
+		},
+		{
+			name: "invalid config",
+			typ:  StorageTypeMemory,
+			opts: []StorageOption{WithGCInterval(-time.Second)},
+			want: "invalid storage config: memory gc interval must be >= 0",
+		},
+		{
+			name: "missing file dir",
+			typ:  StorageTypeFile,
+			opts: []StorageOption{WithPrefix("app:")},
+			want: "file storage requires FileDir",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, err := NewStorageWithOptions(tt.typ, tt.opts...)
+			if err == nil {
+				_ = storage.Close()
+				t.Fatalf("expected an error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %q", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestNewStorageWithOptionsStorageConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultStorageConfig().WithFileDir(dir).WithKeyPrefix("cfg:")
+
+	// The type argument wins over cfg.Type and later options override cfg
+	storage, err := NewStorageWithOptions(StorageTypeFile, WithStorageConfig(cfg), WithPrefix("opt:"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	file, ok := storage.(*FileStorage)
+	if !ok {
+		t.Fatalf("expected *FileStorage, got %T", storage)
+	}
+	if file.GetKeyPrefix() != "opt:" {
+		t.Errorf("expected the later prefix to win, got %q", file.GetKeyPrefix())
+	}
+
+	// StorageConfig keeps its precedence rules instead of conflicts
+	mr := miniredis.RunT(t)
+	storage, err = NewStorageWithOptions(StorageTypeRedis, WithStorageConfig(DefaultStorageConfig().
+		WithRedisURL("redis://"+mr.Addr()).
+		WithRedisAddr("localhost:0")))
+	if err != nil {
+		t.Fatalf("expected RedisURL to win over RedisAddr, got %v", err)
+	}
+	_ = storage.Close()
+}
@@ -1,8 +1,16 @@
 package session
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func TestDefaultStorageConfig(t *testing.T) {
@@ -35,6 +43,7 @@ func TestStorageConfigWithMethods(t *testing.T) {
 		WithRedisAddr("redis.example.com:6379").
 		WithRedisPassword("secret").
 		WithRedisDB(5).
+		WithRedisURL("redis://localhost:6379/1").
 		WithMemoryGCInterval(5 * time.Minute)
 
 	if cfg.Type != StorageTypeRedis {
@@ -52,11 +61,40 @@ func TestStorageConfigWithMethods(t *testing.T) {
 	if cfg.RedisDB != 5 {
 		t.Errorf("expected RedisDB to be 5, got %d", cfg.RedisDB)
 	}
+	if cfg.RedisURL != "redis://localhost:6379/1" {
+		t.Errorf("expected RedisURL to be set, got %s", cfg.RedisURL)
+	}
 	if cfg.MemoryGCInterval != 5*time.Minute {
 		t.Errorf("expected MemoryGCInterval to be 5m, got %v", cfg.MemoryGCInterval)
 	}
 }
 
+func TestNewStorageRedisURL(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	// The URL wins over the address fields
+	cfg := DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisAddr("127.0.0.1:1").
+		WithRedisURL("redis://" + mr.Addr() + "/2").
+		WithKeyPrefix("test:")
+
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if got, _ := mr.DB(2).Get("test:key"); got != "value" {
+		t.Errorf("expected key in db 2, got %q", got)
+	}
+}
+
 func TestNewStorageMemory(t *testing.T) {
 	cfg := DefaultStorageConfig().WithType(StorageTypeMemory)
 
@@ -81,6 +119,37 @@ func TestNewStorageMemory(t *testing.T) {
 	}
 }
 
+func TestNewStorageFile(t *testing.T) {
+	cfg := DefaultStorageConfig().WithType(StorageTypeFile).WithFileDir(t.TempDir())
+
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	if _, ok := storage.(*FileStorage); !ok {
+		t.Fatalf("expected *FileStorage, got %T", storage)
+	}
+	_ = storage.Set("test", []byte("value"), time.Hour)
+	if got, _ := storage.Get("test"); string(got) != "value" {
+		t.Errorf("expected 'value', got '%s'", string(got))
+	}
+
+	if _, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeFile)); err == nil {
+		t.Error("expected error without a file directory")
+	}
+}
+
+func TestNewStorageSQLiteValidation(t *testing.T) {
+	if _, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeSQLite)); err == nil {
+		t.Error("expected error without a DSN")
+	}
+	if _, err := NewSQLiteStorageWithConfig(DefaultSQLiteStorageConfig().WithDSN("sessions.db").WithDriverName("unregistered")); err == nil {
+		t.Error("expected error for an unregistered driver")
+	}
+}
+
 func TestNewStorageUnknownType(t *testing.T) {
 	cfg := StorageConfig{Type: "unknown"}
 
@@ -104,10 +173,64 @@ func TestNewStorageFromEnvMemory(t *testing.T) {
 	}
 }
 
+func TestStorageConfigValidate(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer func() { _ = client.Close() }()
+
+	redisCfg := DefaultStorageConfig().WithType(StorageTypeRedis)
+	tests := []struct {
+		name string
+		cfg  StorageConfig
+		want string
+	}{
+		{"unknown type", DefaultStorageConfig().WithType("mongo"), "unknown storage type: mongo"},
+		{"empty type", StorageConfig{}, "unknown storage type"},
+		{"prefix with space", DefaultStorageConfig().WithKeyPrefix("my app:"), "key prefix cannot contain"},
+		{"prefix with newline", DefaultStorageConfig().WithKeyPrefix("app\n"), "key prefix cannot contain"},
+		{"negative gc interval", DefaultStorageConfig().WithMemoryGCInterval(-time.Second), "memory gc interval must be >= 0"},
+		{"redis without addr", redisCfg.WithRedisAddr(""), "redis storage requires RedisAddr or RedisClient"},
+		{"redis negative db", redisCfg.WithRedisDB(-1), "redis db must be >= 0"},
+		{"redis negative pool size", redisCfg.WithRedisPoolSize(-1), "redis pool size must be >= 0"},
+		{"redis negative dial timeout", redisCfg.WithRedisDialTimeout(-time.Second), "redis dial timeout must be >= 0"},
+		{"redis sentinel without master", redisCfg.WithRedisSentinel("", "127.0.0.1:26379"), "master name cannot be empty"},
+		{"file without dir", DefaultStorageConfig().WithType(StorageTypeFile), "file storage requires FileDir"},
+		{"sqlite without dsn", DefaultStorageConfig().WithType(StorageTypeSQLite), "sqlite storage requires SQLiteDSN"},
+		{"bolt without path", DefaultStorageConfig().WithType(StorageTypeBolt), "bolt storage requires BoltPath or BoltDB"},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+		if _, err := NewStorage(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected NewStorage to fail with %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	valid := []StorageConfig{
+		DefaultStorageConfig(),
+		DefaultStorageConfig().WithMemoryGCInterval(0),
+		redisCfg,
+		redisCfg.WithRedisAddr("").WithRedisClient(client),
+		redisCfg.WithRedisAddr("").WithRedisURL("redis://localhost:6379/0"),
+		redisCfg.WithRedisDB(-1).WithRedisClient(client),
+		redisCfg.WithRedisSentinel("mymaster", "127.0.0.1:26379"),
+		DefaultStorageConfig().WithType(StorageTypeFile).WithFileDir("/tmp"),
+	}
+	for _, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", cfg, err)
+		}
+	}
+}
+
 func TestMustNewStoragePanic(t *testing.T) {
 	defer func() {
-		if r := recover(); r == nil {
+		r := recover()
+		if r == nil {
 			t.Error("expected panic for invalid storage config")
+		} else if !strings.Contains(fmt.Sprint(r), "unknown storage type: invalid") {
+			t.Errorf("expected the validation message in the panic, got %v", r)
 		}
 	}()
 
@@ -127,3 +250,239 @@ func TestMustNewStorageSuccess(t *testing.T) {
 	storage := MustNewStorage(cfg)
 	defer func() { _ = storage.Close() }()
 }
+
+func TestNewStorageRedisClientOptions(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cfg := DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisAddr(mr.Addr()).
+		WithRedisPoolSize(42).
+		WithRedisDialTimeout(2 * time.Second).
+		WithRedisReadTimeout(700 * time.Millisecond).
+		WithRedisWriteTimeout(800 * time.Millisecond)
+
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	opts := storage.(*RedisStorage).GetClient().Options()
+	if opts.PoolSize != 42 {
+		t.Errorf("expected pool size 42, got %d", opts.PoolSize)
+	}
+	if opts.DialTimeout != 2*time.Second {
+		t.Errorf("expected dial timeout 2s, got %v", opts.DialTimeout)
+	}
+	if opts.ReadTimeout != 700*time.Millisecond {
+		t.Errorf("expected read timeout 700ms, got %v", opts.ReadTimeout)
+	}
+	if opts.WriteTimeout != 800*time.Millisecond {
+		t.Errorf("expected write timeout 800ms, got %v", opts.WriteTimeout)
+	}
+
+	// Zero values keep the client defaults
+	defaults := redisClientConfig(DefaultStorageConfig())
+	if defaults.PoolSize <= 0 || defaults.DialTimeout <= 0 || defaults.ReadTimeout <= 0 || defaults.WriteTimeout <= 0 {
+		t.Errorf("expected sensible defaults, got %+v", defaults)
+	}
+	if defaults.Dialer != nil {
+		t.Error("expected no custom dialer without TLS")
+	}
+}
+
+func TestNewStorageRedisSentinelOptions(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	cfg := DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisSentinel("mymaster", "10.0.0.1:26379", "10.0.0.2:26379").
+		WithRedisPassword("secret").
+		WithRedisDB(3).
+		WithRedisPoolSize(42).
+		WithRedisDialTimeout(2 * time.Second).
+		WithRedisTLSConfig(tlsConfig)
+
+	opts := redisFailoverOptions(cfg)
+	if opts.MasterName != "mymaster" {
+		t.Errorf("expected master name mymaster, got %s", opts.MasterName)
+	}
+	if len(opts.SentinelAddrs) != 2 || opts.SentinelAddrs[1] != "10.0.0.2:26379" {
+		t.Errorf("unexpected sentinel addresses: %v", opts.SentinelAddrs)
+	}
+	if opts.Password != "secret" || opts.DB != 3 {
+		t.Errorf("expected password and db to be passed on, got %q and %d", opts.Password, opts.DB)
+	}
+	if opts.PoolSize != 42 || opts.DialTimeout != 2*time.Second || opts.TLSConfig != tlsConfig {
+		t.Errorf("expected client options to be passed on, got %+v", opts)
+	}
+}
+
+func TestNewStorageRedisSentinelValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  StorageConfig
+		want string
+	}{
+		{"no master", DefaultStorageConfig().WithRedisSentinel("", "127.0.0.1:26379"), "master name cannot be empty"},
+		{"no addresses", DefaultStorageConfig().WithRedisSentinel("mymaster"), "addresses cannot be empty"},
+		{"blank address", DefaultStorageConfig().WithRedisSentinel("mymaster", ""), "address cannot be empty"},
+	}
+	for _, tt := range tests {
+		_, err := NewStorage(tt.cfg.WithType(StorageTypeRedis))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	if _, err := NewRedisStorageFromSentinel("", nil, "", 0, "test:"); err == nil {
+		t.Error("expected error for empty sentinel configuration")
+	}
+}
+
+func TestNewStorageRedisTLS(t *testing.T) {
+	// Borrow the test certificate of an httptest TLS server
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+
+	mr, err := miniredis.RunTLS(srv.TLS)
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	cfg := DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisAddr(mr.Addr()).
+		WithKeyPrefix("test:").
+		WithRedisTLSConfig(&tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12})
+
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		t.Fatalf("failed to create TLS storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("failed to set over TLS: %v", err)
+	}
+
+	// Without TLS the server rejects the connection
+	_, err = NewStorage(cfg.WithRedisTLSConfig(nil).WithRedisDialTimeout(time.Second))
+	if err == nil {
+		t.Error("expected plain connection to a TLS server to fail")
+	}
+}
+
+func TestStorageConfigRedisConnection(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer func() { _ = client.Close() }()
+
+	base := DefaultStorageConfig().WithType(StorageTypeRedis)
+	url := "redis://localhost:6379/0"
+	tests := []struct {
+		name string
+		cfg  StorageConfig
+		want redisConnection
+		err  string
+	}{
+		{"single", base, redisConnectionSingle, ""},
+		{"url wins over addr", base.WithRedisURL(url), redisConnectionURL, ""},
+		{"cluster wins over addr", base.WithRedisCluster("n1:6379"), redisConnectionCluster, ""},
+		{"sentinel wins over addr", base.WithRedisSentinel("mymaster", "s1:26379"), redisConnectionSentinel, ""},
+		{"master name alone selects sentinel", base.WithRedisSentinel("mymaster"), redisConnectionSentinel, ""},
+		{"client wins over everything", base.WithRedisURL(url).WithRedisCluster("n1:6379").WithRedisClient(client), redisConnectionClient, ""},
+		{"url and cluster", base.WithRedisURL(url).WithRedisCluster("n1:6379"), "", "RedisURL and RedisClusterAddrs cannot be combined"},
+		{"url and sentinel", base.WithRedisURL(url).WithRedisSentinel("mymaster", "s1:26379"), "", "RedisURL and Sentinel"},
+		{"cluster and sentinel", base.WithRedisCluster("n1:6379").WithRedisSentinel("mymaster", "s1:26379"), "", "RedisClusterAddrs and Sentinel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.cfg.redisConnection()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				if err := tt.cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ambiguous redis configuration") {
+					t.Errorf("expected Validate to reject the configuration, got %v", err)
+				}
+				return
+			}
+			if err != nil || conn != tt.want {
+				t.Errorf("expected %s, got %s (%v)", tt.want, conn, err)
+			}
+		})
+	}
+}
+
+func TestNewStorageRedisClusterValidation(t *testing.T) {
+	base := DefaultStorageConfig().WithType(StorageTypeRedis)
+	tests := []struct {
+		name string
+		cfg  StorageConfig
+		want string
+	}{
+		{"db", base.WithRedisCluster("n1:6379").WithRedisDB(1), "redis cluster only supports db 0"},
+		{"blank address", base.WithRedisCluster("n1:6379", ""), "redis cluster address cannot be empty"},
+	}
+	for _, tt := range tests {
+		_, err := NewStorage(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestNewStorageRedisClusterOptions(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	cfg := DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisCluster("n1:6379", "n2:6379").
+		WithRedisPassword("secret").
+		WithRedisPoolSize(42).
+		WithRedisReadTimeout(time.Second).
+		WithRedisTLSConfig(tlsConfig)
+
+	opts := redisClusterOptions(cfg)
+	if len(opts.Addrs) != 2 || opts.Addrs[1] != "n2:6379" {
+		t.Errorf("unexpected cluster addresses: %v", opts.Addrs)
+	}
+	if opts.Password != "secret" || opts.PoolSize != 42 || opts.ReadTimeout != time.Second || opts.TLSConfig != tlsConfig {
+		t.Errorf("expected client options to be passed on, got %+v", opts)
+	}
+}
+
+func TestNewStorageRedisCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	storage, err := NewStorage(DefaultStorageConfig().
+		WithType(StorageTypeRedis).
+		WithRedisCluster(mr.Addr()).
+		WithKeyPrefix("test:"))
+	if err != nil {
+		t.Fatalf("failed to create cluster storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	redisStorage := storage.(*RedisStorage)
+	if _, ok := redisStorage.client.(*redis.ClusterClient); !ok {
+		t.Errorf("expected a cluster client, got %T", redisStorage.client)
+	}
+	if err := storage.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Errorf("expected value, got %q", got)
+	}
+	if !mr.Exists("test:key") {
+		t.Error("expected the key in redis")
+	}
+}
@@ -0,0 +1,68 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessiontest"
+)
+
+func TestManagerSaveSessionStorageError(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	errSet := errors.New("storage set failed")
+	storage.FailNextSet(errSet)
+	manager := session.NewManager(storage, session.DefaultConfig())
+
+	err := manager.SaveSession(manager.CreateSession("session-123"))
+	if !errors.Is(err, errSet) {
+		t.Errorf("expected the storage error, got %v", err)
+	}
+}
+
+func TestManagerLoadSessionStorageGetError(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	errGet := errors.New("storage get failed")
+	storage.FailNextGet(errGet)
+	manager := session.NewManager(storage, session.DefaultConfig())
+
+	_, err := manager.LoadSession("any-id")
+	if !errors.Is(err, errGet) {
+		t.Errorf("expected the storage error, got %v", err)
+	}
+}
+
+func TestManagerLoadExpiredSessionFakeClock(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	manager := session.NewManager(storage, session.DefaultConfig().WithExpiration(time.Hour))
+
+	if err := manager.SaveSession(manager.CreateSession("session-123")); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	storage.Clock().Advance(time.Hour)
+
+	loaded, err := manager.LoadSession("session-123")
+	if loaded != nil || err != nil {
+		t.Errorf("expected the session to expire with the storage clock, got %+v (%v)", loaded, err)
+	}
+	if calls := storage.Calls(); len(calls) != 2 || calls[0].TTL <= 0 {
+		t.Errorf("expected a Set with the session TTL and a Get, got %+v", calls)
+	}
+}
+
+func TestKVManager_RefreshError(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	errTouch := errors.New("touch failed")
+	store.FailNext("Touch", errTouch)
+	mgr := session.NewKVManager(store, 5*time.Minute)
+
+	if err := mgr.Refresh(ctx, "any-id", 10*time.Minute); !errors.Is(err, errTouch) {
+		t.Errorf("expected the store error, got %v", err)
+	}
+	if calls := store.Calls(); len(calls) != 1 || calls[0].Method != "Touch" || calls[0].TTL != 10*time.Minute {
+		t.Errorf("expected Refresh to touch the session, got %+v", calls)
+	}
+}
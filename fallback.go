@@ -0,0 +1,299 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fallbackProbeKey is read from primaries that do not implement Pinger to
+// check whether they are back.
+const fallbackProbeKey = "__fallback_probe__"
+
+// FallbackOptions configures FallbackStorage.
+type FallbackOptions struct {
+	// ProbeInterval is how often a degraded primary is checked for recovery.
+	// Default: 1 second
+	ProbeInterval time.Duration
+
+	// OnError is called with errors that are not returned to the caller,
+	// such as a failed write to the secondary.
+	// Default: log the error with the standard logger
+	OnError func(err error)
+}
+
+// DefaultFallbackOptions returns FallbackOptions with default values.
+func DefaultFallbackOptions() FallbackOptions {
+	return FallbackOptions{
+		ProbeInterval: time.Second,
+		OnError: func(err error) {
+			log.Printf("session: fallback storage: %v", err)
+		},
+	}
+}
+
+// WithProbeInterval sets how often a degraded primary is probed.
+func (o FallbackOptions) WithProbeInterval(d time.Duration) FallbackOptions {
+	o.ProbeInterval = d
+	return o
+}
+
+// WithOnError sets the handler for errors that are not returned to the caller.
+func (o FallbackOptions) WithOnError(fn func(err error)) FallbackOptions {
+	o.OnError = fn
+	return o
+}
+
+// FallbackStorage keeps sessions working when the primary storage (usually
+// Redis) fails, by degrading to a secondary storage (usually MemoryStorage).
+//
+// Writes go to both storages; a failed secondary write is only reported to
+// OnError. Reads try the primary and fall back to the secondary on an error
+// or a miss. When the primary fails, the storage is marked degraded and the
+// primary is skipped entirely until a background probe sees it answering again.
+//
+// Consistency caveats:
+//   - The secondary only knows sessions written through this instance. With
+//     several servers behind a load balancer, a degraded server cannot see
+//     sessions created on the others.
+//   - Sessions written while degraded exist only in the secondary and are
+//     lost when the process restarts; they are not copied to the primary on
+//     recovery, but stay readable from the secondary until they expire.
+//   - Deletes made while degraded are remembered and replayed on the primary
+//     before it is used again, so revoked sessions are not resurrected. The
+//     pending deletes are kept in memory and are lost on restart.
+//   - Writes made while degraded do not reach the primary, so after recovery
+//     the primary may serve an older copy of a session that was updated
+//     during the outage.
+type FallbackStorage struct {
+	primary   Storage
+	secondary Storage
+	opts      FallbackOptions
+
+	degraded atomic.Bool
+
+	pendingMu      sync.Mutex
+	pendingDeletes map[string]struct{}
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFallbackStorage creates a FallbackStorage with default options.
+func NewFallbackStorage(primary, secondary Storage) Storage {
+	return NewFallbackStorageWithOptions(primary, secondary, DefaultFallbackOptions())
+}
+
+// NewFallbackStorageWithOptions creates a FallbackStorage with the given options.
+// It starts the probe goroutine, which is stopped by Close.
+func NewFallbackStorageWithOptions(primary, secondary Storage, opts FallbackOptions) Storage {
+	defaults := DefaultFallbackOptions()
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = defaults.ProbeInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = defaults.OnError
+	}
+
+	s := &FallbackStorage{
+		primary:        primary,
+		secondary:      secondary,
+		opts:           opts,
+		pendingDeletes: make(map[string]struct{}),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go s.probe()
+
+	return s
+}
+
+// Degraded reports whether the primary is currently being skipped.
+func (s *FallbackStorage) Degraded() bool {
+	return s.degraded.Load()
+}
+
+// markDegraded switches to the secondary after a primary failure.
+func (s *FallbackStorage) markDegraded(op string, err error) {
+	if !s.degraded.Swap(true) {
+		s.opts.OnError(fmt.Errorf("primary %s failed, degrading to secondary: %w", op, err))
+	}
+}
+
+// probe periodically checks a degraded primary and restores it once it
+// answers and all pending deletes have been replayed.
+func (s *FallbackStorage) probe() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !s.degraded.Load() {
+			continue
+		}
+		if err := s.probePrimary(); err != nil {
+			continue
+		}
+		if s.replayDeletes() {
+			s.degraded.Store(false)
+		}
+	}
+}
+
+// probePrimary checks whether the primary answers again, using Ping if it
+// is available and a read otherwise.
+func (s *FallbackStorage) probePrimary() error {
+	if pinger, ok := s.primary.(Pinger); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.ProbeInterval)
+		defer cancel()
+		return pinger.Ping(ctx)
+	}
+	_, err := s.primary.Get(fallbackProbeKey)
+	return err
+}
+
+// replayDeletes applies deletes made while degraded to the primary and
+// reports whether all of them succeeded.
+func (s *FallbackStorage) replayDeletes() bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	for key := range s.pendingDeletes {
+		if err := s.primary.Delete(key); err != nil {
+			return false
+		}
+		delete(s.pendingDeletes, key)
+	}
+	return true
+}
+
+// addPendingDelete remembers a delete to replay on the primary.
+func (s *FallbackStorage) addPendingDelete(key string) {
+	s.pendingMu.Lock()
+	s.pendingDeletes[key] = struct{}{}
+	s.pendingMu.Unlock()
+}
+
+// Get retrieves the value for the given key from the primary, falling back
+// to the secondary if the primary fails, is degraded, or misses.
+func (s *FallbackStorage) Get(key string) ([]byte, error) {
+	primaryMissed := false
+	var primaryErr error
+	if !s.degraded.Load() {
+		data, err := s.primary.Get(key)
+		if err == nil && data != nil {
+			return data, nil
+		}
+		if err != nil {
+			primaryErr = err
+			s.markDegraded("get", err)
+		} else {
+			primaryMissed = true
+		}
+	}
+
+	data, err := s.secondary.Get(key)
+	if err != nil {
+		// The primary gave a definite answer, so report the miss
+		if primaryMissed {
+			s.opts.OnError(fmt.Errorf("secondary get failed: %w", err))
+			return nil, nil
+		}
+		if primaryErr != nil {
+			return nil, fmt.Errorf("both storages failed: %w", errors.Join(primaryErr, err))
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Set stores the given value in both storages. It only fails if the value
+// could be stored in neither.
+func (s *FallbackStorage) Set(key string, val []byte, exp time.Duration) error {
+	secondaryErr := s.secondary.Set(key, val, exp)
+
+	if s.degraded.Load() {
+		return secondaryErr
+	}
+
+	if err := s.primary.Set(key, val, exp); err != nil {
+		s.markDegraded("set", err)
+		if secondaryErr != nil {
+			return fmt.Errorf("both storages failed: %w", errors.Join(err, secondaryErr))
+		}
+		return nil
+	}
+
+	if secondaryErr != nil {
+		s.opts.OnError(fmt.Errorf("secondary set failed: %w", secondaryErr))
+	}
+	return nil
+}
+
+// Delete removes the value for the given key from both storages. If the
+// primary cannot be reached, the delete is replayed on it before it is
+// used again.
+func (s *FallbackStorage) Delete(key string) error {
+	secondaryErr := s.secondary.Delete(key)
+
+	if s.degraded.Load() {
+		s.addPendingDelete(key)
+		return secondaryErr
+	}
+
+	if err := s.primary.Delete(key); err != nil {
+		s.addPendingDelete(key)
+		s.markDegraded("delete", err)
+	}
+	return secondaryErr
+}
+
+// Reset removes all keys from both storages. Errors from the primary are returned.
+func (s *FallbackStorage) Reset() error {
+	if err := s.secondary.Reset(); err != nil {
+		s.opts.OnError(fmt.Errorf("secondary reset failed: %w", err))
+	}
+	return s.primary.Reset()
+}
+
+// Ping succeeds as long as sessions can be served: it pings the primary
+// unless degraded, and the secondary if the primary is degraded or fails.
+// Storages that do not implement Pinger are assumed to be healthy.
+func (s *FallbackStorage) Ping(ctx context.Context) error {
+	var primaryErr error
+	if !s.degraded.Load() {
+		if primaryErr = pingStorage(ctx, s.primary); primaryErr == nil {
+			return nil
+		}
+	}
+
+	err := pingStorage(ctx, s.secondary)
+	if err != nil && primaryErr != nil {
+		return fmt.Errorf("both storages failed: %w", errors.Join(primaryErr, err))
+	}
+	return err
+}
+
+// Close stops the probe goroutine and closes both storages.
+// It is safe to call more than once.
+func (s *FallbackStorage) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = errors.Join(s.primary.Close(), s.secondary.Close())
+	})
+	return err
+}
@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errorCollector records errors reported through FallbackOptions.OnError.
+type errorCollector struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (c *errorCollector) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func (c *errorCollector) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.errs)
+}
+
+func newTestFallback(t *testing.T) (*FallbackStorage, *switchStorage, *MemoryStorage, *errorCollector) {
+	t.Helper()
+	primary := &switchStorage{Storage: NewMemoryStorage("primary:", 0)}
+	secondary := NewMemoryStorage("secondary:", 0)
+	errs := &errorCollector{}
+
+	opts := DefaultFallbackOptions().
+		WithProbeInterval(5 * time.Millisecond).
+		WithOnError(errs.record)
+	storage := NewFallbackStorageWithOptions(primary, secondary, opts).(*FallbackStorage)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	return storage, primary, secondary, errs
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFallbackStoragePrimaryDiesMidSession(t *testing.T) {
+	storage, primary, _, errs := newTestFallback(t)
+	manager := NewManager(storage, DefaultConfig())
+
+	session := manager.CreateSession("session-123")
+	session.SetValue("user", "alice")
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	// Redis goes away; the session keeps working from memory
+	primary.down.Store(true)
+
+	loaded, err := manager.LoadSession("session-123")
+	if err != nil || loaded == nil {
+		t.Fatalf("expected session from secondary, got %v (%v)", loaded, err)
+	}
+	if v, _ := loaded.GetValue("user"); v != "alice" {
+		t.Errorf("expected user alice, got %v", v)
+	}
+	if !storage.Degraded() {
+		t.Error("expected storage to be degraded")
+	}
+	if errs.len() != 1 {
+		t.Errorf("expected the degradation to be reported once, got %d", errs.len())
+	}
+
+	// A degraded primary is skipped without being called
+	calls := primary.calls.Load()
+	loaded.SetValue("cart", 3)
+	if err := manager.SaveSession(loaded); err != nil {
+		t.Fatalf("expected save to succeed while degraded, got %v", err)
+	}
+	if loaded, _ = manager.LoadSession("session-123"); loaded == nil {
+		t.Fatal("expected session to still load while degraded")
+	}
+	// Only the probe may have touched the primary
+	if extra := primary.calls.Load() - calls; extra > 5 {
+		t.Errorf("expected requests to skip the degraded primary, got %d calls", extra)
+	}
+
+	// Redis comes back and the storage recovers
+	primary.down.Store(false)
+	waitFor(t, func() bool { return !storage.Degraded() })
+
+	if loaded, _ = manager.LoadSession("session-123"); loaded == nil {
+		t.Error("expected session after recovery")
+	}
+}
+
+func TestFallbackStorageDeleteNotResurrected(t *testing.T) {
+	storage, primary, secondary, _ := newTestFallback(t)
+
+	_ = storage.Set("session-123", []byte("data"), time.Hour)
+
+	// The session is revoked while the primary is down
+	primary.down.Store(true)
+	if err := storage.Delete("session-123"); err != nil {
+		t.Fatalf("expected delete to succeed on the secondary, got %v", err)
+	}
+	if got, _ := secondary.Get("session-123"); got != nil {
+		t.Error("expected secondary copy to be deleted")
+	}
+
+	primary.down.Store(false)
+	waitFor(t, func() bool { return !storage.Degraded() })
+
+	if got, _ := storage.Get("session-123"); got != nil {
+		t.Error("expected revoked session to stay deleted after recovery")
+	}
+	if got, _ := primary.Storage.Get("session-123"); got != nil {
+		t.Error("expected pending delete to be replayed on the primary")
+	}
+}
+
+func TestFallbackStorageSecondaryFailureOnlyReported(t *testing.T) {
+	primary := NewMemoryStorage("primary:", 0)
+	secondary := NewMemoryStorage("secondary:", 0)
+	_ = secondary.Close()
+
+	errs := &errorCollector{}
+	storage := NewFallbackStorageWithOptions(primary, secondary, DefaultFallbackOptions().WithOnError(errs.record))
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("expected secondary failure to not fail the write, got %v", err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Errorf("expected value from primary, got %s", string(got))
+	}
+	if errs.len() != 1 {
+		t.Errorf("expected secondary failure to be reported, got %d errors", errs.len())
+	}
+}
+
+func TestFallbackStorageBothFail(t *testing.T) {
+	storage, primary, secondary, _ := newTestFallback(t)
+
+	primary.down.Store(true)
+	_ = secondary.Close()
+
+	if err := storage.Set("key", []byte("value"), time.Hour); err == nil {
+		t.Error("expected error when both storages fail")
+	}
+	if _, err := storage.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected secondary error once degraded, got %v", err)
+	}
+}
+
+func TestFallbackStorageCloseIdempotent(t *testing.T) {
+	storage := NewFallbackStorage(NewMemoryStorage("a:", 0), NewMemoryStorage("b:", 0))
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Errorf("unexpected error on second close: %v", err)
+	}
+}
+
+func TestFallbackStoragePing(t *testing.T) {
+	primary := NewMemoryStorage("primary:", 0)
+	secondary := NewMemoryStorage("secondary:", 0)
+	storage := NewFallbackStorageWithOptions(primary, secondary, DefaultFallbackOptions().WithOnError(func(error) {}))
+	defer func() { _ = storage.Close() }()
+
+	pinger := storage.(Pinger)
+	if err := pinger.Ping(context.Background()); err != nil {
+		t.Fatalf("expected ping to succeed, got %v", err)
+	}
+
+	// The secondary keeps sessions available while the primary is down
+	_ = primary.Close()
+	if err := pinger.Ping(context.Background()); err != nil {
+		t.Errorf("expected ping to succeed through the secondary, got %v", err)
+	}
+
+	_ = secondary.Close()
+	if err := pinger.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ping to fail when both storages are down, got %v", err)
+	}
+}
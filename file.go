@@ -0,0 +1,453 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fileMagic starts every file written by FileStorage.
+const fileMagic = "SKF1"
+
+// fileHeaderSize is the size of the header preceding the value: the magic
+// followed by the expiry as big-endian Unix nanoseconds, 0 meaning never.
+const fileHeaderSize = len(fileMagic) + 8
+
+// fileTempPrefix starts the names of files being written. They are renamed
+// into place once complete and skipped by Get, Reset and the sweep.
+const fileTempPrefix = ".tmp-"
+
+// fileStaleTempAge is the age after which the sweep removes temporary files
+// left behind by a crash.
+const fileStaleTempAge = time.Hour
+
+// fileLockStripes is the number of mutexes serializing changes to files.
+const fileLockStripes = 64
+
+// FileStorageConfig represents configuration for FileStorage.
+type FileStorageConfig struct {
+	// Dir is the directory holding the session files. It is created if it
+	// does not exist. Required.
+	Dir string
+
+	// KeyPrefix is the prefix for session keys.
+	// Default: "session:"
+	KeyPrefix string
+
+	// GCInterval is how often expired files are swept.
+	// Set to 0 to disable the sweep; expired files are still removed on access.
+	// Default: 10 minutes
+	GCInterval time.Duration
+
+	// Sync makes every write fsync the file and its directory before
+	// returning, so that acknowledged writes survive a power loss.
+	// Default: false
+	Sync bool
+}
+
+// DefaultFileStorageConfig returns a FileStorageConfig with default values.
+func DefaultFileStorageConfig() FileStorageConfig {
+	return FileStorageConfig{
+		KeyPrefix:  "session:",
+		GCInterval: 10 * time.Minute,
+	}
+}
+
+// WithDir sets the directory holding the session files.
+func (c FileStorageConfig) WithDir(dir string) FileStorageConfig {
+	c.Dir = dir
+	return c
+}
+
+// WithKeyPrefix sets the key prefix.
+func (c FileStorageConfig) WithKeyPrefix(prefix string) FileStorageConfig {
+	c.KeyPrefix = prefix
+	return c
+}
+
+// WithGCInterval sets the sweep interval.
+func (c FileStorageConfig) WithGCInterval(interval time.Duration) FileStorageConfig {
+	c.GCInterval = interval
+	return c
+}
+
+// WithSync sets whether writes are fsynced.
+func (c FileStorageConfig) WithSync(sync bool) FileStorageConfig {
+	c.Sync = sync
+	return c
+}
+
+// FileStorage implements Storage interface with one file per key, so that
+// sessions survive restarts of single-instance deployments without Redis.
+//
+// Each key prefix gets its own subdirectory of Dir, named after a hash of
+// the prefix, and each key a file named after the SHA-256 hash of the key,
+// so keys never need escaping. Files start with a small header holding the
+// expiry. Writes go to a temporary file renamed into place, so readers never
+// see a partial value. Expired files are removed lazily on Get and by a
+// periodic sweep.
+//
+// Several FileStorage instances, even in different processes, may share a
+// directory, but the sweep of each one only covers its own prefix.
+type FileStorage struct {
+	dir       string
+	keyPrefix string
+	config    FileStorageConfig
+
+	locks [fileLockStripes]sync.Mutex
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+	gcStop    chan struct{}
+	gcDone    chan struct{}
+}
+
+// NewFileStorage creates a new file storage under dir.
+// The gcInterval parameter specifies how often to sweep expired files.
+// If gcInterval is 0, the sweep is disabled.
+func NewFileStorage(dir, keyPrefix string, gcInterval time.Duration) (*FileStorage, error) {
+	cfg := DefaultFileStorageConfig().
+		WithDir(dir).
+		WithKeyPrefix(keyPrefix).
+		WithGCInterval(gcInterval)
+	return NewFileStorageWithConfig(cfg)
+}
+
+// NewFileStorageWithConfig creates a new file storage using configuration.
+func NewFileStorageWithConfig(cfg FileStorageConfig) (*FileStorage, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("file storage directory cannot be empty")
+	}
+
+	keyPrefix := cfg.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = "session:"
+	} else if keyPrefix[len(keyPrefix)-1] != ':' {
+		keyPrefix += ":"
+	}
+	cfg.KeyPrefix = keyPrefix
+
+	prefixHash := sha256.Sum256([]byte(keyPrefix))
+	dir := filepath.Join(cfg.Dir, hex.EncodeToString(prefixHash[:8]))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create file storage directory: %w", err)
+	}
+
+	s := &FileStorage{
+		dir:       dir,
+		keyPrefix: keyPrefix,
+		config:    cfg,
+	}
+
+	if cfg.GCInterval > 0 {
+		s.gcStop = make(chan struct{})
+		s.gcDone = make(chan struct{})
+		go s.runGC(cfg.GCInterval)
+	}
+
+	return s, nil
+}
+
+// GetKeyPrefix returns the key prefix used by this storage.
+func (s *FileStorage) GetKeyPrefix() string {
+	return s.keyPrefix
+}
+
+// fileName returns the name of the file holding key.
+func (s *FileStorage) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// lock returns the mutex serializing changes to the named file.
+func (s *FileStorage) lock(name string) *sync.Mutex {
+	return &s.locks[fnv32a(name)%fileLockStripes]
+}
+
+// readFile reads the named file and returns its value and expiry.
+// Returns nil data if the file does not exist.
+func (s *FileStorage) readFile(name string) ([]byte, time.Time, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, time.Time{}, nil
+		}
+		return nil, time.Time{}, fmt.Errorf("failed to read session file: %w", err)
+	}
+	if len(raw) < fileHeaderSize || string(raw[:len(fileMagic)]) != fileMagic {
+		return nil, time.Time{}, fmt.Errorf("invalid session file %s", name)
+	}
+
+	var expiresAt time.Time
+	if nanos := int64(binary.BigEndian.Uint64(raw[len(fileMagic):fileHeaderSize])); nanos != 0 {
+		expiresAt = time.Unix(0, nanos)
+	}
+	return raw[fileHeaderSize:], expiresAt, nil
+}
+
+// isExpiredAt reports whether an expiry lies in the past. The zero time
+// never expires.
+func isExpiredAt(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
+
+// removeIfExpired removes the named file if it is still expired once its
+// lock is held, since it may have been rewritten in the meantime, and
+// reports whether it did.
+func (s *FileStorage) removeIfExpired(name string) (bool, error) {
+	mu := s.lock(name)
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, expiresAt, err := s.readFile(name)
+	if err != nil || data == nil || !isExpiredAt(expiresAt) {
+		return false, err
+	}
+	return true, s.remove(name)
+}
+
+// remove deletes the named file, ignoring files that do not exist.
+func (s *FileStorage) remove(name string) error {
+	err := os.Remove(filepath.Join(s.dir, name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove session file: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves the value for the given key.
+// Returns nil, nil if the key does not exist or has expired.
+func (s *FileStorage) Get(key string) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	name := s.fileName(key)
+	data, expiresAt, err := s.readFile(name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if isExpiredAt(expiresAt) {
+		_, err := s.removeIfExpired(name)
+		return nil, err
+	}
+	return data, nil
+}
+
+// Set stores the given value for the given key along with an expiration value.
+// If expiration is 0, the value never expires.
+// Empty key or value will be ignored without an error.
+func (s *FileStorage) Set(key string, val []byte, exp time.Duration) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	var nanos int64
+	if exp > 0 {
+		nanos = time.Now().Add(exp).UnixNano()
+	}
+	raw := make([]byte, fileHeaderSize, fileHeaderSize+len(val))
+	copy(raw, fileMagic)
+	binary.BigEndian.PutUint64(raw[len(fileMagic):], uint64(nanos))
+	raw = append(raw, val...)
+
+	name := s.fileName(key)
+	mu := s.lock(name)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return s.writeFile(name, raw)
+}
+
+// writeFile atomically replaces the named file with raw.
+func (s *FileStorage) writeFile(name string, raw []byte) error {
+	tmp, err := os.CreateTemp(s.dir, fileTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create session file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// No-op once the file has been renamed
+		_ = os.Remove(tmpName)
+	}()
+
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if s.config.Sync {
+		if err := tmp.Sync(); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("failed to sync session file: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	if err := os.Rename(tmpName, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+
+	if s.config.Sync {
+		return s.syncDir()
+	}
+	return nil
+}
+
+// syncDir fsyncs the storage directory so renames are durable.
+func (s *FileStorage) syncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to sync session directory: %w", err)
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync session directory: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the value for the given key.
+// It returns no error if the storage does not contain the key.
+func (s *FileStorage) Delete(key string) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	name := s.fileName(key)
+	mu := s.lock(name)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return s.remove(name)
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *FileStorage) Reset() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	names, err := s.listFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		mu := s.lock(name)
+		mu.Lock()
+		if err := s.remove(name); err != nil {
+			errs = append(errs, err)
+		}
+		mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// listFiles returns the names of the session files, without temporary files.
+func (s *FileStorage) listFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session files: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// runGC sweeps expired files periodically until Close.
+func (s *FileStorage) runGC(interval time.Duration) {
+	defer close(s.gcDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = s.gc()
+		case <-s.gcStop:
+			return
+		}
+	}
+}
+
+// gc removes expired files and stale temporary files, and returns the
+// number of expired files removed.
+func (s *FileStorage) gc() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list session files: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+
+		if strings.HasPrefix(name, fileTempPrefix) {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > fileStaleTempAge {
+				_ = os.Remove(filepath.Join(s.dir, name))
+			}
+			continue
+		}
+
+		data, expiresAt, err := s.readFile(name)
+		if err != nil || data == nil || !isExpiredAt(expiresAt) {
+			continue
+		}
+		if ok, err := s.removeIfExpired(name); ok && err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Ping checks that the storage is open and its directory is accessible.
+func (s *FileStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.dir); err != nil {
+		return fmt.Errorf("file storage directory is not accessible: %w", err)
+	}
+	return nil
+}
+
+// Close stops the sweep. Close is idempotent; files are kept on disk.
+// After Close, Get, Set, Delete and Reset return ErrClosed.
+func (s *FileStorage) Close() error {
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		if s.gcStop != nil {
+			close(s.gcStop)
+			<-s.gcDone
+		}
+	})
+	return nil
+}
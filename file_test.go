@@ -0,0 +1,246 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStoragePersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	storage, err := NewFileStorageWithConfig(DefaultFileStorageConfig().WithDir(dir).WithKeyPrefix("test").WithSync(true))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	if storage.GetKeyPrefix() != "test:" {
+		t.Errorf("expected prefix 'test:', got %s", storage.GetKeyPrefix())
+	}
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Set("forever", []byte("value"), 0)
+	_ = storage.Close()
+
+	if _, err := storage.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	reopened, err := NewFileStorage(dir, "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+
+	for _, key := range []string{"key", "forever"} {
+		got, err := reopened.Get(key)
+		if err != nil || string(got) != "value" {
+			t.Errorf("expected %s to survive the restart, got %q (%v)", key, got, err)
+		}
+	}
+	if got, _ := reopened.Get("missing"); got != nil {
+		t.Errorf("expected nil for missing key, got %q", got)
+	}
+}
+
+func TestFileStorageExpiry(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("short", []byte("value"), 20*time.Millisecond)
+	_ = storage.Set("swept", []byte("value"), 20*time.Millisecond)
+	_ = storage.Set("long", []byte("value"), time.Hour)
+	time.Sleep(50 * time.Millisecond)
+
+	// Lazy expiry removes the file on access
+	if got, err := storage.Get("short"); got != nil || err != nil {
+		t.Errorf("expected expired key to be gone, got %q (%v)", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(storage.dir, storage.fileName("short"))); !os.IsNotExist(err) {
+		t.Errorf("expected expired file to be removed, got %v", err)
+	}
+
+	// The sweep removes the rest, along with stale temporary files
+	stale := filepath.Join(storage.dir, fileTempPrefix+"stale")
+	_ = os.WriteFile(stale, []byte("partial"), 0o600)
+	old := time.Now().Add(-2 * fileStaleTempAge)
+	_ = os.Chtimes(stale, old, old)
+
+	removed, err := storage.gc()
+	if err != nil || removed != 1 {
+		t.Errorf("expected 1 file swept, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected stale temporary file to be removed, got %v", err)
+	}
+	names, _ := storage.listFiles()
+	if len(names) != 1 {
+		t.Errorf("expected only the unexpired file to remain, got %v", names)
+	}
+}
+
+func TestFileStorageGCLoop(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 5*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		names, _ := storage.listFiles()
+		if len(names) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sweep to remove the expired file")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFileStorageReset(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir, "a:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+	other, err := NewFileStorage(dir, "b:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = other.Close() }()
+
+	_ = storage.Set("key1", []byte("value"), time.Hour)
+	_ = storage.Set("key2", []byte("value"), 0)
+	_ = other.Set("key1", []byte("other"), time.Hour)
+
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if got, _ := storage.Get("key1"); got != nil {
+		t.Error("expected key1 to be reset")
+	}
+	if got, _ := storage.Get("key2"); got != nil {
+		t.Error("expected key2 to be reset")
+	}
+	if got, _ := other.Get("key1"); string(got) != "other" {
+		t.Errorf("expected other prefix to survive reset, got %q", got)
+	}
+}
+
+func TestFileStorageDelete(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if err := storage.Delete("key"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if got, _ := storage.Get("key"); got != nil {
+		t.Error("expected key to be deleted")
+	}
+	if err := storage.Delete("missing"); err != nil {
+		t.Errorf("expected no error deleting a missing key, got %v", err)
+	}
+
+	// Empty key or value is ignored
+	if err := storage.Set("", []byte("value"), 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := storage.Set("empty", nil, 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if names, _ := storage.listFiles(); len(names) != 0 {
+		t.Errorf("expected no files, got %v", names)
+	}
+}
+
+func TestFileStorageCorruptFile(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = os.WriteFile(filepath.Join(storage.dir, storage.fileName("key")), []byte("garbage"), 0o600)
+	if _, err := storage.Get("key"); err == nil {
+		t.Error("expected error for a corrupt file")
+	}
+}
+
+func TestFileStorageConcurrent(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				key := fmt.Sprintf("key%d", j%5)
+				value := fmt.Sprintf("value-%d-%d", i, j)
+				if err := storage.Set(key, []byte(value), time.Hour); err != nil {
+					t.Errorf("failed to set: %v", err)
+					return
+				}
+				if _, err := storage.Get(key); err != nil {
+					t.Errorf("failed to get: %v", err)
+					return
+				}
+				if j%10 == 0 {
+					_ = storage.Delete(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, _ := os.ReadDir(storage.dir)
+	for _, entry := range entries {
+		if len(entry.Name()) > 0 && entry.Name()[0] == '.' {
+			t.Errorf("expected no temporary files left, got %s", entry.Name())
+		}
+	}
+}
+
+func TestFileStoragePing(t *testing.T) {
+	storage, err := NewFileStorage(t.TempDir(), "test:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error on ping: %v", err)
+	}
+	_ = os.RemoveAll(storage.dir)
+	if err := storage.Ping(context.Background()); err == nil {
+		t.Error("expected error when the directory is gone")
+	}
+	_ = storage.Close()
+	if err := storage.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestNewFileStorageEmptyDir(t *testing.T) {
+	if _, err := NewFileStorage("", "test:", 0); err == nil {
+		t.Error("expected error for empty directory")
+	}
+}
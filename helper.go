@@ -0,0 +1,110 @@
+package session
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// SessionCallback is called by Helper when a user logs in or out.
+type SessionCallback func(c *fiber.Ctx, sess *fibersession.Session)
+
+// Helper wraps a fiber session store to log users in and out like
+// AuthenticateWithOptions and Unauthenticate, calling the callbacks
+// registered with OnAuthenticate and OnLogout, such as for audit logging.
+// A Helper is safe for concurrent use.
+type Helper struct {
+	store *fibersession.Store
+
+	mu             sync.RWMutex
+	onAuthenticate []SessionCallback
+	onLogout       []SessionCallback
+}
+
+// NewHelper creates a new Helper for store.
+func NewHelper(store *fibersession.Store) *Helper {
+	return &Helper{store: store}
+}
+
+// Store returns the store of the helper.
+func (h *Helper) Store() *fibersession.Store {
+	return h.store
+}
+
+// OnAuthenticate registers fn to be called after Authenticate logged a user
+// in. Callbacks run in registration order; panics are recovered.
+func (h *Helper) OnAuthenticate(fn SessionCallback) {
+	if fn == nil {
+		return
+	}
+	h.mu.Lock()
+	h.onAuthenticate = append(h.onAuthenticate, fn)
+	h.mu.Unlock()
+}
+
+// OnLogout registers fn to be called when Unauthenticate logs a user out.
+// Callbacks run in registration order; panics are recovered.
+func (h *Helper) OnLogout(fn SessionCallback) {
+	if fn == nil {
+		return
+	}
+	h.mu.Lock()
+	h.onLogout = append(h.onLogout, fn)
+	h.mu.Unlock()
+}
+
+// Authenticate logs in the session of the request like
+// AuthenticateWithOptions. The OnAuthenticate callbacks are called once the
+// session is marked as authenticated under its new ID, just before it is
+// saved, so values they set are saved with it; they must not save or
+// destroy it themselves. Callbacks are not called if opts is invalid or the
+// session ID cannot be regenerated.
+func (h *Helper) Authenticate(c *fiber.Ctx, opts AuthOptions) error {
+	sess, err := h.store.Get(c)
+	if err != nil {
+		return err
+	}
+	if err := applyAuthOptions(sess, opts); err != nil {
+		return err
+	}
+	h.fire(h.callbacks(&h.onAuthenticate), c, sess)
+	return saveFiberSession(sess)
+}
+
+// Unauthenticate logs out the session of the request like the
+// Unauthenticate function, deleting its cookie with CreateExpiredCookie.
+// The OnLogout callbacks are called first, while
+// the session still holds the user's data; they must not save or destroy
+// it themselves.
+func (h *Helper) Unauthenticate(c *fiber.Ctx) error {
+	sess, err := h.store.Get(c)
+	if err != nil {
+		return err
+	}
+	h.fire(h.callbacks(&h.onLogout), c, sess)
+	if err := Unauthenticate(sess); err != nil {
+		return err
+	}
+	expireStoreCookie(c, h.store)
+	return nil
+}
+
+// callbacks returns a snapshot of the callbacks in list.
+func (h *Helper) callbacks(list *[]SessionCallback) []SessionCallback {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return *list
+}
+
+// fire calls each callback with c and sess, recovering panics.
+func (h *Helper) fire(callbacks []SessionCallback, c *fiber.Ctx, sess *fibersession.Session) {
+	for _, fn := range callbacks {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			fn(c, sess)
+		}()
+	}
+}
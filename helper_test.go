@@ -0,0 +1,92 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestHelperCallbacks(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	helper := NewHelper(fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour}))
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) SessionCallback {
+		return func(c *fiber.Ctx, sess *fibersession.Session) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event+" "+sess.ID()+" "+GetUserID(sess))
+		}
+	}
+	helper.OnAuthenticate(func(c *fiber.Ctx, sess *fibersession.Session) {
+		panic("callback panic")
+	})
+	helper.OnAuthenticate(record("login"))
+	helper.OnLogout(record("logout"))
+	helper.OnLogout(nil)
+
+	app.Get("/login", func(c *fiber.Ctx) error {
+		return helper.Authenticate(c, AuthOptions{UserID: c.Query("user")})
+	})
+	app.Get("/logout", func(c *fiber.Ctx) error {
+		return helper.Unauthenticate(c)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login?user=user-1", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(cookie)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("failed to log out: %v", err)
+	}
+
+	// Logging in without a user ID fails and fires nothing
+	resp, err = app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("expected login without user to fail, got %d", resp.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"login " + cookie.Value + " user-1",
+		"logout " + cookie.Value + " user-1",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("expected event %q, got %q", want[i], events[i])
+		}
+	}
+
+	if data, _ := storage.Get(cookie.Value); data != nil {
+		t.Error("expected the session to be deleted on logout")
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// SessionInfo is the JSON body returned by the handler of
+// NewSessionInfoHandler. Fields that are not set in the session are
+// omitted.
+type SessionInfo struct {
+	Authenticated bool       `json:"authenticated"`
+	UserID        string     `json:"user_id,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	AMR           []string   `json:"amr,omitempty"`
+	Scopes        []string   `json:"scopes,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	LastAccess    *time.Time `json:"last_access,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// InfoOptions configures the handler returned by NewSessionInfoHandler.
+type InfoOptions struct {
+	// MaskEmail replaces the local part of the email but its first
+	// character with "***", such as "a***@example.com".
+	// Default: false
+	MaskEmail bool
+
+	// RequireAuth makes unauthenticated sessions get a 401 with
+	// ErrorCodeUnauthenticated instead of a SessionInfo with Authenticated
+	// false.
+	// Default: false
+	RequireAuth bool
+}
+
+// DefaultInfoOptions returns an InfoOptions with default values.
+func DefaultInfoOptions() InfoOptions {
+	return InfoOptions{}
+}
+
+// WithMaskEmail sets whether the email is masked.
+func (o InfoOptions) WithMaskEmail(mask bool) InfoOptions {
+	o.MaskEmail = mask
+	return o
+}
+
+// WithRequireAuth sets whether unauthenticated sessions get a 401.
+func (o InfoOptions) WithRequireAuth(require bool) InfoOptions {
+	o.RequireAuth = require
+	return o
+}
+
+// NewSessionInfoHandler returns a handler responding with the public state
+// of the session of the request as a SessionInfo, for frontends to tell who
+// is logged in. The expiration time is reported if the storage of store can
+// tell the remaining TTL of a key, like ExtendedStorage.
+func NewSessionInfoHandler(store *fibersession.Store, opts InfoOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			if opts.RequireAuth {
+				return c.Status(fiber.StatusUnauthorized).JSON(AuthErrorResponse{Error: ErrorCodeUnauthenticated})
+			}
+			return c.JSON(SessionInfo{})
+		}
+
+		info := SessionInfo{
+			Authenticated: true,
+			UserID:        GetUserID(sess),
+			Email:         GetEmail(sess),
+			AMR:           GetAMR(sess),
+			Scopes:        GetScopes(sess),
+			CreatedAt:     timePtr(GetCreatedAt(sess)),
+			LastAccess:    timePtr(GetLastAccess(sess)),
+		}
+		if opts.MaskEmail {
+			info.Email = maskEmail(info.Email)
+		}
+		if getter, ok := store.Storage.(interface {
+			GetTTL(key string) (time.Duration, error)
+		}); ok && !sess.Fresh() {
+			if ttl, err := getter.GetTTL(sess.ID()); err == nil && ttl > 0 {
+				info.ExpiresAt = timePtr(time.Now().Add(ttl).Truncate(time.Second))
+			}
+		}
+		return c.JSON(info)
+	}
+}
+
+// timePtr returns a pointer to t, or nil if t is zero.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// maskEmail keeps the first character of the local part of email and the
+// domain, replacing the rest with "***".
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return email
+	}
+	return local[:1] + "***@" + domain
+}
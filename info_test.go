@@ -0,0 +1,110 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestSessionInfoHandler(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	t.Cleanup(func() { _ = storage.Close() })
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return AuthenticateWithOptions(sess, AuthOptions{
+			UserID: "user-1",
+			Email:  "alice@example.com",
+			AMR:    []string{"pwd"},
+		})
+	})
+	app.Get("/info", NewSessionInfoHandler(store, DefaultInfoOptions()))
+	app.Get("/masked", NewSessionInfoHandler(store, DefaultInfoOptions().WithMaskEmail(true)))
+	app.Get("/strict", NewSessionInfoHandler(store, DefaultInfoOptions().WithRequireAuth(true)))
+
+	get := func(path string, cookie *http.Cookie) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode %s: %v", path, err)
+		}
+		return resp.StatusCode, body
+	}
+
+	if status, body := get("/info", nil); status != fiber.StatusOK || len(body) != 1 || body["authenticated"] != false {
+		t.Errorf("expected only authenticated=false, got %d %v", status, body)
+	}
+	if status, body := get("/strict", nil); status != fiber.StatusUnauthorized || body["error"] != ErrorCodeUnauthenticated {
+		t.Errorf("expected 401 with RequireAuth, got %d %v", status, body)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	status, body := get("/info", cookie)
+	if status != fiber.StatusOK || body["authenticated"] != true || body["user_id"] != "user-1" || body["email"] != "alice@example.com" {
+		t.Errorf("unexpected session info %d %v", status, body)
+	}
+	if _, ok := body["scopes"]; ok {
+		t.Errorf("expected unset scopes to be omitted, got %v", body)
+	}
+	for _, field := range []string{"amr", "created_at", "last_access", "expires_at"} {
+		if _, ok := body[field]; !ok {
+			t.Errorf("expected %s in %v", field, body)
+		}
+	}
+	var info SessionInfo
+	raw, _ := json.Marshal(body)
+	if err := json.Unmarshal(raw, &info); err != nil || info.ExpiresAt == nil || time.Until(*info.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expected the expiration of the session, got %+v, %v", info, err)
+	}
+
+	if _, body := get("/masked", cookie); body["email"] != "a***@example.com" {
+		t.Errorf("expected a masked email, got %v", body["email"])
+	}
+	if status, _ := get("/strict", cookie); status != fiber.StatusOK {
+		t.Errorf("expected authenticated sessions through RequireAuth, got %d", status)
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	for email, want := range map[string]string{
+		"alice@example.com": "a***@example.com",
+		"a@example.com":     "a***@example.com",
+		"@example.com":      "@example.com",
+		"not-an-email":      "not-an-email",
+	} {
+		if got := maskEmail(email); got != want {
+			t.Errorf("maskEmail(%q) = %q, want %q", email, got, want)
+		}
+	}
+}
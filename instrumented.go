@@ -0,0 +1,134 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// InstrumentedStorage wraps a Storage and reports the name, duration and
+// error of every operation to an observer, e.g. to export latency histograms
+// and error counters for any backend. Operation names match those reported
+// by RedisStorage.WithLatencyObserver: get, set, delete, reset, ping, close,
+// and exists, get_ttl, expire and touch for ExtendedStorage.
+//
+// Wrapping hides the concrete type of the inner storage, so assertions such
+// as storage.(*RedisStorage) fail on the wrapper. The wrapper implements
+// ExtendedStorage only if the inner storage does; other optional interfaces
+// are not forwarded. Use Unwrap to reach the inner storage.
+type InstrumentedStorage struct {
+	inner    Storage
+	observer func(op string, d time.Duration, err error)
+}
+
+// instrumentedExtendedStorage is the InstrumentedStorage returned for inner
+// storages implementing ExtendedStorage.
+type instrumentedExtendedStorage struct {
+	*InstrumentedStorage
+	extended ExtendedStorage
+}
+
+// NewInstrumentedStorage wraps inner so that obs is called after every
+// operation with its name, duration and error. Errors are returned to the
+// caller unchanged. If obs is nil, inner is returned as is.
+func NewInstrumentedStorage(inner Storage, obs func(op string, d time.Duration, err error)) Storage {
+	if obs == nil {
+		return inner
+	}
+
+	s := &InstrumentedStorage{inner: inner, observer: obs}
+	if extended, ok := inner.(ExtendedStorage); ok {
+		return &instrumentedExtendedStorage{InstrumentedStorage: s, extended: extended}
+	}
+	return s
+}
+
+// Unwrap returns the inner storage.
+func (s *InstrumentedStorage) Unwrap() Storage {
+	return s.inner
+}
+
+// observe reports an operation that started at start.
+func (s *InstrumentedStorage) observe(op string, start time.Time, err error) {
+	s.observer(op, time.Since(start), err)
+}
+
+// Get retrieves the value for the given key.
+func (s *InstrumentedStorage) Get(key string) ([]byte, error) {
+	start := time.Now()
+	data, err := s.inner.Get(key)
+	s.observe("get", start, err)
+	return data, err
+}
+
+// Set stores the given value for the given key.
+func (s *InstrumentedStorage) Set(key string, val []byte, exp time.Duration) error {
+	start := time.Now()
+	err := s.inner.Set(key, val, exp)
+	s.observe("set", start, err)
+	return err
+}
+
+// Delete removes the value for the given key.
+func (s *InstrumentedStorage) Delete(key string) error {
+	start := time.Now()
+	err := s.inner.Delete(key)
+	s.observe("delete", start, err)
+	return err
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *InstrumentedStorage) Reset() error {
+	start := time.Now()
+	err := s.inner.Reset()
+	s.observe("reset", start, err)
+	return err
+}
+
+// Ping checks the inner storage. Storages that do not implement Pinger are
+// assumed to be healthy.
+func (s *InstrumentedStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := pingStorage(ctx, s.inner)
+	s.observe("ping", start, err)
+	return err
+}
+
+// Close closes the inner storage.
+func (s *InstrumentedStorage) Close() error {
+	start := time.Now()
+	err := s.inner.Close()
+	s.observe("close", start, err)
+	return err
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *instrumentedExtendedStorage) Exists(key string) (bool, error) {
+	start := time.Now()
+	ok, err := s.extended.Exists(key)
+	s.observe("exists", start, err)
+	return ok, err
+}
+
+// GetTTL returns the remaining TTL for the key.
+func (s *instrumentedExtendedStorage) GetTTL(key string) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := s.extended.GetTTL(key)
+	s.observe("get_ttl", start, err)
+	return ttl, err
+}
+
+// Expire sets a new expiration on the key.
+func (s *instrumentedExtendedStorage) Expire(key string, exp time.Duration) error {
+	start := time.Now()
+	err := s.extended.Expire(key, exp)
+	s.observe("expire", start, err)
+	return err
+}
+
+// Touch sets a new expiration on the key and reports whether it existed.
+func (s *instrumentedExtendedStorage) Touch(key string, exp time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := s.extended.Touch(key, exp)
+	s.observe("touch", start, err)
+	return ok, err
+}
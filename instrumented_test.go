@@ -0,0 +1,125 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// opRecorder collects the operations reported by an InstrumentedStorage.
+type opRecorder struct {
+	mu   sync.Mutex
+	ops  []string
+	errs []error
+}
+
+func (r *opRecorder) observe(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d < 0 {
+		panic("negative duration")
+	}
+	r.ops = append(r.ops, op)
+	r.errs = append(r.errs, err)
+}
+
+// count returns how many times op was recorded.
+func (r *opRecorder) count(op string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, recorded := range r.ops {
+		if recorded == op {
+			n++
+		}
+	}
+	return n
+}
+
+// reset forgets the recorded operations.
+func (r *opRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops, r.errs = nil, nil
+}
+
+func TestInstrumentedStorageOps(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	rec := &opRecorder{}
+	storage := NewInstrumentedStorage(inner, rec.observe)
+
+	extended, ok := storage.(ExtendedStorage)
+	if !ok {
+		t.Fatal("expected ExtendedStorage to be preserved")
+	}
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Errorf("expected value, got %q", got)
+	}
+	_, _ = extended.Exists("key")
+	_, _ = extended.GetTTL("key")
+	_ = extended.Expire("key", time.Hour)
+	_, _ = extended.Touch("key", time.Hour)
+	_ = storage.Delete("key")
+	_ = storage.Reset()
+	_ = storage.(Pinger).Ping(context.Background())
+	_ = storage.Close()
+
+	want := []string{"set", "get", "exists", "get_ttl", "expire", "touch", "delete", "reset", "ping", "close"}
+	if !reflect.DeepEqual(rec.ops, want) {
+		t.Errorf("expected ops %v, got %v", want, rec.ops)
+	}
+	for i, err := range rec.errs {
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", rec.ops[i], err)
+		}
+	}
+
+	if storage.(interface{ Unwrap() Storage }).Unwrap() != Storage(inner) {
+		t.Error("expected Unwrap to return the inner storage")
+	}
+	if _, ok := storage.(*MemoryStorage); ok {
+		t.Error("expected the wrapper to hide the concrete type")
+	}
+}
+
+func TestInstrumentedStorageErrors(t *testing.T) {
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	defer func() { _ = inner.Close() }()
+	inner.down.Store(true)
+
+	rec := &opRecorder{}
+	storage := NewInstrumentedStorage(inner, rec.observe)
+
+	if _, err := storage.Get("key"); err != syscall.ECONNREFUSED {
+		t.Errorf("expected the error to be returned unchanged, got %v", err)
+	}
+	if err := storage.Set("key", []byte("value"), 0); err != syscall.ECONNREFUSED {
+		t.Errorf("expected the error to be returned unchanged, got %v", err)
+	}
+	if len(rec.errs) != 2 || !errors.Is(rec.errs[0], syscall.ECONNREFUSED) || !errors.Is(rec.errs[1], syscall.ECONNREFUSED) {
+		t.Errorf("expected the observer to see the errors, got %v", rec.errs)
+	}
+}
+
+func TestInstrumentedStorageBasicOnly(t *testing.T) {
+	inner := &plainStorage{Storage: NewMemoryStorage("test:", 0)}
+	storage := NewInstrumentedStorage(inner, func(string, time.Duration, error) {})
+	defer func() { _ = storage.Close() }()
+
+	if _, ok := storage.(ExtendedStorage); ok {
+		t.Error("expected ExtendedStorage not to be advertised for a basic storage")
+	}
+	if _, ok := storage.(*InstrumentedStorage); !ok {
+		t.Errorf("expected *InstrumentedStorage, got %T", storage)
+	}
+
+	if NewInstrumentedStorage(inner, nil) != Storage(inner) {
+		t.Error("expected a nil observer to return the inner storage")
+	}
+}
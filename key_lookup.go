@@ -0,0 +1,155 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// keyLookup is a place where requests carry the session ID, parsed from
+// Config.KeyLookup.
+type keyLookup struct {
+	source fibersession.Source
+	name   string
+}
+
+// fiberValue returns the session ID carried by the request of c in l.
+func (l keyLookup) fiberValue(c *fiber.Ctx) string {
+	switch l.source {
+	case fibersession.SourceHeader:
+		return c.Get(l.name)
+	case fibersession.SourceURLQuery:
+		return c.Query(l.name)
+	default:
+		return c.Cookies(l.name)
+	}
+}
+
+// setFiberValue makes the request of c carry the session ID id in l.
+func (l keyLookup) setFiberValue(c *fiber.Ctx, id string) {
+	switch l.source {
+	case fibersession.SourceHeader:
+		c.Request().Header.Set(l.name, id)
+	case fibersession.SourceURLQuery:
+		c.Request().URI().QueryArgs().Set(l.name, id)
+	default:
+		c.Request().Header.SetCookie(l.name, id)
+	}
+}
+
+// keyLookups parses c.KeyLookup, defaulting to the session cookie.
+func (c Config) keyLookups() ([]keyLookup, error) {
+	if strings.TrimSpace(c.KeyLookup) == "" {
+		return []keyLookup{{source: fibersession.SourceCookie, name: c.CookieName}}, nil
+	}
+
+	var lookups []keyLookup
+	for _, part := range strings.Split(c.KeyLookup, ",") {
+		source, name, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid key lookup %q: expected source:name", part)
+		}
+		lookup := keyLookup{source: fibersession.Source(source), name: name}
+		switch lookup.source {
+		case fibersession.SourceCookie:
+			if name != c.CookieName {
+				return nil, fmt.Errorf("key lookup cookie %s does not match cookie name %s", name, c.CookieName)
+			}
+		case fibersession.SourceHeader, fibersession.SourceURLQuery:
+		default:
+			return nil, fmt.Errorf("invalid key lookup source %q: expected cookie, header or query", source)
+		}
+		lookups = append(lookups, lookup)
+	}
+	return lookups, nil
+}
+
+// usesCookie reports whether c looks the session ID up in the session
+// cookie, in which case the session cookie must be written.
+func (c Config) usesCookie() bool {
+	lookups, err := c.keyLookups()
+	if err != nil {
+		return false
+	}
+	for _, lookup := range lookups {
+		if lookup.source == fibersession.SourceCookie {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionIDFromRequest returns the raw session ID carried by r in the first
+// source of lookups that has one, and whether it came from the cookie.
+func sessionIDFromRequest(r *http.Request, lookups []keyLookup) (string, bool) {
+	for _, lookup := range lookups {
+		switch lookup.source {
+		case fibersession.SourceCookie:
+			if cookie, err := r.Cookie(lookup.name); err == nil && cookie.Value != "" {
+				return cookie.Value, true
+			}
+		case fibersession.SourceHeader:
+			if value := r.Header.Get(lookup.name); value != "" {
+				return value, false
+			}
+		case fibersession.SourceURLQuery:
+			if value := r.URL.Query().Get(lookup.name); value != "" {
+				return value, false
+			}
+		}
+	}
+	return "", false
+}
+
+// NewKeyLookupMiddleware returns a middleware letting Fiber sessions find
+// the session ID in every entry of config.KeyLookup. Fiber sessions only
+// read the first one, so when the request carries no session ID there, it
+// copies the ID of the first other entry that has one. Register it before
+// anything using the session, and after NewSignedCookieMiddleware, since
+// IDs copied into the cookie are not signed. Responses still carry the
+// session ID in the first entry only.
+func NewKeyLookupMiddleware(config Config) fiber.Handler {
+	lookups, err := config.keyLookups()
+	return func(c *fiber.Ctx) error {
+		if err != nil || len(lookups) < 2 || lookups[0].fiberValue(c) != "" {
+			return c.Next()
+		}
+		for _, lookup := range lookups[1:] {
+			if id := lookup.fiberValue(c); id != "" {
+				lookups[0].setFiberValue(c, id)
+				break
+			}
+		}
+		return c.Next()
+	}
+}
+
+// WriteHTTPCookie sets the session cookie for sessionID, created by
+// CreateHTTPCookie, on w in response to r if the session ID is looked up in
+// the cookie, and does nothing if it is only read from headers or the
+// query. The cookie is Secure if ShouldSetSecure says so for r.
+func (m *Manager) WriteHTTPCookie(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if m.config.usesCookie() {
+		http.SetCookie(w, CreateHTTPCookie(m.requestConfig(r), sessionID))
+	}
+}
+
+// ExpireHTTPCookie sets the cookie deleting the session cookie, created by
+// CreateExpiredHTTPCookie, on w in response to r if the session ID is
+// looked up in the cookie.
+func (m *Manager) ExpireHTTPCookie(w http.ResponseWriter, r *http.Request) {
+	if m.config.usesCookie() {
+		http.SetCookie(w, CreateExpiredHTTPCookie(m.requestConfig(r)))
+	}
+}
+
+// requestConfig returns the config of m with Secure set as ShouldSetSecure
+// says for r.
+func (m *Manager) requestConfig(r *http.Request) Config {
+	config := m.config
+	config.Secure = ShouldSetSecure(config, r)
+	return config
+}
@@ -0,0 +1,199 @@
+package session
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestConfigValidateKeyLookup(t *testing.T) {
+	valid := []string{"", "cookie:session_id", "header:X-Session-Token", "query:session", "header:X-Session-Token, cookie:session_id"}
+	for _, lookup := range valid {
+		if err := DefaultConfig().WithKeyLookup(lookup).Validate(); err != nil {
+			t.Errorf("expected %q to be valid, got %v", lookup, err)
+		}
+	}
+	invalid := []string{"session_id", "cookie:", "form:session", "cookie:sid", "header:X-Session-Token,"}
+	for _, lookup := range invalid {
+		if err := DefaultConfig().WithKeyLookup(lookup).Validate(); err == nil {
+			t.Errorf("expected an error for %q", lookup)
+		}
+	}
+}
+
+func TestSessionIDFromRequestKeyLookup(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	signingKey := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		name    string
+		lookup  string
+		prepare func(r *http.Request)
+		want    string
+	}{
+		{"cookie", "", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: SignCookieValue(DefaultConfig().WithCookieSigningKey(signingKey), "s1")})
+		}, "s1"},
+		{"header", "header:X-Session-Token", func(r *http.Request) { r.Header.Set("X-Session-Token", "s2") }, "s2"},
+		{"query", "query:session", func(r *http.Request) { r.URL.RawQuery = "session=s3" }, "s3"},
+		{"header ignores cookie", "header:X-Session-Token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: "s1"})
+		}, ""},
+		{"mixed header", "header:X-Session-Token,cookie:session_id", func(r *http.Request) {
+			r.Header.Set("X-Session-Token", "s2")
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: SignCookieValue(DefaultConfig().WithCookieSigningKey(signingKey), "s1")})
+		}, "s2"},
+		{"mixed cookie", "header:X-Session-Token,cookie:session_id", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: SignCookieValue(DefaultConfig().WithCookieSigningKey(signingKey), "s1")})
+		}, "s1"},
+		{"mixed forged cookie", "header:X-Session-Token,cookie:session_id", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: "s1.forged"})
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(storage, DefaultConfig().WithCookieSigningKey(signingKey).WithKeyLookup(tt.lookup))
+			req := httptest.NewRequest("GET", "/", nil)
+			tt.prepare(req)
+			if got := manager.SessionIDFromRequest(req); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteHTTPCookieKeyLookup(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	for lookup, want := range map[string]bool{
+		"":                       true,
+		"header:X-Session-Token": false,
+		"query:session":          false,
+		"header:X-Session-Token,cookie:session_id": true,
+	} {
+		manager := NewManager(storage, DefaultConfig().WithKeyLookup(lookup))
+		rec := httptest.NewRecorder()
+		manager.WriteHTTPCookie(rec, httptest.NewRequest("GET", "/", nil), "s1")
+		if got := rec.Header().Get("Set-Cookie") != ""; got != want {
+			t.Errorf("%q: expected a cookie to be written: %v, got %v", lookup, want, got)
+		}
+		rec = httptest.NewRecorder()
+		manager.ExpireHTTPCookie(rec, httptest.NewRequest("GET", "/", nil))
+		if got := rec.Header().Get("Set-Cookie") != ""; got != want {
+			t.Errorf("%q: expected an expired cookie to be written: %v, got %v", lookup, want, got)
+		}
+	}
+}
+
+func TestFiberSessionConfigKeyLookup(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithKeyLookup("header:X-Session-Token,cookie:session_id"))
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		SetUserID(sess, "user-1")
+		return sess.Save()
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(GetUserID(sess))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	token := resp.Header.Get("X-Session-Token")
+	if token == "" {
+		t.Fatal("expected the session ID in the response header")
+	}
+	if len(resp.Cookies()) != 0 {
+		t.Errorf("expected no cookie, got %v", resp.Cookies())
+	}
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("X-Session-Token", token)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if got := string(body); got != "user-1" {
+		t.Errorf("expected the header to load the session, got %q", got)
+	}
+}
+
+func TestKeyLookupMiddleware(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	config := DefaultConfig().WithKeyLookup("header:X-Session-Token,query:sid,cookie:session_id")
+	manager := NewManager(storage, config)
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Use(NewKeyLookupMiddleware(config))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		SetUserID(sess, "user-1")
+		return sess.Save()
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return c.SendString(GetUserID(sess))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	token := resp.Header.Get("X-Session-Token")
+	if token == "" {
+		t.Fatal("expected the session ID in the response header")
+	}
+
+	me := func(req *http.Request) string {
+		t.Helper()
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	if got := me(httptest.NewRequest("GET", "/me?sid="+token, nil)); got != "user-1" {
+		t.Errorf("expected the query to load the session, got %q", got)
+	}
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: token})
+	if got := me(req); got != "user-1" {
+		t.Errorf("expected the cookie to load the session, got %q", got)
+	}
+
+	// The first entry wins over the others
+	req = httptest.NewRequest("GET", "/me?sid="+token, nil)
+	req.Header.Set("X-Session-Token", "unknown")
+	if got := me(req); got != "" {
+		t.Errorf("expected the header to be used, got %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// LoggingOptions configures LoggingStorage.
+type LoggingOptions struct {
+	// Level is the level of the log records.
+	// Default: slog.LevelDebug
+	Level slog.Level
+
+	// SampleRate logs one successful operation in SampleRate. Failed
+	// operations are always logged. Values <= 1 log every operation.
+	// Default: 1
+	SampleRate int
+
+	// HashKeys replaces keys in log records with a short SHA-256 hash, since
+	// keys usually contain session IDs. The same key always gets the same
+	// hash, so operations on one session can still be correlated.
+	// Default: false
+	HashKeys bool
+}
+
+// DefaultLoggingOptions returns LoggingOptions with default values.
+func DefaultLoggingOptions() LoggingOptions {
+	return LoggingOptions{
+		Level:      slog.LevelDebug,
+		SampleRate: 1,
+	}
+}
+
+// WithLevel sets the level of the log records.
+func (o LoggingOptions) WithLevel(level slog.Level) LoggingOptions {
+	o.Level = level
+	return o
+}
+
+// WithSampleRate sets the sampling rate of successful operations.
+func (o LoggingOptions) WithSampleRate(n int) LoggingOptions {
+	o.SampleRate = n
+	return o
+}
+
+// WithHashKeys sets whether keys are hashed in log records.
+func (o LoggingOptions) WithHashKeys(hash bool) LoggingOptions {
+	o.HashKeys = hash
+	return o
+}
+
+// LoggingStorage wraps a Storage and logs every operation with structured
+// attributes: op, key, value_len, ttl, duration and err. Values themselves
+// are never logged. Like InstrumentedStorage, it hides the concrete type of
+// the inner storage and only forwards Ping; use Unwrap to reach it.
+type LoggingStorage struct {
+	inner  Storage
+	logger *slog.Logger
+	opts   LoggingOptions
+
+	count atomic.Uint64
+}
+
+// NewLoggingStorage wraps inner so that every operation is logged to logger
+// at the given level. If logger is nil, slog.Default is used.
+func NewLoggingStorage(inner Storage, logger *slog.Logger, level slog.Level) Storage {
+	return NewLoggingStorageWithOptions(inner, logger, DefaultLoggingOptions().WithLevel(level))
+}
+
+// NewLoggingStorageWithOptions wraps inner with the given options.
+// If logger is nil, slog.Default is used.
+func NewLoggingStorageWithOptions(inner Storage, logger *slog.Logger, opts LoggingOptions) Storage {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.SampleRate < 1 {
+		opts.SampleRate = 1
+	}
+	return &LoggingStorage{inner: inner, logger: logger, opts: opts}
+}
+
+// Unwrap returns the inner storage.
+func (s *LoggingStorage) Unwrap() Storage {
+	return s.inner
+}
+
+// logKey returns key as it should appear in log records.
+func (s *LoggingStorage) logKey(key string) string {
+	if !s.opts.HashKeys {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sampled reports whether the current successful operation should be logged.
+func (s *LoggingStorage) sampled() bool {
+	if s.opts.SampleRate <= 1 {
+		return true
+	}
+	return (s.count.Add(1)-1)%uint64(s.opts.SampleRate) == 0
+}
+
+// log records an operation that started at start. Attributes that do not
+// apply to the operation are passed as nil and left out.
+func (s *LoggingStorage) log(op string, start time.Time, key *string, valueLen *int, ttl *time.Duration, err error) {
+	duration := time.Since(start)
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, s.opts.Level) {
+		return
+	}
+	if err == nil && !s.sampled() {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 6)
+	attrs = append(attrs, slog.String("op", op))
+	if key != nil {
+		attrs = append(attrs, slog.String("key", s.logKey(*key)))
+	}
+	if valueLen != nil {
+		attrs = append(attrs, slog.Int("value_len", *valueLen))
+	}
+	if ttl != nil {
+		attrs = append(attrs, slog.Duration("ttl", *ttl))
+	}
+	attrs = append(attrs, slog.Duration("duration", duration))
+	if err != nil {
+		attrs = append(attrs, slog.String("err", err.Error()))
+	}
+	s.logger.LogAttrs(ctx, s.opts.Level, "session storage operation", attrs...)
+}
+
+// Get retrieves the value for the given key. A miss is logged with a
+// value_len of 0.
+func (s *LoggingStorage) Get(key string) ([]byte, error) {
+	start := time.Now()
+	data, err := s.inner.Get(key)
+	n := len(data)
+	s.log("get", start, &key, &n, nil, err)
+	return data, err
+}
+
+// Set stores the given value for the given key.
+func (s *LoggingStorage) Set(key string, val []byte, exp time.Duration) error {
+	start := time.Now()
+	err := s.inner.Set(key, val, exp)
+	n := len(val)
+	s.log("set", start, &key, &n, &exp, err)
+	return err
+}
+
+// Delete removes the value for the given key.
+func (s *LoggingStorage) Delete(key string) error {
+	start := time.Now()
+	err := s.inner.Delete(key)
+	s.log("delete", start, &key, nil, nil, err)
+	return err
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *LoggingStorage) Reset() error {
+	start := time.Now()
+	err := s.inner.Reset()
+	s.log("reset", start, nil, nil, nil, err)
+	return err
+}
+
+// Ping checks the inner storage. Storages that do not implement Pinger are
+// assumed to be healthy.
+func (s *LoggingStorage) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := pingStorage(ctx, s.inner)
+	s.log("ping", start, nil, nil, nil, err)
+	return err
+}
+
+// Close closes the inner storage.
+func (s *LoggingStorage) Close() error {
+	start := time.Now()
+	err := s.inner.Close()
+	s.log("close", start, nil, nil, nil, err)
+	return err
+}
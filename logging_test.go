@@ -0,0 +1,136 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureHandler is a slog.Handler keeping the records it handles.
+type captureHandler struct {
+	mu      sync.Mutex
+	level   slog.Level
+	records []map[string]slog.Value
+}
+
+func (h *captureHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value
+		return true
+	})
+	h.mu.Lock()
+	h.records = append(h.records, attrs)
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+func TestLoggingStorageFields(t *testing.T) {
+	h := &captureHandler{level: slog.LevelInfo}
+	storage := NewLoggingStorage(NewMemoryStorage("test:", 0), slog.New(h), slog.LevelInfo)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("sid-1", []byte("secret-payload"), time.Minute)
+	_, _ = storage.Get("sid-1")
+	_, _ = storage.Get("missing")
+	_ = storage.Delete("sid-1")
+
+	if len(h.records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(h.records))
+	}
+
+	set := h.records[0]
+	if set["op"].String() != "set" || set["key"].String() != "sid-1" || set["value_len"].Int64() != 14 || set["ttl"].Duration() != time.Minute {
+		t.Errorf("unexpected set record %v", set)
+	}
+	if get := h.records[1]; get["op"].String() != "get" || get["value_len"].Int64() != 14 {
+		t.Errorf("unexpected get record %v", get)
+	}
+	if miss := h.records[2]; miss["value_len"].Int64() != 0 {
+		t.Errorf("expected a miss to log value_len 0, got %v", miss)
+	}
+	if del := h.records[3]; del["op"].String() != "delete" || del["key"].String() != "sid-1" {
+		t.Errorf("unexpected delete record %v", del)
+	}
+	for _, r := range h.records {
+		if _, ok := r["err"]; ok {
+			t.Errorf("unexpected err attribute in %v", r)
+		}
+		for _, v := range r {
+			if strings.Contains(v.String(), "secret-payload") {
+				t.Errorf("expected the payload never to be logged, got %v", r)
+			}
+		}
+	}
+}
+
+func TestLoggingStorageHashKeys(t *testing.T) {
+	h := &captureHandler{}
+	opts := DefaultLoggingOptions().WithLevel(slog.LevelInfo).WithHashKeys(true)
+	storage := NewLoggingStorageWithOptions(NewMemoryStorage("test:", 0), slog.New(h), opts)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("sid-1", []byte("value"), 0)
+	_ = storage.Delete("sid-1")
+
+	first, second := h.records[0]["key"].String(), h.records[1]["key"].String()
+	if first == "sid-1" || len(first) != 16 {
+		t.Errorf("expected a hashed key, got %q", first)
+	}
+	if first != second {
+		t.Errorf("expected the same key to hash the same, got %q and %q", first, second)
+	}
+}
+
+func TestLoggingStorageSampling(t *testing.T) {
+	h := &captureHandler{}
+	opts := DefaultLoggingOptions().WithLevel(slog.LevelInfo).WithSampleRate(10)
+	inner := &switchStorage{Storage: NewMemoryStorage("test:", 0)}
+	storage := NewLoggingStorageWithOptions(inner, slog.New(h), opts)
+	defer func() { _ = storage.Close() }()
+
+	for i := 0; i < 95; i++ {
+		_, _ = storage.Get("key")
+	}
+	if len(h.records) != 10 {
+		t.Errorf("expected 1 in 10 of 95 operations to be logged, got %d", len(h.records))
+	}
+
+	// Failures bypass sampling
+	inner.down.Store(true)
+	for i := 0; i < 3; i++ {
+		_, _ = storage.Get("key")
+	}
+	if len(h.records) != 13 {
+		t.Fatalf("expected every failure to be logged, got %d records", len(h.records))
+	}
+	if err := h.records[12]["err"].String(); !strings.Contains(err, "connection refused") {
+		t.Errorf("expected the error to be logged, got %q", err)
+	}
+}
+
+func TestLoggingStorageDisabledLevel(t *testing.T) {
+	h := &captureHandler{level: slog.LevelInfo}
+	inner := NewMemoryStorage("test:", 0)
+	storage := NewLoggingStorage(inner, slog.New(h), slog.LevelDebug)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 0)
+	if len(h.records) != 0 {
+		t.Errorf("expected no records below the handler level, got %d", len(h.records))
+	}
+	if storage.(*LoggingStorage).Unwrap() != Storage(inner) {
+		t.Error("expected Unwrap to return the inner storage")
+	}
+}
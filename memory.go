@@ -1,14 +1,147 @@
 package session
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// EvictionReason describes why an entry was removed from MemoryStorage.
+type EvictionReason int
+
+const (
+	// EvictionReasonExpired means the entry's TTL elapsed and it was removed
+	// by garbage collection or lazily on access.
+	EvictionReasonExpired EvictionReason = iota
+	// EvictionReasonDeleted means the entry was removed by an explicit Delete.
+	EvictionReasonDeleted
+	// EvictionReasonEvicted means the entry was removed to make room for a
+	// new one because the storage reached its maximum number of entries.
+	EvictionReasonEvicted
+	// EvictionReasonReset means the entry was removed by Reset.
+	EvictionReasonReset
+)
+
+// String returns the name of the eviction reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionReasonExpired:
+		return "expired"
+	case EvictionReasonDeleted:
+		return "deleted"
+	case EvictionReasonEvicted:
+		return "evicted"
+	case EvictionReasonReset:
+		return "reset"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionCallback is invoked with the key (without prefix) of every entry
+// removed from MemoryStorage, along with the reason for its removal.
+type EvictionCallback func(key string, reason EvictionReason)
+
+// MemoryStorageConfig represents configuration for MemoryStorage.
+type MemoryStorageConfig struct {
+	// KeyPrefix is the prefix for session keys.
+	// Default: "session:"
+	KeyPrefix string
+
+	// GCInterval is how often garbage collection runs.
+	// Set to 0 to disable GC.
+	// Default: 10 minutes
+	GCInterval time.Duration
+
+	// GCBatchSize is the maximum number of entries examined by one garbage
+	// collection cycle, shared between the shards.
+	// Default: 1000
+	GCBatchSize int
+
+	// GCJitter randomizes each GC interval by up to ±GCJitter (a fraction of
+	// GCInterval) so several storages in one process don't sweep at the same time.
+	// Default: 0.1
+	GCJitter float64
+
+	// Shards is the number of independently locked partitions of the storage.
+	// It is rounded up to a power of two.
+	// Default: 16
+	Shards int
+}
+
+// DefaultMemoryStorageConfig returns a MemoryStorageConfig with default values.
+func DefaultMemoryStorageConfig() MemoryStorageConfig {
+	return MemoryStorageConfig{
+		KeyPrefix:   "session:",
+		GCInterval:  10 * time.Minute,
+		GCBatchSize: 1000,
+		GCJitter:    0.1,
+		Shards:      16,
+	}
+}
+
+// WithKeyPrefix sets the key prefix.
+func (c MemoryStorageConfig) WithKeyPrefix(prefix string) MemoryStorageConfig {
+	c.KeyPrefix = prefix
+	return c
+}
+
+// WithGCInterval sets the garbage collection interval.
+func (c MemoryStorageConfig) WithGCInterval(interval time.Duration) MemoryStorageConfig {
+	c.GCInterval = interval
+	return c
+}
+
+// WithGCBatchSize sets the maximum number of entries examined per GC cycle.
+func (c MemoryStorageConfig) WithGCBatchSize(n int) MemoryStorageConfig {
+	c.GCBatchSize = n
+	return c
+}
+
+// WithGCJitter sets the GC interval jitter fraction.
+func (c MemoryStorageConfig) WithGCJitter(jitter float64) MemoryStorageConfig {
+	c.GCJitter = jitter
+	return c
+}
+
+// WithShards sets the number of shards.
+func (c MemoryStorageConfig) WithShards(n int) MemoryStorageConfig {
+	c.Shards = n
+	return c
+}
+
+// GCStats describes the outcome of a single garbage collection cycle.
+type GCStats struct {
+	// Scanned is the number of entries examined.
+	Scanned int
+	// Removed is the number of expired entries removed.
+	Removed int
+	// Duration is how long the cycle took.
+	Duration time.Duration
+	// FinishedAt is when the cycle completed.
+	FinishedAt time.Time
+}
+
+// evictionSampleSize is the number of entries inspected when choosing an
+// entry to evict once the storage is full.
+const evictionSampleSize = 5
+
 // memoryEntry represents an entry in the memory storage.
 type memoryEntry struct {
 	data      []byte
 	expiresAt time.Time
+	// accessedAt is when the entry was last read or written, in Unix
+	// nanoseconds. It is updated without holding the shard lock.
+	accessedAt atomic.Int64
+}
+
+// markAccessed records that the entry is being used.
+func (e *memoryEntry) markAccessed() {
+	e.accessedAt.Store(time.Now().UnixNano())
 }
 
 // isExpired checks if the entry has expired.
@@ -19,64 +152,418 @@ func (e *memoryEntry) isExpired() bool {
 	return time.Now().After(e.expiresAt)
 }
 
+// memoryShard is one independently locked partition of MemoryStorage.
+type memoryShard struct {
+	mu         sync.RWMutex
+	data       map[string]*memoryEntry
+	maxEntries int
+}
+
 // MemoryStorage implements Storage interface using in-memory map.
 // This is useful for development and testing, but not suitable for production
 // with multiple server instances as sessions won't be shared.
+//
+// Entries are spread over a number of shards selected by an FNV-1a hash of the
+// full key, each with its own lock, to reduce contention under parallel load.
 type MemoryStorage struct {
-	mu        sync.RWMutex
-	data      map[string]*memoryEntry
+	shards    []*memoryShard
+	shardMask uint32
 	keyPrefix string
-	gcTicker  *time.Ticker
-	done      chan struct{}
+	config    MemoryStorageConfig
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+
+	gcMu  sync.Mutex
+	gcRun *gcRun
+	// gcNotifying counts the GC cycles running eviction callbacks.
+	gcNotifying atomic.Int32
+	// gcNext is the shard the next GC cycle starts from.
+	gcNext atomic.Uint32
+
+	statsMu     sync.Mutex
+	lastGCStats GCStats
+
+	callbackMu sync.RWMutex
+	onEvict    EvictionCallback
+	onExpire   func(key string)
+
+	counters memoryCounters
 }
 
 // NewMemoryStorage creates a new in-memory storage.
 // The gcInterval parameter specifies how often to run garbage collection
 // to clean up expired entries. If gcInterval is 0, garbage collection is disabled.
 func NewMemoryStorage(keyPrefix string, gcInterval time.Duration) *MemoryStorage {
+	cfg := DefaultMemoryStorageConfig().
+		WithKeyPrefix(keyPrefix).
+		WithGCInterval(gcInterval)
+	return NewMemoryStorageWithConfig(cfg)
+}
+
+// NewMemoryStorageWithConfig creates a new in-memory storage using configuration.
+func NewMemoryStorageWithConfig(cfg MemoryStorageConfig) *MemoryStorage {
+	keyPrefix := cfg.KeyPrefix
 	if keyPrefix == "" {
 		keyPrefix = "session:"
 	} else if len(keyPrefix) > 0 && keyPrefix[len(keyPrefix)-1] != ':' {
 		keyPrefix += ":"
 	}
+	cfg.KeyPrefix = keyPrefix
+
+	if cfg.GCBatchSize <= 0 {
+		cfg.GCBatchSize = DefaultMemoryStorageConfig().GCBatchSize
+	}
+	if cfg.GCJitter < 0 {
+		cfg.GCJitter = 0
+	} else if cfg.GCJitter > 1 {
+		cfg.GCJitter = 1
+	}
+	cfg.Shards = shardCount(cfg.Shards)
 
 	s := &MemoryStorage{
-		data:      make(map[string]*memoryEntry),
+		shards:    make([]*memoryShard, cfg.Shards),
+		shardMask: uint32(cfg.Shards - 1),
 		keyPrefix: keyPrefix,
-		done:      make(chan struct{}),
+		config:    cfg,
+	}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{data: make(map[string]*memoryEntry)}
 	}
 
 	// Start garbage collection if interval is set
-	if gcInterval > 0 {
-		s.gcTicker = time.NewTicker(gcInterval)
-		go s.runGC()
+	if cfg.GCInterval > 0 {
+		_ = s.StartGC(cfg.GCInterval)
 	}
 
 	return s
 }
 
-// runGC runs periodic garbage collection.
-func (s *MemoryStorage) runGC() {
+// GetKeyPrefix returns the key prefix used by this storage.
+func (s *MemoryStorage) GetKeyPrefix() string {
+	return s.keyPrefix
+}
+
+// shardCount rounds n up to a power of two, using the default for n <= 0.
+func shardCount(n int) int {
+	if n <= 0 {
+		n = DefaultMemoryStorageConfig().Shards
+	}
+	count := 1
+	for count < n {
+		count <<= 1
+	}
+	return count
+}
+
+// shard returns the shard responsible for the given full key.
+func (s *MemoryStorage) shard(fullKey string) *memoryShard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	return s.shards[fnv32a(fullKey)&s.shardMask]
+}
+
+// fnv32a computes the 32-bit FNV-1a hash of key without allocating.
+func fnv32a(key string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return h
+}
+
+// gcRun is a running garbage collector goroutine.
+type gcRun struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// waitGC waits for the goroutine of run to exit, unless a GC cycle is
+// running eviction callbacks: the call may come from one of them, which
+// may stop or restart the collector, and the goroutine then exits once the
+// cycle is over.
+func (s *MemoryStorage) waitGC(run *gcRun) {
+	if run == nil || s.gcNotifying.Load() > 0 {
+		return
+	}
+	<-run.done
+}
+
+// StartGC starts periodic garbage collection with the given interval,
+// replacing any collector that is already running. It can be used to change
+// the interval at runtime. Returns ErrClosed if the storage has been closed.
+func (s *MemoryStorage) StartGC(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("gc interval must be > 0")
+	}
+
+	s.gcMu.Lock()
+	if s.closed.Load() {
+		s.gcMu.Unlock()
+		return ErrClosed
+	}
+	previous := s.detachGCLocked()
+	run := &gcRun{stop: make(chan struct{}), done: make(chan struct{})}
+	s.gcRun = run
+	go s.runGC(interval, run)
+	s.gcMu.Unlock()
+
+	s.waitGC(previous)
+	return nil
+}
+
+// StopGC stops periodic garbage collection and waits for a running cycle to
+// finish. Expired entries are still removed lazily on access.
+// It is a no-op if GC is not running. While a GC cycle runs eviction or
+// expiry callbacks, which may call it, it returns without waiting.
+func (s *MemoryStorage) StopGC() {
+	s.gcMu.Lock()
+	run := s.detachGCLocked()
+	s.gcMu.Unlock()
+	s.waitGC(run)
+}
+
+// detachGCLocked signals the running collector, if any, to stop and returns
+// it so the caller can wait for it after releasing s.gcMu. The caller must
+// hold s.gcMu.
+func (s *MemoryStorage) detachGCLocked() *gcRun {
+	run := s.gcRun
+	if run != nil {
+		close(run.stop)
+		s.gcRun = nil
+	}
+	return run
+}
+
+// nextGCDelay returns the GC interval with jitter applied.
+func (s *MemoryStorage) nextGCDelay(interval time.Duration) time.Duration {
+	if s.config.GCJitter <= 0 {
+		return interval
+	}
+	spread := float64(interval) * s.config.GCJitter
+	delay := time.Duration(float64(interval) + (rand.Float64()*2-1)*spread)
+	if delay <= 0 {
+		delay = interval
+	}
+	return delay
+}
+
+// runGC runs periodic garbage collection until run is stopped.
+func (s *MemoryStorage) runGC(interval time.Duration, run *gcRun) {
+	defer close(run.done)
+
+	timer := time.NewTimer(s.nextGCDelay(interval))
+	defer timer.Stop()
+
 	for {
 		select {
-		case <-s.gcTicker.C:
+		case <-timer.C:
 			s.gc()
-		case <-s.done:
+			timer.Reset(s.nextGCDelay(interval))
+		case <-run.stop:
 			return
 		}
 	}
 }
 
-// gc removes expired entries.
-func (s *MemoryStorage) gc() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// gc removes expired entries incrementally. A cycle examines at most
+// GCBatchSize entries in total, split evenly between the shards and starting
+// from a random position in each shard's map, and a shard's write lock is
+// only held while deleting the expired keys of its batch. Budget left unused
+// by small shards goes to the following ones, and each cycle starts from the
+// shard after the last one the previous cycle reached, so none is starved
+// when the budget is smaller than the number of shards.
+func (s *MemoryStorage) gc() GCStats {
+	start := time.Now()
+	stats := GCStats{}
+
+	budget := s.config.GCBatchSize
+	first := s.gcNext.Load()
+	visited := uint32(0)
+	for visited < uint32(len(s.shards)) && budget > 0 {
+		left := len(s.shards) - int(visited)
+		sh := s.shards[(first+visited)&s.shardMask]
+		visited++
+
+		scanned, removed := s.gcBatch(sh, (budget+left-1)/left)
+		budget -= scanned
+		stats.Scanned += scanned
+		stats.Removed += removed
+	}
+	if visited == uint32(len(s.shards)) {
+		visited = 1
+	}
+	s.gcNext.Store(first + visited)
+
+	stats.FinishedAt = time.Now()
+	stats.Duration = stats.FinishedAt.Sub(start)
 
-	for key, entry := range s.data {
+	s.statsMu.Lock()
+	s.lastGCStats = stats
+	s.statsMu.Unlock()
+	s.counters.gcRuns.Add(1)
+
+	return stats
+}
+
+// gcBatch examines up to limit entries of a shard and removes the expired ones.
+// It returns the number of entries examined and removed.
+func (s *MemoryStorage) gcBatch(sh *memoryShard, limit int) (int, int) {
+	var candidates []string
+	scanned := 0
+
+	sh.mu.RLock()
+	for key, entry := range sh.data {
+		if scanned >= limit {
+			break
+		}
+		scanned++
 		if entry.isExpired() {
-			delete(s.data, key)
+			candidates = append(candidates, key)
+		}
+	}
+	sh.mu.RUnlock()
+
+	if len(candidates) == 0 {
+		return scanned, 0
+	}
+
+	expired := candidates[:0]
+	sh.mu.Lock()
+	for _, key := range candidates {
+		// Re-check: the entry may have been replaced since it was scanned
+		if entry, ok := sh.data[key]; ok && entry.isExpired() {
+			delete(sh.data, key)
+			expired = append(expired, key)
 		}
 	}
+	sh.mu.Unlock()
+
+	s.gcNotifying.Add(1)
+	defer s.gcNotifying.Add(-1)
+	s.notifyEvicted(expired, EvictionReasonExpired)
+
+	return scanned, len(expired)
+}
+
+// LastGCStats returns the statistics of the most recent garbage collection cycle.
+func (s *MemoryStorage) LastGCStats() GCStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.lastGCStats
+}
+
+// SetEvictionCallback registers fn to be called whenever an entry is removed
+// from the storage. The callback runs outside the storage lock, so it may
+// safely call back into the storage. Panics raised by the callback are
+// recovered. Passing nil removes the callback.
+func (s *MemoryStorage) SetEvictionCallback(fn EvictionCallback) {
+	s.callbackMu.Lock()
+	s.onEvict = fn
+	s.callbackMu.Unlock()
+}
+
+// SetMaxEntries limits the number of entries held by the storage. When the
+// limit is reached, setting a new key evicts an existing entry, preferring
+// expired entries and otherwise the least recently read or written one among
+// a small random sample, an approximation of LRU. The limit is divided
+// evenly between shards, so the storage may start evicting slightly before
+// holding n entries.
+// A value of 0 or less disables the limit.
+func (s *MemoryStorage) SetMaxEntries(n int) {
+	perShard := 0
+	if n > 0 {
+		perShard = (n + len(s.shards) - 1) / len(s.shards)
+	}
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		sh.maxEntries = perShard
+		sh.mu.Unlock()
+	}
+}
+
+// notifyEvicted invokes the eviction callback for each of the given full keys.
+// It must be called without holding any shard lock.
+func (s *MemoryStorage) notifyEvicted(fullKeys []string, reason EvictionReason) {
+	if len(fullKeys) == 0 {
+		return
+	}
+
+	s.callbackMu.RLock()
+	fn := s.onEvict
+	onExpire := s.onExpire
+	s.callbackMu.RUnlock()
+
+	if reason != EvictionReasonExpired {
+		onExpire = nil
+	}
+	if fn == nil && onExpire == nil {
+		return
+	}
+
+	for _, fullKey := range fullKeys {
+		key := strings.TrimPrefix(fullKey, s.keyPrefix)
+		if fn != nil {
+			s.invokeCallback(fn, key, reason)
+		}
+		if onExpire != nil {
+			s.invokeCallback(func(key string, _ EvictionReason) { onExpire(key) }, key, reason)
+		}
+	}
+}
+
+// NotifyExpired registers fn to be called with the key of every entry that
+// expires, whether it is found by garbage collection or on access. It is
+// independent of the eviction callback and runs under the same guarantees.
+func (s *MemoryStorage) NotifyExpired(fn func(key string)) error {
+	s.callbackMu.Lock()
+	s.onExpire = fn
+	s.callbackMu.Unlock()
+	return nil
+}
+
+// invokeCallback calls fn and recovers from any panic so that a misbehaving
+// callback cannot take down the garbage collector goroutine.
+func (s *MemoryStorage) invokeCallback(fn EvictionCallback, key string, reason EvictionReason) {
+	defer func() {
+		_ = recover()
+	}()
+	fn(key, reason)
+}
+
+// evictOne removes one entry to make room for a new key and returns its full key.
+// It removes the first expired entry it finds, or else the least recently
+// used of a few entries sampled from the shard, approximating LRU eviction
+// without maintaining a recency list. The caller must hold sh.mu for writing.
+func (sh *memoryShard) evictOne() (string, EvictionReason) {
+	var (
+		victim  string
+		victimE *memoryEntry
+		sampled int
+	)
+	for key, entry := range sh.data {
+		if entry.isExpired() {
+			delete(sh.data, key)
+			return key, EvictionReasonExpired
+		}
+		if victimE == nil || entry.accessedAt.Load() < victimE.accessedAt.Load() {
+			victim, victimE = key, entry
+		}
+		sampled++
+		if sampled >= evictionSampleSize {
+			break
+		}
+	}
+	if victimE != nil {
+		delete(sh.data, victim)
+	}
+	return victim, EvictionReasonEvicted
 }
 
 // buildKey constructs the full key with prefix.
@@ -86,87 +573,428 @@ func (s *MemoryStorage) buildKey(key string) string {
 
 // Get retrieves the value for the given key.
 // Returns nil, nil if the key does not exist or has expired.
+// The returned slice is a copy that the caller may modify freely.
 func (s *MemoryStorage) Get(key string) ([]byte, error) {
-	fullKey := s.buildKey(key)
+	data, err := s.GetUnsafe(key)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+// GetUnsafe retrieves the value for the given key without copying it.
+// The returned slice is shared with the storage and must not be modified;
+// use it only on hot paths where the copy made by Get matters.
+func (s *MemoryStorage) GetUnsafe(key string) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	data := s.load(s.buildKey(key))
+	if data == nil {
+		s.counters.misses.Add(1)
+		return nil, nil
+	}
+	s.counters.hits.Add(1)
+	return data, nil
+}
+
+// load returns the stored value for fullKey, or nil if it is missing or has
+// expired, removing expired entries as it finds them.
+func (s *MemoryStorage) load(fullKey string) []byte {
+	sh := s.shard(fullKey)
 
-	s.mu.RLock()
-	entry, ok := s.data[fullKey]
-	s.mu.RUnlock()
+	sh.mu.RLock()
+	entry, ok := sh.data[fullKey]
+	sh.mu.RUnlock()
 
 	if !ok {
-		return nil, nil
+		return nil
 	}
 
 	if entry.isExpired() {
-		// Clean up expired entry
-		s.mu.Lock()
-		delete(s.data, fullKey)
-		s.mu.Unlock()
-		return nil, nil
+		// Clean up expired entry, unless it was replaced in the meantime
+		sh.mu.Lock()
+		removed := sh.data[fullKey] == entry
+		if removed {
+			delete(sh.data, fullKey)
+		}
+		sh.mu.Unlock()
+		if removed {
+			s.notifyEvicted([]string{fullKey}, EvictionReasonExpired)
+		}
+		return nil
 	}
 
-	return entry.data, nil
+	entry.markAccessed()
+	return entry.data
 }
 
 // Set stores the given value for the given key along with an expiration value.
 // If expiration is 0, the value never expires.
 // Empty key or value will be ignored without an error.
 func (s *MemoryStorage) Set(key string, val []byte, exp time.Duration) error {
+	_, err := s.set(key, val, exp, setAlways)
+	return err
+}
+
+// SetKeepTTL stores the given value for the given key, keeping the expiration
+// of the existing entry. If the key does not exist or has expired, the value
+// is stored without an expiration.
+func (s *MemoryStorage) SetKeepTTL(key string, val []byte) error {
+	_, err := s.set(key, val, 0, setKeepTTL)
+	return err
+}
+
+// SetIfAbsent stores the given value for the given key unless the key
+// exists and has not expired, and reports whether it was stored. If exp is
+// 0, the value never expires.
+func (s *MemoryStorage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	return s.set(key, val, exp, setIfAbsent)
+}
+
+// setMode selects how set treats an existing entry.
+type setMode int
+
+const (
+	// setAlways replaces the entry.
+	setAlways setMode = iota
+	// setKeepTTL replaces the entry, keeping its expiration.
+	setKeepTTL
+	// setIfAbsent leaves the entry unless it has expired.
+	setIfAbsent
+)
+
+// set stores a copy of val, evicting an entry if the shard is full, and
+// reports whether it was stored. With setKeepTTL, exp is ignored and the
+// existing entry's expiration is kept.
+func (s *MemoryStorage) set(key string, val []byte, exp time.Duration, mode setMode) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
 	if key == "" || len(val) == 0 {
-		return nil
+		return false, nil
 	}
 
 	fullKey := s.buildKey(key)
+	sh := s.shard(fullKey)
 
 	entry := &memoryEntry{
 		data: make([]byte, len(val)),
 	}
 	copy(entry.data, val)
+	entry.markAccessed()
 
 	if exp > 0 {
 		entry.expiresAt = time.Now().Add(exp)
 	}
 
-	s.mu.Lock()
-	s.data[fullKey] = entry
-	s.mu.Unlock()
+	var (
+		evicted string
+		reason  EvictionReason
+	)
 
-	return nil
+	sh.mu.Lock()
+	existing, exists := sh.data[fullKey]
+	if mode == setIfAbsent && exists && !existing.isExpired() {
+		sh.mu.Unlock()
+		return false, nil
+	}
+	if !exists && sh.maxEntries > 0 && len(sh.data) >= sh.maxEntries {
+		evicted, reason = sh.evictOne()
+	}
+	if mode == setKeepTTL && exists && !existing.isExpired() {
+		entry.expiresAt = existing.expiresAt
+	}
+	sh.data[fullKey] = entry
+	sh.mu.Unlock()
+
+	s.counters.sets.Add(1)
+	if evicted != "" {
+		if reason == EvictionReasonEvicted {
+			s.counters.evictions.Add(1)
+		}
+		s.notifyEvicted([]string{evicted}, reason)
+	}
+
+	return true, nil
 }
 
 // Delete removes the value for the given key.
 // It returns no error if the storage does not contain the key.
 func (s *MemoryStorage) Delete(key string) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
 	fullKey := s.buildKey(key)
+	sh := s.shard(fullKey)
 
-	s.mu.Lock()
-	delete(s.data, fullKey)
-	s.mu.Unlock()
+	sh.mu.Lock()
+	_, existed := sh.data[fullKey]
+	delete(sh.data, fullKey)
+	sh.mu.Unlock()
+
+	if existed {
+		s.counters.deletes.Add(1)
+		s.notifyEvicted([]string{fullKey}, EvictionReasonDeleted)
+	}
 
 	return nil
 }
 
+// DeleteMany removes the values for the given keys, acquiring each shard's
+// lock at most once. Missing keys are ignored and an empty slice is a no-op.
+func (s *MemoryStorage) DeleteMany(keys []string) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	byShard := make(map[*memoryShard][]string)
+	for _, key := range keys {
+		fullKey := s.buildKey(key)
+		sh := s.shard(fullKey)
+		byShard[sh] = append(byShard[sh], fullKey)
+	}
+
+	var deleted []string
+	for sh, fullKeys := range byShard {
+		sh.mu.Lock()
+		for _, fullKey := range fullKeys {
+			if _, ok := sh.data[fullKey]; ok {
+				delete(sh.data, fullKey)
+				deleted = append(deleted, fullKey)
+			}
+		}
+		sh.mu.Unlock()
+	}
+
+	s.counters.deletes.Add(uint64(len(deleted)))
+	s.notifyEvicted(deleted, EvictionReasonDeleted)
+
+	return nil
+}
+
+// DeleteByPattern removes the entries whose key (without prefix) matches
+// the Redis glob pattern, with the same syntax as RedisStorage.DeleteByPattern,
+// and returns how many were removed.
+func (s *MemoryStorage) DeleteByPattern(pattern string) (int, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	var deleted []string
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for fullKey := range sh.data {
+			if matchPattern(pattern, strings.TrimPrefix(fullKey, s.keyPrefix)) {
+				delete(sh.data, fullKey)
+				deleted = append(deleted, fullKey)
+			}
+		}
+		sh.mu.Unlock()
+	}
+
+	s.counters.deletes.Add(uint64(len(deleted)))
+	s.notifyEvicted(deleted, EvictionReasonDeleted)
+
+	return len(deleted), nil
+}
+
+// GetMulti retrieves copies of the values for the given keys.
+// Missing and expired keys are absent from the returned map.
+func (s *MemoryStorage) GetMulti(keys []string) (map[string][]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		data, err := s.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			result[key] = data
+		}
+	}
+	return result, nil
+}
+
+// SetMulti stores all items with the same expiration.
+// Entries with an empty key or value are skipped.
+func (s *MemoryStorage) SetMulti(items map[string][]byte, exp time.Duration) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	for key, val := range items {
+		if err := s.Set(key, val, exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Reset removes all keys with the configured prefix.
 func (s *MemoryStorage) Reset() error {
-	s.mu.Lock()
-	s.data = make(map[string]*memoryEntry)
-	s.mu.Unlock()
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	var keys []string
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		old := sh.data
+		sh.data = make(map[string]*memoryEntry)
+		sh.mu.Unlock()
+
+		for key := range old {
+			keys = append(keys, key)
+		}
+	}
+	s.notifyEvicted(keys, EvictionReasonReset)
 
 	return nil
 }
 
+// Exists reports whether the key exists and has not expired.
+func (s *MemoryStorage) Exists(key string) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+	return s.load(s.buildKey(key)) != nil, nil
+}
+
+// GetTTL returns the remaining TTL for a key.
+// Returns -2 if the key does not exist, -1 if the key has no expiration,
+// matching the values reported by RedisStorage.
+func (s *MemoryStorage) GetTTL(key string) (time.Duration, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	fullKey := s.buildKey(key)
+	sh := s.shard(fullKey)
+
+	sh.mu.RLock()
+	entry, ok := sh.data[fullKey]
+	sh.mu.RUnlock()
+
+	if !ok || entry.isExpired() {
+		return -2, nil
+	}
+	if entry.expiresAt.IsZero() {
+		return -1, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+// Expire sets a new expiration on a key.
+// If exp is 0, the expiration is removed.
+func (s *MemoryStorage) Expire(key string, exp time.Duration) error {
+	_, err := s.Touch(key, exp)
+	return err
+}
+
+// Touch sets a new expiration on a key without copying its value and reports
+// whether the key existed, mirroring the result of a Redis EXPIRE.
+// If exp is 0, the expiration is removed and the key never expires.
+func (s *MemoryStorage) Touch(key string, exp time.Duration) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+
+	data := s.touch(s.buildKey(key), exp)
+	return data != nil, nil
+}
+
+// GetAndRefresh returns a copy of the value for the given key and sets a new
+// expiration on it in one step. If exp is 0, the expiration is removed.
+// Returns nil, nil if the key does not exist.
+func (s *MemoryStorage) GetAndRefresh(key string, exp time.Duration) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	data := s.touch(s.buildKey(key), exp)
+	if data == nil {
+		s.counters.misses.Add(1)
+		return nil, nil
+	}
+	s.counters.hits.Add(1)
+
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out, nil
+}
+
+// touch sets a new expiration on the entry and returns its value,
+// or nil if the entry does not exist or has expired.
+func (s *MemoryStorage) touch(fullKey string, exp time.Duration) []byte {
+	sh := s.shard(fullKey)
+
+	// Entries are read without holding the lock, so the expiry is updated by
+	// swapping in a new entry that shares the stored value.
+	touched := &memoryEntry{}
+	touched.markAccessed()
+	if exp > 0 {
+		touched.expiresAt = time.Now().Add(exp)
+	}
+
+	sh.mu.Lock()
+	entry, ok := sh.data[fullKey]
+	expired := ok && entry.isExpired()
+	if expired {
+		delete(sh.data, fullKey)
+	} else if ok {
+		touched.data = entry.data
+		sh.data[fullKey] = touched
+	}
+	sh.mu.Unlock()
+
+	if expired {
+		s.notifyEvicted([]string{fullKey}, EvictionReasonExpired)
+		return nil
+	}
+	return touched.data
+}
+
+// Ping returns ErrClosed if the storage has been closed, or the context
+// error if ctx is done.
+func (s *MemoryStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	return ctx.Err()
+}
+
 // Close stops the garbage collector and releases resources.
+// Close is idempotent. After Close, Get, Set, Delete and Reset return ErrClosed;
+// entries are kept in memory and can still be counted with Len.
 func (s *MemoryStorage) Close() error {
-	if s.gcTicker != nil {
-		s.gcTicker.Stop()
-	}
-	close(s.done)
+	s.closeOnce.Do(func() {
+		s.gcMu.Lock()
+		s.closed.Store(true)
+		run := s.detachGCLocked()
+		s.gcMu.Unlock()
+		s.waitGC(run)
+	})
 	return nil
 }
 
 // Len returns the number of entries in the storage (including expired ones).
 func (s *MemoryStorage) Len() int {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return len(s.data)
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		n += len(sh.data)
+		sh.mu.RUnlock()
+	}
+	return n
 }
@@ -0,0 +1,148 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshotVersion is the current MemoryStorage snapshot format version.
+const snapshotVersion = 1
+
+// memorySnapshot is the serialized form of a MemoryStorage snapshot.
+type memorySnapshot struct {
+	Version   int                   `json:"version"`
+	CreatedAt time.Time             `json:"created_at"`
+	Entries   []memorySnapshotEntry `json:"entries"`
+}
+
+// memorySnapshotEntry is a single entry in a snapshot. Keys are stored
+// without the storage prefix and ExpiresAt is nil for entries that never expire.
+type memorySnapshotEntry struct {
+	Key       string     `json:"key"`
+	Value     []byte     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SaveSnapshot writes all non-expired entries to w, along with their absolute
+// expiration times. Each shard is locked only long enough to copy its entries;
+// encoding happens outside the locks so concurrent Gets are not blocked.
+func (s *MemoryStorage) SaveSnapshot(w io.Writer) error {
+	type ref struct {
+		key   string
+		entry *memoryEntry
+	}
+
+	var refs []ref
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for key, entry := range sh.data {
+			refs = append(refs, ref{key: key, entry: entry})
+		}
+		sh.mu.RUnlock()
+	}
+
+	snapshot := memorySnapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		Entries:   make([]memorySnapshotEntry, 0, len(refs)),
+	}
+	for _, r := range refs {
+		if r.entry.isExpired() {
+			continue
+		}
+		e := memorySnapshotEntry{
+			Key:   strings.TrimPrefix(r.key, s.keyPrefix),
+			Value: r.entry.data,
+		}
+		if !r.entry.expiresAt.IsZero() {
+			expiresAt := r.entry.expiresAt
+			e.ExpiresAt = &expiresAt
+		}
+		snapshot.Entries = append(snapshot.Entries, e)
+	}
+
+	if err := json.NewEncoder(w).Encode(&snapshot); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot restores entries previously written by SaveSnapshot.
+// Entries that have expired since the snapshot was taken are dropped.
+// Existing entries with the same keys are overwritten; other entries are kept.
+func (s *MemoryStorage) LoadSnapshot(r io.Reader) error {
+	var snapshot memorySnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version: %d", snapshot.Version)
+	}
+
+	now := time.Now()
+	for _, e := range snapshot.Entries {
+		if e.Key == "" || len(e.Value) == 0 {
+			continue
+		}
+
+		entry := &memoryEntry{data: e.Value}
+		entry.markAccessed()
+		if e.ExpiresAt != nil {
+			if !now.Before(*e.ExpiresAt) {
+				continue
+			}
+			entry.expiresAt = *e.ExpiresAt
+		}
+
+		fullKey := s.buildKey(e.Key)
+		sh := s.shard(fullKey)
+		sh.mu.Lock()
+		sh.data[fullKey] = entry
+		sh.mu.Unlock()
+	}
+
+	return nil
+}
+
+// SaveSnapshotFile writes a snapshot to the file at path. The snapshot is
+// written to a temporary file first and renamed into place, so a crash never
+// leaves a truncated snapshot behind.
+func (s *MemoryStorage) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if err := s.SaveSnapshot(tmp); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshotFile restores a snapshot from the file at path.
+// If the file does not exist, the returned error wraps fs.ErrNotExist.
+func (s *MemoryStorage) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return s.LoadSnapshot(f)
+}
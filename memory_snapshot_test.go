@@ -0,0 +1,141 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorageSnapshotRoundTrip(t *testing.T) {
+	src := NewMemoryStorage("test:", 0)
+	defer func() { _ = src.Close() }()
+
+	_ = src.Set("expiring", []byte("value1"), time.Hour)
+	_ = src.Set("persistent", []byte("value2"), 0)
+
+	var buf bytes.Buffer
+	if err := src.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+
+	dst := NewMemoryStorage("test:", 0)
+	defer func() { _ = dst.Close() }()
+
+	if err := dst.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+
+	got, _ := dst.Get("expiring")
+	if string(got) != "value1" {
+		t.Errorf("expected value1, got %s", string(got))
+	}
+	got, _ = dst.Get("persistent")
+	if string(got) != "value2" {
+		t.Errorf("expected value2, got %s", string(got))
+	}
+
+	// Expiry times are restored as absolute times
+	srcEntry := src.shard("test:expiring").data["test:expiring"]
+	dstEntry := dst.shard("test:expiring").data["test:expiring"]
+	if !srcEntry.expiresAt.Equal(dstEntry.expiresAt) {
+		t.Errorf("expected expiry %v, got %v", srcEntry.expiresAt, dstEntry.expiresAt)
+	}
+	if !dst.shard("test:persistent").data["test:persistent"].expiresAt.IsZero() {
+		t.Error("expected persistent entry to have no expiry")
+	}
+}
+
+func TestMemoryStorageSnapshotDropsExpired(t *testing.T) {
+	src := NewMemoryStorage("test:", 0)
+	defer func() { _ = src.Close() }()
+
+	_ = src.Set("short", []byte("value"), 30*time.Millisecond)
+	_ = src.Set("long", []byte("value"), time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"short"`) {
+		t.Fatal("expected snapshot to contain the short-lived entry")
+	}
+
+	// Expire the entry between save and load
+	time.Sleep(50 * time.Millisecond)
+
+	dst := NewMemoryStorage("test:", 0)
+	defer func() { _ = dst.Close() }()
+
+	if err := dst.LoadSnapshot(&buf); err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if dst.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", dst.Len())
+	}
+	if got, _ := dst.Get("short"); got != nil {
+		t.Error("expected expired entry to be dropped")
+	}
+}
+
+func TestMemoryStorageSnapshotSkipsExpiredOnSave(t *testing.T) {
+	src := NewMemoryStorage("test:", 0)
+	defer func() { _ = src.Close() }()
+
+	_ = src.Set("dead", []byte("value"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := src.SaveSnapshot(&buf); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	if strings.Contains(buf.String(), `"dead"`) {
+		t.Error("expected expired entry to be left out of the snapshot")
+	}
+}
+
+func TestMemoryStorageSnapshotVersion(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	err := storage.LoadSnapshot(strings.NewReader(`{"version":99,"entries":[]}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("expected unsupported version error, got %v", err)
+	}
+
+	err = storage.LoadSnapshot(strings.NewReader(`not json`))
+	if err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestMemoryStorageSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	src := NewMemoryStorage("test:", 0)
+	defer func() { _ = src.Close() }()
+	_ = src.Set("key", []byte("value"), time.Hour)
+
+	if err := src.SaveSnapshotFile(path); err != nil {
+		t.Fatalf("failed to save snapshot file: %v", err)
+	}
+
+	dst := NewMemoryStorage("test:", 0)
+	defer func() { _ = dst.Close() }()
+
+	if err := dst.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("failed to load snapshot file: %v", err)
+	}
+	got, _ := dst.Get("key")
+	if string(got) != "value" {
+		t.Errorf("expected value, got %s", string(got))
+	}
+
+	err := dst.LoadSnapshotFile(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// memoryCounters holds the operation counters of a MemoryStorage.
+// They are updated with atomic adds so they stay cheap on the hot path.
+type memoryCounters struct {
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	sets      atomic.Uint64
+	deletes   atomic.Uint64
+	evictions atomic.Uint64
+	gcRuns    atomic.Uint64
+}
+
+// MemoryStats is a point-in-time view of MemoryStorage usage.
+type MemoryStats struct {
+	// Entries is the number of entries held, including expired ones not yet collected.
+	Entries int `json:"entries"`
+	// ExpiredPending is the number of expired entries waiting to be collected.
+	ExpiredPending int `json:"expired_pending"`
+	// Hits is the number of Get calls that found a live entry.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of Get calls for missing or expired keys.
+	Misses uint64 `json:"misses"`
+	// Sets is the number of values stored.
+	Sets uint64 `json:"sets"`
+	// Deletes is the number of entries removed by Delete or DeleteMany.
+	Deletes uint64 `json:"deletes"`
+	// Evictions is the number of entries evicted because the storage was full.
+	Evictions uint64 `json:"evictions"`
+	// GCRuns is the number of completed garbage collection cycles.
+	GCRuns uint64 `json:"gc_runs"`
+	// LastGCDuration is how long the most recent garbage collection cycle took.
+	LastGCDuration time.Duration `json:"last_gc_duration"`
+}
+
+// HitRatio returns the fraction of Get calls that found a live entry,
+// or 0 if there were none.
+func (st MemoryStats) HitRatio() float64 {
+	total := st.Hits + st.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(st.Hits) / float64(total)
+}
+
+// Stats returns the current usage statistics of the storage.
+// Counting entries scans every shard, so avoid calling it on a hot path.
+func (s *MemoryStorage) Stats() MemoryStats {
+	st := MemoryStats{
+		Hits:           s.counters.hits.Load(),
+		Misses:         s.counters.misses.Load(),
+		Sets:           s.counters.sets.Load(),
+		Deletes:        s.counters.deletes.Load(),
+		Evictions:      s.counters.evictions.Load(),
+		GCRuns:         s.counters.gcRuns.Load(),
+		LastGCDuration: s.LastGCStats().Duration,
+	}
+
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		st.Entries += len(sh.data)
+		for _, entry := range sh.data {
+			if entry.isExpired() {
+				st.ExpiredPending++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	return st
+}
+
+// PublishExpvar publishes the storage statistics as an expvar variable with
+// the given name, served as a JSON object on /debug/vars. It returns an
+// error if a variable with that name is already published.
+func (s *MemoryStorage) PublishExpvar(name string) error {
+	if name == "" {
+		return fmt.Errorf("expvar name cannot be empty")
+	}
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %q is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return s.Stats()
+	}))
+	return nil
+}
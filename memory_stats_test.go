@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorageStats(t *testing.T) {
+	cfg := DefaultMemoryStorageConfig().WithGCInterval(0).WithShards(1)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key1", []byte("value"), time.Hour)
+	_ = storage.Set("key2", []byte("value"), time.Hour)
+	_ = storage.Set("short", []byte("value"), time.Millisecond)
+	_ = storage.Set("", []byte("ignored"), time.Hour)
+
+	_, _ = storage.Get("key1")
+	_, _ = storage.Get("key2")
+	_, _ = storage.Get("missing")
+
+	_ = storage.Delete("key2")
+	_ = storage.Delete("missing")
+
+	time.Sleep(5 * time.Millisecond)
+
+	st := storage.Stats()
+	if st.Entries != 2 {
+		t.Errorf("expected 2 entries, got %d", st.Entries)
+	}
+	if st.ExpiredPending != 1 {
+		t.Errorf("expected 1 expired pending entry, got %d", st.ExpiredPending)
+	}
+	if st.Hits != 2 || st.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d and %d", st.Hits, st.Misses)
+	}
+	if st.Sets != 3 {
+		t.Errorf("expected 3 sets, got %d", st.Sets)
+	}
+	if st.Deletes != 1 {
+		t.Errorf("expected 1 delete, got %d", st.Deletes)
+	}
+	if st.HitRatio() < 0.66 || st.HitRatio() > 0.67 {
+		t.Errorf("expected hit ratio of 2/3, got %f", st.HitRatio())
+	}
+
+	storage.gc()
+	st = storage.Stats()
+	if st.GCRuns != 1 {
+		t.Errorf("expected 1 GC run, got %d", st.GCRuns)
+	}
+	if st.ExpiredPending != 0 || st.Entries != 1 {
+		t.Errorf("expected GC to collect the expired entry, got %+v", st)
+	}
+	if st.LastGCDuration <= 0 {
+		t.Errorf("expected last GC duration to be recorded, got %v", st.LastGCDuration)
+	}
+
+	// Reading an expired key counts as a miss
+	_ = storage.Set("short", []byte("value"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, _ = storage.Get("short")
+	if st := storage.Stats(); st.Misses != 2 {
+		t.Errorf("expected 2 misses, got %d", st.Misses)
+	}
+
+	storage.SetMaxEntries(1)
+	_ = storage.Set("another", []byte("value"), time.Hour)
+	if st := storage.Stats(); st.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", st.Evictions)
+	}
+}
+
+func TestMemoryStatsHitRatioEmpty(t *testing.T) {
+	if ratio := (MemoryStats{}).HitRatio(); ratio != 0 {
+		t.Errorf("expected 0 hit ratio, got %f", ratio)
+	}
+}
+
+func TestMemoryStoragePublishExpvar(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	name := fmt.Sprintf("session_memory_test_%d", time.Now().UnixNano())
+	if err := storage.PublishExpvar(name); err != nil {
+		t.Fatalf("failed to publish expvar: %v", err)
+	}
+	if err := storage.PublishExpvar(name); err == nil {
+		t.Error("expected error when publishing the same name twice")
+	}
+	if err := storage.PublishExpvar(""); err == nil {
+		t.Error("expected error for empty name")
+	}
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_, _ = storage.Get("key")
+
+	var st MemoryStats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &st); err != nil {
+		t.Fatalf("failed to decode expvar output: %v", err)
+	}
+	if st.Entries != 1 || st.Hits != 1 || st.Sets != 1 {
+		t.Errorf("unexpected expvar stats: %+v", st)
+	}
+}
+
+func BenchmarkMemoryStorageGetSet(b *testing.B) {
+	storage := NewMemoryStorage("bench:", 0)
+	defer func() { _ = storage.Close() }()
+
+	value := []byte("value")
+	_ = storage.Set("key", value, time.Hour)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = storage.Set("key", value, time.Hour)
+		_, _ = storage.Get("key")
+	}
+}
@@ -1,6 +1,12 @@
 package session
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -9,6 +15,10 @@ func TestMemoryStorageBasicOperations(t *testing.T) {
 	storage := NewMemoryStorage("test:", 0)
 	defer func() { _ = storage.Close() }()
 
+	if storage.GetKeyPrefix() != "test:" {
+		t.Errorf("expected prefix 'test:', got %s", storage.GetKeyPrefix())
+	}
+
 	// Test Set and Get
 	key := "session1"
 	value := []byte("test data")
@@ -206,3 +216,947 @@ func TestMemoryStorageDataIsolation(t *testing.T) {
 		t.Error("expected data to be isolated from original slice")
 	}
 }
+
+func TestMemoryStorageGetReturnsCopy(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("original"), time.Hour)
+
+	// Mutating the result of Get must not affect the stored value
+	got, _ := storage.Get("key")
+	got[0] = 'X'
+
+	again, _ := storage.Get("key")
+	if string(again) != "original" {
+		t.Errorf("expected stored value to be unchanged, got %s", string(again))
+	}
+}
+
+func TestMemoryStorageGetUnsafe(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	got, err := storage.GetUnsafe("key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("expected value, got %s", string(got))
+	}
+
+	got, err = storage.GetUnsafe("missing")
+	if err != nil || got != nil {
+		t.Errorf("expected nil, nil for missing key, got %v, %v", got, err)
+	}
+}
+
+// evictionRecorder collects eviction callback invocations for assertions.
+type evictionRecorder struct {
+	mu     sync.Mutex
+	events map[string]EvictionReason
+}
+
+func newEvictionRecorder() *evictionRecorder {
+	return &evictionRecorder{events: make(map[string]EvictionReason)}
+}
+
+func (r *evictionRecorder) record(key string, reason EvictionReason) {
+	r.mu.Lock()
+	r.events[key] = reason
+	r.mu.Unlock()
+}
+
+func (r *evictionRecorder) get(key string) (EvictionReason, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reason, ok := r.events[key]
+	return reason, ok
+}
+
+func TestMemoryStorageEvictionCallbackDeleted(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Delete("key")
+
+	reason, ok := rec.get("key")
+	if !ok || reason != EvictionReasonDeleted {
+		t.Errorf("expected key to be reported as deleted, got %v (reported=%v)", reason, ok)
+	}
+
+	// Deleting a missing key must not fire the callback
+	_ = storage.Delete("missing")
+	if _, ok := rec.get("missing"); ok {
+		t.Error("expected no callback for missing key")
+	}
+}
+
+func TestMemoryStorageEvictionCallbackExpired(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+
+	_ = storage.Set("key", []byte("value"), 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if got, _ := storage.Get("key"); got != nil {
+		t.Fatal("expected value to be expired")
+	}
+
+	reason, ok := rec.get("key")
+	if !ok || reason != EvictionReasonExpired {
+		t.Errorf("expected key to be reported as expired, got %v (reported=%v)", reason, ok)
+	}
+}
+
+func TestMemoryStorageEvictionCallbackGC(t *testing.T) {
+	storage := NewMemoryStorage("test:", 20*time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	done := make(chan EvictionReason, 1)
+	storage.SetEvictionCallback(func(key string, reason EvictionReason) {
+		if key == "key" {
+			done <- reason
+		}
+	})
+
+	_ = storage.Set("key", []byte("value"), 10*time.Millisecond)
+
+	select {
+	case reason := <-done:
+		if reason != EvictionReasonExpired {
+			t.Errorf("expected expired reason, got %v", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected GC to report the expired key")
+	}
+}
+
+func TestMemoryStorageEvictionCallbackReset(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+
+	_ = storage.Set("key1", []byte("value1"), time.Hour)
+	_ = storage.Set("key2", []byte("value2"), 0)
+	_ = storage.Reset()
+
+	for _, key := range []string{"key1", "key2"} {
+		reason, ok := rec.get(key)
+		if !ok || reason != EvictionReasonReset {
+			t.Errorf("expected %s to be reported as reset, got %v (reported=%v)", key, reason, ok)
+		}
+	}
+}
+
+func TestMemoryStorageEvictionCallbackEvicted(t *testing.T) {
+	storage := NewMemoryStorageWithConfig(DefaultMemoryStorageConfig().WithGCInterval(0).WithShards(1))
+	defer func() { _ = storage.Close() }()
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+	storage.SetMaxEntries(2)
+
+	_ = storage.Set("idle", []byte("value"), time.Hour)
+	time.Sleep(time.Millisecond)
+	_ = storage.Set("active", []byte("value"), time.Minute)
+
+	// Overwriting an existing key must not evict anything
+	_ = storage.Set("active", []byte("value2"), time.Minute)
+	if storage.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", storage.Len())
+	}
+
+	// Reading an entry keeps it, however soon it expires
+	time.Sleep(time.Millisecond)
+	_, _ = storage.Get("active")
+	_ = storage.Set("new", []byte("value"), time.Hour)
+	if storage.Len() != 2 {
+		t.Errorf("expected 2 entries after eviction, got %d", storage.Len())
+	}
+
+	reason, ok := rec.get("idle")
+	if !ok || reason != EvictionReasonEvicted {
+		t.Errorf("expected the least recently used entry to be evicted, got %v (reported=%v)", reason, ok)
+	}
+	if _, ok := rec.get("active"); ok {
+		t.Error("expected the recently read entry to be kept")
+	}
+}
+
+func TestMemoryStorageEvictionCallbackReentrant(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	storage.SetEvictionCallback(func(key string, reason EvictionReason) {
+		// Re-entering the storage must not deadlock
+		_ = storage.Set("tombstone:"+key, []byte(reason.String()), time.Hour)
+	})
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Delete("key")
+
+	got, _ := storage.Get("tombstone:key")
+	if string(got) != "deleted" {
+		t.Errorf("expected tombstone to be written, got %q", string(got))
+	}
+}
+
+func TestMemoryStorageEvictionCallbackPanic(t *testing.T) {
+	storage := NewMemoryStorage("test:", 20*time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	var calls int32
+	storage.SetEvictionCallback(func(key string, reason EvictionReason) {
+		atomic.AddInt32(&calls, 1)
+		panic("boom")
+	})
+
+	_ = storage.Set("key1", []byte("value"), 10*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+
+	// The GC goroutine must survive the panic and keep collecting
+	_ = storage.Set("key2", []byte("value"), 10*time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&calls); n < 2 {
+		t.Errorf("expected GC to keep running after a callback panic, got %d calls", n)
+	}
+	if storage.Len() != 0 {
+		t.Errorf("expected 0 entries after GC, got %d", storage.Len())
+	}
+}
+
+func TestEvictionReasonString(t *testing.T) {
+	tests := map[EvictionReason]string{
+		EvictionReasonExpired: "expired",
+		EvictionReasonDeleted: "deleted",
+		EvictionReasonEvicted: "evicted",
+		EvictionReasonReset:   "reset",
+		EvictionReason(99):    "unknown",
+	}
+	for reason, want := range tests {
+		if got := reason.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestMemoryStorageWithConfigDefaults(t *testing.T) {
+	storage := NewMemoryStorageWithConfig(MemoryStorageConfig{KeyPrefix: "test", GCBatchSize: -1, GCJitter: 5})
+	defer func() { _ = storage.Close() }()
+
+	if storage.keyPrefix != "test:" {
+		t.Errorf("expected prefix 'test:', got %s", storage.keyPrefix)
+	}
+	if storage.config.GCBatchSize != DefaultMemoryStorageConfig().GCBatchSize {
+		t.Errorf("expected default batch size, got %d", storage.config.GCBatchSize)
+	}
+	if storage.config.GCJitter != 1 {
+		t.Errorf("expected jitter to be clamped to 1, got %f", storage.config.GCJitter)
+	}
+}
+
+func TestMemoryStorageGCBatchLimit(t *testing.T) {
+	cfg := DefaultMemoryStorageConfig().
+		WithKeyPrefix("test:").
+		WithGCInterval(0).
+		WithGCBatchSize(100).
+		WithShards(1)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	for i := 0; i < 1000; i++ {
+		_ = storage.Set(fmt.Sprintf("live%d", i), []byte("value"), time.Hour)
+	}
+	for i := 0; i < 5; i++ {
+		_ = storage.Set(fmt.Sprintf("dead%d", i), []byte("value"), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Mostly live entries: a single batch is examined
+	stats := storage.gc()
+	if stats.Scanned > 100 {
+		t.Errorf("expected at most 100 entries scanned, got %d", stats.Scanned)
+	}
+	if stats.Duration <= 0 || stats.FinishedAt.IsZero() {
+		t.Errorf("expected duration and finish time to be recorded, got %+v", stats)
+	}
+	if got := storage.LastGCStats(); got != stats {
+		t.Errorf("expected last GC stats %+v, got %+v", stats, got)
+	}
+}
+
+func TestMemoryStorageGCBudgetSharedByShards(t *testing.T) {
+	cfg := DefaultMemoryStorageConfig().
+		WithKeyPrefix("test:").
+		WithGCInterval(0).
+		WithGCBatchSize(100).
+		WithShards(16)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	for i := 0; i < 1000; i++ {
+		_ = storage.Set(fmt.Sprintf("dead%d", i), []byte("value"), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Every cycle stays within the budget, and repeated cycles collect all
+	// entries even though the shards hold different numbers of them
+	cycles := 0
+	for storage.Len() > 0 && cycles < 20 {
+		if stats := storage.gc(); stats.Scanned > 100 || stats.Removed != stats.Scanned {
+			t.Fatalf("cycle %d: expected up to 100 expired entries examined, got %+v", cycles, stats)
+		}
+		cycles++
+	}
+	if storage.Len() != 0 || cycles < 10 {
+		t.Errorf("expected all entries collected in at least 10 cycles, got %d left after %d", storage.Len(), cycles)
+	}
+}
+
+func TestMemoryStorageGCRotatesShards(t *testing.T) {
+	cfg := DefaultMemoryStorageConfig().
+		WithKeyPrefix("test:").
+		WithGCInterval(0).
+		WithGCBatchSize(4).
+		WithShards(16)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	for i := 0; i < 1000; i++ {
+		_ = storage.Set(fmt.Sprintf("dead%d", i), []byte("value"), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	before := make([]int, len(storage.shards))
+	for i, sh := range storage.shards {
+		before[i] = len(sh.data)
+	}
+	// A budget smaller than the number of shards reaches each of them in turn
+	for cycle := 0; cycle < 4; cycle++ {
+		storage.gc()
+	}
+	for i, sh := range storage.shards {
+		if removed := before[i] - len(sh.data); removed != 1 {
+			t.Errorf("shard %d: expected 1 entry collected, got %d", i, removed)
+		}
+	}
+}
+
+func TestMemoryStorageGCJitter(t *testing.T) {
+	cfg := DefaultMemoryStorageConfig().
+		WithGCInterval(0).
+		WithGCJitter(0.2)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	for i := 0; i < 100; i++ {
+		d := storage.nextGCDelay(time.Second)
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("expected delay within ±20%% of 1s, got %v", d)
+		}
+	}
+
+	storage.config.GCJitter = 0
+	if d := storage.nextGCDelay(time.Second); d != time.Second {
+		t.Errorf("expected exact interval without jitter, got %v", d)
+	}
+}
+
+// BenchmarkMemoryStorageGetDuringGC measures Get latency on a large storage
+// while garbage collection sweeps it, reporting the worst observed latency.
+func BenchmarkMemoryStorageGetDuringGC(b *testing.B) {
+	cfg := DefaultMemoryStorageConfig().WithGCInterval(0)
+	storage := NewMemoryStorageWithConfig(cfg)
+	defer func() { _ = storage.Close() }()
+
+	const entries = 500000
+	value := []byte("value")
+	for i := 0; i < entries; i++ {
+		exp := time.Hour
+		if i%10 == 0 {
+			exp = time.Millisecond
+		}
+		_ = storage.Set(fmt.Sprintf("key%d", i), value, exp)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				storage.gc()
+			}
+		}
+	}()
+	defer close(stop)
+
+	var worst time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		_, _ = storage.Get(fmt.Sprintf("key%d", i%entries))
+		if d := time.Since(start); d > worst {
+			worst = d
+		}
+	}
+	b.ReportMetric(float64(worst.Microseconds()), "max-µs")
+}
+
+func TestMemoryStorageShardCount(t *testing.T) {
+	tests := []struct {
+		in   int
+		want int
+	}{
+		{0, 16},
+		{-3, 16},
+		{1, 1},
+		{3, 4},
+		{16, 16},
+		{17, 32},
+	}
+	for _, tt := range tests {
+		if got := shardCount(tt.in); got != tt.want {
+			t.Errorf("shardCount(%d): expected %d, got %d", tt.in, tt.want, got)
+		}
+	}
+}
+
+func TestMemoryStorageShardedAggregates(t *testing.T) {
+	storage := NewMemoryStorageWithConfig(DefaultMemoryStorageConfig().WithGCInterval(0).WithShards(8))
+	defer func() { _ = storage.Close() }()
+
+	if len(storage.shards) != 8 {
+		t.Fatalf("expected 8 shards, got %d", len(storage.shards))
+	}
+
+	for i := 0; i < 200; i++ {
+		_ = storage.Set(fmt.Sprintf("key%d", i), []byte("value"), time.Hour)
+	}
+	if storage.Len() != 200 {
+		t.Errorf("expected 200 entries, got %d", storage.Len())
+	}
+
+	used := 0
+	for _, sh := range storage.shards {
+		if len(sh.data) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("expected keys to be spread over several shards, got %d", used)
+	}
+
+	_ = storage.Reset()
+	if storage.Len() != 0 {
+		t.Errorf("expected 0 entries after reset, got %d", storage.Len())
+	}
+}
+
+func TestMemoryStorageConcurrentAccess(t *testing.T) {
+	storage := NewMemoryStorage("test:", 5*time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key%d", (w*500+i)%100)
+				_ = storage.Set(key, []byte("value"), time.Millisecond*time.Duration(1+i%5))
+				_, _ = storage.Get(key)
+				if i%7 == 0 {
+					_ = storage.Delete(key)
+				}
+				if i%97 == 0 {
+					_ = storage.Len()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func benchmarkMemoryStorageParallel(b *testing.B, shards int) {
+	storage := NewMemoryStorageWithConfig(DefaultMemoryStorageConfig().WithGCInterval(0).WithShards(shards))
+	defer func() { _ = storage.Close() }()
+
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		_ = storage.Set(keys[i], []byte("value"), time.Hour)
+	}
+	value := []byte("value")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				_ = storage.Set(key, value, time.Hour)
+			} else {
+				_, _ = storage.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkMemoryStorageParallelSingleLock uses one shard, matching the
+// previous single-mutex layout.
+func BenchmarkMemoryStorageParallelSingleLock(b *testing.B) {
+	benchmarkMemoryStorageParallel(b, 1)
+}
+
+func BenchmarkMemoryStorageParallelSharded(b *testing.B) {
+	benchmarkMemoryStorageParallel(b, 16)
+}
+
+func TestFNV32a(t *testing.T) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte("session:abc"))
+	if got := fnv32a("session:abc"); got != h.Sum32() {
+		t.Errorf("expected %d, got %d", h.Sum32(), got)
+	}
+}
+
+func TestMemoryStorageCloseIdempotent(t *testing.T) {
+	storage := NewMemoryStorage("test:", 10*time.Millisecond)
+
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error on first close: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %v", err)
+	}
+}
+
+func TestMemoryStorageUseAfterClose(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Fatalf("expected open storage to be healthy, got %v", err)
+	}
+	_ = storage.Close()
+
+	if err := storage.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Ping, got %v", err)
+	}
+	if _, err := storage.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Get, got %v", err)
+	}
+	if err := storage.Set("key", []byte("value"), time.Hour); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Set, got %v", err)
+	}
+	if err := storage.Delete("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Delete, got %v", err)
+	}
+	if err := storage.Reset(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from Reset, got %v", err)
+	}
+	if err := storage.StartGC(time.Second); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from StartGC, got %v", err)
+	}
+	if storage.Len() != 1 {
+		t.Errorf("expected entries to be kept after close, got %d", storage.Len())
+	}
+}
+
+func TestMemoryStorageStartStopGC(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.StartGC(0); err == nil {
+		t.Error("expected error for zero interval")
+	}
+
+	_ = storage.Set("key1", []byte("value"), 5*time.Millisecond)
+
+	// Start with a long interval, then shorten it while entries exist
+	if err := storage.StartGC(time.Hour); err != nil {
+		t.Fatalf("failed to start GC: %v", err)
+	}
+	if err := storage.StartGC(10 * time.Millisecond); err != nil {
+		t.Fatalf("failed to restart GC: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if storage.Len() != 0 {
+		t.Errorf("expected 0 entries after GC, got %d", storage.Len())
+	}
+
+	// After StopGC expired entries are no longer collected in the background
+	storage.StopGC()
+	storage.StopGC()
+	_ = storage.Set("key2", []byte("value"), 5*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if storage.Len() != 1 {
+		t.Errorf("expected expired entry to remain after StopGC, got %d", storage.Len())
+	}
+}
+
+func TestMemoryStorageGCControlFromCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		control func(s *MemoryStorage)
+		running bool
+	}{
+		{"stop", func(s *MemoryStorage) { s.StopGC() }, false},
+		{"restart", func(s *MemoryStorage) { _ = s.StartGC(10 * time.Millisecond) }, true},
+		{"close", func(s *MemoryStorage) { _ = s.Close() }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMemoryStorageConfig().
+				WithKeyPrefix("test:").
+				WithGCInterval(10 * time.Millisecond).
+				WithGCJitter(0)
+			storage := NewMemoryStorageWithConfig(cfg)
+			defer func() { _ = storage.Close() }()
+
+			// The collector calls back into its own controls from its goroutine
+			returned := make(chan struct{})
+			var once sync.Once
+			storage.SetEvictionCallback(func(key string, reason EvictionReason) {
+				once.Do(func() {
+					tt.control(storage)
+					close(returned)
+				})
+			})
+			_ = storage.Set("first", []byte("value"), time.Millisecond)
+			select {
+			case <-returned:
+			case <-time.After(time.Second):
+				t.Fatal("expected the callback to return")
+			}
+
+			storage.gcMu.Lock()
+			running := storage.gcRun != nil
+			storage.gcMu.Unlock()
+			if running != tt.running {
+				t.Errorf("expected GC running %v, got %v", tt.running, running)
+			}
+		})
+	}
+}
+
+func TestMemoryStorageTouch(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	ok, err := storage.Touch("missing", time.Hour)
+	if err != nil || ok {
+		t.Errorf("expected false, nil for missing key, got %v, %v", ok, err)
+	}
+
+	_ = storage.Set("key", []byte("value"), 20*time.Millisecond)
+	ok, err = storage.Touch("key", time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("expected true, nil for existing key, got %v, %v", ok, err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	got, _ := storage.Get("key")
+	if string(got) != "value" {
+		t.Errorf("expected touched key to survive its original TTL, got %q", string(got))
+	}
+
+	ttl, _ := storage.GetTTL("key")
+	if ttl <= 59*time.Minute {
+		t.Errorf("expected TTL close to 1h, got %v", ttl)
+	}
+}
+
+func TestMemoryStorageTouchZeroRemovesExpiry(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 20*time.Millisecond)
+	ok, err := storage.Touch("key", 0)
+	if err != nil || !ok {
+		t.Fatalf("expected true, nil, got %v, %v", ok, err)
+	}
+
+	ttl, _ := storage.GetTTL("key")
+	if ttl != -1 {
+		t.Errorf("expected TTL -1 for key without expiry, got %v", ttl)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if got, _ := storage.Get("key"); got == nil {
+		t.Error("expected key without expiry to survive")
+	}
+}
+
+func TestMemoryStorageTouchWithGC(t *testing.T) {
+	storage := NewMemoryStorage("test:", 10*time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("touched", []byte("value"), 30*time.Millisecond)
+	_ = storage.Set("untouched", []byte("value"), 30*time.Millisecond)
+	_, _ = storage.Touch("touched", time.Hour)
+
+	time.Sleep(80 * time.Millisecond)
+
+	if storage.Len() != 1 {
+		t.Errorf("expected only the touched entry to survive GC, got %d entries", storage.Len())
+	}
+	if ok, _ := storage.Exists("touched"); !ok {
+		t.Error("expected touched entry to exist")
+	}
+
+	// Touching an entry that already expired reports it as missing
+	_ = storage.Set("late", []byte("value"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ok, _ := storage.Touch("late", time.Hour); ok {
+		t.Error("expected expired entry to be reported as missing")
+	}
+}
+
+func TestMemoryStorageExtendedOperations(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	var _ ExtendedStorage = storage
+
+	if ok, _ := storage.Exists("key"); ok {
+		t.Error("expected key to not exist")
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl != -2 {
+		t.Errorf("expected TTL -2 for missing key, got %v", ttl)
+	}
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if ok, _ := storage.Exists("key"); !ok {
+		t.Error("expected key to exist")
+	}
+
+	if err := storage.Expire("key", time.Minute); err != nil {
+		t.Fatalf("failed to expire: %v", err)
+	}
+	ttl, _ := storage.GetTTL("key")
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected TTL within 1m, got %v", ttl)
+	}
+}
+
+func TestMemoryStorageDeleteMany(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	var _ BatchDeleter = storage
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+
+	for i := 0; i < 10; i++ {
+		_ = storage.Set(fmt.Sprintf("key%d", i), []byte("value"), time.Hour)
+	}
+
+	if err := storage.DeleteMany(nil); err != nil {
+		t.Fatalf("expected no error for empty input, got %v", err)
+	}
+
+	if err := storage.DeleteMany([]string{"key1", "key3", "key5", "missing"}); err != nil {
+		t.Fatalf("failed to delete many: %v", err)
+	}
+	if storage.Len() != 7 {
+		t.Errorf("expected 7 entries, got %d", storage.Len())
+	}
+	if got, _ := storage.Get("key3"); got != nil {
+		t.Error("expected key3 to be deleted")
+	}
+	if got, _ := storage.Get("key2"); got == nil {
+		t.Error("expected key2 to survive")
+	}
+	if reason, ok := rec.get("key5"); !ok || reason != EvictionReasonDeleted {
+		t.Errorf("expected key5 to be reported as deleted, got %v (reported=%v)", reason, ok)
+	}
+	if _, ok := rec.get("missing"); ok {
+		t.Error("expected no callback for missing key")
+	}
+}
+
+func TestMemoryStorageNotifyExpired(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	rec := newEvictionRecorder()
+	storage.SetEvictionCallback(rec.record)
+
+	var expired []string
+	_ = storage.NotifyExpired(func(key string) { expired = append(expired, key) })
+
+	_ = storage.Set("short", []byte("value"), time.Millisecond)
+	_ = storage.Set("deleted", []byte("value"), time.Hour)
+	_ = storage.Delete("deleted")
+	time.Sleep(5 * time.Millisecond)
+	storage.gc()
+
+	if len(expired) != 1 || expired[0] != "short" {
+		t.Errorf("expected only 'short' to be reported as expired, got %v", expired)
+	}
+	// The eviction callback keeps working alongside the expiry listener
+	if _, ok := rec.get("short"); !ok {
+		t.Error("expected eviction callback to fire too")
+	}
+}
+
+func TestMemoryStorageGetSetMulti(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	err := storage.SetMulti(map[string][]byte{
+		"a":     []byte("1"),
+		"b":     []byte("2"),
+		"empty": nil,
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to set multi: %v", err)
+	}
+	if storage.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", storage.Len())
+	}
+
+	got, err := storage.GetMulti([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("failed to get multi: %v", err)
+	}
+	if len(got) != 2 || string(got["a"]) != "1" || string(got["b"]) != "2" {
+		t.Errorf("unexpected result: %v", got)
+	}
+
+	_ = storage.Close()
+	if _, err := storage.GetMulti([]string{"a"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := storage.SetMulti(map[string][]byte{"a": []byte("1")}, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestMemoryStorageSetKeepTTL(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("v1"), time.Hour)
+	if err := storage.SetKeepTTL("key", []byte("v2")); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "v2" {
+		t.Errorf("expected v2, got %s", string(got))
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl <= 59*time.Minute {
+		t.Errorf("expected TTL to survive the update, got %v", ttl)
+	}
+
+	_ = storage.SetKeepTTL("new", []byte("value"))
+	if ttl, _ := storage.GetTTL("new"); ttl != -1 {
+		t.Errorf("expected no expiration for a new key, got %v", ttl)
+	}
+
+	// An expired entry does not pass on its expiration
+	_ = storage.Set("dead", []byte("v1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_ = storage.SetKeepTTL("dead", []byte("v2"))
+	if got, _ := storage.Get("dead"); string(got) != "v2" {
+		t.Errorf("expected v2, got %s", string(got))
+	}
+}
+
+func TestMemoryStorageSetIfAbsent(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	testSetIfAbsent(t, storage)
+}
+
+// testSetIfAbsent checks that storage stores values with SetIfAbsent only
+// over missing or expired keys.
+func testSetIfAbsent(t *testing.T, storage interface {
+	Storage
+	AbsentSetter
+}) {
+	t.Helper()
+
+	if stored, err := storage.SetIfAbsent("key", []byte("v1"), time.Hour); !stored || err != nil {
+		t.Fatalf("expected a missing key to be stored, got %v, %v", stored, err)
+	}
+	if stored, err := storage.SetIfAbsent("key", []byte("v2"), time.Hour); stored || err != nil {
+		t.Errorf("expected an existing key to be kept, got %v, %v", stored, err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "v1" {
+		t.Errorf("expected v1, got %s", got)
+	}
+
+	// Expired keys count as missing
+	_ = storage.Set("dead", []byte("v1"), 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if stored, err := storage.SetIfAbsent("dead", []byte("v2"), time.Hour); !stored || err != nil {
+		t.Errorf("expected an expired key to be replaced, got %v, %v", stored, err)
+	}
+	if got, _ := storage.Get("dead"); string(got) != "v2" {
+		t.Errorf("expected v2, got %s", got)
+	}
+
+	if stored, err := storage.SetIfAbsent("", []byte("value"), time.Hour); stored || err != nil {
+		t.Errorf("expected an empty key to be ignored, got %v, %v", stored, err)
+	}
+}
+
+func TestMemoryStorageGetAndRefresh(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Minute)
+	got, err := storage.GetAndRefresh("key", time.Hour)
+	if err != nil || string(got) != "value" {
+		t.Fatalf("expected value, got %q (%v)", got, err)
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl <= 59*time.Minute {
+		t.Errorf("expected TTL to be refreshed, got %v", ttl)
+	}
+
+	// The returned slice is a copy
+	got[0] = 'X'
+	if again, _ := storage.Get("key"); string(again) != "value" {
+		t.Error("expected stored value to be unaffected by caller mutation")
+	}
+
+	if got, err := storage.GetAndRefresh("missing", time.Hour); err != nil || got != nil {
+		t.Errorf("expected nil, nil for missing key, got %q (%v)", got, err)
+	}
+
+	_ = storage.Set("dead", []byte("value"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if got, _ := storage.GetAndRefresh("dead", time.Hour); got != nil {
+		t.Error("expected expired key to not be refreshed")
+	}
+}
+
+func TestMemoryStorageDeleteByPattern(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	testDeleteByPattern(t, storage)
+
+	_ = storage.Close()
+	if _, err := storage.DeleteByPattern("*"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// Error codes of the responses of the authorization middlewares, in the
+// "error" field of their JSON body.
+const (
+	// ErrorCodeUnauthenticated is returned with 401 when the session is not
+	// authenticated.
+	ErrorCodeUnauthenticated = "unauthenticated"
+
+	// ErrorCodeStepUpRequired is returned with 403 by RequireAMR when the
+	// session lacks required authentication methods, listed in "missing".
+	ErrorCodeStepUpRequired = "step_up_required"
+
+	// ErrorCodeReauthenticationRequired is returned with 403 by
+	// RequireRecentAuth when the user authenticated too long ago; "max_age"
+	// gives the accepted age in seconds.
+	ErrorCodeReauthenticationRequired = "reauthentication_required"
+
+	// ErrorCodeInsufficientRole is returned with 403 by RequireRole when the
+	// session lacks required roles, listed in "missing".
+	ErrorCodeInsufficientRole = "insufficient_role"
+
+	// ErrorCodeSessionExpired is returned with 401 by the middleware
+	// returned by NewMaxLifetimeMiddleware when the session has reached its
+	// maximum lifetime.
+	ErrorCodeSessionExpired = "session_expired"
+)
+
+// AuthErrorResponse is the JSON body of the error responses of the
+// authorization middlewares, for frontends to tell which flow to start.
+type AuthErrorResponse struct {
+	Error   string   `json:"error"`
+	Missing []string `json:"missing,omitempty"`
+	MaxAge  int64    `json:"max_age,omitempty"`
+}
+
+// RequireAMR returns a middleware that lets requests through only if their
+// session is authenticated and its AMR contains all of methods, such as
+// "otp" for routes requiring a second factor. Otherwise it responds with 401
+// and ErrorCodeUnauthenticated, or 403 and ErrorCodeStepUpRequired naming
+// the missing methods in sorted order.
+//
+// Like the other Require middlewares, it loads the session with
+// GetSessionCached, so handlers behind it can reuse it without decoding it
+// again.
+func RequireAMR(store *fibersession.Store, methods ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			return c.Status(fiber.StatusUnauthorized).JSON(AuthErrorResponse{Error: ErrorCodeUnauthenticated})
+		}
+
+		var missing []string
+		for _, method := range methods {
+			if !HasAMR(sess, method) {
+				missing = append(missing, method)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return c.Status(fiber.StatusForbidden).JSON(AuthErrorResponse{
+				Error:   ErrorCodeStepUpRequired,
+				Missing: missing,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireRole returns a middleware that lets requests through only if their
+// session is authenticated and has all of roles, as reported by HasRole.
+// Otherwise it responds with 401 and ErrorCodeUnauthenticated, or 403 and
+// ErrorCodeInsufficientRole naming the missing roles in sorted order.
+func RequireRole(store *fibersession.Store, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			return c.Status(fiber.StatusUnauthorized).JSON(AuthErrorResponse{Error: ErrorCodeUnauthenticated})
+		}
+
+		var missing []string
+		for _, role := range roles {
+			if !HasRole(sess, role) {
+				missing = append(missing, role)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return c.Status(fiber.StatusForbidden).JSON(AuthErrorResponse{
+				Error:   ErrorCodeInsufficientRole,
+				Missing: missing,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireRecentAuth returns a middleware that lets requests through only if
+// their session is authenticated and the user authenticated at most maxAge
+// ago, as reported by GetAuthTime. Otherwise it responds with 401 and
+// ErrorCodeUnauthenticated, or 403 and ErrorCodeReauthenticationRequired.
+func RequireRecentAuth(store *fibersession.Store, maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			return c.Status(fiber.StatusUnauthorized).JSON(AuthErrorResponse{Error: ErrorCodeUnauthenticated})
+		}
+
+		authTime := GetAuthTime(sess)
+		if authTime.IsZero() || time.Since(authTime) > maxAge {
+			return c.Status(fiber.StatusForbidden).JSON(AuthErrorResponse{
+				Error:  ErrorCodeReauthenticationRequired,
+				MaxAge: int64(maxAge / time.Second),
+			})
+		}
+		return c.Next()
+	}
+}
+
+// MaxLifetimeOptions configures the middleware returned by
+// NewMaxLifetimeMiddlewareWithOptions.
+type MaxLifetimeOptions struct {
+	// MaxLifetime is how long an authenticated session lasts after
+	// authentication, regardless of activity.
+	// Default: 12 hours
+	MaxLifetime time.Duration
+
+	// RedirectURL, if set, is where requests with an expired session are
+	// redirected, such as the login page, instead of getting a 401.
+	// Default: "" (respond with 401)
+	RedirectURL string
+}
+
+// DefaultMaxLifetimeOptions returns a MaxLifetimeOptions with default values.
+func DefaultMaxLifetimeOptions() MaxLifetimeOptions {
+	return MaxLifetimeOptions{
+		MaxLifetime: 12 * time.Hour,
+	}
+}
+
+// WithMaxLifetime sets the maximum session lifetime.
+func (o MaxLifetimeOptions) WithMaxLifetime(maxLifetime time.Duration) MaxLifetimeOptions {
+	o.MaxLifetime = maxLifetime
+	return o
+}
+
+// WithRedirectURL sets the URL requests with an expired session are
+// redirected to.
+func (o MaxLifetimeOptions) WithRedirectURL(url string) MaxLifetimeOptions {
+	o.RedirectURL = url
+	return o
+}
+
+// NewMaxLifetimeMiddleware returns a middleware capping the lifetime of
+// authenticated sessions at maxLifetime since their creation time, as set by
+// Authenticate, whatever their activity. Expired sessions are destroyed and
+// the request gets a 401 with ErrorCodeSessionExpired. Anonymous sessions,
+// and authenticated ones without a creation time, are let through.
+func NewMaxLifetimeMiddleware(store *fibersession.Store, maxLifetime time.Duration) fiber.Handler {
+	return NewMaxLifetimeMiddlewareWithOptions(store, DefaultMaxLifetimeOptions().WithMaxLifetime(maxLifetime))
+}
+
+// NewMaxLifetimeMiddlewareWithOptions is NewMaxLifetimeMiddleware with
+// options, such as redirecting to the login page instead of responding with
+// 401.
+func NewMaxLifetimeMiddlewareWithOptions(store *fibersession.Store, opts MaxLifetimeOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			return c.Next()
+		}
+		createdAt := GetCreatedAt(sess)
+		if createdAt.IsZero() || time.Since(createdAt) <= opts.MaxLifetime {
+			return c.Next()
+		}
+
+		if err := destroySession(c, store, sess); err != nil {
+			return err
+		}
+		if opts.RedirectURL != "" {
+			return c.Redirect(opts.RedirectURL, fiber.StatusFound)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(AuthErrorResponse{Error: ErrorCodeSessionExpired})
+	}
+}
+
+// NewAutoTouchMiddleware returns a middleware implementing sliding
+// expiration for Fiber sessions: after the handler runs, it updates the
+// session's last access time with UpdateLastAccess and saves it, which also
+// renews its expiration. To avoid a storage write on every request, it only
+// does so if the previous last access is at least minInterval old.
+//
+// The session is loaded after the handler with GetSessionCached, so keys
+// the handler saved are kept and changes it made to the cached session are
+// saved along with the touch. Requests without a stored session, including
+// ones whose handler destroyed it, are left alone; no session is created
+// for them. Neither are requests whose handler saved the cached session,
+// which renewed its expiration already.
+func NewAutoTouchMiddleware(store *fibersession.Store, minInterval time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if savedCached(c, store) {
+			// The handler saved the session, which renewed it
+			return nil
+		}
+
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if sess.Fresh() {
+			return nil
+		}
+		if last := GetLastAccess(sess); !last.IsZero() && time.Since(last) < minInterval {
+			return nil
+		}
+		UpdateLastAccess(sess)
+		return saveSession(c, sess)
+	}
+}
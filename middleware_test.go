@@ -0,0 +1,473 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// newStepUpApp returns an app with a login route setting the AMR and roles
+// from the amr and roles query parameters and backdating the authentication by the age query
+// parameter, and the middleware returned by guard on /protected.
+func newStepUpApp(t *testing.T, guard func(*fibersession.Store) fiber.Handler) *fiber.App {
+	t.Helper()
+	storage := NewMemoryStorage("test:", 0)
+	t.Cleanup(func() { _ = storage.Close() })
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if amr := c.Query("amr"); amr != "" {
+			SetAMR(sess, strings.Split(amr, ","))
+		}
+		if roles := c.Query("roles"); roles != "" {
+			SetRoles(sess, strings.Split(roles, ","))
+		}
+		if age, err := time.ParseDuration(c.Query("age", "0s")); err == nil && age > 0 {
+			sess.Set(KeyAuthenticated, true)
+			SetAuthTime(sess, time.Now().Add(-age))
+			return sess.Save()
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/protected", guard(store), func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	return app
+}
+
+// login logs in with the given query and returns the session cookie.
+func login(t *testing.T, app *fiber.App, query string) *http.Cookie {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/login?"+query, nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			return cookie
+		}
+	}
+	t.Fatal("expected a session cookie")
+	return nil
+}
+
+// getProtected requests /protected with cookie and returns the status and
+// decoded error body.
+func getProtected(t *testing.T, app *fiber.App, cookie *http.Cookie) (int, AuthErrorResponse) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/protected", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to request: %v", err)
+	}
+	var body AuthErrorResponse
+	if resp.StatusCode != fiber.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode the error body: %v", err)
+		}
+	}
+	return resp.StatusCode, body
+}
+
+func TestRequireAMR(t *testing.T) {
+	app := newStepUpApp(t, func(store *fibersession.Store) fiber.Handler {
+		return RequireAMR(store, "webauthn", "otp")
+	})
+
+	if status, body := getProtected(t, app, nil); status != fiber.StatusUnauthorized || body.Error != ErrorCodeUnauthenticated {
+		t.Errorf("expected 401 unauthenticated, got %d %+v", status, body)
+	}
+
+	status, body := getProtected(t, app, login(t, app, "amr=pwd"))
+	if status != fiber.StatusForbidden || body.Error != ErrorCodeStepUpRequired {
+		t.Fatalf("expected 403 step_up_required for a pwd-only session, got %d %+v", status, body)
+	}
+	if len(body.Missing) != 2 || body.Missing[0] != "otp" || body.Missing[1] != "webauthn" {
+		t.Errorf("expected the missing methods, got %v", body.Missing)
+	}
+
+	if status, body := getProtected(t, app, login(t, app, "amr=pwd,otp")); status != fiber.StatusForbidden || len(body.Missing) != 1 || body.Missing[0] != "webauthn" {
+		t.Errorf("expected webauthn to be missing, got %d %+v", status, body)
+	}
+	if status, body := getProtected(t, app, login(t, app, "amr=pwd,otp,webauthn")); status != fiber.StatusOK {
+		t.Errorf("expected the request through, got %d %+v", status, body)
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	app := newStepUpApp(t, func(store *fibersession.Store) fiber.Handler {
+		return RequireRecentAuth(store, 5*time.Minute)
+	})
+
+	if status, body := getProtected(t, app, nil); status != fiber.StatusUnauthorized || body.Error != ErrorCodeUnauthenticated {
+		t.Errorf("expected 401 unauthenticated, got %d %+v", status, body)
+	}
+	if status, body := getProtected(t, app, login(t, app, "")); status != fiber.StatusOK {
+		t.Errorf("expected a fresh login through, got %d %+v", status, body)
+	}
+	status, body := getProtected(t, app, login(t, app, "age=10m"))
+	if status != fiber.StatusForbidden || body.Error != ErrorCodeReauthenticationRequired || body.MaxAge != 300 {
+		t.Errorf("expected 403 reauthentication_required, got %d %+v", status, body)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	app := newStepUpApp(t, func(store *fibersession.Store) fiber.Handler {
+		return RequireRole(store, "editor", "admin")
+	})
+
+	if status, body := getProtected(t, app, nil); status != fiber.StatusUnauthorized || body.Error != ErrorCodeUnauthenticated {
+		t.Errorf("expected 401 unauthenticated, got %d %+v", status, body)
+	}
+
+	status, body := getProtected(t, app, login(t, app, ""))
+	if status != fiber.StatusForbidden || body.Error != ErrorCodeInsufficientRole {
+		t.Fatalf("expected 403 insufficient_role for a session without roles, got %d %+v", status, body)
+	}
+	if len(body.Missing) != 2 || body.Missing[0] != "admin" || body.Missing[1] != "editor" {
+		t.Errorf("expected the missing roles, got %v", body.Missing)
+	}
+
+	if status, body := getProtected(t, app, login(t, app, "roles=viewer,editor")); status != fiber.StatusForbidden || len(body.Missing) != 1 || body.Missing[0] != "admin" {
+		t.Errorf("expected admin to be missing, got %d %+v", status, body)
+	}
+	if status, body := getProtected(t, app, login(t, app, "roles=admin,editor")); status != fiber.StatusOK {
+		t.Errorf("expected the request through, got %d %+v", status, body)
+	}
+}
+
+func TestMaxLifetimeMiddleware(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	t.Cleanup(func() { _ = storage.Close() })
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: 24 * time.Hour})
+
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if c.Query("anonymous") != "" {
+			sess.Set("locale", "fr")
+			return sess.Save()
+		}
+		age, _ := time.ParseDuration(c.Query("age", "0s"))
+		sess.Set(KeyAuthenticated, true)
+		createdAt := time.Now().Add(-age).Unix()
+		if c.Query("float") != "" {
+			sess.Set(KeyCreatedAt, float64(createdAt))
+		} else {
+			sess.Set(KeyCreatedAt, createdAt)
+		}
+		return sess.Save()
+	})
+	limited := NewMaxLifetimeMiddleware(store, 12*time.Hour)
+	redirected := NewMaxLifetimeMiddlewareWithOptions(store, DefaultMaxLifetimeOptions().WithRedirectURL("/login"))
+	app.Get("/protected", limited, func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/page", redirected, func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	request := func(path string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		return resp
+	}
+	loginCookie := func(query string) *http.Cookie {
+		t.Helper()
+		for _, c := range request("/login?"+query, nil).Cookies() {
+			if c.Name == "session_id" {
+				return c
+			}
+		}
+		t.Fatal("expected a session cookie")
+		return nil
+	}
+
+	if resp := request("/protected", nil); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("expected requests without a session through, got %d", resp.StatusCode)
+	}
+	if resp := request("/protected", loginCookie("anonymous=1")); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("expected anonymous sessions through, got %d", resp.StatusCode)
+	}
+	for _, query := range []string{"age=11h", "age=11h&float=1"} {
+		if resp := request("/protected", loginCookie(query)); resp.StatusCode != fiber.StatusOK {
+			t.Errorf("expected a session under the limit through for %s, got %d", query, resp.StatusCode)
+		}
+	}
+
+	for _, query := range []string{"age=13h", "age=13h&float=1"} {
+		cookie := loginCookie(query)
+		resp := request("/protected", cookie)
+		var body AuthErrorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != fiber.StatusUnauthorized || body.Error != ErrorCodeSessionExpired {
+			t.Errorf("expected 401 session_expired for %s, got %d %+v", query, resp.StatusCode, body)
+		}
+		if data, _ := storage.Get(cookie.Value); data != nil {
+			t.Errorf("expected the expired session to be destroyed for %s", query)
+		}
+	}
+
+	resp := request("/page", loginCookie("age=13h"))
+	if resp.StatusCode != fiber.StatusFound || resp.Header.Get("Location") != "/login" {
+		t.Errorf("expected a redirect to the login page, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestAutoTouchMiddleware(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewAutoTouchMiddleware(store, time.Minute))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/read", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/write", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		SetUserID(sess, "user-1")
+		return sess.Save()
+	})
+	app.Get("/logout", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Unauthenticate(sess)
+	})
+
+	request := func(path string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		return resp
+	}
+
+	// Anonymous requests don't create sessions
+	request("/read", nil)
+	if memory.Len() != 0 || rec.count("set") != 0 {
+		t.Fatalf("expected no session for an anonymous request, got %d writes", rec.count("set"))
+	}
+
+	var cookie *http.Cookie
+	for _, c := range request("/login", nil).Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+	// The login saved a fresh session, which the middleware leaves alone
+	if n := rec.count("set"); n != 1 {
+		t.Fatalf("expected 1 write after login, got %d", n)
+	}
+
+	// The first request touches the session, the rest of the burst is
+	// within minInterval
+	for i := 0; i < 10; i++ {
+		request("/read", cookie)
+	}
+	if n := rec.count("set"); n != 2 {
+		t.Errorf("expected a single touch over the burst, got %d writes", n)
+	}
+
+	// Keys the handler saved are kept
+	request("/write", cookie)
+	data, _ := memory.Get(cookie.Value)
+	if n := rec.count("set"); n != 3 || !bytes.Contains(data, []byte("user-1")) {
+		t.Errorf("expected the handler's write only, with its key, got %d writes", n)
+	}
+
+	// Destroyed sessions are not saved again
+	request("/logout", cookie)
+	if n := rec.count("set"); n != 3 || memory.Len() != 0 {
+		t.Errorf("expected the destroyed session to stay deleted, got %d writes and %d keys", n, memory.Len())
+	}
+}
+
+func TestAutoTouchMiddlewareEveryRequest(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewAutoTouchMiddleware(store, 0))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	sessID := ""
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sessID = sess.ID()
+		return sess.Save()
+	})
+	_, _ = app.Test(httptest.NewRequest("GET", "/login", nil))
+	rec.reset()
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessID})
+		if _, err := app.Test(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := rec.count("set"); n != 3 {
+		t.Errorf("expected a touch per request without minInterval, got %d", n)
+	}
+}
+
+func TestAutoTouchMiddlewareCachedSession(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewSaveCachedMiddleware())
+	app.Use(NewAutoTouchMiddleware(store, 0))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/visit", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		sess.Set("visited", true)
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	rec.reset()
+	req := httptest.NewRequest("GET", "/visit", nil)
+	for _, c := range resp.Cookies() {
+		req.AddCookie(c)
+	}
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("failed to visit: %v", err)
+	}
+
+	// The touch saves the instance the handler changed, loaded once
+	if gets, sets := rec.count("get"), rec.count("set"); gets != 1 || sets != 1 {
+		t.Errorf("expected one storage read and one write, got %d and %d", gets, sets)
+	}
+	for _, c := range resp.Cookies() {
+		if data, _ := memory.Get(c.Value); c.Name == "session_id" && !bytes.Contains(data, []byte("visited")) {
+			t.Errorf("expected the handler's change to be saved, got %s", data)
+		}
+	}
+}
+
+func TestAutoTouchMiddlewareHandlerSaved(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewAutoTouchMiddleware(store, 0))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/save", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		sess.Set("saved", true)
+		return sess.Save()
+	})
+	app.Get("/flash", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		return AddFlash(sess, "info", "flashed")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	// The handler's save renews the session; the middleware does not save
+	// the released session again
+	for path, want := range map[string]string{"/save": "saved", "/flash": "flashed"} {
+		rec.reset()
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		if sets := rec.count("set"); sets != 1 {
+			t.Errorf("%s: expected a single write, got %d", path, sets)
+		}
+		if data, _ := memory.Get(cookie.Value); !bytes.Contains(data, []byte(want)) {
+			t.Errorf("%s: expected the handler's change to be saved, got %s", path, data)
+		}
+	}
+}
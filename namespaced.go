@@ -0,0 +1,147 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// namespaceSeparator separates the namespace from the key.
+const namespaceSeparator = ":"
+
+// NamespacedStorage wraps a Storage and prepends "namespace:" to every key,
+// so that several tenants can share one storage, and one connection pool,
+// without seeing each other's keys.
+//
+// Reset only removes the keys of the namespace, which requires the inner
+// storage to implement PatternDeleter, as MemoryStorage and RedisStorage do;
+// otherwise Reset returns an error rather than resetting every namespace.
+// Close does nothing, as the inner storage is shared: close it once all its
+// namespaces are done with it.
+//
+// The wrapper implements ExtendedStorage only if the inner storage does;
+// other optional interfaces are not forwarded. Use Unwrap to reach the inner
+// storage.
+type NamespacedStorage struct {
+	inner     Storage
+	namespace string
+	prefix    string
+}
+
+// namespacedExtendedStorage is the NamespacedStorage returned for inner
+// storages implementing ExtendedStorage.
+type namespacedExtendedStorage struct {
+	*NamespacedStorage
+	extended ExtendedStorage
+}
+
+// NewNamespacedStorage wraps inner so that every key is stored as
+// namespace + ":" + key. It panics if the namespace is empty or contains
+// ":", which would let one namespace see into another, e.g. "a" into "a:b".
+func NewNamespacedStorage(inner Storage, namespace string) Storage {
+	if err := validateNamespace(namespace); err != nil {
+		panic("session: " + err.Error())
+	}
+
+	s := &NamespacedStorage{
+		inner:     inner,
+		namespace: namespace,
+		prefix:    namespace + namespaceSeparator,
+	}
+	if extended, ok := inner.(ExtendedStorage); ok {
+		return &namespacedExtendedStorage{NamespacedStorage: s, extended: extended}
+	}
+	return s
+}
+
+// validateNamespace checks that namespace can be used by NamespacedStorage.
+func validateNamespace(namespace string) error {
+	if namespace == "" {
+		return errors.New("namespace cannot be empty")
+	}
+	if strings.Contains(namespace, namespaceSeparator) {
+		return fmt.Errorf("namespace %q cannot contain %q", namespace, namespaceSeparator)
+	}
+	return nil
+}
+
+// Namespace returns the namespace of the storage.
+func (s *NamespacedStorage) Namespace() string {
+	return s.namespace
+}
+
+// Unwrap returns the inner storage.
+func (s *NamespacedStorage) Unwrap() Storage {
+	return s.inner
+}
+
+// buildKey constructs the key in the inner storage.
+func (s *NamespacedStorage) buildKey(key string) string {
+	return s.prefix + key
+}
+
+// Get retrieves the value for the given key.
+func (s *NamespacedStorage) Get(key string) ([]byte, error) {
+	return s.inner.Get(s.buildKey(key))
+}
+
+// Set stores the given value for the given key along with an expiration value.
+// Empty key or value will be ignored without an error.
+func (s *NamespacedStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	return s.inner.Set(s.buildKey(key), val, exp)
+}
+
+// Delete removes the value for the given key.
+func (s *NamespacedStorage) Delete(key string) error {
+	return s.inner.Delete(s.buildKey(key))
+}
+
+// Reset removes all keys of the namespace. The inner storage must implement
+// PatternDeleter.
+func (s *NamespacedStorage) Reset() error {
+	deleter, ok := s.inner.(PatternDeleter)
+	if !ok {
+		return fmt.Errorf("namespaced storage reset requires an inner storage implementing PatternDeleter, got %T", s.inner)
+	}
+	if _, err := deleter.DeleteByPattern(EscapePattern(s.prefix) + "*"); err != nil {
+		return fmt.Errorf("failed to reset namespace %q: %w", s.namespace, err)
+	}
+	return nil
+}
+
+// Ping checks the inner storage. Storages that do not implement Pinger are
+// assumed to be healthy.
+func (s *NamespacedStorage) Ping(ctx context.Context) error {
+	return pingStorage(ctx, s.inner)
+}
+
+// Close does nothing, as the inner storage may be shared with other
+// namespaces.
+func (s *NamespacedStorage) Close() error {
+	return nil
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *namespacedExtendedStorage) Exists(key string) (bool, error) {
+	return s.extended.Exists(s.buildKey(key))
+}
+
+// GetTTL returns the remaining TTL for the key.
+func (s *namespacedExtendedStorage) GetTTL(key string) (time.Duration, error) {
+	return s.extended.GetTTL(s.buildKey(key))
+}
+
+// Expire sets a new expiration on the key.
+func (s *namespacedExtendedStorage) Expire(key string, exp time.Duration) error {
+	return s.extended.Expire(s.buildKey(key), exp)
+}
+
+// Touch sets a new expiration on the key and reports whether it existed.
+func (s *namespacedExtendedStorage) Touch(key string, exp time.Duration) (bool, error) {
+	return s.extended.Touch(s.buildKey(key), exp)
+}
@@ -0,0 +1,147 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNamespaceIsolation checks that two namespaces over inner don't see
+// each other's keys and that resetting one leaves the other intact.
+func testNamespaceIsolation(t *testing.T, inner Storage) {
+	t.Helper()
+	tenantA := NewNamespacedStorage(inner, "tenant-a")
+	tenantB := NewNamespacedStorage(inner, "tenant-b")
+
+	_ = tenantA.Set("key", []byte("a"), time.Hour)
+	_ = tenantB.Set("key", []byte("b"), time.Hour)
+	_ = tenantA.Set("only-a", []byte("a"), time.Hour)
+	_ = inner.Set("key", []byte("unscoped"), time.Hour)
+
+	if got, _ := tenantA.Get("key"); string(got) != "a" {
+		t.Errorf("expected tenant-a value, got %q", got)
+	}
+	if got, _ := tenantB.Get("key"); string(got) != "b" {
+		t.Errorf("expected tenant-b value, got %q", got)
+	}
+	if got, _ := tenantB.Get("only-a"); got != nil {
+		t.Errorf("expected tenant-b not to see tenant-a keys, got %q", got)
+	}
+	if got, _ := inner.Get("tenant-a:key"); string(got) != "a" {
+		t.Errorf("expected the namespaced key in the inner storage, got %q", got)
+	}
+
+	if err := tenantA.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if got, _ := tenantA.Get("key"); got != nil {
+		t.Errorf("expected tenant-a to be reset, got %q", got)
+	}
+	if got, _ := tenantB.Get("key"); string(got) != "b" {
+		t.Errorf("expected tenant-b to survive the reset, got %q", got)
+	}
+	if got, _ := inner.Get("key"); string(got) != "unscoped" {
+		t.Errorf("expected unscoped keys to survive the reset, got %q", got)
+	}
+
+	_ = tenantB.Delete("key")
+	if got, _ := tenantB.Get("key"); got != nil {
+		t.Errorf("expected tenant-b key to be deleted, got %q", got)
+	}
+}
+
+func TestNamespacedStorageMemory(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+	testNamespaceIsolation(t, inner)
+}
+
+func TestNamespacedStorageRedis(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+	testNamespaceIsolation(t, NewRedisStorage(client, "test:"))
+}
+
+func TestNamespacedStorageGlobNamespace(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	wild := NewNamespacedStorage(inner, "t*")
+	other := NewNamespacedStorage(inner, "tx")
+	_ = other.Set("key", []byte("x"), time.Hour)
+
+	if err := wild.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if got, _ := other.Get("key"); string(got) != "x" {
+		t.Errorf("expected glob characters in the namespace to match literally, got %q", got)
+	}
+}
+
+func TestNamespacedStorageExtended(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	storage, ok := NewNamespacedStorage(inner, "tenant").(ExtendedStorage)
+	if !ok {
+		t.Fatal("expected the wrapper to implement ExtendedStorage")
+	}
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	if exists, _ := storage.Exists("key"); !exists {
+		t.Error("expected key to exist")
+	}
+	if found, _ := storage.Touch("key", 2*time.Hour); !found {
+		t.Error("expected Touch to find the key")
+	}
+	if ttl, _ := inner.GetTTL("tenant:key"); ttl <= time.Hour {
+		t.Errorf("expected Touch to apply to the namespaced key, got %v", ttl)
+	}
+
+	if _, ok := NewNamespacedStorage(&plainStorage{Storage: inner}, "tenant").(ExtendedStorage); ok {
+		t.Error("expected the wrapper not to implement ExtendedStorage for a basic storage")
+	}
+}
+
+func TestNamespacedStorageResetUnsupported(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	storage := NewNamespacedStorage(&plainStorage{Storage: inner}, "tenant")
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	err := storage.Reset()
+	if err == nil || !strings.Contains(err.Error(), "PatternDeleter") {
+		t.Errorf("expected an error naming PatternDeleter, got %v", err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Errorf("expected a failed reset to keep the keys, got %q", got)
+	}
+}
+
+func TestNamespacedStorageClose(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	storage := NewNamespacedStorage(inner, "tenant")
+	if err := storage.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if err := inner.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Errorf("expected the shared inner storage to stay open, got %v", err)
+	}
+}
+
+func TestNewNamespacedStorageInvalidNamespace(t *testing.T) {
+	for _, namespace := range []string{"", "a:b", ":"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for namespace %q", namespace)
+				}
+			}()
+			NewNamespacedStorage(NewMemoryStorage("test:", 0), namespace)
+		}()
+	}
+}
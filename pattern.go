@@ -0,0 +1,117 @@
+package session
+
+import "strings"
+
+// patternEscaper escapes the characters with a special meaning in Redis
+// glob patterns.
+var patternEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`?`, `\?`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+
+// EscapePattern escapes the glob characters *, ?, [, ] and \ in s, so that
+// user-provided fragments such as tenant or user IDs match literally in
+// DeleteByPattern patterns.
+func EscapePattern(s string) string {
+	return patternEscaper.Replace(s)
+}
+
+// matchPattern reports whether str matches the Redis glob pattern, with the
+// same rules as the server's KEYS and SCAN MATCH:
+//   - * matches any sequence of characters, including none
+//   - ? matches any single character
+//   - [abc], [a-z] and [^abc] match one character from (or not from) a set
+//   - \ escapes the next character
+//
+// Unlike path.Match, '/' is an ordinary character.
+func matchPattern(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if matchPattern(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			var ok bool
+			ok, pattern = matchClass(pattern[1:], str[0])
+			if !ok {
+				return false
+			}
+			str = str[1:]
+
+		default:
+			if pattern[0] == '\\' && len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
+
+// matchClass matches c against the character class at the start of pattern,
+// just after the opening '['. It returns whether c matched and the rest of
+// the pattern after the closing ']'. An unterminated class ends the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == c {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // Skip the closing ']'
+	}
+
+	return matched != negate, pattern
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "anything/at:all", true},
+		{"tenant:1:*", "tenant:1:abc", true},
+		{"tenant:1:*", "tenant:10:abc", false},
+		{"tenant:*:abc", "tenant:1/2:abc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"a**c", "abbbc", true},
+		{"*c", "abd", false},
+		{"[abc]x", "bx", true},
+		{"[abc]x", "dx", false},
+		{"[^abc]x", "dx", true},
+		{"[^abc]x", "ax", false},
+		{"[a-c]x", "bx", true},
+		{"[c-a]x", "bx", true},
+		{"[a-c]x", "dx", false},
+		{`[\]]`, "]", true},
+		{"[ab", "a", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{`a\?`, "a?", true},
+		{"abc", "abcd", false},
+		{"abcd", "abc", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.str); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	for _, s := range []string{"plain", "a*b", "what?", "[x]", `back\slash`, "*?[]\\"} {
+		if !matchPattern(EscapePattern(s), s) {
+			t.Errorf("expected escaped %q to match itself", s)
+		}
+	}
+	if matchPattern(EscapePattern("a*b"), "aXb") {
+		t.Error("expected escaped * to match only itself")
+	}
+	if got := EscapePattern("a*b?[c]"); got != `a\*b\?\[c\]` {
+		t.Errorf("unexpected escape result %q", got)
+	}
+}
+
+// patternDeleter is implemented by the storages supporting DeleteByPattern.
+type patternDeleter interface {
+	Storage
+	PatternDeleter
+}
+
+// testDeleteByPattern checks that overlapping patterns only remove the keys
+// they match.
+func testDeleteByPattern(t *testing.T, storage patternDeleter) {
+	t.Helper()
+	keys := []string{
+		"tenant:1:a", "tenant:1:b", "tenant:10:a", "tenant:2:a",
+		"tenant:a*b:x", "tenant:aXb:x", "user:1",
+	}
+	for _, key := range keys {
+		if err := storage.Set(key, []byte("value"), time.Hour); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+	}
+
+	steps := []struct {
+		pattern string
+		removed int
+		gone    []string
+	}{
+		{"tenant:1:*", 2, []string{"tenant:1:a", "tenant:1:b"}},
+		{"tenant:" + EscapePattern("a*b") + ":*", 1, []string{"tenant:a*b:x"}},
+		{"tenant:1:*", 0, nil},
+		{"tenant:[12]*", 2, []string{"tenant:10:a", "tenant:2:a"}},
+	}
+	survivors := map[string]bool{}
+	for _, key := range keys {
+		survivors[key] = true
+	}
+	for _, step := range steps {
+		n, err := storage.DeleteByPattern(step.pattern)
+		if err != nil {
+			t.Fatalf("%s: failed to delete: %v", step.pattern, err)
+		}
+		if n != step.removed {
+			t.Errorf("%s: expected %d keys removed, got %d", step.pattern, step.removed, n)
+		}
+		for _, key := range step.gone {
+			delete(survivors, key)
+		}
+
+		var left []string
+		for _, key := range keys {
+			if data, _ := storage.Get(key); data != nil {
+				left = append(left, key)
+			}
+		}
+		var want []string
+		for key := range survivors {
+			want = append(want, key)
+		}
+		sort.Strings(left)
+		sort.Strings(want)
+		if len(left) != len(want) {
+			t.Fatalf("%s: expected %v to survive, got %v", step.pattern, want, left)
+		}
+		for i := range want {
+			if left[i] != want[i] {
+				t.Errorf("%s: expected %v to survive, got %v", step.pattern, want, left)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// ReadOnlyOptions configures ReadOnlyStorage.
+type ReadOnlyOptions struct {
+	// CloseInner makes Close close the inner storage. Disable it when the
+	// inner storage is shared with writers that outlive the wrapper.
+	// Default: true
+	CloseInner bool
+}
+
+// DefaultReadOnlyOptions returns ReadOnlyOptions with default values.
+func DefaultReadOnlyOptions() ReadOnlyOptions {
+	return ReadOnlyOptions{
+		CloseInner: true,
+	}
+}
+
+// WithCloseInner sets whether Close closes the inner storage.
+func (o ReadOnlyOptions) WithCloseInner(closeInner bool) ReadOnlyOptions {
+	o.CloseInner = closeInner
+	return o
+}
+
+// ReadOnlyStorage wraps a Storage so that it can be read but never modified,
+// e.g. for services that verify sessions against a replica. Get passes
+// through, while Set, Delete and Reset return ErrReadOnly without reaching
+// the inner storage.
+//
+// A Manager over a ReadOnlyStorage can load sessions, as long as sliding
+// expiration is disabled; SaveSession, TouchSession and DeleteSession return
+// ErrReadOnly. Expired sessions found by LoadSession are reported as missing
+// but left in place.
+//
+// The wrapper implements ExtendedStorage only if the inner storage does, in
+// which case Exists and GetTTL pass through and Expire and Touch return
+// ErrReadOnly; other optional interfaces are not forwarded. Use Unwrap to
+// reach the inner storage.
+type ReadOnlyStorage struct {
+	inner Storage
+	opts  ReadOnlyOptions
+}
+
+// readOnlyExtendedStorage is the ReadOnlyStorage returned for inner storages
+// implementing ExtendedStorage.
+type readOnlyExtendedStorage struct {
+	*ReadOnlyStorage
+	extended ExtendedStorage
+}
+
+// NewReadOnlyStorage wraps inner so that it cannot be modified. Close closes
+// the inner storage.
+func NewReadOnlyStorage(inner Storage) Storage {
+	return NewReadOnlyStorageWithOptions(inner, DefaultReadOnlyOptions())
+}
+
+// NewReadOnlyStorageWithOptions wraps inner with the given options.
+func NewReadOnlyStorageWithOptions(inner Storage, opts ReadOnlyOptions) Storage {
+	s := &ReadOnlyStorage{inner: inner, opts: opts}
+	if extended, ok := inner.(ExtendedStorage); ok {
+		return &readOnlyExtendedStorage{ReadOnlyStorage: s, extended: extended}
+	}
+	return s
+}
+
+// Unwrap returns the inner storage.
+func (s *ReadOnlyStorage) Unwrap() Storage {
+	return s.inner
+}
+
+// Get retrieves the value for the given key.
+func (s *ReadOnlyStorage) Get(key string) ([]byte, error) {
+	return s.inner.Get(key)
+}
+
+// Set returns ErrReadOnly.
+func (s *ReadOnlyStorage) Set(key string, val []byte, exp time.Duration) error {
+	return ErrReadOnly
+}
+
+// Delete returns ErrReadOnly.
+func (s *ReadOnlyStorage) Delete(key string) error {
+	return ErrReadOnly
+}
+
+// Reset returns ErrReadOnly.
+func (s *ReadOnlyStorage) Reset() error {
+	return ErrReadOnly
+}
+
+// Ping checks the inner storage. Storages that do not implement Pinger are
+// assumed to be healthy.
+func (s *ReadOnlyStorage) Ping(ctx context.Context) error {
+	return pingStorage(ctx, s.inner)
+}
+
+// Close closes the inner storage if CloseInner is set, and does nothing
+// otherwise.
+func (s *ReadOnlyStorage) Close() error {
+	if !s.opts.CloseInner {
+		return nil
+	}
+	return s.inner.Close()
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *readOnlyExtendedStorage) Exists(key string) (bool, error) {
+	return s.extended.Exists(key)
+}
+
+// GetTTL returns the remaining TTL for the key.
+func (s *readOnlyExtendedStorage) GetTTL(key string) (time.Duration, error) {
+	return s.extended.GetTTL(key)
+}
+
+// Expire returns ErrReadOnly.
+func (s *readOnlyExtendedStorage) Expire(key string, exp time.Duration) error {
+	return ErrReadOnly
+}
+
+// Touch returns ErrReadOnly.
+func (s *readOnlyExtendedStorage) Touch(key string, exp time.Duration) (bool, error) {
+	return false, ErrReadOnly
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyStorage(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+	_ = inner.Set("key", []byte("value"), time.Hour)
+
+	storage := NewReadOnlyStorage(inner)
+	if got, err := storage.Get("key"); string(got) != "value" || err != nil {
+		t.Errorf("expected Get to pass through, got %q (%v)", got, err)
+	}
+
+	if err := storage.Set("key", []byte("other"), time.Hour); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from Set, got %v", err)
+	}
+	if err := storage.Delete("key"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from Delete, got %v", err)
+	}
+	if err := storage.Reset(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from Reset, got %v", err)
+	}
+	if got, _ := inner.Get("key"); string(got) != "value" {
+		t.Errorf("expected the inner storage to be unchanged, got %q", got)
+	}
+}
+
+func TestReadOnlyStorageExtended(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+	_ = inner.Set("key", []byte("value"), time.Hour)
+
+	storage, ok := NewReadOnlyStorage(inner).(ExtendedStorage)
+	if !ok {
+		t.Fatal("expected the wrapper to implement ExtendedStorage")
+	}
+	if exists, err := storage.Exists("key"); !exists || err != nil {
+		t.Errorf("expected Exists to pass through, got %v (%v)", exists, err)
+	}
+	if ttl, err := storage.GetTTL("key"); ttl <= 0 || err != nil {
+		t.Errorf("expected GetTTL to pass through, got %v (%v)", ttl, err)
+	}
+	if err := storage.Expire("key", 0); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from Expire, got %v", err)
+	}
+	if _, err := storage.Touch("key", 0); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from Touch, got %v", err)
+	}
+	if ttl, _ := inner.GetTTL("key"); ttl <= 0 {
+		t.Errorf("expected the expiration to be unchanged, got %v", ttl)
+	}
+
+	if _, ok := NewReadOnlyStorage(&plainStorage{Storage: inner}).(ExtendedStorage); ok {
+		t.Error("expected the wrapper not to implement ExtendedStorage for a basic storage")
+	}
+}
+
+func TestReadOnlyStorageClose(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	shared := NewReadOnlyStorageWithOptions(inner, DefaultReadOnlyOptions().WithCloseInner(false))
+	if err := shared.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if err := inner.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Errorf("expected the inner storage to stay open, got %v", err)
+	}
+
+	if err := NewReadOnlyStorage(inner).Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if _, err := inner.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected Close to close the inner storage, got %v", err)
+	}
+}
+
+func TestManagerReadOnlyStorage(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	writer := NewManager(inner, DefaultConfig())
+	session := writer.CreateSession("session-1")
+	session.SetValue("user", "alice")
+	if err := writer.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	manager := NewManager(NewReadOnlyStorage(inner), DefaultConfig())
+	loaded, err := manager.LoadSession("session-1")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if loaded == nil {
+		t.Fatal("expected the session to load")
+	}
+	if user, _ := loaded.GetValue("user"); user != "alice" {
+		t.Fatalf("expected the session values to load, got %v", user)
+	}
+
+	if err := manager.TouchSession(loaded); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from TouchSession, got %v", err)
+	}
+	if err := manager.SaveSession(loaded); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from SaveSession, got %v", err)
+	}
+	if err := manager.DeleteSession("session-1"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly from DeleteSession, got %v", err)
+	}
+	if data, _ := inner.Get("session-1"); data == nil {
+		t.Error("expected the session to survive")
+	}
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// redisCompressedMagic marks a value stored gzip-compressed by RedisStorage.
+// It is followed by the gzip stream, whose own header (0x1f 0x8b) is checked
+// too, so a legacy value starting with this byte is not mistaken for one.
+const redisCompressedMagic byte = 0x00
+
+// gzipWriters reuses gzip writers, which allocate large internal tables.
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// WithCompression makes the storage gzip values of at least minSize bytes
+// before storing them, to save Redis memory on large sessions. Compressed
+// values are stored with a magic prefix byte, so values stored without
+// compression, including those written before it was enabled, still read
+// fine. Values that fail to compress or do not get smaller are stored as is.
+// A minSize <= 0 disables compression; compressed values already stored
+// remain readable. It should be called right after construction, before the
+// storage is shared between goroutines.
+func (s *RedisStorage) WithCompression(minSize int) *RedisStorage {
+	s.compressMin = minSize
+	return s
+}
+
+// encodeValue compresses val if compression is enabled and val is large
+// enough, falling back to val itself if compression fails or does not help.
+func (s *RedisStorage) encodeValue(val []byte) []byte {
+	if s.compressMin <= 0 || len(val) < s.compressMin {
+		return val
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(val) / 2)
+	buf.WriteByte(redisCompressedMagic)
+
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+
+	if _, err := zw.Write(val); err != nil {
+		return val
+	}
+	if err := zw.Close(); err != nil {
+		return val
+	}
+	if buf.Len() >= len(val) {
+		return val
+	}
+	return buf.Bytes()
+}
+
+// isCompressedValue reports whether data was stored by encodeValue in
+// compressed form.
+func isCompressedValue(data []byte) bool {
+	return len(data) >= 3 && data[0] == redisCompressedMagic && data[1] == 0x1f && data[2] == 0x8b
+}
+
+// decodeValue returns the original value of data read from Redis,
+// decompressing it if it was stored compressed.
+func decodeValue(data []byte) ([]byte, error) {
+	if !isCompressedValue(data) {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	val, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	return val, nil
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisStorageCompressionRoundTrip(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	const minSize = 1024
+	storage := NewRedisStorage(client, "test:").WithCompression(minSize)
+
+	tests := []struct {
+		name       string
+		size       int
+		compressed bool
+	}{
+		{"below threshold", minSize - 1, false},
+		{"at threshold", minSize, true},
+		{"large session", 50 * 1024, true},
+	}
+	for _, tt := range tests {
+		val := bytes.Repeat([]byte("a"), tt.size)
+		if err := storage.Set(tt.name, val, time.Hour); err != nil {
+			t.Fatalf("%s: failed to set: %v", tt.name, err)
+		}
+
+		raw, _ := mr.Get("test:" + tt.name)
+		if got := isCompressedValue([]byte(raw)); got != tt.compressed {
+			t.Errorf("%s: expected compressed=%v, got %v", tt.name, tt.compressed, got)
+		}
+		if tt.compressed && len(raw) >= tt.size {
+			t.Errorf("%s: expected stored value to shrink, got %d bytes", tt.name, len(raw))
+		}
+
+		got, err := storage.Get(tt.name)
+		if err != nil {
+			t.Fatalf("%s: failed to get: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, val) {
+			t.Errorf("%s: value did not round-trip", tt.name)
+		}
+	}
+}
+
+func TestRedisStorageCompressionLegacyValue(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	legacy := `{"id":"legacy","authenticated":true}`
+	_ = mr.Set("test:legacy", legacy)
+
+	storage := NewRedisStorage(client, "test:").WithCompression(1)
+	got, err := storage.Get("legacy")
+	if err != nil || string(got) != legacy {
+		t.Errorf("expected legacy value to read unchanged, got %q (%v)", got, err)
+	}
+}
+
+func TestRedisStorageCompressionIncompressible(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:").WithCompression(16)
+
+	val := make([]byte, 4096)
+	_, _ = rand.Read(val)
+	if err := storage.Set("random", val, time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if raw, _ := mr.Get("test:random"); raw != string(val) {
+		t.Error("expected incompressible value to be stored raw")
+	}
+	if got, _ := storage.Get("random"); !bytes.Equal(got, val) {
+		t.Error("value did not round-trip")
+	}
+}
+
+func TestRedisStorageCompressionBatchAndRefresh(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:").WithCompression(64)
+	val := []byte(strings.Repeat("session ", 100))
+
+	if err := storage.SetMulti(map[string][]byte{"a": val, "b": []byte("small")}, time.Hour); err != nil {
+		t.Fatalf("failed to set multi: %v", err)
+	}
+	if err := storage.SetKeepTTL("c", val); err != nil {
+		t.Fatalf("failed to set keep ttl: %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if raw, _ := mr.Get("test:" + key); !isCompressedValue([]byte(raw)) {
+			t.Errorf("expected %s to be stored compressed", key)
+		}
+	}
+
+	got, err := storage.GetMulti([]string{"a", "b"})
+	if err != nil || !bytes.Equal(got["a"], val) || string(got["b"]) != "small" {
+		t.Errorf("unexpected multi result: %v", err)
+	}
+	refreshed, err := storage.GetAndRefresh("c", time.Hour)
+	if err != nil || !bytes.Equal(refreshed, val) {
+		t.Errorf("expected refreshed value to be decompressed, got %v", err)
+	}
+
+	// Disabling compression keeps compressed values readable
+	storage.WithCompression(0)
+	if got, _ := storage.Get("a"); !bytes.Equal(got, val) {
+		t.Error("expected compressed value to stay readable")
+	}
+}
+
+func TestRedisStorageCompressionCorrupted(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	_ = mr.Set("test:corrupted", "\x00\x1f\x8bnot gzip at all")
+
+	storage := NewRedisStorage(client, "test:").WithCompression(1)
+	_, err := storage.Get("corrupted")
+	if err == nil || !strings.Contains(err.Error(), "failed to decompress value") {
+		t.Errorf("expected a decompression error, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// expirySubscriberMinBackoff is the initial delay before resubscribing
+	// after the keyspace notification connection fails.
+	expirySubscriberMinBackoff = 100 * time.Millisecond
+	// expirySubscriberMaxBackoff caps the delay between resubscribe attempts.
+	expirySubscriberMaxBackoff = 5 * time.Second
+)
+
+// RedisExpirySubscriber listens to Redis keyspace notifications for expired
+// keys and reports the ones under a key prefix with the prefix removed.
+//
+// Redis only publishes these events when the server is configured with
+// notify-keyspace-events including "Ex" (e.g. CONFIG SET notify-keyspace-events Ex).
+// Notifications are fire-and-forget: events published while the subscriber
+// is disconnected are lost, so treat them as a best-effort signal.
+type RedisExpirySubscriber struct {
+	client    *redis.Client
+	keyPrefix string
+	channel   string
+	fn        func(key string)
+	pubsub    *redis.PubSub
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRedisExpirySubscriber subscribes to expired-key events of the client's
+// database and calls fn with the bare key of every expired key starting with
+// keyPrefix. The subscription is re-established automatically if the
+// connection drops. Call Close to stop it.
+func NewRedisExpirySubscriber(client *redis.Client, keyPrefix string, fn func(key string)) (*RedisExpirySubscriber, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("expiry callback is nil")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &RedisExpirySubscriber{
+		client:    client,
+		keyPrefix: keyPrefix,
+		channel:   fmt.Sprintf("__keyevent@%d__:expired", client.Options().DB),
+		fn:        fn,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	pubsub := client.Subscribe(ctx, sub.channel)
+	// Wait for the subscription to be confirmed so no event is missed after return
+	if _, err := pubsub.Receive(ctx); err != nil {
+		cancel()
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to expiry notifications: %w", err)
+	}
+
+	sub.pubsub = pubsub
+	go sub.run(ctx)
+
+	return sub, nil
+}
+
+// run receives notifications until the subscriber is closed, backing off
+// between reconnect attempts when the connection fails.
+func (sub *RedisExpirySubscriber) run(ctx context.Context) {
+	defer close(sub.done)
+
+	backoff := expirySubscriberMinBackoff
+	for {
+		msg, err := sub.pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// go-redis reconnects and resubscribes on the next receive
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > expirySubscriberMaxBackoff {
+				backoff = expirySubscriberMaxBackoff
+			}
+			continue
+		}
+		backoff = expirySubscriberMinBackoff
+
+		if !strings.HasPrefix(msg.Payload, sub.keyPrefix) {
+			continue
+		}
+		sub.dispatch(strings.TrimPrefix(msg.Payload, sub.keyPrefix))
+	}
+}
+
+// dispatch calls the callback, recovering from panics so the subscriber keeps running.
+func (sub *RedisExpirySubscriber) dispatch(key string) {
+	defer func() {
+		_ = recover()
+	}()
+	sub.fn(key)
+}
+
+// Close stops the subscriber and waits for it to exit. It is safe to call more than once.
+func (sub *RedisExpirySubscriber) Close() error {
+	sub.closeOnce.Do(func() {
+		sub.cancel()
+		// Closing the connection unblocks a pending receive
+		_ = sub.pubsub.Close()
+		<-sub.done
+	})
+	return nil
+}
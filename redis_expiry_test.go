@@ -0,0 +1,130 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisExpirySubscriber(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	got := make(chan string, 4)
+	sub, err := NewRedisExpirySubscriber(client, "test:", func(key string) {
+		got <- key
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer func() { _ = sub.Close() }()
+
+	// miniredis does not emit keyspace events itself, so publish them directly
+	mr.Publish("__keyevent@0__:expired", "other:ignored")
+	mr.Publish("__keyevent@0__:expired", "test:session-1")
+
+	select {
+	case key := <-got:
+		if key != "session-1" {
+			t.Errorf("expected bare key 'session-1', got %q", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected expiry notification")
+	}
+
+	select {
+	case key := <-got:
+		t.Errorf("expected keys outside the prefix to be filtered, got %q", key)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Errorf("unexpected error on close: %v", err)
+	}
+	if err := sub.Close(); err != nil {
+		t.Errorf("unexpected error on second close: %v", err)
+	}
+}
+
+func TestRedisExpirySubscriberReconnect(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	got := make(chan string, 4)
+	sub, err := NewRedisExpirySubscriber(client, "test:", func(key string) {
+		got <- key
+	})
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer func() { _ = sub.Close() }()
+
+	// Drop the connection and wait for the subscriber to come back
+	addr := mr.Addr()
+	mr.Close()
+	time.Sleep(50 * time.Millisecond)
+	if err := mr.StartAddr(addr); err != nil {
+		t.Fatalf("failed to restart miniredis: %v", err)
+	}
+
+	deadline := time.After(3 * time.Second)
+	for {
+		mr.Publish("__keyevent@0__:expired", "test:session-2")
+		select {
+		case key := <-got:
+			if key != "session-2" {
+				t.Errorf("expected 'session-2', got %q", key)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("expected subscriber to reconnect")
+		}
+	}
+}
+
+func TestRedisExpirySubscriberErrors(t *testing.T) {
+	if _, err := NewRedisExpirySubscriber(nil, "test:", func(string) {}); err == nil {
+		t.Error("expected error for nil client")
+	}
+
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	if _, err := NewRedisExpirySubscriber(client, "test:", nil); err == nil {
+		t.Error("expected error for nil callback")
+	}
+
+	mr.Close()
+	if _, err := NewRedisExpirySubscriber(client, "test:", func(string) {}); err == nil {
+		t.Error("expected error when redis is down")
+	}
+}
+
+func TestManagerOnExpiredRedis(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+
+	storage := NewRedisStorage(client, "test:")
+	defer func() { _ = storage.Close() }()
+
+	manager := NewManager(storage, DefaultConfig())
+
+	got := make(chan string, 1)
+	if err := manager.OnExpired(func(id string) { got <- id }); err != nil {
+		t.Fatalf("failed to register hook: %v", err)
+	}
+
+	mr.Publish("__keyevent@0__:expired", "test:session-3")
+
+	select {
+	case id := <-got:
+		if id != "session-3" {
+			t.Errorf("expected 'session-3', got %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnExpired hook to fire")
+	}
+}
@@ -0,0 +1,356 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Hash field names used by RedisHashStorage. They match the JSON names of
+// the SessionData fields.
+const (
+	HashFieldID             = "id"
+	HashFieldUserID         = "user_id"
+	HashFieldEmail          = "email"
+	HashFieldPhone          = "phone"
+	HashFieldAuthenticated  = "authenticated"
+	HashFieldData           = "data"
+	HashFieldCreatedAt      = "created_at"
+	HashFieldExpiresAt      = "expires_at"
+	HashFieldLastAccessedAt = "last_accessed_at"
+	HashFieldAMR            = "amr"
+	HashFieldScopes         = "scopes"
+	HashFieldIPAddress      = "ip_address"
+	HashFieldUserAgent      = "user_agent"
+)
+
+// RedisHashStorage stores sessions as Redis hashes with one field per
+// top-level SessionData attribute, instead of one JSON blob per session.
+// This lets FieldUpdater callers such as Manager.TouchSession change a few
+// fields without rewriting the rest of the session.
+//
+// Values passed to Set must be JSON-encoded SessionData, as written by
+// Manager; Get returns the session JSON-encoded again. Strings are stored
+// as is, Authenticated as "true" or "false", timestamps in RFC 3339 format
+// with nanoseconds, and Data, AMR and Scopes as JSON. Empty optional fields
+// are not stored. Because values must be SessionData, RedisHashStorage
+// cannot back the Fiber session middleware; use RedisStorage there.
+type RedisHashStorage struct {
+	base *RedisStorage
+}
+
+// NewRedisHashStorage creates a new hash-based Redis storage for sessions
+// backed by any go-redis client. The keyPrefix is prepended to all session
+// keys, as with NewRedisStorageUniversal.
+func NewRedisHashStorage(client redis.UniversalClient, keyPrefix string) *RedisHashStorage {
+	return &RedisHashStorage{base: NewRedisStorageUniversal(client, keyPrefix)}
+}
+
+// WithOpTimeout sets the timeout applied to the methods that do not take a
+// context; see RedisStorage.WithOpTimeout.
+func (s *RedisHashStorage) WithOpTimeout(d time.Duration) *RedisHashStorage {
+	s.base.WithOpTimeout(d)
+	return s
+}
+
+// GetKeyPrefix returns the key prefix used by this storage.
+func (s *RedisHashStorage) GetKeyPrefix() string {
+	return s.base.GetKeyPrefix()
+}
+
+// Get retrieves the session stored under the given key as JSON.
+// Returns nil, nil if the key does not exist.
+func (s *RedisHashStorage) Get(key string) ([]byte, error) {
+	ctx, cancel := s.base.opContext()
+	defer cancel()
+	return s.GetCtx(ctx, key)
+}
+
+// GetCtx is like Get but uses the given context.
+func (s *RedisHashStorage) GetCtx(ctx context.Context, key string) (_ []byte, err error) {
+	if s.base.client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	defer s.base.observe("get", s.base.opStart(), &err)
+
+	fields, err := s.base.client.HGetAll(ctx, s.base.buildKey(key)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from redis: %w", contextError(ctx, err))
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	session, err := sessionFromHash(fields)
+	if err != nil {
+		return nil, invalidData(err)
+	}
+	return json.Marshal(session)
+}
+
+// Set stores the JSON-encoded SessionData val as a hash, replacing any
+// previous session under the key, and applies the expiration with PEXPIRE.
+// If expiration is 0, the session never expires.
+// Empty key or value will be ignored without an error.
+func (s *RedisHashStorage) Set(key string, val []byte, exp time.Duration) error {
+	ctx, cancel := s.base.opContext()
+	defer cancel()
+	return s.SetCtx(ctx, key, val, exp)
+}
+
+// SetCtx is like Set but uses the given context.
+func (s *RedisHashStorage) SetCtx(ctx context.Context, key string, val []byte, exp time.Duration) (err error) {
+	if s.base.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+
+	if key == "" || len(val) == 0 {
+		return nil // Ignore empty key or value as per interface
+	}
+
+	var session SessionData
+	if err := json.Unmarshal(val, &session); err != nil {
+		return fmt.Errorf("failed to decode session data: %w", invalidData(err))
+	}
+	fields, err := sessionToHash(&session)
+	if err != nil {
+		return err
+	}
+	defer s.base.observe("set", s.base.opStart(), &err)
+
+	fullKey := s.base.buildKey(key)
+
+	// The old hash is removed in the same transaction so fields that are
+	// now empty do not linger
+	_, err = s.base.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, fullKey)
+		pipe.HSet(ctx, fullKey, fields)
+		if exp > 0 {
+			pipe.PExpire(ctx, fullKey, exp)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set in redis: %w", contextError(ctx, err))
+	}
+
+	return nil
+}
+
+// updateFieldsScript sets the field/value pairs in ARGV on the hash KEYS[1]
+// if it exists, and returns whether it did.
+var updateFieldsScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// UpdateFields sets the given session fields, named by the HashField
+// constants and encoded as described on RedisHashStorage, without rewriting
+// the other fields. The key keeps its expiration. It reports whether the
+// session existed; missing sessions are not created.
+func (s *RedisHashStorage) UpdateFields(key string, fields map[string]string) (bool, error) {
+	ctx, cancel := s.base.opContext()
+	defer cancel()
+	return s.UpdateFieldsCtx(ctx, key, fields)
+}
+
+// UpdateFieldsCtx is like UpdateFields but uses the given context.
+func (s *RedisHashStorage) UpdateFieldsCtx(ctx context.Context, key string, fields map[string]string) (_ bool, err error) {
+	if s.base.client == nil {
+		return false, fmt.Errorf("redis client is nil")
+	}
+	if len(fields) == 0 {
+		return s.base.ExistsCtx(ctx, key)
+	}
+
+	// Reject values Get could not decode later
+	args := make([]interface{}, 0, 2*len(fields))
+	var scratch SessionData
+	for name, value := range fields {
+		if err := setSessionField(&scratch, name, value); err != nil {
+			return false, err
+		}
+		args = append(args, name, value)
+	}
+	defer s.base.observe("update_fields", s.base.opStart(), &err)
+
+	updated, err := updateFieldsScript.Run(ctx, s.base.client, []string{s.base.buildKey(key)}, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to update fields in redis: %w", contextError(ctx, err))
+	}
+
+	return updated == 1, nil
+}
+
+// Delete removes the session stored under the given key.
+func (s *RedisHashStorage) Delete(key string) error {
+	return s.base.Delete(key)
+}
+
+// DeleteCtx is like Delete but uses the given context.
+func (s *RedisHashStorage) DeleteCtx(ctx context.Context, key string) error {
+	return s.base.DeleteCtx(ctx, key)
+}
+
+// DeleteMany removes the sessions stored under the given keys.
+func (s *RedisHashStorage) DeleteMany(keys []string) error {
+	return s.base.DeleteMany(keys)
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *RedisHashStorage) Reset() error {
+	return s.base.Reset()
+}
+
+// Exists checks if a session exists.
+func (s *RedisHashStorage) Exists(key string) (bool, error) {
+	return s.base.Exists(key)
+}
+
+// GetTTL returns the remaining TTL for a session.
+// Returns -2 if the key does not exist, -1 if the key has no expiration.
+func (s *RedisHashStorage) GetTTL(key string) (time.Duration, error) {
+	return s.base.GetTTL(key)
+}
+
+// Expire sets a new expiration on a session.
+func (s *RedisHashStorage) Expire(key string, exp time.Duration) error {
+	return s.base.Expire(key, exp)
+}
+
+// Touch sets a new expiration on a session without rewriting it and
+// reports whether it existed. If exp is 0, the expiration is removed.
+func (s *RedisHashStorage) Touch(key string, exp time.Duration) (bool, error) {
+	return s.base.Touch(key, exp)
+}
+
+// NotifyExpired calls fn with the key of every expired session; see
+// RedisStorage.NotifyExpired.
+func (s *RedisHashStorage) NotifyExpired(fn func(key string)) error {
+	return s.base.NotifyExpired(fn)
+}
+
+// Ping checks that the Redis server is reachable.
+func (s *RedisHashStorage) Ping(ctx context.Context) error {
+	return s.base.Ping(ctx)
+}
+
+// Close closes the Redis client connection.
+func (s *RedisHashStorage) Close() error {
+	return s.base.Close()
+}
+
+// sessionToHash encodes session as hash fields, leaving out empty optional fields.
+func sessionToHash(session *SessionData) (map[string]interface{}, error) {
+	fields := map[string]interface{}{
+		HashFieldID:             session.ID,
+		HashFieldAuthenticated:  strconv.FormatBool(session.Authenticated),
+		HashFieldCreatedAt:      session.CreatedAt.Format(time.RFC3339Nano),
+		HashFieldExpiresAt:      session.ExpiresAt.Format(time.RFC3339Nano),
+		HashFieldLastAccessedAt: session.LastAccessedAt.Format(time.RFC3339Nano),
+	}
+	if session.UserID != "" {
+		fields[HashFieldUserID] = session.UserID
+	}
+	if session.Email != "" {
+		fields[HashFieldEmail] = session.Email
+	}
+	if session.Phone != "" {
+		fields[HashFieldPhone] = session.Phone
+	}
+	if session.IPAddress != "" {
+		fields[HashFieldIPAddress] = session.IPAddress
+	}
+	if session.UserAgent != "" {
+		fields[HashFieldUserAgent] = session.UserAgent
+	}
+
+	addJSON := func(name string, value interface{}) error {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode session field %s: %w", name, err)
+		}
+		fields[name] = string(encoded)
+		return nil
+	}
+	if len(session.Data) > 0 {
+		if err := addJSON(HashFieldData, session.Data); err != nil {
+			return nil, err
+		}
+	}
+	if len(session.AMR) > 0 {
+		if err := addJSON(HashFieldAMR, session.AMR); err != nil {
+			return nil, err
+		}
+	}
+	if len(session.Scopes) > 0 {
+		if err := addJSON(HashFieldScopes, session.Scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+// sessionFromHash decodes the hash fields written by sessionToHash.
+// Unknown fields are ignored.
+func sessionFromHash(fields map[string]string) (*SessionData, error) {
+	var session SessionData
+	for name, value := range fields {
+		if err := setSessionField(&session, name, value); err != nil && !errors.Is(err, errUnknownSessionField) {
+			return nil, err
+		}
+	}
+	return &session, nil
+}
+
+// errUnknownSessionField is returned by setSessionField for names that are
+// not SessionData fields.
+var errUnknownSessionField = errors.New("unknown session field")
+
+// setSessionField decodes one hash field into session.
+func setSessionField(session *SessionData, name, value string) error {
+	var err error
+	switch name {
+	case HashFieldID:
+		session.ID = value
+	case HashFieldUserID:
+		session.UserID = value
+	case HashFieldEmail:
+		session.Email = value
+	case HashFieldPhone:
+		session.Phone = value
+	case HashFieldIPAddress:
+		session.IPAddress = value
+	case HashFieldUserAgent:
+		session.UserAgent = value
+	case HashFieldAuthenticated:
+		session.Authenticated, err = strconv.ParseBool(value)
+	case HashFieldData:
+		err = json.Unmarshal([]byte(value), &session.Data)
+	case HashFieldCreatedAt:
+		session.CreatedAt, err = time.Parse(time.RFC3339Nano, value)
+	case HashFieldExpiresAt:
+		session.ExpiresAt, err = time.Parse(time.RFC3339Nano, value)
+	case HashFieldLastAccessedAt:
+		session.LastAccessedAt, err = time.Parse(time.RFC3339Nano, value)
+	case HashFieldAMR:
+		err = json.Unmarshal([]byte(value), &session.AMR)
+	case HashFieldScopes:
+		err = json.Unmarshal([]byte(value), &session.Scopes)
+	default:
+		return fmt.Errorf("%w: %s", errUnknownSessionField, name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for session field %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package session
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSessionData() *SessionData {
+	session := NewSessionData("session-123", time.Hour)
+	session.UserID = "user-1"
+	session.Email = "alice@example.com"
+	session.Phone = "+1555000"
+	session.Authenticated = true
+	session.SetValue("theme", "dark")
+	session.SetValue("prefs", map[string]interface{}{"lang": "en", "count": 3.0})
+	session.AddAMR("pwd")
+	session.AddAMR("otp")
+	session.AddScope("read")
+	session.IPAddress = "203.0.113.7"
+	session.UserAgent = "test-agent/1.0"
+	return session
+}
+
+func TestRedisHashStorageMatchesBlobMode(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	blob := NewManager(NewRedisStorage(client, "blob:"), DefaultConfig())
+	hash := NewManager(NewRedisHashStorage(client, "hash:"), DefaultConfig())
+
+	for _, session := range []*SessionData{newTestSessionData(), NewSessionData("minimal", time.Hour)} {
+		for _, m := range []*Manager{blob, hash} {
+			if err := m.SaveSession(session); err != nil {
+				t.Fatalf("failed to save: %v", err)
+			}
+		}
+
+		fromBlob, err := blob.LoadSession(session.ID)
+		if err != nil || fromBlob == nil {
+			t.Fatalf("failed to load from blob storage: %v", err)
+		}
+		fromHash, err := hash.LoadSession(session.ID)
+		if err != nil || fromHash == nil {
+			t.Fatalf("failed to load from hash storage: %v", err)
+		}
+
+		want, _ := json.Marshal(fromBlob)
+		got, _ := json.Marshal(fromHash)
+		if string(got) != string(want) {
+			t.Errorf("hash mode differs from blob mode:\n got  %s\n want %s", got, want)
+		}
+	}
+
+	if fields, _ := mr.HKeys("hash:session-123"); len(fields) != 13 {
+		t.Errorf("expected one hash field per attribute, got %v", fields)
+	}
+	if fields, _ := mr.HKeys("hash:minimal"); len(fields) != 5 {
+		t.Errorf("expected empty optional fields to be left out, got %v", fields)
+	}
+}
+
+func TestRedisHashStoragePartialUpdate(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisHashStorage(client, "test:")
+	manager := NewManager(storage, DefaultConfig())
+
+	session := newTestSessionData()
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	ttl := mr.TTL("test:session-123")
+
+	later := session.LastAccessedAt.Add(time.Minute)
+	found, err := storage.UpdateFields(session.ID, map[string]string{
+		HashFieldLastAccessedAt: later.Format(time.RFC3339Nano),
+	})
+	if err != nil || !found {
+		t.Fatalf("failed to update fields: %v", err)
+	}
+
+	loaded, err := manager.LoadSession(session.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if !loaded.LastAccessedAt.Equal(later) {
+		t.Errorf("expected last access %v, got %v", later, loaded.LastAccessedAt)
+	}
+	if loaded.UserID != "user-1" || loaded.Email != "alice@example.com" || !loaded.Authenticated {
+		t.Errorf("expected other fields to be kept, got %+v", loaded)
+	}
+	if v, _ := loaded.GetValue("theme"); v != "dark" || !loaded.HasAMR("otp") || !loaded.HasScope("read") {
+		t.Errorf("expected data, AMR and scopes to be kept, got %+v", loaded)
+	}
+	if got := mr.TTL("test:session-123"); got != ttl {
+		t.Errorf("expected TTL %v to be kept, got %v", ttl, got)
+	}
+
+	// Missing sessions are not created
+	found, err = storage.UpdateFields("missing", map[string]string{HashFieldUserID: "x"})
+	if err != nil || found {
+		t.Errorf("expected missing session to be reported, got %v (%v)", found, err)
+	}
+	if mr.Exists("test:missing") {
+		t.Error("expected missing session to not be created")
+	}
+
+	// Values Get could not decode are rejected
+	for name, value := range map[string]string{
+		"unknown":               "x",
+		HashFieldAuthenticated:  "maybe",
+		HashFieldExpiresAt:      "tomorrow",
+		HashFieldData:           "{",
+		HashFieldLastAccessedAt: "",
+	} {
+		if _, err := storage.UpdateFields(session.ID, map[string]string{name: value}); err == nil {
+			t.Errorf("expected error for %s=%q", name, value)
+		}
+	}
+}
+
+func TestManagerTouchSessionFieldUpdater(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	manager := NewManager(NewRedisHashStorage(client, "test:"), DefaultConfig().WithExpiration(time.Hour))
+
+	session := newTestSessionData()
+	_ = manager.SaveSession(session)
+	mr.FastForward(30 * time.Minute)
+
+	// Only the access and expiration times are written
+	session.UserID = "not-saved"
+	if err := manager.TouchSession(session); err != nil {
+		t.Fatalf("failed to touch: %v", err)
+	}
+	if ttl := mr.TTL("test:session-123"); ttl != time.Hour {
+		t.Errorf("expected TTL to be extended to 1h, got %v", ttl)
+	}
+
+	loaded, _ := manager.LoadSession(session.ID)
+	if loaded.UserID != "user-1" {
+		t.Errorf("expected user ID to be untouched, got %s", loaded.UserID)
+	}
+	if !loaded.ExpiresAt.Equal(session.ExpiresAt) || !loaded.LastAccessedAt.Equal(session.LastAccessedAt) {
+		t.Error("expected access and expiration times to be updated")
+	}
+
+	// A session that disappeared is saved again
+	mr.Del("test:session-123")
+	if err := manager.TouchSession(session); err != nil {
+		t.Fatalf("failed to touch: %v", err)
+	}
+	if loaded, _ := manager.LoadSession(session.ID); loaded == nil || loaded.UserID != "not-saved" {
+		t.Errorf("expected session to be saved again, got %+v", loaded)
+	}
+}
+
+func TestRedisHashStorageInvalidValue(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisHashStorage(client, "test:")
+	if err := storage.Set("key", []byte("not json"), time.Hour); err == nil || !strings.Contains(err.Error(), "failed to decode session data") {
+		t.Errorf("expected decode error, got %v", err)
+	}
+	if got, err := storage.Get("missing"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing key, got %v, %v", got, err)
+	}
+
+	// Set replaces the previous hash instead of merging into it
+	_ = storage.Set("key", []byte(`{"id":"key","user_id":"u1"}`), time.Hour)
+	_ = storage.Set("key", []byte(`{"id":"key"}`), time.Hour)
+	if mr.HGet("test:key", HashFieldUserID) != "" {
+		t.Error("expected stale fields to be removed")
+	}
+}
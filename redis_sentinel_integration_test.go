@@ -0,0 +1,39 @@
+//go:build integration
+
+package session
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRedisStorageSentinelIntegration needs a running Sentinel deployment:
+//
+//	REDIS_SENTINEL_ADDRS=127.0.0.1:26379 REDIS_SENTINEL_MASTER=mymaster \
+//		go test -tags integration -run Sentinel .
+func TestRedisStorageSentinelIntegration(t *testing.T) {
+	addrs := os.Getenv("REDIS_SENTINEL_ADDRS")
+	master := os.Getenv("REDIS_SENTINEL_MASTER")
+	if addrs == "" || master == "" {
+		t.Skip("REDIS_SENTINEL_ADDRS and REDIS_SENTINEL_MASTER are not set")
+	}
+
+	storage, err := NewRedisStorageFromSentinel(master, strings.Split(addrs, ","), os.Getenv("REDIS_PASSWORD"), 0, "integration:")
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	got, err := storage.Get("key")
+	if err != nil || string(got) != "value" {
+		t.Errorf("expected value, got %q (%v)", got, err)
+	}
+	if err := storage.Delete("key"); err != nil {
+		t.Errorf("failed to delete: %v", err)
+	}
+}
@@ -2,51 +2,201 @@ package session
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisStoreOptions configures RedisStore.
+type RedisStoreOptions struct {
+	// KeyPrefix is prepended to all keys (e.g. "otp:session:"). A ':' is
+	// appended if missing.
+	KeyPrefix string
+
+	// IDGenerator generates the IDs of sessions created with Create.
+	// Default: DefaultIDGenerator
+	IDGenerator IDGenerator
+
+	// EncryptionKeys enables AES-GCM encryption of the stored records, in
+	// the format of EncryptedStorage. Each key is 16, 24 or 32 bytes long;
+	// the first encrypts new records and all decrypt, for key rotation.
+	// Default: nil (no encryption)
+	EncryptionKeys [][]byte
+}
+
+// DefaultRedisStoreOptions returns RedisStoreOptions with default values.
+func DefaultRedisStoreOptions() RedisStoreOptions {
+	return RedisStoreOptions{
+		IDGenerator: DefaultIDGenerator,
+	}
+}
+
+// WithKeyPrefix sets the key prefix.
+func (o RedisStoreOptions) WithKeyPrefix(prefix string) RedisStoreOptions {
+	o.KeyPrefix = prefix
+	return o
+}
+
+// WithIDGenerator sets the session ID generator.
+func (o RedisStoreOptions) WithIDGenerator(gen IDGenerator) RedisStoreOptions {
+	o.IDGenerator = gen
+	return o
+}
+
+// WithEncryption enables encryption of the stored records with keys.
+func (o RedisStoreOptions) WithEncryption(keys [][]byte) RedisStoreOptions {
+	o.EncryptionKeys = keys
+	return o
+}
+
+// redisStoreCreateAttempts is how many IDs Create generates before giving
+// up on finding one that is not taken.
+const redisStoreCreateAttempts = 3
+
 // RedisStore implements Store using Redis. Keys are prefixed with keyPrefix.
+// Every command touches a single key, so it works with Cluster clients too.
+//
+// With encryption enabled, records that cannot be decrypted make Get and the
+// other reads return an error wrapping ErrDecryptionFailed, which callers
+// should treat as a missing session. Touch and SetCAS then read and write
+// the record under WATCH instead of running a script on the plaintext.
 type RedisStore struct {
-	client    *redis.Client
+	client    redis.UniversalClient
 	keyPrefix string
+	newID     IDGenerator
+	keys      encryptionKeys
 }
 
 // NewRedisStore creates a Redis-backed Store. keyPrefix is prepended to all keys (e.g. "otp:session:").
 func NewRedisStore(client *redis.Client, keyPrefix string) *RedisStore {
+	// Avoid storing a typed nil so the nil client checks keep working
+	if client == nil {
+		return NewRedisStoreUniversal(nil, keyPrefix)
+	}
+	return NewRedisStoreUniversal(client, keyPrefix)
+}
+
+// NewRedisStoreUniversal creates a Redis-backed Store from any go-redis client,
+// including cluster and Sentinel failover clients.
+func NewRedisStoreUniversal(client redis.UniversalClient, keyPrefix string) *RedisStore {
+	return NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().WithKeyPrefix(keyPrefix))
+}
+
+// NewRedisStoreWithOptions creates a Redis-backed Store from any go-redis
+// client using options. It panics if an encryption key has an invalid
+// length, as keys are expected to come from configuration checked at
+// startup.
+func NewRedisStoreWithOptions(client redis.UniversalClient, opts RedisStoreOptions) *RedisStore {
+	keyPrefix := opts.KeyPrefix
 	if keyPrefix != "" && keyPrefix[len(keyPrefix)-1] != ':' {
 		keyPrefix += ":"
 	}
-	return &RedisStore{client: client, keyPrefix: keyPrefix}
+	newID := opts.IDGenerator
+	if newID == nil {
+		newID = DefaultIDGenerator
+	}
+	s := &RedisStore{client: client, keyPrefix: keyPrefix, newID: newID}
+	if opts.EncryptionKeys != nil {
+		s.keys = newEncryptionKeys("redis store encryption", opts.EncryptionKeys)
+	}
+	return s
 }
 
 func (s *RedisStore) key(id string) string {
 	return s.keyPrefix + id
 }
 
-func generateSessionID() (string, error) {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+// nonNilData returns data, or an empty map if data is nil, so that records
+// are never written with null data.
+func nonNilData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return make(map[string]interface{})
 	}
-	return "sess_" + base64.URLEncoding.EncodeToString(b)[:22], nil
+	return data
 }
 
-// Create creates a new session and returns its ID.
+// Create creates a new session and returns its ID. ttl must be positive.
+// The key is written with SET NX, and a new ID is generated if the ID is
+// already taken.
 func (s *RedisStore) Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (string, error) {
-	id, err := generateSessionID()
+	return s.CreateWithMeta(ctx, data, nil, ttl)
+}
+
+// CreateWithMeta is Create recording meta in the session.
+func (s *RedisStore) CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error) {
+	if s.client == nil {
+		return "", fmt.Errorf("redis client is nil")
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("session ttl must be > 0")
+	}
+	for attempt := 0; attempt < redisStoreCreateAttempts; attempt++ {
+		id, err := s.newID()
+		if err != nil {
+			return "", fmt.Errorf("generate session id: %w", err)
+		}
+		if err := ValidateSessionID(id); err != nil {
+			return "", fmt.Errorf("generate session id: %w", err)
+		}
+		created, err := s.setNX(ctx, id, data, meta, ttl)
+		if err != nil {
+			return "", err
+		}
+		if created {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("generate session id: %d ids collided with existing sessions: %w", redisStoreCreateAttempts, ErrAlreadyExists)
+}
+
+// CreateWithID creates a session with the given ID instead of generating
+// one. The key is written with SET NX, so ErrAlreadyExists is returned if a
+// session with the ID exists, even under concurrent creations.
+func (s *RedisStore) CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if err := ValidateSessionID(id); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("session ttl must be > 0")
+	}
+	created, err := s.setNX(ctx, id, data, nil, ttl)
 	if err != nil {
-		return "", fmt.Errorf("generate session id: %w", err)
+		return err
 	}
-	if err := s.Set(ctx, id, data, ttl); err != nil {
-		return "", err
+	if !created {
+		return ErrAlreadyExists
 	}
-	return id, nil
+	return nil
+}
+
+// setNX writes a new session record unless the key exists, and reports
+// whether it did.
+func (s *RedisStore) setNX(ctx context.Context, id string, data map[string]interface{}, meta map[string]string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	body, err := s.encode(id, &KVSessionRecord{
+		Revision:  1,
+		ID:        id,
+		Data:      nonNilData(data),
+		CreatedAt: now,
+		Meta:      meta,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return false, err
+	}
+	created, err := s.client.SetNX(ctx, s.key(id), body, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis set: %w", unavailable(err))
+	}
+	return created, nil
 }
 
 // Get returns the session for the given ID, or nil and error if not found/expired.
@@ -59,53 +209,693 @@ func (s *RedisStore) Get(ctx context.Context, id string) (*KVSessionRecord, erro
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("redis get: %w", err)
+		return nil, fmt.Errorf("redis get: %w", unavailable(err))
+	}
+	rec, err := s.decode(id, data)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		_ = s.client.Del(ctx, s.key(id))
+	}
+	return rec, nil
+}
+
+// encode marshals the record of id and encrypts it if encryption is
+// enabled.
+func (s *RedisStore) encode(id string, rec *KVSessionRecord) ([]byte, error) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal session: %w", err)
+	}
+	if s.keys == nil {
+		return body, nil
+	}
+	return s.keys.seal(s.key(id), body)
+}
+
+// unmarshal decrypts data if encryption is enabled and unmarshals it.
+func (s *RedisStore) unmarshal(id string, data []byte) (*KVSessionRecord, error) {
+	if s.keys != nil {
+		var err error
+		if data, err = s.keys.open(s.key(id), data); err != nil {
+			return nil, fmt.Errorf("session %s: %w", id, err)
+		}
 	}
 	var rec KVSessionRecord
 	if err := json.Unmarshal(data, &rec); err != nil {
-		return nil, fmt.Errorf("unmarshal session: %w", err)
+		return nil, fmt.Errorf("unmarshal session: %w", invalidData(err))
+	}
+	return &rec, nil
+}
+
+// decode is unmarshal returning nil for an expired session.
+func (s *RedisStore) decode(id string, data []byte) (*KVSessionRecord, error) {
+	rec, err := s.unmarshal(id, data)
+	if err != nil {
+		return nil, err
 	}
 	if time.Now().After(rec.ExpiresAt) {
-		_ = s.client.Del(ctx, s.key(id))
 		return nil, nil
 	}
-	return &rec, nil
+	return rec, nil
+}
+
+// routesByKey reports whether the client spreads keys over several nodes,
+// so that multi-key commands are only safe if a hash tag in the prefix puts
+// all sessions on the same node.
+func (s *RedisStore) routesByKey() bool {
+	if _, _, ok := hashTagSpan(s.keyPrefix); ok {
+		return false
+	}
+	switch s.client.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetMulti returns the sessions for the given IDs in one round trip,
+// leaving out missing and expired ones. A single MGET is used, except with
+// cluster and ring clients where sessions are fetched with pipelined GETs.
+// Sessions that cannot be decoded are reported in a *GetMultiError,
+// returned along with the others.
+func (s *RedisStore) GetMulti(ctx context.Context, ids []string) (map[string]*KVSessionRecord, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	records := make(map[string]*KVSessionRecord, len(ids))
+	if len(ids) == 0 {
+		return records, nil
+	}
+
+	values := make([][]byte, len(ids))
+	if !s.routesByKey() {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = s.key(id)
+		}
+		res, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("redis mget: %w", unavailable(err))
+		}
+		for i, v := range res {
+			// MGET returns bulk strings as string and missing keys as nil
+			if str, ok := v.(string); ok {
+				values[i] = []byte(str)
+			}
+		}
+	} else {
+		cmds := make([]*redis.StringCmd, len(ids))
+		// The pipeline reports the first failed command, which is usually
+		// just a missing key, so errors are checked per command instead
+		_, _ = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, id := range ids {
+				cmds[i] = pipe.Get(ctx, s.key(id))
+			}
+			return nil
+		})
+		for i, cmd := range cmds {
+			data, err := cmd.Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("redis get: %w", unavailable(err))
+			}
+			values[i] = data
+		}
+	}
+
+	var failed map[string]error
+	for i, data := range values {
+		if data == nil {
+			continue
+		}
+		rec, err := s.decode(ids[i], data)
+		if err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[ids[i]] = err
+			continue
+		}
+		if rec != nil {
+			records[ids[i]] = rec
+		}
+	}
+	if failed != nil {
+		return records, &GetMultiError{Errors: failed}
+	}
+	return records, nil
+}
+
+// DeleteMulti removes the sessions for the given IDs with a single UNLINK,
+// or DEL on servers without UNLINK. With cluster and ring clients each
+// session is removed with its own command in a pipeline.
+func (s *RedisStore) DeleteMulti(ctx context.Context, ids []string) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+
+	err := s.removeKeys(ctx, true, keys)
+	if err != nil && isUnknownCommand(err) {
+		err = s.removeKeys(ctx, false, keys)
+	}
+	if err != nil {
+		return fmt.Errorf("redis del: %w", unavailable(err))
+	}
+	return nil
+}
+
+// removeKeys runs UNLINK or DEL for the given keys.
+func (s *RedisStore) removeKeys(ctx context.Context, unlink bool, keys []string) error {
+	remove := func(c redis.Cmdable, keys ...string) *redis.IntCmd {
+		if unlink {
+			return c.Unlink(ctx, keys...)
+		}
+		return c.Del(ctx, keys...)
+	}
+	if !s.routesByKey() {
+		return remove(s.client, keys...).Err()
+	}
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			remove(pipe, key)
+		}
+		return nil
+	})
+	return err
 }
 
 // Set stores or updates the session for the given ID with the given ttl.
-// When updating an existing session, CreatedAt is preserved.
+// When updating an existing session, CreatedAt and Meta are preserved and
+// Revision incremented. If ttl is 0, an existing session keeps its
+// ExpiresAt and key TTL, and a missing one is not created:
+// ErrSessionNotFound is returned.
 func (s *RedisStore) Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
 	if s.client == nil {
 		return fmt.Errorf("redis client is nil")
 	}
-	now := time.Now()
-	createdAt := now
-	if existing, _ := s.Get(ctx, id); existing != nil {
-		createdAt = existing.CreatedAt
+	if ttl <= 0 {
+		return s.setKeepTTL(ctx, id, data)
 	}
+	now := time.Now()
 	rec := &KVSessionRecord{
+		Revision:  1,
 		ID:        id,
-		Data:      data,
-		CreatedAt: createdAt,
+		Data:      nonNilData(data),
+		CreatedAt: now,
 		ExpiresAt: now.Add(ttl),
 	}
-	body, err := json.Marshal(rec)
+	if existing, _ := s.Get(ctx, id); existing != nil {
+		rec.Revision = existing.Revision + 1
+		rec.CreatedAt = existing.CreatedAt
+		rec.Meta = existing.Meta
+	}
+	body, err := s.encode(id, rec)
 	if err != nil {
-		return fmt.Errorf("marshal session: %w", err)
+		return err
 	}
 	if err := s.client.Set(ctx, s.key(id), body, ttl).Err(); err != nil {
-		return fmt.Errorf("redis set: %w", err)
+		return fmt.Errorf("redis set: %w", unavailable(err))
+	}
+	return nil
+}
+
+// setKeepTTL replaces the data of an existing session, keeping its
+// ExpiresAt, and writes it with SET KEEPTTL so the key TTL is kept as well.
+func (s *RedisStore) setKeepTTL(ctx context.Context, id string, data map[string]interface{}) error {
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return fmt.Errorf("cannot create a session without a ttl: %w", ErrSessionNotFound)
+	}
+	rec.Data = nonNilData(data)
+	rec.Revision++
+	body, err := s.encode(id, rec)
+	if err != nil {
+		return err
+	}
+	if err := s.client.SetArgs(ctx, s.key(id), body, redis.SetArgs{KeepTTL: true}).Err(); err != nil {
+		return fmt.Errorf("redis set: %w", unavailable(err))
 	}
 	return nil
 }
 
+// setCASScript sets KEYS[1] to ARGV[2] if the revision of the record in it
+// is ARGV[1], with a TTL of ARGV[3] milliseconds or keeping the TTL if that
+// is 0. It returns 1 on success, 0 on a revision mismatch and -1 if the key
+// does not exist. revision is the first field of a marshaled
+// KVSessionRecord; records without it have revision 0.
+var setCASScript = redis.NewScript(`
+local body = redis.call("GET", KEYS[1])
+if not body then
+	return -1
+end
+local revision = 0
+local match = string.match(body, '^{"revision":(%-?%d+),')
+if match then
+	revision = tonumber(match)
+end
+if revision ~= tonumber(ARGV[1]) then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+end
+return 1
+`)
+
+// SetCAS is Set if the revision of the session is expectedRevision, and
+// returns ErrRevisionMismatch otherwise. The revision is compared and the
+// session written in a single script, so of concurrent SetCAS calls with
+// the same expected revision exactly one succeeds. If ttl is 0, the session
+// keeps its expiration. Returns ErrSessionNotFound if the session does not
+// exist or has expired.
+func (s *RedisStore) SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if s.keys != nil {
+		return s.modify(ctx, id, func(rec *KVSessionRecord, now time.Time) (time.Duration, error) {
+			if rec.Revision != expectedRevision {
+				return 0, ErrRevisionMismatch
+			}
+			exp := ttl
+			if exp <= 0 {
+				exp = rec.ExpiresAt.Sub(now)
+			}
+			rec.Revision++
+			rec.Data = nonNilData(data)
+			rec.ExpiresAt = now.Add(exp)
+			return exp, nil
+		})
+	}
+
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return ErrSessionNotFound
+	}
+	if rec.Revision != expectedRevision {
+		return ErrRevisionMismatch
+	}
+
+	rec.Revision = expectedRevision + 1
+	rec.Data = nonNilData(data)
+	if ttl > 0 {
+		rec.ExpiresAt = time.Now().Add(ttl)
+	}
+	body, err := s.encode(id, rec)
+	if err != nil {
+		return err
+	}
+	n, err := setCASScript.Run(ctx, s.client, []string{s.key(id)}, expectedRevision, string(body), ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("redis set: %w", unavailable(err))
+	}
+	switch n {
+	case -1:
+		return ErrSessionNotFound
+	case 0:
+		return ErrRevisionMismatch
+	}
+	return nil
+}
+
+// touchScript replaces the expires_at field of the record in KEYS[1] with
+// the JSON string ARGV[1] and sets the key TTL to ARGV[2] milliseconds. It
+// returns the updated record, or nil if the key does not exist. expires_at is the last field of a
+// marshaled KVSessionRecord, so anchoring the match at the end of the value
+// leaves fields of the same name inside the data alone.
+var touchScript = redis.NewScript(`
+local body = redis.call("GET", KEYS[1])
+if not body then
+	return false
+end
+local updated, n = string.gsub(body, '"expires_at":"[^"]*"}$', '"expires_at":' .. ARGV[1] .. '}')
+if n == 0 then
+	return redis.error_reply("unexpected session record format")
+end
+redis.call("SET", KEYS[1], updated, "PX", ARGV[2])
+return updated
+`)
+
+// Touch sets the expiration of the session to ttl from now, updating the
+// record's ExpiresAt and the key TTL together in a single script, without
+// rewriting the data. ttl must be positive. Returns ErrSessionNotFound if
+// the session does not exist.
+func (s *RedisStore) Touch(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := s.touch(ctx, id, ttl, false)
+	return err
+}
+
+// GetAndTouch is Touch returning the updated record, read in the same
+// script, so a session is fetched and extended in a single round trip.
+func (s *RedisStore) GetAndTouch(ctx context.Context, id string, ttl time.Duration) (*KVSessionRecord, error) {
+	return s.touch(ctx, id, ttl, true)
+}
+
+// touch implements Touch, returning the updated record if get is set.
+func (s *RedisStore) touch(ctx context.Context, id string, ttl time.Duration, get bool) (*KVSessionRecord, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("session ttl must be > 0")
+	}
+	if s.keys != nil {
+		var updated *KVSessionRecord
+		err := s.modify(ctx, id, func(rec *KVSessionRecord, now time.Time) (time.Duration, error) {
+			rec.ExpiresAt = now.Add(ttl)
+			updated = rec
+			return ttl, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+
+	expiresAt, err := json.Marshal(time.Now().Add(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("marshal expiration: %w", err)
+	}
+	body, err := touchScript.Run(ctx, s.client, []string{s.key(id)}, string(expiresAt), ttl.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis touch: %w", unavailable(err))
+	}
+	if !get {
+		return nil, nil
+	}
+	return s.unmarshal(id, []byte(body))
+}
+
+// GetTTL returns the time left before the session expires, from the PTTL of
+// its key, or from the ExpiresAt of the record if that is sooner or the key
+// has no TTL. Returns ErrSessionNotFound if the key does not exist or the
+// record has expired.
+func (s *RedisStore) GetTTL(ctx context.Context, id string) (time.Duration, error) {
+	if s.client == nil {
+		return 0, fmt.Errorf("redis client is nil")
+	}
+	var getCmd *redis.StringCmd
+	var pttlCmd *redis.DurationCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, s.key(id))
+		pttlCmd = pipe.PTTL(ctx, s.key(id))
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrSessionNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("redis get ttl: %w", unavailable(err))
+	}
+
+	rec, err := s.unmarshal(id, []byte(getCmd.Val()))
+	if err != nil {
+		return 0, err
+	}
+	ttl := time.Until(rec.ExpiresAt)
+	if keyTTL := pttlCmd.Val(); keyTTL >= 0 && keyTTL < ttl {
+		ttl = keyTTL
+	}
+	if ttl <= 0 {
+		return 0, ErrSessionNotFound
+	}
+	return ttl, nil
+}
+
+// List returns a page of session IDs with SCAN over the keys under the key
+// prefix; nextCursor is the SCAN cursor. A Redis key prefix shared with
+// other data should end with a separator that the other keys do not use, or
+// their keys are listed too. Cluster and Ring clients are not supported, as
+// a single cursor cannot span their nodes.
+func (s *RedisStore) List(ctx context.Context, cursor string, limit int) ([]string, string, error) {
+	if s.client == nil {
+		return nil, "", fmt.Errorf("redis client is nil")
+	}
+	switch s.client.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		return nil, "", fmt.Errorf("redis list is not supported with %T", s.client)
+	}
+
+	var scanCursor uint64
+	if cursor != "" {
+		var err error
+		if scanCursor, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("invalid list cursor %q", cursor)
+		}
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	keys, next, err := s.client.Scan(ctx, scanCursor, EscapePattern(s.keyPrefix)+"*", int64(limit)).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("redis scan: %w", unavailable(err))
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// Skip index and lookup keys, the only keys with ':' after the prefix
+		if id := strings.TrimPrefix(key, s.keyPrefix); !strings.Contains(id, ":") {
+			ids = append(ids, id)
+		}
+	}
+	nextCursor := ""
+	if next != 0 {
+		nextCursor = strconv.FormatUint(next, 10)
+	}
+	return ids, nextCursor, nil
+}
+
+// indexKey returns the key of the named index. Session IDs cannot contain
+// ':', so index keys never collide with session keys.
+func (s *RedisStore) indexKey(index string) string {
+	return s.keyPrefix + "index:" + index
+}
+
+// addToIndexScript adds ARGV[2] to the sorted set in KEYS[1] with the score
+// ARGV[1], and extends the TTL of the set to ARGV[3] milliseconds if it is
+// shorter.
+var addToIndexScript = redis.NewScript(`
+redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// AddToIndex adds id to the index, a sorted set scored by the time of the
+// addition, whose TTL is extended to ttl if it is shorter.
+func (s *RedisStore) AddToIndex(ctx context.Context, index, id string, ttl time.Duration) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("index ttl must be > 0")
+	}
+	err := addToIndexScript.Run(ctx, s.client, []string{s.indexKey(index)}, time.Now().UnixMicro(), id, ttl.Milliseconds()).Err()
+	if err != nil {
+		return fmt.Errorf("redis zadd: %w", unavailable(err))
+	}
+	return nil
+}
+
+// RemoveFromIndex removes ids from the index.
+func (s *RedisStore) RemoveFromIndex(ctx context.Context, index string, ids ...string) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+	if err := s.client.ZRem(ctx, s.indexKey(index), members...).Err(); err != nil {
+		return fmt.Errorf("redis zrem: %w", unavailable(err))
+	}
+	return nil
+}
+
+// ListIndex returns the IDs in the index, oldest first.
+func (s *RedisStore) ListIndex(ctx context.Context, index string) ([]string, error) {
+	if s.client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	ids, err := s.client.ZRange(ctx, s.indexKey(index), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis zrange: %w", unavailable(err))
+	}
+	return ids, nil
+}
+
+// lookupKey returns the key of the named lookup. Like index keys, lookup
+// keys never collide with session keys.
+func (s *RedisStore) lookupKey(key string) string {
+	return s.keyPrefix + "idx:" + key
+}
+
+// SetLookup maps key to id for ttl.
+func (s *RedisStore) SetLookup(ctx context.Context, key, id string, ttl time.Duration) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("lookup ttl must be > 0")
+	}
+	if err := s.client.Set(ctx, s.lookupKey(key), id, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set: %w", unavailable(err))
+	}
+	return nil
+}
+
+// GetLookup returns the ID mapped to key, or "" if there is none.
+func (s *RedisStore) GetLookup(ctx context.Context, key string) (string, error) {
+	if s.client == nil {
+		return "", fmt.Errorf("redis client is nil")
+	}
+	id, err := s.client.Get(ctx, s.lookupKey(key)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis get: %w", unavailable(err))
+	}
+	return id, nil
+}
+
+// deleteLookupScript deletes KEYS[1] if its value is ARGV[1].
+var deleteLookupScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DeleteLookup removes the mapping of key if it still maps to id, checking
+// and deleting in a single script.
+func (s *RedisStore) DeleteLookup(ctx context.Context, key, id string) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	if err := deleteLookupScript.Run(ctx, s.client, []string{s.lookupKey(key)}, id).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", unavailable(err))
+	}
+	return nil
+}
+
+// redisStoreUpdateAttempts is how many times RedisStore.Update tries again
+// when the session changes between its read and its write.
+const redisStoreUpdateAttempts = 10
+
+// Update replaces the data of the session with the result of fn, called with
+// the current data. The read and the write run under WATCH, so if another
+// client changes the session in between, the write is discarded and fn is
+// called again on the new data, up to a bounded number of attempts. fn
+// receives a fresh copy of the data on every attempt and must not have side
+// effects. If ttl is 0, the session keeps its expiration; CreatedAt is
+// always preserved. Returns ErrSessionNotFound if the session does not exist
+// or has expired, and errors from fn as is.
+func (s *RedisStore) Update(ctx context.Context, id string, fn func(data map[string]interface{}) (map[string]interface{}, error), ttl time.Duration) error {
+	if s.client == nil {
+		return fmt.Errorf("redis client is nil")
+	}
+	return s.modify(ctx, id, func(rec *KVSessionRecord, now time.Time) (time.Duration, error) {
+		data, err := fn(nonNilData(rec.Data))
+		if err != nil {
+			return 0, err
+		}
+		exp := ttl
+		if exp <= 0 {
+			exp = rec.ExpiresAt.Sub(now)
+		}
+		rec.Data = nonNilData(data)
+		rec.Revision++
+		rec.ExpiresAt = now.Add(exp)
+		return exp, nil
+	})
+}
+
+// modify reads the session under WATCH, changes it with fn and writes it
+// back with the key TTL returned by fn, trying again if the session changed
+// in between. Errors from fn are returned as is. Returns ErrSessionNotFound
+// if the session does not exist or has expired.
+func (s *RedisStore) modify(ctx context.Context, id string, fn func(rec *KVSessionRecord, now time.Time) (time.Duration, error)) error {
+	key := s.key(id)
+
+	txf := func(tx *redis.Tx) error {
+		body, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("redis get: %w", unavailable(err))
+		}
+		rec, err := s.unmarshal(id, body)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if now.After(rec.ExpiresAt) {
+			return ErrSessionNotFound
+		}
+
+		exp, err := fn(rec, now)
+		if err != nil {
+			return err
+		}
+		if body, err = s.encode(id, rec); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, body, exp)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("redis set: %w", unavailable(err))
+		}
+		return nil
+	}
+
+	for attempt := 0; attempt < redisStoreUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, txf, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("session update conflicted %d times: %w", redisStoreUpdateAttempts, redis.TxFailedErr)
+}
+
 // Delete removes the session for the given ID.
 func (s *RedisStore) Delete(ctx context.Context, id string) error {
 	if s.client == nil {
 		return fmt.Errorf("redis client is nil")
 	}
 	if err := s.client.Del(ctx, s.key(id)).Err(); err != nil {
-		return fmt.Errorf("redis del: %w", err)
+		return fmt.Errorf("redis del: %w", unavailable(err))
 	}
 	return nil
 }
@@ -117,7 +907,7 @@ func (s *RedisStore) Exists(ctx context.Context, id string) (bool, error) {
 	}
 	n, err := s.client.Exists(ctx, s.key(id)).Result()
 	if err != nil {
-		return false, fmt.Errorf("redis exists: %w", err)
+		return false, fmt.Errorf("redis exists: %w", unavailable(err))
 	}
 	return n > 0, nil
 }
@@ -1,6 +1,13 @@
 package session
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -62,6 +69,25 @@ func TestRedisStorageBasicOperations(t *testing.T) {
 	}
 }
 
+func TestRedisStoragePing(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Fatalf("expected ping to succeed, got %v", err)
+	}
+
+	mr.Close()
+	if err := storage.Ping(context.Background()); err == nil {
+		t.Error("expected ping to fail after the server stopped")
+	}
+
+	if err := (&RedisStorage{}).Ping(context.Background()); err == nil {
+		t.Error("expected error for nil client")
+	}
+}
+
 func TestRedisStorageGetNonExistent(t *testing.T) {
 	mr, client := setupMiniRedis(t)
 	defer mr.Close()
@@ -182,6 +208,9 @@ func TestRedisStorageClose(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to close: %v", err)
 	}
+	if err := storage.Close(); err != nil {
+		t.Errorf("expected a second Close to succeed, got %v", err)
+	}
 }
 
 func TestRedisStorageGetClient(t *testing.T) {
@@ -469,3 +498,942 @@ func TestRedisStorageSetNoExpiration(t *testing.T) {
 		}
 	}
 }
+
+func TestRedisStorageTouch(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	var _ ExtendedStorage = storage
+
+	ok, err := storage.Touch("missing", time.Hour)
+	if err != nil || ok {
+		t.Errorf("expected false, nil for missing key, got %v, %v", ok, err)
+	}
+
+	_ = storage.Set("key", []byte("value"), time.Minute)
+	ok, err = storage.Touch("key", time.Hour)
+	if err != nil || !ok {
+		t.Fatalf("expected true, nil for existing key, got %v, %v", ok, err)
+	}
+	if ttl := mr.TTL("test:key"); ttl != time.Hour {
+		t.Errorf("expected TTL 1h, got %v", ttl)
+	}
+
+	// Zero removes the expiry, including for keys that already have none
+	for i := 0; i < 2; i++ {
+		ok, err = storage.Touch("key", 0)
+		if err != nil || !ok {
+			t.Fatalf("expected true, nil, got %v, %v", ok, err)
+		}
+		if ttl := mr.TTL("test:key"); ttl != 0 {
+			t.Errorf("expected no TTL, got %v", ttl)
+		}
+	}
+	ok, _ = storage.Touch("missing", 0)
+	if ok {
+		t.Error("expected false for missing key with zero duration")
+	}
+}
+
+func TestRedisStorageDeleteMany(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	var _ BatchDeleter = storage
+
+	_ = storage.Set("key1", []byte("value"), time.Hour)
+	_ = storage.Set("key2", []byte("value"), time.Hour)
+	_ = storage.Set("key3", []byte("value"), time.Hour)
+
+	if err := storage.DeleteMany([]string{}); err != nil {
+		t.Fatalf("expected no error for empty input, got %v", err)
+	}
+	if err := storage.DeleteMany([]string{"key1", "key3", "missing"}); err != nil {
+		t.Fatalf("failed to delete many: %v", err)
+	}
+
+	if mr.Exists("test:key1") || mr.Exists("test:key3") {
+		t.Error("expected key1 and key3 to be deleted")
+	}
+	if !mr.Exists("test:key2") {
+		t.Error("expected key2 to survive")
+	}
+
+	mr.Close()
+	if err := storage.DeleteMany([]string{"key2"}); err == nil {
+		t.Error("expected error when redis is down")
+	}
+}
+
+func benchmarkRedisDelete(b *testing.B, batch bool) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "bench:")
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_ = storage.DeleteMany(keys)
+			continue
+		}
+		for _, key := range keys {
+			_ = storage.Delete(key)
+		}
+	}
+}
+
+func BenchmarkRedisStorageDeleteLoop(b *testing.B) {
+	benchmarkRedisDelete(b, false)
+}
+
+func BenchmarkRedisStorageDeleteMany(b *testing.B) {
+	benchmarkRedisDelete(b, true)
+}
+
+// setupBlackholeRedis returns a client pointed at a listener that accepts
+// connections but never answers, so every command hangs until its deadline.
+func setupBlackholeRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:                  ln.Addr().String(),
+		ContextTimeoutEnabled: true,
+		MaxRetries:            -1,
+	})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestRedisStorageOpTimeout(t *testing.T) {
+	client := setupBlackholeRedis(t)
+
+	storage := NewRedisStorage(client, "test:")
+	if storage.opTimeout != DefaultRedisOpTimeout {
+		t.Errorf("expected default timeout %v, got %v", DefaultRedisOpTimeout, storage.opTimeout)
+	}
+	storage.WithOpTimeout(50 * time.Millisecond)
+
+	ops := map[string]func() error{
+		"Get":    func() error { _, err := storage.Get("key"); return err },
+		"Set":    func() error { return storage.Set("key", []byte("value"), time.Hour) },
+		"Delete": func() error { return storage.Delete("key") },
+		"Reset":  storage.Reset,
+		"Exists": func() error { _, err := storage.Exists("key"); return err },
+		"GetTTL": func() error { _, err := storage.GetTTL("key"); return err },
+		"Expire": func() error { return storage.Expire("key", time.Hour) },
+	}
+	for name, op := range ops {
+		start := time.Now()
+		err := op()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected context.DeadlineExceeded, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected timeout to be honored, took %v", name, elapsed)
+		}
+	}
+}
+
+func TestRedisStorageCtxCancel(t *testing.T) {
+	client := setupBlackholeRedis(t)
+	storage := NewRedisStorage(client, "test:").WithOpTimeout(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := storage.GetCtx(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := storage.SetCtx(ctx, "key", []byte("value"), time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRedisStorageCtxMethods(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	ctx := context.Background()
+
+	if err := storage.SetCtx(ctx, "key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	got, err := storage.GetCtx(ctx, "key")
+	if err != nil || string(got) != "value" {
+		t.Fatalf("expected value, got %q (%v)", got, err)
+	}
+	if exists, _ := storage.ExistsCtx(ctx, "key"); !exists {
+		t.Error("expected key to exist")
+	}
+	if err := storage.ExpireCtx(ctx, "key", time.Minute); err != nil {
+		t.Fatalf("failed to expire: %v", err)
+	}
+	if ttl, _ := storage.GetTTLCtx(ctx, "key"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected TTL within a minute, got %v", ttl)
+	}
+	if err := storage.DeleteCtx(ctx, "key"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	_ = storage.SetCtx(ctx, "a", []byte("1"), 0)
+	if err := storage.ResetCtx(ctx); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if got, _ := storage.GetCtx(ctx, "a"); got != nil {
+		t.Error("expected reset to remove keys")
+	}
+}
+
+func TestRedisStorageUniversal(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+
+	var universal redis.UniversalClient = client
+	storage := NewRedisStorageUniversal(universal, "test:")
+	defer func() { _ = storage.Close() }()
+
+	if storage.GetClient() != client {
+		t.Error("expected GetClient to return the provided *redis.Client")
+	}
+	if storage.GetUniversalClient() != universal {
+		t.Error("expected GetUniversalClient to return the provided client")
+	}
+
+	_ = storage.Set("a", []byte("1"), time.Hour)
+	_ = storage.Set("b", []byte("2"), time.Hour)
+	if got, _ := storage.Get("a"); string(got) != "1" {
+		t.Errorf("expected 1, got %s", string(got))
+	}
+	if err := storage.DeleteMany([]string{"a", "b"}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	if _, err := NewRedisStorage(nil, "test:").Get("a"); err == nil {
+		t.Error("expected error for nil client")
+	}
+}
+
+func TestRedisStorageCluster(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	// miniredis answers CLUSTER SLOTS with a single node owning every slot
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{mr.Addr()},
+	})
+	storage := NewRedisStorageUniversal(cluster, "test:")
+	defer func() { _ = storage.Close() }()
+
+	if storage.GetClient() != nil {
+		t.Error("expected GetClient to be nil for a cluster client")
+	}
+	if storage.GetUniversalClient() != cluster {
+		t.Error("expected GetUniversalClient to return the cluster client")
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := storage.Set(fmt.Sprintf("key%d", i), []byte("value"), time.Hour); err != nil {
+			t.Fatalf("failed to set: %v", err)
+		}
+	}
+	if err := storage.DeleteMany([]string{"key0", "key1"}); err != nil {
+		t.Fatalf("failed to delete many: %v", err)
+	}
+	if exists, _ := storage.Exists("key0"); exists {
+		t.Error("expected key0 to be deleted")
+	}
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("expected all keys to be removed, got %v", keys)
+	}
+
+	if err := storage.NotifyExpired(func(string) {}); err == nil {
+		t.Error("expected expiry notifications to be rejected for cluster clients")
+	}
+}
+
+func TestRedisStorageWithHashTag(t *testing.T) {
+	tests := []struct {
+		prefix string
+		tags   []string
+		want   string
+	}{
+		{"session", []string{"tenant1"}, "session:{tenant1}:"},
+		{"session:{tenant1}:", nil, "session:{tenant1}:"},
+		{"session:{tenant1}:", []string{"tenant2"}, "session:{tenant2}:"},
+		{"session", []string{"tenant1", "tenant2"}, "session:{tenant2}:"},
+		{"session", []string{"{tenant1}"}, "session:{tenant1}:"},
+		{"session", []string{""}, "session:"},
+		{"session:{}:", []string{"tenant1"}, "session:{tenant1}:"},
+	}
+	for _, tt := range tests {
+		storage := NewRedisStorage(nil, tt.prefix)
+		for _, tag := range tt.tags {
+			storage.WithHashTag(tag)
+		}
+		if got := storage.GetKeyPrefix(); got != tt.want {
+			t.Errorf("prefix %q with tags %q: expected %q, got %q", tt.prefix, tt.tags, tt.want, got)
+		}
+	}
+
+	storage := NewRedisStorage(nil, "session").WithHashTag("tenant1")
+	key := storage.buildKey("abc")
+	if strings.Count(key, "{") != 1 || strings.Count(key, "}") != 1 {
+		t.Errorf("expected exactly one brace pair in %q", key)
+	}
+
+	// Braces in the session ID cannot move the key to another slot
+	start, end, _ := hashTagSpan(storage.buildKey("x{other}"))
+	if tag := storage.buildKey("x{other}")[start+1 : end]; tag != "tenant1" {
+		t.Errorf("expected the prefix tag to win, got %q", tag)
+	}
+}
+
+func TestRedisStorageHashTagCluster(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	storage := NewRedisStorageUniversal(redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{mr.Addr()},
+	}), "session:").WithHashTag("tenant1")
+	defer func() { _ = storage.Close() }()
+
+	if storage.routesByKey() {
+		t.Error("expected multi-key commands to be allowed with a hash tag")
+	}
+
+	_ = mr.Set("session:{tenant2}:a", "other tenant")
+	if err := storage.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Hour); err != nil {
+		t.Fatalf("failed to set multi: %v", err)
+	}
+	if !mr.Exists("session:{tenant1}:a") {
+		t.Fatalf("expected tagged key, got %v", mr.Keys())
+	}
+
+	got, err := storage.GetMulti([]string{"a", "b"})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("expected both keys, got %v (%v)", got, err)
+	}
+
+	// Reset's pattern matches the tagged keys and nothing else
+	n, err := storage.ResetWithCount()
+	if err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 keys removed, got %d", n)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "session:{tenant2}:a" {
+		t.Errorf("expected only the other tenant's key to remain, got %v", keys)
+	}
+}
+
+func TestRedisStorageResetWithCount(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+
+	const n = 3000
+	for i := 0; i < n; i++ {
+		_ = mr.Set(fmt.Sprintf("test:key%d", i), "value")
+	}
+	_ = mr.Set("other:key", "value")
+
+	removed, err := storage.ResetWithCount()
+	if err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if removed != n {
+		t.Errorf("expected %d keys removed, got %d", n, removed)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "other:key" {
+		t.Errorf("expected only other:key to remain, got %d keys", len(keys))
+	}
+
+	removed, err = storage.ResetWithCount()
+	if err != nil || removed != 0 {
+		t.Errorf("expected nothing to remove, got %d (%v)", removed, err)
+	}
+}
+
+// noUnlinkHook makes the client behave like a server without UNLINK.
+type noUnlinkHook struct {
+	unlinks atomic.Int64
+}
+
+func (h *noUnlinkHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *noUnlinkHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "unlink" {
+			h.unlinks.Add(1)
+			err := errors.New("ERR unknown command 'unlink'")
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *noUnlinkHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisStorageUnlinkFallback(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	hook := &noUnlinkHook{}
+	client.AddHook(hook)
+	storage := NewRedisStorage(client, "test:")
+
+	_ = storage.Set("a", []byte("1"), time.Hour)
+	_ = storage.Set("b", []byte("2"), time.Hour)
+
+	if err := storage.Delete("a"); err != nil {
+		t.Fatalf("expected fallback to DEL, got %v", err)
+	}
+	if mr.Exists("test:a") {
+		t.Error("expected key a to be deleted")
+	}
+
+	removed, err := storage.ResetWithCount()
+	if err != nil || removed != 1 {
+		t.Errorf("expected 1 key removed, got %d (%v)", removed, err)
+	}
+	if got := hook.unlinks.Load(); got != 1 {
+		t.Errorf("expected UNLINK to be tried once, got %d", got)
+	}
+}
+
+func TestRedisStorageGetSetMulti(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+
+	err := storage.SetMulti(map[string][]byte{
+		"a":     []byte("1"),
+		"b":     []byte("2"),
+		"empty": nil,
+	}, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to set multi: %v", err)
+	}
+	if mr.Exists("test:empty") {
+		t.Error("expected nil value to be skipped")
+	}
+	if ttl := mr.TTL("test:a"); ttl != time.Hour {
+		t.Errorf("expected shared TTL of 1h, got %v", ttl)
+	}
+
+	got, err := storage.GetMulti([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("failed to get multi: %v", err)
+	}
+	if len(got) != 2 || string(got["a"]) != "1" || string(got["b"]) != "2" {
+		t.Errorf("unexpected result: %v", got)
+	}
+	if _, ok := got["missing"]; ok {
+		t.Error("expected missing key to be absent")
+	}
+
+	if got, err := storage.GetMulti(nil); err != nil || len(got) != 0 {
+		t.Errorf("expected empty result, got %v (%v)", got, err)
+	}
+	if err := storage.SetMulti(nil, time.Hour); err != nil {
+		t.Errorf("expected empty set to be a no-op, got %v", err)
+	}
+}
+
+func TestRedisStorageExistsMulti(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	_ = storage.Set("present", []byte("value"), time.Hour)
+	_ = storage.Set("expiring", []byte("value"), time.Second)
+	_ = mr.Set("present-unprefixed", "value")
+	mr.FastForward(2 * time.Second)
+
+	got, err := storage.ExistsMulti([]string{"present", "expiring", "absent", "present-unprefixed"})
+	if err != nil {
+		t.Fatalf("failed to check existence: %v", err)
+	}
+	want := map[string]bool{"present": true, "expiring": false, "absent": false, "present-unprefixed": false}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for key, exists := range want {
+		if got[key] != exists {
+			t.Errorf("%s: expected %v, got %v", key, exists, got[key])
+		}
+	}
+
+	if got, err := storage.ExistsMulti(nil); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("expected empty map, got %v (%v)", got, err)
+	}
+
+	mr.Close()
+	if _, err := storage.ExistsMulti([]string{"present"}); err == nil {
+		t.Error("expected error after the server stopped")
+	}
+}
+
+func TestRedisStorageGetWithTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	_ = storage.Set("expiring", []byte("value"), time.Hour)
+	_ = storage.Set("persistent", []byte("value"), 0)
+
+	data, ttl, err := storage.GetWithTTL("expiring")
+	if err != nil || string(data) != "value" {
+		t.Fatalf("expected value, got %q (%v)", data, err)
+	}
+	if ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("expected TTL of about 1h, got %v", ttl)
+	}
+
+	data, ttl, err = storage.GetWithTTL("persistent")
+	if err != nil || string(data) != "value" {
+		t.Fatalf("expected value, got %q (%v)", data, err)
+	}
+	if ttl != -1 {
+		t.Errorf("expected TTL -1 for a key without expiration, got %v", ttl)
+	}
+
+	data, ttl, err = storage.GetWithTTL("missing")
+	if data != nil || ttl != 0 || err != nil {
+		t.Errorf("expected nil, 0, nil for a missing key, got %v, %v, %v", data, ttl, err)
+	}
+}
+
+func TestRedisStorageDeleteByPattern(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	_ = mr.Set("other:tenant:1:a", "value")
+	testDeleteByPattern(t, NewRedisStorage(client, "test:"))
+	if !mr.Exists("other:tenant:1:a") {
+		t.Error("expected keys outside the prefix to survive")
+	}
+}
+
+func TestRedisStorageResetEscapesPrefix(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "app[1]:")
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = mr.Set("app1:key", "value")
+
+	if n, err := storage.ResetWithCount(); err != nil || n != 1 {
+		t.Errorf("expected 1 key removed, got %d (%v)", n, err)
+	}
+	if !mr.Exists("app1:key") {
+		t.Error("expected the prefix to match literally")
+	}
+}
+
+func TestRedisStorageGetMultiCluster(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	storage := NewRedisStorageUniversal(redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{mr.Addr()},
+	}), "test:")
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.SetMulti(map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Hour)
+
+	got, err := storage.GetMulti([]string{"a", "missing", "b"})
+	if err != nil {
+		t.Fatalf("failed to get multi: %v", err)
+	}
+	if len(got) != 2 || string(got["a"]) != "1" || string(got["b"]) != "2" {
+		t.Errorf("unexpected result: %v", got)
+	}
+}
+
+func benchmarkRedisMulti(b *testing.B, batch bool, write bool) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "bench:")
+	keys := make([]string, 50)
+	items := make(map[string][]byte, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		items[keys[i]] = []byte("value")
+	}
+	_ = storage.SetMulti(items, time.Hour)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		switch {
+		case batch && write:
+			_ = storage.SetMulti(items, time.Hour)
+		case batch:
+			_, _ = storage.GetMulti(keys)
+		case write:
+			for key, val := range items {
+				_ = storage.Set(key, val, time.Hour)
+			}
+		default:
+			for _, key := range keys {
+				_, _ = storage.Get(key)
+			}
+		}
+	}
+}
+
+func BenchmarkRedisStorageGetLoop(b *testing.B) {
+	benchmarkRedisMulti(b, false, false)
+}
+
+func BenchmarkRedisStorageGetMulti(b *testing.B) {
+	benchmarkRedisMulti(b, true, false)
+}
+
+func BenchmarkRedisStorageSetLoop(b *testing.B) {
+	benchmarkRedisMulti(b, false, true)
+}
+
+func BenchmarkRedisStorageSetMulti(b *testing.B) {
+	benchmarkRedisMulti(b, true, true)
+}
+
+func TestRedisStorageSetKeepTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+
+	_ = storage.Set("key", []byte("v1"), time.Hour)
+	if err := storage.SetKeepTTL("key", []byte("v2")); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if got, _ := storage.Get("key"); string(got) != "v2" {
+		t.Errorf("expected v2, got %s", string(got))
+	}
+	if ttl := mr.TTL("test:key"); ttl != time.Hour {
+		t.Errorf("expected TTL to survive the update, got %v", ttl)
+	}
+
+	// Keys without an existing TTL are stored without one
+	if err := storage.SetKeepTTL("new", []byte("value")); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if ttl := mr.TTL("test:new"); ttl != 0 {
+		t.Errorf("expected no TTL for a new key, got %v", ttl)
+	}
+
+	if err := storage.SetKeepTTL("", []byte("value")); err != nil {
+		t.Errorf("expected empty key to be ignored, got %v", err)
+	}
+}
+
+func TestRedisStorageSetIfAbsent(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	if stored, _ := storage.SetIfAbsent("key", []byte("v1"), time.Hour); !stored {
+		t.Fatal("expected a missing key to be stored")
+	}
+	if stored, _ := storage.SetIfAbsent("key", []byte("v2"), time.Hour); stored {
+		t.Error("expected an existing key to be kept")
+	}
+	if got, _ := storage.Get("key"); string(got) != "v1" {
+		t.Errorf("expected v1, got %s", got)
+	}
+	if ttl := mr.TTL("test:key"); ttl != time.Hour {
+		t.Errorf("expected a TTL of 1h, got %v", ttl)
+	}
+}
+
+func TestRedisStorageGetAndRefresh(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+
+	_ = storage.Set("key", []byte("value"), time.Minute)
+	got, err := storage.GetAndRefresh("key", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to get and refresh: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("expected value, got %s", string(got))
+	}
+	if ttl := mr.TTL("test:key"); ttl != time.Hour {
+		t.Errorf("expected TTL of 1h, got %v", ttl)
+	}
+
+	// A flushed script cache is reloaded transparently
+	mr.FlushAll()
+	_ = storage.Set("key", []byte("value"), time.Minute)
+	_ = client.ScriptFlush(context.Background()).Err()
+	if got, err := storage.GetAndRefresh("key", 0); err != nil || string(got) != "value" {
+		t.Fatalf("expected value after script flush, got %q (%v)", got, err)
+	}
+	if ttl := mr.TTL("test:key"); ttl != 0 {
+		t.Errorf("expected expiration to be removed, got %v", ttl)
+	}
+
+	got, err = storage.GetAndRefresh("missing", time.Hour)
+	if err != nil || got != nil {
+		t.Errorf("expected nil, nil for missing key, got %q (%v)", got, err)
+	}
+	if mr.Exists("test:missing") {
+		t.Error("expected missing key to not be created")
+	}
+}
+
+func TestNewRedisStorageFromURL(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	mr.RequireUserAuth("app", "s3cret")
+
+	storage, err := NewRedisStorageFromURL("redis://app:s3cret@"+mr.Addr()+"/3?dial_timeout=2s", "test:")
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	opts := storage.GetClient().Options()
+	if opts.Username != "app" || opts.Password != "s3cret" {
+		t.Errorf("expected credentials from URL, got %q/%q", opts.Username, opts.Password)
+	}
+	if opts.DB != 3 {
+		t.Errorf("expected db 3, got %d", opts.DB)
+	}
+	if opts.DialTimeout != 2*time.Second {
+		t.Errorf("expected dial timeout 2s, got %v", opts.DialTimeout)
+	}
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if got, _ := mr.DB(3).Get("test:key"); got != "value" {
+		t.Errorf("expected key in db 3, got %q", got)
+	}
+
+	if _, err := NewRedisStorageFromURL("redis://app:wrong@"+mr.Addr()+"/0", "test:"); err == nil {
+		t.Error("expected error for wrong password")
+	}
+}
+
+func TestNewRedisStorageFromURLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"wrong scheme", "http://localhost:6379"},
+		{"bad db", "redis://localhost:6379/abc"},
+		{"bad option", "redis://localhost:6379/0?dial_timeout=soon"},
+		{"malformed", "redis://:secret@local host:6379"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRedisStorageFromURL(tt.url, "test:")
+			if err == nil || !strings.Contains(err.Error(), "invalid redis URL") {
+				t.Fatalf("expected invalid URL error, got %v", err)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("expected password to be kept out of the error: %v", err)
+			}
+		})
+	}
+}
+
+// latencyRecorder collects the operations reported to a latency observer.
+type latencyRecorder struct {
+	mu   sync.Mutex
+	ops  []string
+	errs []error
+}
+
+func (r *latencyRecorder) observe(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = append(r.ops, op)
+	r.errs = append(r.errs, err)
+}
+
+func TestRedisStorageLatencyObserver(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer func() { _ = client.Close() }()
+
+	rec := &latencyRecorder{}
+	storage := NewRedisStorage(client, "test:").WithLatencyObserver(rec.observe)
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Set("", []byte("value"), time.Hour) // Not sent, not reported
+	_, _ = storage.Get("key")
+	_ = storage.Delete("key")
+	_ = storage.Reset()
+
+	want := []string{"set", "get", "delete", "reset"}
+	if strings.Join(rec.ops, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected ops %v, got %v", want, rec.ops)
+	}
+	for i, err := range rec.errs {
+		if err != nil {
+			t.Errorf("expected %s to succeed, got %v", rec.ops[i], err)
+		}
+	}
+
+	mr.Close()
+	_, getErr := storage.Get("key")
+	if last := rec.errs[len(rec.errs)-1]; last == nil || last != getErr {
+		t.Errorf("expected the observer to see the returned error, got %v", last)
+	}
+
+	// A nil observer is allowed
+	storage.WithLatencyObserver(nil)
+	_, _ = storage.Get("key")
+	if len(rec.ops) != 5 {
+		t.Errorf("expected no more observations, got %v", rec.ops)
+	}
+}
+
+func TestRedisStoragePoolStats(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	stats := storage.PoolStats()
+	if stats == nil || stats.TotalConns == 0 {
+		t.Errorf("expected pool stats with open connections, got %+v", stats)
+	}
+	if NewRedisStorage(nil, "test:").PoolStats() != nil {
+		t.Error("expected nil stats without a client")
+	}
+}
+
+func benchmarkRedisObserver(b *testing.B, observer func(string, time.Duration, error)) {
+	storage := NewRedisStorage(nil, "bench:").WithLatencyObserver(observer)
+	op := func() (err error) {
+		defer storage.observe("get", storage.opStart(), &err)
+		return nil
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = op()
+	}
+}
+
+func BenchmarkRedisStorageObserverUnset(b *testing.B) {
+	benchmarkRedisObserver(b, nil)
+}
+
+func BenchmarkRedisStorageObserverSet(b *testing.B) {
+	benchmarkRedisObserver(b, func(string, time.Duration, error) {})
+}
+
+func benchmarkRedisExists(b *testing.B, batch bool) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "bench:")
+	keys := make([]string, 50)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		if i%2 == 0 {
+			_ = storage.Set(keys[i], []byte("value"), time.Hour)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_, _ = storage.ExistsMulti(keys)
+			continue
+		}
+		for _, key := range keys {
+			_, _ = storage.Exists(key)
+		}
+	}
+}
+
+func BenchmarkRedisStorageExistsLoop(b *testing.B) {
+	benchmarkRedisExists(b, false)
+}
+
+func BenchmarkRedisStorageExistsMulti(b *testing.B) {
+	benchmarkRedisExists(b, true)
+}
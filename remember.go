@@ -0,0 +1,241 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Storage keys of remember-me tokens, under the storage's key prefix.
+// Redeemed tokens are marked under rememberUsedKeyPrefix until they expire.
+const (
+	rememberKeyPrefix     = "remember:"
+	rememberUsedKeyPrefix = "remember_used:"
+	rememberUserKeyPrefix = "remember_user:"
+)
+
+// rememberRecord is the stored form of a remember-me token. Only a hash of
+// the validator is kept, so a leaked storage cannot be used to log in.
+type rememberRecord struct {
+	UserID        string    `json:"user_id"`
+	ValidatorHash []byte    `json:"validator_hash"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// rememberSetter returns the storage of manager as an AbsentSetter, which
+// remember-me tokens require to be redeemed once.
+func rememberSetter(manager *Manager) (AbsentSetter, error) {
+	setter, ok := manager.storage.(AbsentSetter)
+	if !ok {
+		return nil, fmt.Errorf("%T does not support remember-me tokens", manager.storage)
+	}
+	return setter, nil
+}
+
+// IssueRememberToken issues a remember-me token logging userID in for ttl,
+// such as 30 days, independently of the session expiration. The token is a
+// random selector, under which it is stored, and a random validator, of
+// which only a hash is stored. The returned cookie holds both, is named
+// Config.RememberCookieName and otherwise has the attributes of the session
+// cookie; read it back with SplitRememberToken. The storage must implement
+// AbsentSetter.
+func IssueRememberToken(manager *Manager, userID string, ttl time.Duration) (selector, validator string, cookie *fiber.Cookie, err error) {
+	if userID == "" {
+		return "", "", nil, fmt.Errorf("user id cannot be empty")
+	}
+	if ttl <= 0 {
+		return "", "", nil, fmt.Errorf("remember-me ttl must be > 0")
+	}
+	if _, err := rememberSetter(manager); err != nil {
+		return "", "", nil, err
+	}
+
+	if selector, err = randomToken(16); err != nil {
+		return "", "", nil, fmt.Errorf("failed to generate remember-me selector: %w", err)
+	}
+	if validator, err = randomToken(32); err != nil {
+		return "", "", nil, fmt.Errorf("failed to generate remember-me validator: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(validator))
+	rec := rememberRecord{
+		UserID:        userID,
+		ValidatorHash: hash[:],
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to marshal remember-me token: %w", err)
+	}
+	if err := manager.set(context.Background(), rememberKeyPrefix+selector, data, ttl); err != nil {
+		return "", "", nil, fmt.Errorf("failed to store remember-me token: %w", err)
+	}
+	if err := addRememberSelector(manager, userID, selector, ttl); err != nil {
+		return "", "", nil, err
+	}
+
+	config := manager.GetConfig()
+	if config.RememberCookieName == "" {
+		config.RememberCookieName = DefaultConfig().RememberCookieName
+	}
+	// The validator already authenticates the token, so it is not signed
+	config.CookieSigningKey = nil
+	config.SessionOnlyCookie = false
+	cookie = CreateCookie(config.WithCookieName(config.RememberCookieName).WithExpiration(ttl), selector+"."+validator)
+	cookie.Expires = rec.ExpiresAt
+	return selector, validator, cookie, nil
+}
+
+// SplitRememberToken splits the value of a cookie issued by
+// IssueRememberToken into its selector and validator.
+func SplitRememberToken(value string) (selector, validator string, ok bool) {
+	selector, validator, ok = strings.Cut(value, ".")
+	return selector, validator, ok && selector != "" && validator != ""
+}
+
+// RedeemRememberToken returns the user a remember-me token was issued for,
+// comparing validators in constant time. Tokens are single-use: the caller
+// starts a new session for the user and issues a new token with
+// IssueRememberToken. It returns ErrRememberTokenInvalid if the token does
+// not exist, has expired or does not match, and ErrRememberTokenReused,
+// after revoking every token of the user, if it was already redeemed.
+//
+// Tokens are marked as redeemed with AbsentSetter.SetIfAbsent, so of
+// concurrent redemptions of a token only one succeeds. Storages that do not
+// implement AbsentSetter are rejected with an error.
+func RedeemRememberToken(manager *Manager, selector, validator string) (string, error) {
+	setter, err := rememberSetter(manager)
+	if err != nil {
+		return "", err
+	}
+	key := rememberKeyPrefix + selector
+	data, err := manager.get(context.Background(), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remember-me token: %w", err)
+	}
+	if data == nil {
+		return "", ErrRememberTokenInvalid
+	}
+	var rec rememberRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return "", fmt.Errorf("failed to unmarshal remember-me token: %w", err)
+	}
+
+	ttl := time.Until(rec.ExpiresAt)
+	if ttl <= 0 {
+		_ = manager.del(context.Background(), key)
+		return "", ErrRememberTokenInvalid
+	}
+	hash := sha256.Sum256([]byte(validator))
+	if subtle.ConstantTimeCompare(hash[:], rec.ValidatorHash) != 1 {
+		return "", ErrRememberTokenInvalid
+	}
+
+	// Keep the token until it expires, marked as redeemed, to detect its reuse
+	redeemed, err := bounded(context.Background(), manager, func() (bool, error) {
+		return setter.SetIfAbsent(rememberUsedKeyPrefix+selector, []byte(rec.UserID), ttl)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store remember-me token: %w", err)
+	}
+	if !redeemed {
+		if err := RevokeRememberTokens(manager, rec.UserID); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrRememberTokenReused, err)
+		}
+		return "", ErrRememberTokenReused
+	}
+	return rec.UserID, nil
+}
+
+// RevokeRememberTokens deletes every remember-me token of userID, such as
+// when the user changes their password or signs out everywhere.
+func RevokeRememberTokens(manager *Manager, userID string) error {
+	manager.userMu.Lock()
+	defer manager.userMu.Unlock()
+
+	selectors, err := rememberSelectors(manager, userID)
+	if err != nil {
+		return err
+	}
+	for _, selector := range selectors {
+		if err := manager.del(context.Background(), rememberKeyPrefix+selector); err != nil {
+			return fmt.Errorf("failed to delete remember-me token: %w", err)
+		}
+		if err := manager.del(context.Background(), rememberUsedKeyPrefix+selector); err != nil {
+			return fmt.Errorf("failed to delete remember-me token: %w", err)
+		}
+	}
+	if err := manager.del(context.Background(), rememberUserKeyPrefix+userID); err != nil {
+		return fmt.Errorf("failed to delete remember-me tokens index: %w", err)
+	}
+	return nil
+}
+
+// rememberSelectors returns the selectors of the tokens issued for userID.
+func rememberSelectors(manager *Manager, userID string) ([]string, error) {
+	data, err := manager.get(context.Background(), rememberUserKeyPrefix+userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remember-me tokens index: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var selectors []string
+	if err := json.Unmarshal(data, &selectors); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal remember-me tokens index: %w", err)
+	}
+	return selectors, nil
+}
+
+// addRememberSelector adds selector to the tokens of userID, dropping the
+// ones that have expired. The index lives as long as the longest-lived token.
+// Index updates are serialized within the Manager, as in SaveSession.
+func addRememberSelector(manager *Manager, userID, selector string, ttl time.Duration) error {
+	manager.userMu.Lock()
+	defer manager.userMu.Unlock()
+
+	selectors, err := rememberSelectors(manager, userID)
+	if err != nil {
+		return err
+	}
+	live := selectors[:0]
+	for _, s := range selectors {
+		data, err := manager.get(context.Background(), rememberKeyPrefix+s)
+		if err != nil {
+			return fmt.Errorf("failed to get remember-me token: %w", err)
+		}
+		var rec rememberRecord
+		if data == nil || json.Unmarshal(data, &rec) != nil {
+			continue
+		}
+		if remaining := time.Until(rec.ExpiresAt); remaining > 0 {
+			live = append(live, s)
+			ttl = max(ttl, remaining)
+		}
+	}
+	data, err := json.Marshal(append(live, selector))
+	if err != nil {
+		return fmt.Errorf("failed to marshal remember-me tokens index: %w", err)
+	}
+	if err := manager.set(context.Background(), rememberUserKeyPrefix+userID, data, ttl); err != nil {
+		return fmt.Errorf("failed to store remember-me tokens index: %w", err)
+	}
+	return nil
+}
+
+// randomToken returns size random bytes in unpadded URL-safe base64.
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,171 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRememberManager(t *testing.T) *Manager {
+	t.Helper()
+	storage := NewMemoryStorage("test:", 0)
+	t.Cleanup(func() { _ = storage.Close() })
+	return NewManager(storage, DefaultConfig().WithRememberCookieName("keep"))
+}
+
+func TestRememberToken(t *testing.T) {
+	manager := newRememberManager(t)
+
+	selector, validator, cookie, err := IssueRememberToken(manager, "user-1", 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if cookie.Name != "keep" || !cookie.Secure || !cookie.HTTPOnly || cookie.Path != "/" {
+		t.Errorf("expected the session cookie attributes, got %+v", cookie)
+	}
+	if time.Until(cookie.Expires) < 29*24*time.Hour {
+		t.Errorf("expected the cookie to expire with the token, got %v", cookie.Expires)
+	}
+	gotSelector, gotValidator, ok := SplitRememberToken(cookie.Value)
+	if !ok || gotSelector != selector || gotValidator != validator {
+		t.Fatalf("expected the cookie to hold the token, got %q", cookie.Value)
+	}
+	if data, _ := manager.GetStorage().Get(rememberKeyPrefix + selector); data == nil || strings.Contains(string(data), validator) {
+		t.Errorf("expected only a hash of the validator to be stored, got %s", data)
+	}
+
+	if _, err := RedeemRememberToken(manager, selector, validator+"x"); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected a wrong validator to be rejected, got %v", err)
+	}
+	if _, err := RedeemRememberToken(manager, "unknown", validator); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected an unknown selector to be rejected, got %v", err)
+	}
+
+	userID, err := RedeemRememberToken(manager, selector, validator)
+	if err != nil || userID != "user-1" {
+		t.Fatalf("expected user-1, got %q, %v", userID, err)
+	}
+
+	for _, value := range []string{"", "selector", ".validator", "selector."} {
+		if _, _, ok := SplitRememberToken(value); ok {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestRememberTokenReuse(t *testing.T) {
+	manager := newRememberManager(t)
+
+	selector, validator, _, err := IssueRememberToken(manager, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	otherSelector, otherValidator, _, err := IssueRememberToken(manager, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	thirdSelector, thirdValidator, _, err := IssueRememberToken(manager, "user-2", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	if _, err := RedeemRememberToken(manager, selector, validator); err != nil {
+		t.Fatalf("failed to redeem token: %v", err)
+	}
+	if _, err := RedeemRememberToken(manager, selector, validator); !errors.Is(err, ErrRememberTokenReused) {
+		t.Fatalf("expected the reuse to be detected, got %v", err)
+	}
+	if _, err := RedeemRememberToken(manager, otherSelector, otherValidator); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected the other tokens of the user to be revoked, got %v", err)
+	}
+	if userID, err := RedeemRememberToken(manager, thirdSelector, thirdValidator); err != nil || userID != "user-2" {
+		t.Errorf("expected the tokens of other users to be kept, got %q, %v", userID, err)
+	}
+}
+
+func TestRememberTokenConcurrentRedeem(t *testing.T) {
+	manager := newRememberManager(t)
+
+	selector, validator, _, err := IssueRememberToken(manager, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	const n = 20
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			_, err := RedeemRememberToken(manager, selector, validator)
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	redeemed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, ErrRememberTokenReused) && !errors.Is(err, ErrRememberTokenInvalid):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("expected the token to be redeemed once, got %d", redeemed)
+	}
+}
+
+func TestRememberTokenRequiresAbsentSetter(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(NewInstrumentedStorage(storage, func(string, time.Duration, error) {}), DefaultConfig())
+
+	if _, _, _, err := IssueRememberToken(manager, "user-1", time.Hour); err == nil {
+		t.Error("expected issuing without AbsentSetter to fail")
+	}
+	if _, err := RedeemRememberToken(manager, "selector", "validator"); err == nil || errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected redeeming without AbsentSetter to fail, got %v", err)
+	}
+}
+
+func TestRememberTokenExpiry(t *testing.T) {
+	manager := newRememberManager(t)
+
+	selector, validator, _, err := IssueRememberToken(manager, "user-1", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := RedeemRememberToken(manager, selector, validator); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected the expired token to be rejected, got %v", err)
+	}
+}
+
+func TestRevokeRememberTokens(t *testing.T) {
+	manager := newRememberManager(t)
+
+	if _, _, _, err := IssueRememberToken(manager, "", time.Hour); err == nil {
+		t.Error("expected an empty user id to be rejected")
+	}
+	if _, _, _, err := IssueRememberToken(manager, "user-1", 0); err == nil {
+		t.Error("expected a zero ttl to be rejected")
+	}
+
+	selector, validator, _, err := IssueRememberToken(manager, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	if err := RevokeRememberTokens(manager, "user-1"); err != nil {
+		t.Fatalf("failed to revoke tokens: %v", err)
+	}
+	if _, err := RedeemRememberToken(manager, selector, validator); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("expected the revoked token to be rejected, got %v", err)
+	}
+	if err := RevokeRememberTokens(manager, "user-1"); err != nil {
+		t.Errorf("expected revoking without tokens to succeed, got %v", err)
+	}
+}
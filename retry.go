@@ -0,0 +1,258 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryOptions configures RetryStorage.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of attempts per operation,
+	// including the first one.
+	// Default: 3
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. It doubles
+	// after every failed attempt.
+	// Default: 50 milliseconds
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.
+	// Default: 1 second
+	MaxBackoff time.Duration
+
+	// Jitter randomizes each delay by up to ±Jitter (a fraction of the delay)
+	// so clients recovering from the same failover don't retry in lockstep.
+	// Default: 0.2
+	Jitter float64
+
+	// MaxElapsed bounds the total time spent on one operation, including
+	// all attempts and delays. No retry is started past this deadline.
+	// Set to 0 for no overall deadline.
+	// Default: 5 seconds
+	MaxElapsed time.Duration
+
+	// IsRetryable reports whether an error is transient. If nil,
+	// IsTransientError is used.
+	IsRetryable func(err error) bool
+}
+
+// DefaultRetryOptions returns RetryOptions with default values.
+func DefaultRetryOptions() RetryOptions {
+	return RetryOptions{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Jitter:         0.2,
+		MaxElapsed:     5 * time.Second,
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts per operation.
+func (o RetryOptions) WithMaxAttempts(n int) RetryOptions {
+	o.MaxAttempts = n
+	return o
+}
+
+// WithInitialBackoff sets the delay before the first retry.
+func (o RetryOptions) WithInitialBackoff(d time.Duration) RetryOptions {
+	o.InitialBackoff = d
+	return o
+}
+
+// WithMaxBackoff sets the maximum delay between attempts.
+func (o RetryOptions) WithMaxBackoff(d time.Duration) RetryOptions {
+	o.MaxBackoff = d
+	return o
+}
+
+// WithJitter sets the backoff jitter fraction.
+func (o RetryOptions) WithJitter(jitter float64) RetryOptions {
+	o.Jitter = jitter
+	return o
+}
+
+// WithMaxElapsed sets the overall deadline for one operation.
+func (o RetryOptions) WithMaxElapsed(d time.Duration) RetryOptions {
+	o.MaxElapsed = d
+	return o
+}
+
+// WithIsRetryable sets the function that classifies errors as transient.
+func (o RetryOptions) WithIsRetryable(fn func(err error) bool) RetryOptions {
+	o.IsRetryable = fn
+	return o
+}
+
+// RetryStorage wraps a Storage and retries Get, Set and Delete on transient
+// errors with exponential backoff and jitter, so brief outages such as a
+// Redis failover don't surface as request failures. Reset and Close are
+// passed through unchanged.
+type RetryStorage struct {
+	inner Storage
+	opts  RetryOptions
+	sleep func(time.Duration)
+}
+
+// NewRetryStorage wraps inner with retries. Zero fields in opts fall back to
+// the values of DefaultRetryOptions, except MaxElapsed and Jitter where zero
+// disables the deadline and the jitter.
+func NewRetryStorage(inner Storage, opts RetryOptions) Storage {
+	defaults := DefaultRetryOptions()
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = defaults.InitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaults.MaxBackoff
+	}
+	if opts.IsRetryable == nil {
+		opts.IsRetryable = IsTransientError
+	}
+
+	return &RetryStorage{
+		inner: inner,
+		opts:  opts,
+		sleep: time.Sleep,
+	}
+}
+
+// IsTransientError reports whether err is likely to go away on its own:
+// network errors such as refused or reset connections, timeouts, and Redis
+// replies sent while a server is loading its dataset, read-only after a
+// failover, or otherwise temporarily unavailable. Cancellation and closed
+// storages are never transient.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrClosed) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		msg := redisErr.Error()
+		for _, prefix := range []string{"LOADING ", "READONLY ", "MASTERDOWN ", "TRYAGAIN ", "CLUSTERDOWN "} {
+			if strings.HasPrefix(msg, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// do runs op until it succeeds, fails with a non-transient error, runs out of
+// attempts or would exceed the overall deadline.
+func (s *RetryStorage) do(name string, op func() error) error {
+	start := time.Now()
+	backoff := s.opts.InitialBackoff
+
+	var err error
+	attempt := 0
+	for attempt < s.opts.MaxAttempts {
+		attempt++
+		if err = op(); err == nil {
+			return nil
+		}
+		if !s.opts.IsRetryable(err) || attempt == s.opts.MaxAttempts {
+			break
+		}
+
+		delay := s.jitter(backoff)
+		if s.opts.MaxElapsed > 0 && time.Since(start)+delay > s.opts.MaxElapsed {
+			break
+		}
+		s.sleep(delay)
+
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+	}
+
+	if attempt == 1 {
+		return err
+	}
+	return fmt.Errorf("%s failed after %d attempts: %w", name, attempt, err)
+}
+
+// jitter applies the configured jitter to d.
+func (s *RetryStorage) jitter(d time.Duration) time.Duration {
+	if s.opts.Jitter <= 0 {
+		return d
+	}
+	spread := float64(d) * s.opts.Jitter
+	delay := time.Duration(float64(d) + (rand.Float64()*2-1)*spread)
+	if delay <= 0 {
+		delay = d
+	}
+	return delay
+}
+
+// Get retrieves the value for the given key, retrying on transient errors.
+func (s *RetryStorage) Get(key string) ([]byte, error) {
+	var data []byte
+	err := s.do("get", func() error {
+		var err error
+		data, err = s.inner.Get(key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Set stores the given value for the given key, retrying on transient errors.
+func (s *RetryStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.do("set", func() error {
+		return s.inner.Set(key, val, exp)
+	})
+}
+
+// Delete removes the value for the given key, retrying on transient errors.
+func (s *RetryStorage) Delete(key string) error {
+	return s.do("delete", func() error {
+		return s.inner.Delete(key)
+	})
+}
+
+// Reset removes all keys from the wrapped storage. It is not retried.
+func (s *RetryStorage) Reset() error {
+	return s.inner.Reset()
+}
+
+// Ping pings the wrapped storage if it implements Pinger. It is not
+// retried, so a health check reports the backend's current state.
+func (s *RetryStorage) Ping(ctx context.Context) error {
+	return pingStorage(ctx, s.inner)
+}
+
+// Close closes the wrapped storage.
+func (s *RetryStorage) Close() error {
+	return s.inner.Close()
+}
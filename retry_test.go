@@ -0,0 +1,216 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// scriptedStorage fails the first failures calls of every operation with err.
+type scriptedStorage struct {
+	Storage
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *scriptedStorage) fail() error {
+	s.calls++
+	if s.calls <= s.failures {
+		return s.err
+	}
+	return nil
+}
+
+func (s *scriptedStorage) Get(key string) ([]byte, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.Storage.Get(key)
+}
+
+func (s *scriptedStorage) Set(key string, val []byte, exp time.Duration) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Storage.Set(key, val, exp)
+}
+
+func (s *scriptedStorage) Delete(key string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.Storage.Delete(key)
+}
+
+// fakeRedisError mimics an error reply from the Redis server.
+type fakeRedisError string
+
+func (e fakeRedisError) Error() string { return string(e) }
+
+func (fakeRedisError) RedisError() {}
+
+func newTestRetryStorage(inner Storage, opts RetryOptions) (*RetryStorage, *[]time.Duration) {
+	var delays []time.Duration
+	s := NewRetryStorage(inner, opts).(*RetryStorage)
+	s.sleep = func(d time.Duration) { delays = append(delays, d) }
+	return s, &delays
+}
+
+func TestRetryStorageSucceedsOnAttemptN(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+	_ = inner.Set("key", []byte("value"), time.Hour)
+
+	scripted := &scriptedStorage{Storage: inner, failures: 2, err: syscall.ECONNREFUSED}
+	opts := DefaultRetryOptions().WithJitter(0).WithMaxAttempts(3)
+	storage, delays := newTestRetryStorage(scripted, opts)
+
+	got, err := storage.Get("key")
+	if err != nil {
+		t.Fatalf("expected success on third attempt, got %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("expected value, got %s", string(got))
+	}
+	if scripted.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", scripted.calls)
+	}
+
+	// Backoff doubles between attempts
+	if len(*delays) != 2 || (*delays)[0] != 50*time.Millisecond || (*delays)[1] != 100*time.Millisecond {
+		t.Errorf("expected delays [50ms 100ms], got %v", *delays)
+	}
+}
+
+func TestRetryStorageAlwaysFails(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	scripted := &scriptedStorage{Storage: inner, failures: 100, err: fakeRedisError("LOADING Redis is loading the dataset in memory")}
+	storage, _ := newTestRetryStorage(scripted, DefaultRetryOptions().WithMaxAttempts(4))
+
+	err := storage.Set("key", []byte("value"), time.Hour)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Errorf("expected attempt count in error, got %v", err)
+	}
+	var redisErr fakeRedisError
+	if !errors.As(err, &redisErr) {
+		t.Errorf("expected the last error to be wrapped, got %v", err)
+	}
+	if scripted.calls != 4 {
+		t.Errorf("expected 4 calls, got %d", scripted.calls)
+	}
+}
+
+func TestRetryStorageNoRetryOnPermanentError(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	for _, permanent := range []error{
+		errors.New("WRONGTYPE"),
+		fakeRedisError("WRONGTYPE Operation against a key holding the wrong kind of value"),
+		context.Canceled,
+		ErrClosed,
+	} {
+		scripted := &scriptedStorage{Storage: inner, failures: 1, err: permanent}
+		storage, delays := newTestRetryStorage(scripted, DefaultRetryOptions())
+
+		err := storage.Delete("key")
+		if !errors.Is(err, permanent) {
+			t.Errorf("expected %v to be returned unchanged, got %v", permanent, err)
+		}
+		if scripted.calls != 1 || len(*delays) != 0 {
+			t.Errorf("expected %v to not be retried, got %d calls", permanent, scripted.calls)
+		}
+	}
+}
+
+func TestRetryStorageMaxElapsed(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	scripted := &scriptedStorage{Storage: inner, failures: 100, err: io.EOF}
+	opts := DefaultRetryOptions().
+		WithMaxAttempts(10).
+		WithInitialBackoff(time.Second).
+		WithMaxBackoff(time.Minute).
+		WithMaxElapsed(1500 * time.Millisecond).
+		WithJitter(0)
+	storage, _ := newTestRetryStorage(scripted, opts)
+
+	// The first delay of 1s fits within the deadline, the second of 2s does not
+	err := storage.Delete("key")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if scripted.calls != 2 {
+		t.Errorf("expected the deadline to stop retries after 2 calls, got %d", scripted.calls)
+	}
+}
+
+func TestRetryStorageCustomClassifier(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	errBusy := errors.New("busy")
+	scripted := &scriptedStorage{Storage: inner, failures: 1, err: errBusy}
+	opts := DefaultRetryOptions().WithIsRetryable(func(err error) bool {
+		return errors.Is(err, errBusy)
+	})
+	storage, _ := newTestRetryStorage(scripted, opts)
+
+	if err := storage.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if got, _ := inner.Get("key"); string(got) != "value" {
+		t.Error("expected value to be stored")
+	}
+}
+
+func TestRetryStorageDefaults(t *testing.T) {
+	storage := NewRetryStorage(NewMemoryStorage("test:", 0), RetryOptions{}).(*RetryStorage)
+	defer func() { _ = storage.Close() }()
+
+	if storage.opts.MaxAttempts != 3 || storage.opts.InitialBackoff != 50*time.Millisecond || storage.opts.MaxBackoff != time.Second {
+		t.Errorf("expected defaults for zero options, got %+v", storage.opts)
+	}
+	if err := storage.Reset(); err != nil {
+		t.Errorf("unexpected error on reset: %v", err)
+	}
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error on ping: %v", err)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{syscall.ECONNREFUSED, true},
+		{fmt.Errorf("failed to get from redis: %w", syscall.ECONNRESET), true},
+		{io.EOF, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{ErrClosed, false},
+		{fakeRedisError("LOADING Redis is loading the dataset in memory"), true},
+		{fmt.Errorf("failed to set in redis: %w", fakeRedisError("READONLY You can't write against a read only replica.")), true},
+		{fakeRedisError("ERR syntax error"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package session
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,14 +23,28 @@ const (
 	KeyPhone         = "phone"
 	KeyAMR           = "amr"
 	KeyScopes        = "scopes"
+	KeyRoles         = "roles"
 	KeyCreatedAt     = "created_at"
 	KeyLastAccess    = "last_access"
+	KeyAuthTime      = "auth_time"
+	KeyIPAddress     = "ip_address"
+	KeyUserAgent     = "user_agent"
+	KeyFlashes       = "_flashes"
 )
 
 // Manager provides high-level session management operations.
 type Manager struct {
 	storage Storage
 	config  Config
+
+	hooksMu   sync.RWMutex
+	onExpired []func(id string)
+	onDeleted []func(id string)
+	onOp      []func(op, id string, d time.Duration, err error)
+
+	// userMu serializes updates of the indexes of the sessions and
+	// remember-me tokens of users
+	userMu sync.Mutex
 }
 
 // NewManager creates a new session Manager with the given storage and configuration.
@@ -45,13 +65,272 @@ func (m *Manager) GetConfig() Config {
 	return m.config
 }
 
+// storageContext returns the context bounding one storage operation
+// called with ctx.
+func (m *Manager) storageContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.config.StorageTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, m.config.StorageTimeout)
+}
+
+// contextStorage returns the storage if it implements ContextStorage and
+// operations called with ctx should go through it: when
+// Config.StorageTimeout is set or the caller gave a context. Otherwise
+// storages apply their own timeouts.
+func (m *Manager) contextStorage(ctx context.Context) (ContextStorage, bool) {
+	cs, ok := m.storage.(ContextStorage)
+	return cs, ok && (m.config.StorageTimeout > 0 || ctx != context.Background())
+}
+
+// get reads a value from storage within ctx and the configured storage
+// timeout.
+func (m *Manager) get(ctx context.Context, key string) ([]byte, error) {
+	if cs, ok := m.contextStorage(ctx); ok {
+		ctx, cancel := m.storageContext(ctx)
+		defer cancel()
+		return cs.GetCtx(ctx, key)
+	}
+	return bounded(ctx, m, func() ([]byte, error) { return m.storage.Get(key) })
+}
+
+// set writes a value to storage within ctx and the configured storage
+// timeout.
+func (m *Manager) set(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	if cs, ok := m.contextStorage(ctx); ok {
+		ctx, cancel := m.storageContext(ctx)
+		defer cancel()
+		return cs.SetCtx(ctx, key, val, exp)
+	}
+	return boundedErr(ctx, m, func() error { return m.storage.Set(key, val, exp) })
+}
+
+// del removes a value from storage within ctx and the configured storage
+// timeout.
+func (m *Manager) del(ctx context.Context, key string) error {
+	if cs, ok := m.contextStorage(ctx); ok {
+		ctx, cancel := m.storageContext(ctx)
+		defer cancel()
+		return cs.DeleteCtx(ctx, key)
+	}
+	return boundedErr(ctx, m, func() error { return m.storage.Delete(key) })
+}
+
+// bounded runs fn, a storage operation without a context variant, within
+// ctx and Config.StorageTimeout. At the deadline it returns an error
+// matching context.DeadlineExceeded and ErrStorageUnavailable, and fn is
+// left to finish in the background; likewise if ctx is done first. Panics
+// in fn are raised again in the caller.
+func bounded[T any](ctx context.Context, m *Manager, fn func() (T, error)) (T, error) {
+	if m.config.StorageTimeout <= 0 && ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		v     T
+		err   error
+		panic any
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		defer func() {
+			if p := recover(); p != nil {
+				r.panic = p
+			}
+			done <- r
+		}()
+		r.v, r.err = fn()
+	}()
+
+	var timeout <-chan time.Time
+	if m.config.StorageTimeout > 0 {
+		timer := time.NewTimer(m.config.StorageTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var zero T
+	select {
+	case r := <-done:
+		if r.panic != nil {
+			panic(r.panic)
+		}
+		return r.v, r.err
+	case <-timeout:
+		return zero, unavailable(fmt.Errorf("storage operation timed out after %v: %w", m.config.StorageTimeout, context.DeadlineExceeded))
+	case <-ctx.Done():
+		return zero, unavailable(fmt.Errorf("storage operation canceled: %w", ctx.Err()))
+	}
+}
+
+// boundedErr is bounded for storage operations only returning an error.
+func boundedErr(ctx context.Context, m *Manager, fn func() error) error {
+	_, err := bounded(ctx, m, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// HealthCheck reports whether the session storage is reachable, by pinging
+// it if it implements Pinger. Storages that cannot be pinged are assumed to
+// be healthy. It is meant for readiness probes.
+func (m *Manager) HealthCheck(ctx context.Context) error {
+	if err := pingStorage(ctx, m.storage); err != nil {
+		return fmt.Errorf("session storage is unhealthy: %w", err)
+	}
+	return nil
+}
+
+// OnExpired registers fn to be called with the ID of every session that
+// expires. Expirations are reported when LoadSession finds an expired session
+// and, if the storage implements ExpiryNotifier, when the storage discovers
+// them in the background, so hooks fire even for sessions nobody loads again.
+// Hooks must be safe for concurrent use; panics are recovered.
+func (m *Manager) OnExpired(fn func(id string)) error {
+	if fn == nil {
+		return nil
+	}
+
+	m.hooksMu.Lock()
+	first := len(m.onExpired) == 0
+	m.onExpired = append(m.onExpired, fn)
+	m.hooksMu.Unlock()
+
+	if notifier, ok := m.storage.(ExpiryNotifier); ok && first {
+		if err := notifier.NotifyExpired(m.fireExpired); err != nil {
+			return fmt.Errorf("failed to subscribe to expirations: %w", err)
+		}
+	}
+	return nil
+}
+
+// fireExpired invokes the OnExpired hooks for the given session ID.
+func (m *Manager) fireExpired(id string) {
+	m.hooksMu.RLock()
+	hooks := m.onExpired
+	m.hooksMu.RUnlock()
+
+	for _, fn := range hooks {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			fn(id)
+		}()
+	}
+}
+
+// OnOperation registers fn to be called after every CreateSession,
+// LoadSession, SaveSession and DeleteSession with the operation name
+// ("create", "load", "save" or "delete"), the session ID, the duration and
+// the error. Loads report ErrSessionNotFound and ErrSessionExpired as
+// LoadSessionStrict returns them, even through LoadSession; DeleteSessions
+// reports a delete for each ID. Hooks run on the calling goroutine, so they
+// should be fast; panics are recovered.
+func (m *Manager) OnOperation(fn func(op, id string, d time.Duration, err error)) {
+	if fn == nil {
+		return
+	}
+	m.hooksMu.Lock()
+	m.onOp = append(m.onOp, fn)
+	m.hooksMu.Unlock()
+}
+
+// opStart returns the start time of an operation, or the zero time if no
+// OnOperation hook is registered, so the clock is not read for nothing.
+func (m *Manager) opStart() time.Time {
+	m.hooksMu.RLock()
+	observed := len(m.onOp) > 0
+	m.hooksMu.RUnlock()
+	if !observed {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe invokes the OnOperation hooks for an operation that started at
+// start.
+func (m *Manager) observe(op, id string, start time.Time, err error) {
+	if start.IsZero() {
+		return
+	}
+	d := time.Since(start)
+
+	m.hooksMu.RLock()
+	hooks := m.onOp
+	m.hooksMu.RUnlock()
+
+	for _, fn := range hooks {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			fn(op, id, d, err)
+		}()
+	}
+}
+
+// expiration returns the expiration of session: AnonymousExpiration if it
+// is set and the session is not authenticated, Expiration otherwise.
+func (m *Manager) expiration(session *SessionData) time.Duration {
+	if m.config.AnonymousExpiration > 0 && !session.Authenticated {
+		return m.config.AnonymousExpiration
+	}
+	return m.config.Expiration
+}
+
 // CreateSession creates a new session and returns its data.
 func (m *Manager) CreateSession(id string) *SessionData {
-	return NewSessionData(id, m.config.Expiration)
+	start := m.opStart()
+	session := NewSessionData(id, m.expiration(&SessionData{}))
+	m.observe("create", id, start, nil)
+	return session
 }
 
 // SaveSession saves a session to storage.
+// If neither the session nor the config provide an expiration, only the
+// payload is updated: storages implementing TTLKeeper keep the key's
+// existing TTL instead of making it never expire.
+//
+// If Config.AnonymousExpiration is set and the session is authenticated
+// but was not when last saved, ExpiresAt is first extended to Expiration
+// from now, so logging in turns a short anonymous session into a full one.
+//
+// If Config.MaxSessionsPerUser is set and the session has a UserID, it is
+// added to the index of the sessions of the user the first time it is
+// saved with that UserID, evicting the oldest sessions of the user beyond
+// the limit or returning ErrTooManySessions under the SessionLimitReject
+// policy, in which case the session is not saved.
+//
+// Session IDs must be valid for ValidateSessionID, so they never collide
+// with the other keys Manager stores, which contain ':'.
 func (m *Manager) SaveSession(session *SessionData) error {
+	return m.SaveSessionWithContext(context.Background(), session)
+}
+
+// SaveSessionWithContext is SaveSession with storage operations bound to
+// ctx: they go through ContextStorage when the storage implements it, and
+// are abandoned with an error matching ErrStorageUnavailable once ctx is
+// done otherwise.
+func (m *Manager) SaveSessionWithContext(ctx context.Context, session *SessionData) error {
+	start := m.opStart()
+	err := m.saveSession(ctx, session)
+	m.observe("save", session.ID, start, err)
+	return err
+}
+
+// saveSession implements SaveSessionWithContext.
+func (m *Manager) saveSession(ctx context.Context, session *SessionData) error {
+	if err := ValidateSessionID(session.ID); err != nil {
+		return err
+	}
+	if err := m.promoteSession(ctx, session); err != nil {
+		return err
+	}
+	if err := m.indexUserSession(ctx, session); err != nil {
+		return err
+	}
+
 	data, err := json.Marshal(session)
 	if err != nil {
 		return fmt.Errorf("failed to marshal session: %w", err)
@@ -59,87 +338,508 @@ func (m *Manager) SaveSession(session *SessionData) error {
 
 	ttl := time.Until(session.ExpiresAt)
 	if ttl <= 0 {
-		ttl = m.config.Expiration
+		ttl = m.expiration(session)
 	}
 
-	return m.storage.Set(session.ID, data, ttl)
+	if keeper, ok := m.storage.(TTLKeeper); ok && ttl <= 0 {
+		err = boundedErr(ctx, m, func() error { return keeper.SetKeepTTL(session.ID, data) })
+	} else {
+		err = m.set(ctx, session.ID, data, ttl)
+	}
+	if err != nil {
+		return err
+	}
+	session.storedAuthenticated = session.Authenticated
+	return nil
 }
 
-// LoadSession loads a session from storage.
+// promoteSession extends the expiration of session to Expiration if
+// Config.AnonymousExpiration is set and session became authenticated since
+// it was last saved. The stored copy is only read for sessions the Manager
+// has not yet loaded or saved as authenticated.
+func (m *Manager) promoteSession(ctx context.Context, session *SessionData) error {
+	if m.config.AnonymousExpiration <= 0 || !session.Authenticated || session.storedAuthenticated {
+		return nil
+	}
+	data, err := m.get(ctx, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if data != nil {
+		var stored SessionData
+		if err := json.Unmarshal(data, &stored); err == nil && stored.Authenticated {
+			return nil
+		}
+	}
+	session.ExpiresAt = time.Now().Add(m.config.Expiration)
+	return nil
+}
+
+// LoadSession loads a session from storage. It returns nil and no error if
+// the session does not exist or has expired; use LoadSessionStrict to tell
+// these cases apart.
 func (m *Manager) LoadSession(id string) (*SessionData, error) {
-	data, err := m.storage.Get(id)
+	return m.LoadSessionWithContext(context.Background(), id)
+}
+
+// LoadSessionWithContext is LoadSession with storage operations bound to
+// ctx, as in SaveSessionWithContext.
+func (m *Manager) LoadSessionWithContext(ctx context.Context, id string) (*SessionData, error) {
+	session, err := m.LoadSessionStrictWithContext(ctx, id)
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) {
+		return nil, nil
+	}
+	return session, err
+}
+
+// LoadSessionStrict is LoadSession returning ErrSessionNotFound if the
+// session does not exist and ErrSessionExpired if it has expired. Sessions
+// whose storage TTL ran out are reported as not found. Errors reading the
+// storage match ErrStorageUnavailable, and sessions that cannot be decoded
+// ErrInvalidSessionData.
+func (m *Manager) LoadSessionStrict(id string) (*SessionData, error) {
+	return m.LoadSessionStrictWithContext(context.Background(), id)
+}
+
+// LoadSessionStrictWithContext is LoadSessionStrict with storage operations
+// bound to ctx, as in SaveSessionWithContext.
+func (m *Manager) LoadSessionStrictWithContext(ctx context.Context, id string) (*SessionData, error) {
+	start := m.opStart()
+	session, err := m.loadSession(ctx, id)
+	m.observe("load", id, start, err)
+	return session, err
+}
+
+// loadSession implements LoadSessionStrictWithContext.
+func (m *Manager) loadSession(ctx context.Context, id string) (*SessionData, error) {
+	if ValidateSessionID(id) != nil {
+		// Not a session but another key, such as a user index
+		return nil, ErrSessionNotFound
+	}
+	if m.config.SlidingExpiration && m.config.Expiration > 0 {
+		// The expiration of anonymous sessions is only known once decoded,
+		// and TouchInterval needs the stored expiration to skip the write
+		if refresher, ok := m.storage.(Refresher); ok && m.config.AnonymousExpiration <= 0 && m.config.TouchInterval <= 0 {
+			return m.refreshSession(ctx, refresher, id)
+		}
+	}
+
+	data, err := m.get(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, fmt.Errorf("failed to get session: %w", unavailable(err))
 	}
 	if data == nil {
-		return nil, nil
+		return nil, ErrSessionNotFound
 	}
 
 	var session SessionData
 	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal session: %w", invalidData(err))
+	}
+	session.storedAuthenticated = session.Authenticated
+
+	if m.config.SlidingExpiration && m.config.Expiration > 0 {
+		return m.slideSession(ctx, id, &session)
 	}
 
 	if session.IsExpired() {
-		_ = m.storage.Delete(id)
-		return nil, nil
+		_ = m.del(ctx, id)
+		m.fireExpired(id)
+		return nil, ErrSessionExpired
+	}
+
+	return &session, nil
+}
+
+// LoadSessionWithTTL loads a session together with its remaining storage
+// TTL, for example to tell a client when its session ends. The TTL is read
+// in the same step as the session if the storage implements TTLGetter, and
+// with a second call if it implements ExtendedStorage; otherwise it is
+// derived from the session's ExpiresAt. A TTL of -1 means no expiration.
+// Unlike LoadSession it never extends the expiration.
+// Returns nil, 0, nil if the session does not exist or has expired. Errors
+// reading the storage match ErrStorageUnavailable, and sessions that cannot
+// be decoded ErrInvalidSessionData.
+func (m *Manager) LoadSessionWithTTL(id string) (*SessionData, time.Duration, error) {
+	if ValidateSessionID(id) != nil {
+		return nil, 0, nil
+	}
+	ctx := context.Background()
+	getter, hasTTL := m.storage.(TTLGetter)
+
+	var data []byte
+	var ttl time.Duration
+	var err error
+	if hasTTL {
+		type valueTTL struct {
+			data []byte
+			ttl  time.Duration
+		}
+		var v valueTTL
+		v, err = bounded(ctx, m, func() (valueTTL, error) {
+			data, ttl, err := getter.GetWithTTL(id)
+			return valueTTL{data, ttl}, err
+		})
+		data, ttl = v.data, v.ttl
+	} else {
+		data, err = m.get(ctx, id)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get session: %w", unavailable(err))
+	}
+	if data == nil {
+		return nil, 0, nil
+	}
+
+	var session SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal session: %w", invalidData(err))
+	}
+	session.storedAuthenticated = session.Authenticated
+
+	if session.IsExpired() {
+		_ = m.del(ctx, id)
+		m.fireExpired(id)
+		return nil, 0, nil
+	}
+	if hasTTL {
+		return &session, ttl, nil
+	}
+
+	ext, ok := m.storage.(ExtendedStorage)
+	if !ok {
+		return &session, time.Until(session.ExpiresAt), nil
+	}
+	if ttl, err = bounded(ctx, m, func() (time.Duration, error) { return ext.GetTTL(id) }); err != nil {
+		return nil, 0, fmt.Errorf("failed to get session TTL: %w", unavailable(err))
+	}
+	if ttl == -2 {
+		// Expired between the two calls
+		return nil, 0, nil
+	}
+	return &session, ttl, nil
+}
+
+// refreshSession loads a session and extends its storage TTL in one atomic
+// step. As with Touch, the storage TTL is authoritative.
+func (m *Manager) refreshSession(ctx context.Context, refresher Refresher, id string) (*SessionData, error) {
+	data, err := bounded(ctx, m, func() ([]byte, error) { return refresher.GetAndRefresh(id, m.config.Expiration) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", unavailable(err))
+	}
+	if data == nil {
+		return nil, ErrSessionNotFound
 	}
 
+	var session SessionData
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", invalidData(err))
+	}
+	session.storedAuthenticated = session.Authenticated
+
+	session.Touch()
+	session.ExpiresAt = time.Now().Add(m.config.Expiration)
+	if err := m.refreshUserIndex(ctx, &session); err != nil {
+		return nil, err
+	}
 	return &session, nil
 }
 
-// DeleteSession removes a session from storage.
+// slideSession extends the expiration of a freshly loaded session.
+// When the storage supports Touch, only the storage TTL is extended and the
+// storage TTL is authoritative, so the stored ExpiresAt is not consulted.
+// Otherwise the session is rewritten with the new expiration. With
+// Config.TouchInterval, the session goes through TouchSession instead, so
+// the ExpiresAt it skips writes by stays up to date.
+func (m *Manager) slideSession(ctx context.Context, id string, session *SessionData) (*SessionData, error) {
+	if m.skipTouch(session) {
+		session.Touch()
+		return session, nil
+	}
+	if ext, ok := m.storage.(ExtendedStorage); ok && m.config.TouchInterval <= 0 {
+		exp := m.expiration(session)
+		found, err := bounded(ctx, m, func() (bool, error) { return ext.Touch(id, exp) })
+		if err != nil {
+			return nil, fmt.Errorf("failed to extend session: %w", unavailable(err))
+		}
+		if !found {
+			return nil, ErrSessionNotFound
+		}
+		session.Touch()
+		session.ExpiresAt = time.Now().Add(exp)
+		if err := m.refreshUserIndex(ctx, session); err != nil {
+			return nil, err
+		}
+		return session, nil
+	}
+
+	if _, ok := m.storage.(ExtendedStorage); !ok && session.IsExpired() {
+		_ = m.del(ctx, id)
+		m.fireExpired(id)
+		return nil, ErrSessionExpired
+	}
+	if err := m.touchSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// DeleteSession removes a session from storage and calls the OnDeleted
+// hooks.
 func (m *Manager) DeleteSession(id string) error {
-	return m.storage.Delete(id)
+	return m.DeleteSessionWithContext(context.Background(), id)
+}
+
+// DeleteSessionWithContext is DeleteSession with the storage operation
+// bound to ctx, as in SaveSessionWithContext.
+func (m *Manager) DeleteSessionWithContext(ctx context.Context, id string) error {
+	start := m.opStart()
+	err := m.del(ctx, id)
+	m.observe("delete", id, start, err)
+	if err != nil {
+		return err
+	}
+	m.fireDeleted(id)
+	return nil
+}
+
+// DeleteSessions removes several sessions from storage, in a single batch
+// when the storage implements BatchDeleter.
+func (m *Manager) DeleteSessions(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if bd, ok := m.storage.(BatchDeleter); ok {
+		start := m.opStart()
+		err := boundedErr(ctx, m, func() error { return bd.DeleteMany(ids) })
+		for _, id := range ids {
+			m.observe("delete", id, start, err)
+		}
+		if err != nil {
+			return err
+		}
+		m.fireDeleted(ids...)
+		return nil
+	}
+	for _, id := range ids {
+		start := m.opStart()
+		err := m.del(ctx, id)
+		m.observe("delete", id, start, err)
+		if err != nil {
+			return fmt.Errorf("failed to delete session %s: %w", id, err)
+		}
+		m.fireDeleted(id)
+	}
+	return nil
 }
 
 // TouchSession updates the last access time and extends expiration.
+// If the storage implements FieldUpdater and ExtendedStorage, only those two
+// fields are written and the storage TTL is extended; otherwise the whole
+// session is saved again. Within Config.TouchInterval of the last write,
+// only LastAccessedAt is updated, in memory, and ExpiresAt keeps the stored
+// expiration.
 func (m *Manager) TouchSession(session *SessionData) error {
+	return m.touchSession(context.Background(), session)
+}
+
+// touchSession implements TouchSession.
+func (m *Manager) touchSession(ctx context.Context, session *SessionData) error {
+	if m.skipTouch(session) {
+		session.Touch()
+		return nil
+	}
+	exp := m.expiration(session)
 	session.Touch()
-	session.ExpiresAt = time.Now().Add(m.config.Expiration)
-	return m.SaveSession(session)
+	session.ExpiresAt = time.Now().Add(exp)
+
+	updater, canUpdate := m.storage.(FieldUpdater)
+	ext, canExpire := m.storage.(ExtendedStorage)
+	if !canUpdate || !canExpire || exp <= 0 {
+		return m.SaveSessionWithContext(ctx, session)
+	}
+
+	fields := map[string]string{
+		HashFieldLastAccessedAt: session.LastAccessedAt.Format(time.RFC3339Nano),
+		HashFieldExpiresAt:      session.ExpiresAt.Format(time.RFC3339Nano),
+	}
+	found, err := bounded(ctx, m, func() (bool, error) { return updater.UpdateFields(session.ID, fields) })
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if !found {
+		return m.SaveSessionWithContext(ctx, session)
+	}
+	if err := boundedErr(ctx, m, func() error { return ext.Expire(session.ID, exp) }); err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return m.refreshUserIndex(ctx, session)
+}
+
+// skipTouch reports whether refreshing session can skip the storage write
+// under Config.TouchInterval: its stored expiration was extended less than
+// TouchInterval ago, so it expires in more than its expiration minus
+// TouchInterval.
+func (m *Manager) skipTouch(session *SessionData) bool {
+	exp := m.expiration(session)
+	if m.config.TouchInterval <= 0 || exp <= 0 {
+		return false
+	}
+	return time.Until(session.ExpiresAt) > exp-m.config.TouchInterval
 }
 
 // FiberSessionConfig returns a fiber/v2/middleware/session.Config configured to use the Manager's storage.
+// Cookie attributes are corrected for the prefix of the cookie name like
+// CreateCookie does.
+// Fiber sessions read and write the cookie themselves, so if cookie signing
+// is enabled, the middleware returned by NewSignedCookieMiddleware must run
+// before them. Fiber sessions look the session ID up in the first entry of
+// Config.KeyLookup, or in the cookie if it is invalid; register the
+// middleware returned by NewKeyLookupMiddleware to try the other entries.
 func (m *Manager) FiberSessionConfig() fibersession.Config {
-	sameSite := fiber.CookieSameSiteLaxMode
-	normalizedSameSite := normalizeSameSite(m.config.SameSite)
-	switch normalizedSameSite {
-	case "Strict":
-		sameSite = fiber.CookieSameSiteStrictMode
-	case "None":
-		sameSite = fiber.CookieSameSiteNoneMode
-	case "Disabled":
-		sameSite = fiber.CookieSameSiteDisabled
+	cookie := baseCookie(m.config)
+	keyLookup := fmt.Sprintf("cookie:%s", m.config.CookieName)
+	if lookups, err := m.config.keyLookups(); err == nil {
+		keyLookup = fmt.Sprintf("%s:%s", lookups[0].source, lookups[0].name)
 	}
-
-	cookieSecure := m.config.Secure
-	if normalizedSameSite == "None" && !cookieSecure {
-		cookieSecure = true
+	return fibersession.Config{
+		Expiration:        m.config.Expiration,
+		Storage:           m.storage,
+		KeyLookup:         keyLookup,
+		CookieDomain:      cookie.Domain,
+		CookiePath:        cookie.Path,
+		CookieSecure:      cookie.Secure,
+		CookieHTTPOnly:    cookie.HTTPOnly,
+		CookieSameSite:    cookie.SameSite,
+		CookieSessionOnly: m.config.SessionOnlyCookie,
 	}
+}
 
-	return fibersession.Config{
-		Expiration:     m.config.Expiration,
-		Storage:        m.storage,
-		KeyLookup:      fmt.Sprintf("cookie:%s", m.config.CookieName),
-		CookieDomain:   m.config.CookieDomain,
-		CookiePath:     m.config.CookiePath,
-		CookieSecure:   cookieSecure,
-		CookieHTTPOnly: m.config.HTTPOnly,
-		CookieSameSite: sameSite,
+// SaveFiberSession saves a fiber session created with FiberSessionConfig,
+// giving it Config.AnonymousExpiration if that is set and the session is
+// not authenticated. The store's expiration, used by Save, is Expiration,
+// so authenticated sessions keep their full lifetime however they are
+// saved. Like Save, it releases the session.
+func (m *Manager) SaveFiberSession(session *fibersession.Session) error {
+	if m.config.AnonymousExpiration > 0 && !IsAuthenticated(session) {
+		session.SetExpiry(m.config.AnonymousExpiration)
 	}
+	return saveFiberSession(session)
 }
 
 // Helper functions for Fiber sessions
 
-// Authenticate marks a fiber session as authenticated.
+// Authenticate marks a fiber session as authenticated, setting its creation
+// and authentication times to now, and saves it. To prevent session
+// fixation, the session gets a new ID first: the record under the old ID is
+// deleted and the data set before logging in moves to the new one.
 func Authenticate(session *fibersession.Session) error {
+	if err := markAuthenticated(session); err != nil {
+		return err
+	}
+	return saveFiberSession(session)
+}
+
+// AuthOptions describes the user logged in by AuthenticateWithOptions.
+// Empty fields are left unset.
+type AuthOptions struct {
+	UserID string
+	Email  string
+	Phone  string
+	AMR    []string
+	Scopes []string
+	Roles  []string
+
+	// TTL overrides the expiration of the session, such as a longer one for
+	// "remember me". Zero keeps the store's expiration.
+	TTL time.Duration
+
+	// AllowEmptyUserID lets UserID be empty, for sessions identified by
+	// their other fields only.
+	AllowEmptyUserID bool
+}
+
+// AuthenticateWithOptions is Authenticate also setting the fields of opts
+// and the last access time, saving the session once. It returns an error
+// without changing the session if opts.UserID is empty, unless
+// opts.AllowEmptyUserID is set.
+func AuthenticateWithOptions(session *fibersession.Session, opts AuthOptions) error {
+	if err := applyAuthOptions(session, opts); err != nil {
+		return err
+	}
+	return saveFiberSession(session)
+}
+
+// applyAuthOptions is AuthenticateWithOptions without saving the session.
+func applyAuthOptions(session *fibersession.Session, opts AuthOptions) error {
+	if opts.UserID == "" && !opts.AllowEmptyUserID {
+		return fmt.Errorf("user id cannot be empty")
+	}
+	if err := markAuthenticated(session); err != nil {
+		return err
+	}
+
+	if opts.UserID != "" {
+		SetUserID(session, opts.UserID)
+	}
+	if opts.Email != "" {
+		SetEmail(session, opts.Email)
+	}
+	if opts.Phone != "" {
+		SetPhone(session, opts.Phone)
+	}
+	if len(opts.AMR) > 0 {
+		SetAMR(session, opts.AMR)
+	}
+	if len(opts.Scopes) > 0 {
+		SetScopes(session, opts.Scopes)
+	}
+	if len(opts.Roles) > 0 {
+		SetRoles(session, opts.Roles)
+	}
+	UpdateLastAccess(session)
+	if opts.TTL > 0 {
+		session.SetExpiry(opts.TTL)
+	}
+	return nil
+}
+
+// AuthenticateWithContext is Authenticate also recording the IP address and
+// user agent of the client of c, for listing a user's devices. The IP
+// address is taken as by MetaFromRequest: from c's remote address, or from
+// X-Forwarded-For if trustedProxies reverse proxies are in front of the
+// application.
+func AuthenticateWithContext(c *fiber.Ctx, session *fibersession.Session, trustedProxies int) error {
+	if err := markAuthenticated(session); err != nil {
+		return err
+	}
+	ip := clientIP(c.GetReqHeaders()["X-Forwarded-For"], c.Context().RemoteAddr().String(), trustedProxies)
+	if ip != "" {
+		SetIPAddress(session, ip)
+	}
+	if ua := c.Get(fiber.HeaderUserAgent); ua != "" {
+		SetUserAgent(session, ua)
+	}
+	return saveFiberSession(session)
+}
+
+// markAuthenticated gives session a new ID and marks it as authenticated
+// now, without saving it.
+func markAuthenticated(session *fibersession.Session) error {
+	if err := session.Regenerate(); err != nil {
+		return fmt.Errorf("failed to regenerate session id: %w", err)
+	}
+	now := time.Now().Unix()
 	session.Set(KeyAuthenticated, true)
-	session.Set(KeyCreatedAt, time.Now().Unix())
-	return session.Save()
+	session.Set(KeyCreatedAt, now)
+	session.Set(KeyAuthTime, now)
+	return nil
 }
 
-// Unauthenticate destroys a fiber session.
+// Unauthenticate destroys a fiber session. The cookie fiber sets to delete
+// it does not honor every attribute of Config, such as SameSite "Disabled";
+// use Helper.Unauthenticate, or overwrite it with CreateExpiredCookie.
 // Note: session.Destroy() requires a valid context (ctx).
 // If session has been previously saved, the context may be released.
 // This function handles nil session gracefully.
@@ -154,90 +854,143 @@ func Unauthenticate(session *fibersession.Session) error {
 	session.Delete(KeyPhone)
 	session.Delete(KeyAMR)
 	session.Delete(KeyScopes)
+	session.Delete(KeyRoles)
 	session.Delete(KeyCreatedAt)
 	session.Delete(KeyLastAccess)
+	session.Delete(KeyAuthTime)
+	session.Delete(KeyIPAddress)
+	session.Delete(KeyUserAgent)
 	return session.Destroy()
 }
 
-// IsAuthenticated checks if a fiber session is authenticated.
-func IsAuthenticated(session *fibersession.Session) bool {
-	val := session.Get(KeyAuthenticated)
+// SetKey sets the value of key in a fiber session.
+func SetKey[T any](session *fibersession.Session, key string, v T) {
+	session.Set(key, v)
+}
+
+// GetKey gets the value of key from a fiber session. It returns false if
+// the key is absent or holds a value that is not a T; use LookupKey to tell
+// the two apart.
+func GetKey[T any](session *fibersession.Session, key string) (T, bool) {
+	v, ok, _ := LookupKey[T](session, key)
+	return v, ok
+}
+
+// LookupKey gets the value of key from a fiber session. It returns false and
+// a nil error if the key is absent, and false and an error wrapping
+// ErrSessionValueType if the key holds a value that is not a T.
+func LookupKey[T any](session *fibersession.Session, key string) (T, bool, error) {
+	var zero T
+	val := session.Get(key)
 	if val == nil {
-		return false
+		return zero, false, nil
+	}
+	v, ok := val.(T)
+	if !ok {
+		return zero, false, fmt.Errorf("session value %s is a %T, not a %T: %w", key, val, zero, ErrSessionValueType)
+	}
+	return v, true, nil
+}
+
+// UnauthenticateAndRotate logs out the session of the request and replaces
+// it with a new anonymous one, so post-logout state such as a flash message
+// or the CSRF token of the login form has a session to live in. The old
+// session is deleted from storage; the new one gets a fresh ID, the values
+// of the keys listed in keep (such as a locale), and is saved at once, which
+// sets its cookie on the response. It returns the new session, which later
+// store.Get calls in the same request also load.
+func UnauthenticateAndRotate(c *fiber.Ctx, store *fibersession.Store, keep ...string) (*fibersession.Session, error) {
+	sess, err := store.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[string]interface{}, len(keep))
+	for _, key := range keep {
+		if val := sess.Get(key); val != nil {
+			kept[key] = val
+		}
+	}
+
+	if err := sess.Reset(); err != nil {
+		return nil, fmt.Errorf("failed to reset session: %w", err)
+	}
+	for key, val := range kept {
+		sess.Set(key, val)
+	}
+	id := sess.ID()
+	if err := saveFiberSession(sess); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	setRequestSessionID(c, store, id)
+	return store.Get(c)
+}
+
+// setRequestSessionID makes the request carry the session ID id where store
+// looks it up, so store.Get loads that session for the rest of the request.
+func setRequestSessionID(c *fiber.Ctx, store *fibersession.Store, id string) {
+	source, name, _ := strings.Cut(store.KeyLookup, ":")
+	keyLookup{source: fibersession.Source(source), name: name}.setFiberValue(c, id)
+}
+
+// IsAuthenticated checks if a fiber session is authenticated. Besides the
+// bool written by this package, it accepts the forms some decoders turn it
+// into: a string in strconv.ParseBool syntax, such as "true" or "1", and the
+// number 1.
+func IsAuthenticated(session *fibersession.Session) bool {
+	switch authenticated := session.Get(KeyAuthenticated).(type) {
+	case bool:
+		return authenticated
+	case string:
+		b, err := strconv.ParseBool(authenticated)
+		return err == nil && b
+	default:
+		n, ok := numberValue(authenticated)
+		return ok && n == 1
 	}
-	authenticated, ok := val.(bool)
-	return ok && authenticated
 }
 
 // SetUserID sets the user ID in a fiber session.
 func SetUserID(session *fibersession.Session, userID string) {
-	session.Set(KeyUserID, userID)
+	SetKey(session, KeyUserID, userID)
 }
 
 // GetUserID gets the user ID from a fiber session.
 func GetUserID(session *fibersession.Session) string {
-	val := session.Get(KeyUserID)
-	if val == nil {
-		return ""
-	}
-	userID, ok := val.(string)
-	if !ok {
-		return ""
-	}
+	userID, _ := GetKey[string](session, KeyUserID)
 	return userID
 }
 
 // SetEmail sets the email in a fiber session.
 func SetEmail(session *fibersession.Session, email string) {
-	session.Set(KeyEmail, email)
+	SetKey(session, KeyEmail, email)
 }
 
 // GetEmail gets the email from a fiber session.
 func GetEmail(session *fibersession.Session) string {
-	val := session.Get(KeyEmail)
-	if val == nil {
-		return ""
-	}
-	email, ok := val.(string)
-	if !ok {
-		return ""
-	}
+	email, _ := GetKey[string](session, KeyEmail)
 	return email
 }
 
 // SetPhone sets the phone in a fiber session.
 func SetPhone(session *fibersession.Session, phone string) {
-	session.Set(KeyPhone, phone)
+	SetKey(session, KeyPhone, phone)
 }
 
 // GetPhone gets the phone from a fiber session.
 func GetPhone(session *fibersession.Session) string {
-	val := session.Get(KeyPhone)
-	if val == nil {
-		return ""
-	}
-	phone, ok := val.(string)
-	if !ok {
-		return ""
-	}
+	phone, _ := GetKey[string](session, KeyPhone)
 	return phone
 }
 
 // SetAMR sets the authentication methods references in a fiber session.
 func SetAMR(session *fibersession.Session, amr []string) {
-	session.Set(KeyAMR, amr)
+	SetKey(session, KeyAMR, amr)
 }
 
 // GetAMR gets the authentication methods references from a fiber session.
 func GetAMR(session *fibersession.Session) []string {
-	val := session.Get(KeyAMR)
-	if val == nil {
-		return nil
-	}
-	amr, ok := val.([]string)
-	if !ok {
-		return nil
-	}
+	amr, _ := GetKey[[]string](session, KeyAMR)
 	return amr
 }
 
@@ -266,19 +1019,12 @@ func HasAMR(session *fibersession.Session, method string) bool {
 
 // SetScopes sets the authorization scopes in a fiber session.
 func SetScopes(session *fibersession.Session, scopes []string) {
-	session.Set(KeyScopes, scopes)
+	SetKey(session, KeyScopes, scopes)
 }
 
 // GetScopes gets the authorization scopes from a fiber session.
 func GetScopes(session *fibersession.Session) []string {
-	val := session.Get(KeyScopes)
-	if val == nil {
-		return nil
-	}
-	scopes, ok := val.([]string)
-	if !ok {
-		return nil
-	}
+	scopes, _ := GetKey[[]string](session, KeyScopes)
 	return scopes
 }
 
@@ -293,65 +1039,298 @@ func HasScope(session *fibersession.Session, scope string) bool {
 	return false
 }
 
+// SetRoles sets the roles of the user in a fiber session.
+func SetRoles(session *fibersession.Session, roles []string) {
+	SetKey(session, KeyRoles, roles)
+}
+
+// GetRoles gets the roles of the user from a fiber session, accepting them
+// stored as []string or as a []interface{} of strings.
+func GetRoles(session *fibersession.Session) []string {
+	roles, _ := stringSlice(session.Get(KeyRoles))
+	return roles
+}
+
+// AddRole adds a role to a fiber session, unless it already has it.
+func AddRole(session *fibersession.Session, role string) {
+	roles := GetRoles(session)
+	for _, r := range roles {
+		if r == role {
+			return
+		}
+	}
+	roles = append(roles, role)
+	SetRoles(session, roles)
+}
+
+// HasRole checks if a fiber session has a specific role.
+func HasRole(session *fibersession.Session, role string) bool {
+	roles := GetRoles(session)
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIPAddress sets the IP address of the client in a fiber session.
+func SetIPAddress(session *fibersession.Session, ip string) {
+	SetKey(session, KeyIPAddress, ip)
+}
+
+// GetIPAddress gets the IP address of the client from a fiber session.
+func GetIPAddress(session *fibersession.Session) string {
+	ip, _ := GetKey[string](session, KeyIPAddress)
+	return ip
+}
+
+// SetUserAgent sets the user agent of the client in a fiber session.
+func SetUserAgent(session *fibersession.Session, userAgent string) {
+	SetKey(session, KeyUserAgent, userAgent)
+}
+
+// GetUserAgent gets the user agent of the client from a fiber session.
+func GetUserAgent(session *fibersession.Session) string {
+	userAgent, _ := GetKey[string](session, KeyUserAgent)
+	return userAgent
+}
+
 // UpdateLastAccess updates the last access timestamp in a fiber session.
 func UpdateLastAccess(session *fibersession.Session) {
-	session.Set(KeyLastAccess, time.Now().Unix())
+	SetKey(session, KeyLastAccess, time.Now().Unix())
 }
 
 // GetLastAccess gets the last access timestamp from a fiber session.
 func GetLastAccess(session *fibersession.Session) time.Time {
-	val := session.Get(KeyLastAccess)
+	return getTimestamp(session, KeyLastAccess)
+}
+
+// GetCreatedAt gets the session creation timestamp from a fiber session.
+func GetCreatedAt(session *fibersession.Session) time.Time {
+	return getTimestamp(session, KeyCreatedAt)
+}
+
+// SetAuthTime sets the time the user last authenticated in a fiber session,
+// such as after re-entering a password for RequireRecentAuth.
+func SetAuthTime(session *fibersession.Session, t time.Time) {
+	SetKey(session, KeyAuthTime, t.Unix())
+}
+
+// GetAuthTime gets the time the user last authenticated from a fiber
+// session, falling back to the creation time for sessions authenticated
+// before the authentication time was recorded.
+func GetAuthTime(session *fibersession.Session) time.Time {
+	val := session.Get(KeyAuthTime)
 	if val == nil {
-		return time.Time{}
+		return GetCreatedAt(session)
 	}
-	timestamp, ok := val.(int64)
+	timestamp, ok := numberValue(val)
 	if !ok {
 		return time.Time{}
 	}
 	return time.Unix(timestamp, 0)
 }
 
-// GetCreatedAt gets the session creation timestamp from a fiber session.
-func GetCreatedAt(session *fibersession.Session) time.Time {
-	val := session.Get(KeyCreatedAt)
-	if val == nil {
-		return time.Time{}
-	}
-	timestamp, ok := val.(int64)
+// getTimestamp gets the Unix timestamp stored under key as a time, or the
+// zero time if it is absent or not a number. See numberValue for the
+// accepted types.
+func getTimestamp(session *fibersession.Session, key string) time.Time {
+	timestamp, ok := numberValue(session.Get(key))
 	if !ok {
 		return time.Time{}
 	}
 	return time.Unix(timestamp, 0)
 }
 
+// numberValue returns val as an int64, accepting besides the int64 written
+// by this package the other forms numbers come back as from some storage
+// encodings: int, float64 and json.Number. Floats are truncated; NaN,
+// infinities and values out of the int64 range are rejected.
+func numberValue(val interface{}) (int64, bool) {
+	switch n := val.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		if math.IsNaN(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return numberValue(f)
+	default:
+		return 0, false
+	}
+}
+
+// AddFlash appends a flash message of the given category, such as "error"
+// or "info", to a fiber session and saves it, so the message can be shown
+// on the next request with ConsumeFlashes. Like Save, it releases the
+// session.
+func AddFlash(session *fibersession.Session, category, message string) error {
+	flashes, _ := stringSlice(session.Get(KeyFlashes))
+	session.Set(KeyFlashes, append(flashes, category, message))
+	return saveFiberSession(session)
+}
+
+// ConsumeFlashes returns the flash messages of a fiber session by category,
+// in the order they were added, and removes them. If there were any, the
+// session is saved, which releases it. The result is empty, not nil, if
+// there are no flashes.
+func ConsumeFlashes(session *fibersession.Session) (map[string][]string, error) {
+	flashes := make(map[string][]string)
+	val := session.Get(KeyFlashes)
+	if val == nil {
+		return flashes, nil
+	}
+	pairs, _ := stringSlice(val)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		flashes[pairs[i]] = append(flashes[pairs[i]], pairs[i+1])
+	}
+	session.Delete(KeyFlashes)
+	if err := saveFiberSession(session); err != nil {
+		return nil, err
+	}
+	return flashes, nil
+}
+
+// SetJSON stores v in a fiber session under key as JSON, so values such as
+// structs, which the session encoding does not keep, survive being saved
+// and loaded. Read it back with GetJSON.
+func SetJSON(session *fibersession.Session, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session value %s: %w", key, err)
+	}
+	session.Set(key, data)
+	return nil
+}
+
+// GetJSON decodes the JSON value stored under key by SetJSON, accepting it
+// stored as []byte or string. It returns false if the key is absent, and an
+// error if the stored value is of another type or cannot be decoded into T.
+func GetJSON[T any](session *fibersession.Session, key string) (T, bool, error) {
+	var v T
+	var data []byte
+	switch val := session.Get(key).(type) {
+	case nil:
+		return v, false, nil
+	case []byte:
+		data = val
+	case string:
+		data = []byte(val)
+	default:
+		return v, false, fmt.Errorf("session value %s is a %T, not JSON: %w", key, val, ErrSessionValueType)
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false, fmt.Errorf("failed to unmarshal session value %s: %w", key, err)
+	}
+	return v, true, nil
+}
+
+// stringSlice returns val as a []string, accepting the []interface{} of
+// strings some encodings decode string slices to.
+func stringSlice(val interface{}) ([]string, bool) {
+	switch v := val.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		out := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			out[i] = s
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
 // CreateCookie creates a fiber.Cookie for session sharing across domains.
+// Its value is signed if config.CookieSigningKey is set. It lasts for
+// config.Expiration through both Max-Age and Expires, unless
+// config.SessionOnlyCookie makes it a browser-session cookie.
+//
+// Browsers drop cookies whose name prefix is not honored, so CreateCookie
+// corrects the attributes instead: a name starting with CookiePrefixSecure
+// or CookiePrefixHost makes the cookie Secure, and CookiePrefixHost also
+// sets Path "/" and clears Domain. Use CreateCookieValidated to get an error
+// instead.
 func CreateCookie(config Config, sessionID string) *fiber.Cookie {
+	cookie := baseCookie(config)
+	cookie.Value = SignCookieValue(config, sessionID)
+	if config.SessionOnlyCookie {
+		cookie.SessionOnly = true
+	} else {
+		cookie.MaxAge = int(config.Expiration / time.Second)
+		cookie.Expires = time.Now().Add(config.Expiration)
+	}
+	return cookie
+}
+
+// CreateCookieValidated is CreateCookie returning an error, rather than
+// correcting the cookie, if config does not meet the requirements of the
+// prefix of config.CookieName.
+func CreateCookieValidated(config Config, sessionID string) (*fiber.Cookie, error) {
+	if err := config.checkCookiePrefix(config.CookieName); err != nil {
+		return nil, err
+	}
+	return CreateCookie(config, sessionID), nil
+}
+
+// CreateExpiredCookie creates a fiber.Cookie deleting the session cookie
+// created by CreateCookie from the browser, for logout flows: its value is
+// empty, its Max-Age is negative and it expired in the past, while its
+// other attributes are those of CreateCookie, which browsers need to match
+// to delete the cookie. Set it with SetCookie to keep it partitioned.
+func CreateExpiredCookie(config Config) *fiber.Cookie {
+	cookie := baseCookie(config)
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	return cookie
+}
+
+// baseCookie returns a cookie with the name and attributes of config,
+// without a value or lifetime, corrected for the prefix of its name as
+// described by CreateCookie.
+func baseCookie(config Config) *fiber.Cookie {
 	sameSite := fiber.CookieSameSiteLaxMode
-	normalizedSameSite := normalizeSameSite(config.SameSite)
-	switch normalizedSameSite {
-	case "Strict":
+	switch SameSite(normalizeSameSite(string(config.SameSite))) {
+	case SameSiteStrict:
 		sameSite = fiber.CookieSameSiteStrictMode
-	case "None":
+	case SameSiteNone:
 		sameSite = fiber.CookieSameSiteNoneMode
-	case "Disabled":
+	case SameSiteDisabled:
 		sameSite = fiber.CookieSameSiteDisabled
 	}
 
-	cookieSecure := config.Secure
-	if normalizedSameSite == "None" && !cookieSecure {
+	cookieSecure := CookieSecure(config.SameSite, config.Secure)
+
+	path, domain := config.CookiePath, config.CookieDomain
+	if strings.HasPrefix(config.CookieName, CookiePrefixHost) {
+		cookieSecure, path, domain = true, "/", ""
+	} else if strings.HasPrefix(config.CookieName, CookiePrefixSecure) {
 		cookieSecure = true
 	}
 
-	cookie := &fiber.Cookie{
+	return &fiber.Cookie{
 		Name:     config.CookieName,
-		Value:    sessionID,
-		Expires:  time.Now().Add(config.Expiration),
-		Path:     config.CookiePath,
-		Domain:   config.CookieDomain,
+		Path:     path,
+		Domain:   domain,
 		Secure:   cookieSecure,
 		HTTPOnly: config.HTTPOnly,
 		SameSite: sameSite,
 	}
-
-	return cookie
 }
@@ -0,0 +1,194 @@
+package session
+
+import (
+	"sync"
+	"sync/atomic"
+	"weak"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// sessionCacheKey is the fiber.Ctx Locals key of the session cached by
+// GetSessionCached.
+type sessionCacheKey struct{}
+
+// cachedSession is a session cached for the rest of a request.
+type cachedSession struct {
+	store   *fibersession.Store
+	session *fibersession.Session
+	// saved is set once a helper of this package saved the session,
+	// which released it to the pool of the fiber session package.
+	saved *atomic.Bool
+}
+
+// released reports whether the cached session can no longer be used: a
+// helper saved it, or it was saved with Save, which clears its ID.
+func (cs *cachedSession) released() bool {
+	return cs.saved.Load() || cs.session.ID() == ""
+}
+
+// cachedStates maps the sessions cached by GetSessionCached to their saved
+// flag, so helpers given only a session can record that they saved it.
+// Sessions are weakly referenced, so those dropped unsaved are collected;
+// their entries are swept as new sessions are cached.
+var cachedStates = struct {
+	sync.Mutex
+	m     map[weak.Pointer[fibersession.Session]]*atomic.Bool
+	added int
+}{m: make(map[weak.Pointer[fibersession.Session]]*atomic.Bool)}
+
+// trackCached records sess as cached and returns its saved flag.
+func trackCached(sess *fibersession.Session) *atomic.Bool {
+	saved := new(atomic.Bool)
+	cachedStates.Lock()
+	defer cachedStates.Unlock()
+	cachedStates.m[weak.Make(sess)] = saved
+	// Sweeping once per as many additions as there are entries keeps the
+	// cost constant per cached session
+	if cachedStates.added++; cachedStates.added > len(cachedStates.m) {
+		for key := range cachedStates.m {
+			if key.Value() == nil {
+				delete(cachedStates.m, key)
+			}
+		}
+		cachedStates.added = 0
+	}
+	return saved
+}
+
+// untrackCached forgets sess and returns its saved flag, or nil if it is
+// not cached.
+func untrackCached(sess *fibersession.Session) *atomic.Bool {
+	cachedStates.Lock()
+	defer cachedStates.Unlock()
+	key := weak.Make(sess)
+	saved := cachedStates.m[key]
+	delete(cachedStates.m, key)
+	return saved
+}
+
+// saveFiberSession saves sess like Save. If it is a session cached by
+// GetSessionCached, it is first marked saved, so SaveCached and the
+// middleware of NewAutoTouchMiddleware don't save it again once released.
+// The helpers of this package save sessions with it.
+func saveFiberSession(sess *fibersession.Session) error {
+	if saved := untrackCached(sess); saved != nil {
+		saved.Store(true)
+	}
+	return sess.Save()
+}
+
+// GetSessionCached returns the session of the request like store.Get, but
+// loads it only once per request: the first call caches it in c.Locals and
+// later calls return the same instance, so middlewares and handlers see each
+// other's changes without decoding the session again. Only one store is
+// cached per request; other stores are loaded with store.Get every time.
+//
+// Changes to the cached session are saved by SaveCached, typically from the
+// middleware returned by NewSaveCachedMiddleware. The helpers of this
+// package that save a session, such as AddFlash or Authenticate, may be
+// given the cached session: SaveCached then leaves it alone, and later calls
+// load it again. Prefer SaveCached to calling Save on it directly, and
+// destroy it with DestroyCached, not Destroy or Unauthenticate, or
+// SaveCached saves it again as an empty session.
+func GetSessionCached(c *fiber.Ctx, store *fibersession.Store) (*fibersession.Session, error) {
+	cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession)
+	if ok && cached.store == store && !cached.released() {
+		return cached.session, nil
+	}
+	sess, err := store.Get(c)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || cached.released() {
+		c.Locals(sessionCacheKey{}, &cachedSession{store: store, session: sess, saved: trackCached(sess)})
+	}
+	return sess, nil
+}
+
+// SaveCached saves the session cached by GetSessionCached, if any, and
+// removes it from the cache. New sessions without any key are not saved, so
+// anonymous requests don't create sessions, nor are sessions already saved
+// during the request.
+func SaveCached(c *fiber.Ctx) error {
+	cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession)
+	if !ok {
+		return nil
+	}
+	c.Locals(sessionCacheKey{}, nil)
+	if cached.released() {
+		return nil
+	}
+	untrackCached(cached.session)
+	if cached.session.Fresh() && len(cached.session.Keys()) == 0 {
+		return nil
+	}
+	return cached.session.Save()
+}
+
+// savedCached reports whether the session of store cached by
+// GetSessionCached was saved during the request.
+func savedCached(c *fiber.Ctx, store *fibersession.Store) bool {
+	cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession)
+	return ok && cached.store == store && cached.released()
+}
+
+// DestroyCached destroys the session cached by GetSessionCached, if any,
+// and removes it from the cache.
+func DestroyCached(c *fiber.Ctx) error {
+	cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession)
+	if !ok {
+		return nil
+	}
+	c.Locals(sessionCacheKey{}, nil)
+	sess := cached.session
+	if cached.released() {
+		// Saved during the request: destroy the stored session
+		var err error
+		if sess, err = cached.store.Get(c); err != nil {
+			return err
+		}
+	} else {
+		untrackCached(sess)
+	}
+	if err := sess.Destroy(); err != nil {
+		return err
+	}
+	expireStoreCookie(c, cached.store)
+	return nil
+}
+
+// destroySession destroys sess, a session of store returned by
+// GetSessionCached, removing it from the cache if it is the cached one.
+func destroySession(c *fiber.Ctx, store *fibersession.Store, sess *fibersession.Session) error {
+	if cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession); ok && cached.session == sess {
+		c.Locals(sessionCacheKey{}, nil)
+		untrackCached(sess)
+	}
+	if err := sess.Destroy(); err != nil {
+		return err
+	}
+	expireStoreCookie(c, store)
+	return nil
+}
+
+// saveSession saves sess, a session returned by GetSessionCached, with
+// SaveCached if it is the cached one, so it is not saved twice.
+func saveSession(c *fiber.Ctx, sess *fibersession.Session) error {
+	if cached, ok := c.Locals(sessionCacheKey{}).(*cachedSession); ok && cached.session == sess {
+		return SaveCached(c)
+	}
+	return saveFiberSession(sess)
+}
+
+// NewSaveCachedMiddleware returns a middleware calling SaveCached after the
+// handler, unless the handler returned an error.
+func NewSaveCachedMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		return SaveCached(c)
+	}
+}
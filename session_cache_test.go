@@ -0,0 +1,190 @@
+package session
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestGetSessionCached(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewSaveCachedMiddleware())
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if err := AuthenticateWithOptions(sess, AuthOptions{UserID: "user-1", AMR: []string{"pwd"}}); err != nil {
+			return err
+		}
+		return nil
+	})
+	app.Get("/anonymous", func(c *fiber.Ctx) error {
+		_, err := GetSessionCached(c, store)
+		return err
+	})
+	app.Get("/profile", RequireAMR(store, "pwd"), RequireRecentAuth(store, time.Hour), func(c *fiber.Ctx) error {
+		for i := 0; i < 3; i++ {
+			sess, err := GetSessionCached(c, store)
+			if err != nil {
+				return err
+			}
+			UpdateLastAccess(sess)
+			sess.Set("visits", i+1)
+		}
+		sess, _ := GetSessionCached(c, store)
+		return c.SendString(GetUserID(sess))
+	})
+	app.Get("/visits", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		return c.JSON(sess.Get("visits"))
+	})
+	app.Get("/logout", func(c *fiber.Ctx) error {
+		if _, err := GetSessionCached(c, store); err != nil {
+			return err
+		}
+		return DestroyCached(c)
+	})
+
+	request := func(path string, cookie *http.Cookie) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	request("/anonymous", nil)
+	if memory.Len() != 0 {
+		t.Fatal("expected no session to be saved for an anonymous request")
+	}
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	rec.reset()
+	if body := request("/profile", cookie); body != "user-1" {
+		t.Fatalf("expected the profile, got %q", body)
+	}
+	if gets, sets := rec.count("get"), rec.count("set"); gets != 1 || sets != 1 {
+		t.Errorf("expected one storage read and one write, got %d and %d", gets, sets)
+	}
+	if body := request("/visits", cookie); body != "3" {
+		t.Errorf("expected the changes to be saved, got %q", body)
+	}
+
+	request("/logout", cookie)
+	if memory.Len() != 0 {
+		t.Error("expected the destroyed session not to be saved again")
+	}
+}
+
+func TestGetSessionCachedSavedByHelper(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: memory, Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Use(NewAutoTouchMiddleware(store, 0))
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/flash", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		if err := AddFlash(sess, "info", "saved"); err != nil {
+			return err
+		}
+		// The saved session is loaded again, with the flash
+		sess, err = GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		flashes, _ := stringSlice(sess.Get(KeyFlashes))
+		return c.JSON(flashes)
+	})
+	app.Get("/consume", func(c *fiber.Ctx) error {
+		sess, err := GetSessionCached(c, store)
+		if err != nil {
+			return err
+		}
+		flashes, err := ConsumeFlashes(sess)
+		if err != nil {
+			return err
+		}
+		return c.JSON(flashes["info"])
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	request := func(path string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if body := request("/flash"); body != `["info","saved"]` {
+		t.Errorf("expected the flash to be reloaded, got %s", body)
+	}
+	if body := request("/consume"); body != `["saved"]` {
+		t.Errorf("expected the saved flash, got %s", body)
+	}
+	if body := request("/consume"); body != "null" {
+		t.Errorf("expected the flash to be consumed, got %s", body)
+	}
+}
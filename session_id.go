@@ -0,0 +1,48 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// IDGenerator returns a new random session ID. IDs must be valid for
+// ValidateSessionID. Stores retry on the rare ID that collides with an
+// existing session.
+type IDGenerator func() (string, error)
+
+// DefaultIDGenerator returns "sess_" followed by 16 random bytes in
+// unpadded URL-safe base64.
+var DefaultIDGenerator = NewBase64IDGenerator("sess_", 16)
+
+// NewBase64IDGenerator returns an IDGenerator of prefix followed by size
+// random bytes in unpadded URL-safe base64. It panics if size is not
+// positive or prefix contains ':'.
+func NewBase64IDGenerator(prefix string, size int) IDGenerator {
+	return newIDGenerator(prefix, size, base64.RawURLEncoding.EncodeToString)
+}
+
+// NewHexIDGenerator returns an IDGenerator of prefix followed by size random
+// bytes in lowercase hex. It panics if size is not positive or prefix
+// contains ':'.
+func NewHexIDGenerator(prefix string, size int) IDGenerator {
+	return newIDGenerator(prefix, size, hex.EncodeToString)
+}
+
+func newIDGenerator(prefix string, size int, encode func([]byte) string) IDGenerator {
+	if size <= 0 {
+		panic(fmt.Sprintf("session: id size must be > 0, got %d", size))
+	}
+	if strings.Contains(prefix, ":") {
+		panic(fmt.Sprintf("session: id prefix %q cannot contain ':'", prefix))
+	}
+	return func() (string, error) {
+		b := make([]byte, size)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return prefix + encode(b), nil
+	}
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestIDGenerators(t *testing.T) {
+	tests := []struct {
+		name   string
+		gen    IDGenerator
+		prefix string
+		length int
+	}{
+		{"default", DefaultIDGenerator, "sess_", 22},
+		{"base64", NewBase64IDGenerator("auth_", 32), "auth_", 43},
+		{"hex", NewHexIDGenerator("otp-", 32), "otp-", 64},
+		{"no prefix", NewHexIDGenerator("", 8), "", 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			for i := 0; i < 100; i++ {
+				id, err := tt.gen()
+				if err != nil {
+					t.Fatalf("failed to generate id: %v", err)
+				}
+				if !strings.HasPrefix(id, tt.prefix) {
+					t.Fatalf("expected prefix %q, got %q", tt.prefix, id)
+				}
+				if n := len(id) - len(tt.prefix); n != tt.length {
+					t.Fatalf("expected %d characters after the prefix, got %d in %q", tt.length, n, id)
+				}
+				if err := ValidateSessionID(id); err != nil {
+					t.Fatalf("expected a valid id, got %v", err)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate id %q", id)
+				}
+				seen[id] = true
+			}
+		})
+	}
+
+	id, _ := NewHexIDGenerator("x", 4)()
+	if _, err := hex.DecodeString(id[1:]); err != nil {
+		t.Errorf("expected hex after the prefix, got %q", id)
+	}
+}
+
+func TestIDGeneratorInvalid(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"zero size":     func() { NewBase64IDGenerator("sess_", 0) },
+		"negative size": func() { NewHexIDGenerator("sess_", -1) },
+		"separator":     func() { NewHexIDGenerator("a:b", 16) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
@@ -1,9 +1,16 @@
 package session
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,37 +18,10 @@ import (
 	fibersession "github.com/gofiber/fiber/v2/middleware/session"
 )
 
-// failingStorage implements Storage and returns configurable errors for testing.
-type failingStorage struct {
-	setErr  error
-	getErr  error
-	Storage Storage
-}
-
-func (f *failingStorage) Get(key string) ([]byte, error) {
-	if f.getErr != nil {
-		return nil, f.getErr
-	}
-	return f.Storage.Get(key)
-}
-
-func (f *failingStorage) Set(key string, val []byte, exp time.Duration) error {
-	if f.setErr != nil {
-		return f.setErr
-	}
-	return f.Storage.Set(key, val, exp)
-}
-
-func (f *failingStorage) Delete(key string) error {
-	return f.Storage.Delete(key)
-}
-
-func (f *failingStorage) Reset() error {
-	return f.Storage.Reset()
-}
-
-func (f *failingStorage) Close() error {
-	return f.Storage.Close()
+// plainStorage hides the optional interfaces of the embedded storage, so
+// only the basic Storage methods are available.
+type plainStorage struct {
+	Storage
 }
 
 func TestManagerCreateSession(t *testing.T) {
@@ -203,6 +183,513 @@ func TestManagerTouchSession(t *testing.T) {
 	}
 }
 
+// hangingStorage blocks every context-aware operation until its context is done.
+type hangingStorage struct {
+	Storage
+}
+
+func (h *hangingStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (h *hangingStorage) SetCtx(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (h *hangingStorage) DeleteCtx(ctx context.Context, key string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestManagerStorageTimeout(t *testing.T) {
+	storage := &hangingStorage{Storage: NewMemoryStorage("test:", 0)}
+	manager := NewManager(storage, DefaultConfig().WithStorageTimeout(20*time.Millisecond))
+
+	ops := map[string]func() error{
+		"LoadSession":   func() error { _, err := manager.LoadSession("session-123"); return err },
+		"SaveSession":   func() error { return manager.SaveSession(manager.CreateSession("session-123")) },
+		"DeleteSession": func() error { return manager.DeleteSession("session-123") },
+	}
+	for name, op := range ops {
+		start := time.Now()
+		err := op()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected context.DeadlineExceeded, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected timeout to be honored, took %v", name, elapsed)
+		}
+	}
+
+	// Without a timeout the plain methods are used, and a missing session is
+	// not an error
+	plain := NewManager(storage, DefaultConfig())
+	if session, err := plain.LoadSession("missing"); session != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing session, got %v, %v", session, err)
+	}
+}
+
+func TestManagerWithContext(t *testing.T) {
+	storage := &hangingStorage{Storage: NewMemoryStorage("test:", 0)}
+	manager := NewManager(storage, DefaultConfig())
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	ops := map[string]func() error{
+		"LoadSessionWithContext": func() error { _, err := manager.LoadSessionWithContext(ctx, "session-123"); return err },
+		"LoadSessionStrictWithContext": func() error {
+			_, err := manager.LoadSessionStrictWithContext(ctx, "session-123")
+			return err
+		},
+		"SaveSessionWithContext":   func() error { return manager.SaveSessionWithContext(ctx, manager.CreateSession("session-123")) },
+		"DeleteSessionWithContext": func() error { return manager.DeleteSessionWithContext(ctx, "session-123") },
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected the context deadline, got %v", name, err)
+		}
+	}
+
+	// Operations without a context variant are abandoned once ctx is done
+	blocking := &blockingStorage{MemoryStorage: NewMemoryStorage("test:", 0), release: make(chan struct{})}
+	defer func() { _ = blocking.Close() }()
+	defer close(blocking.release)
+	sliding := NewManager(blocking, DefaultConfig().WithSlidingExpiration(true))
+	canceled, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := sliding.LoadSessionWithContext(canceled, "session-123")
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("expected a canceled storage error, got %v", err)
+	}
+}
+
+// blockingStorage blocks the optional operations of a MemoryStorage, which
+// have no context variant, until release is closed.
+type blockingStorage struct {
+	*MemoryStorage
+	release chan struct{}
+}
+
+func (b *blockingStorage) GetAndRefresh(string, time.Duration) ([]byte, error) {
+	<-b.release
+	return nil, nil
+}
+
+func (b *blockingStorage) SetKeepTTL(string, []byte) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingStorage) DeleteMany([]string) error {
+	<-b.release
+	return nil
+}
+
+func (b *blockingStorage) GetWithTTL(string) ([]byte, time.Duration, error) {
+	<-b.release
+	return nil, 0, nil
+}
+
+func (b *blockingStorage) UpdateFields(string, map[string]string) (bool, error) {
+	<-b.release
+	return true, nil
+}
+
+func TestManagerStorageTimeoutOptionalInterfaces(t *testing.T) {
+	storage := &blockingStorage{MemoryStorage: NewMemoryStorage("test:", 0), release: make(chan struct{})}
+	defer func() { _ = storage.Close() }()
+	defer close(storage.release)
+
+	config := DefaultConfig().WithStorageTimeout(20 * time.Millisecond)
+	manager := NewManager(storage, config)
+	ops := map[string]func() error{
+		"GetAndRefresh": func() error {
+			_, err := NewManager(storage, config.WithSlidingExpiration(true)).LoadSession("session-123")
+			return err
+		},
+		"SetKeepTTL": func() error {
+			return NewManager(storage, config.WithExpiration(0)).SaveSession(&SessionData{ID: "session-123"})
+		},
+		"DeleteMany":   func() error { return manager.DeleteSessions([]string{"a", "b"}) },
+		"GetWithTTL":   func() error { _, _, err := manager.LoadSessionWithTTL("session-123"); return err },
+		"UpdateFields": func() error { return manager.TouchSession(manager.CreateSession("session-123")) },
+	}
+	for name, op := range ops {
+		start := time.Now()
+		err := op()
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrStorageUnavailable) {
+			t.Errorf("%s: expected a deadline error, got %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected timeout to be honored, took %v", name, elapsed)
+		}
+	}
+}
+
+func TestManagerStorageTimeoutRedis(t *testing.T) {
+	client := setupBlackholeRedis(t)
+	storage := NewRedisStorage(client, "test:").WithOpTimeout(0)
+	manager := NewManager(storage, DefaultConfig().WithStorageTimeout(50*time.Millisecond))
+
+	if _, err := manager.LoadSession("session-123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestManagerLoadSessionWithTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storages := map[string]Storage{
+		"redis":  NewRedisStorage(client, "test:"),
+		"memory": NewMemoryStorage("test:", 0),
+		"plain":  &plainStorage{Storage: NewMemoryStorage("test:", 0)},
+	}
+	for name, storage := range storages {
+		manager := NewManager(storage, DefaultConfig().WithExpiration(time.Hour))
+		session := manager.CreateSession("session-123")
+		if err := manager.SaveSession(session); err != nil {
+			t.Fatalf("%s: failed to save: %v", name, err)
+		}
+
+		loaded, ttl, err := manager.LoadSessionWithTTL("session-123")
+		if err != nil || loaded == nil || loaded.ID != "session-123" {
+			t.Fatalf("%s: failed to load: %v", name, err)
+		}
+		if ttl <= 59*time.Minute || ttl > time.Hour {
+			t.Errorf("%s: expected TTL of about 1h, got %v", name, ttl)
+		}
+
+		loaded, ttl, err = manager.LoadSessionWithTTL("missing")
+		if loaded != nil || ttl != 0 || err != nil {
+			t.Errorf("%s: expected nil, 0, nil for a missing session, got %v, %v, %v", name, loaded, ttl, err)
+		}
+	}
+}
+
+func TestManagerHealthCheck(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	manager := NewManager(storage, DefaultConfig())
+
+	if err := manager.HealthCheck(context.Background()); err != nil {
+		t.Fatalf("expected healthy storage, got %v", err)
+	}
+
+	_ = storage.Close()
+	if err := manager.HealthCheck(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed from closed storage, got %v", err)
+	}
+
+	// Storages without Ping are assumed to be healthy
+	plain := NewManager(&plainStorage{Storage: NewMemoryStorage("test:", 0)}, DefaultConfig())
+	if err := plain.HealthCheck(context.Background()); err != nil {
+		t.Errorf("expected storage without Ping to be healthy, got %v", err)
+	}
+}
+
+// TestManagerHealthCheckHandler shows a readiness endpoint backed by HealthCheck.
+func TestManagerHealthCheckHandler(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	manager := NewManager(storage, DefaultConfig())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := manager.HealthCheck(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	check := func() int {
+		resp, err := http.Get(srv.URL + "/healthz")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := check(); code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+	_ = storage.Close()
+	if code := check(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after close, got %d", code)
+	}
+}
+
+func TestManagerSlidingExpiration(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	config := DefaultConfig().
+		WithExpiration(50 * time.Millisecond).
+		WithSlidingExpiration(true)
+	manager := NewManager(storage, config)
+
+	session := manager.CreateSession("session-123")
+	_ = manager.SaveSession(session)
+
+	// Each load extends the TTL, so the session outlives its original expiration
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		loaded, err := manager.LoadSession("session-123")
+		if err != nil {
+			t.Fatalf("failed to load session: %v", err)
+		}
+		if loaded == nil {
+			t.Fatalf("expected session to still exist after %d loads", i)
+		}
+		if time.Until(loaded.ExpiresAt) < 40*time.Millisecond {
+			t.Errorf("expected ExpiresAt to be extended, got %v", loaded.ExpiresAt)
+		}
+	}
+
+	// Without activity the session eventually expires
+	time.Sleep(80 * time.Millisecond)
+	loaded, _ := manager.LoadSession("session-123")
+	if loaded != nil {
+		t.Error("expected idle session to expire")
+	}
+}
+
+func TestManagerSlidingExpirationWithoutTouch(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	// plainStorage hides the ExtendedStorage methods, forcing a rewrite
+	storage := &plainStorage{Storage: inner}
+	config := DefaultConfig().
+		WithExpiration(50 * time.Millisecond).
+		WithSlidingExpiration(true)
+	manager := NewManager(storage, config)
+
+	session := manager.CreateSession("session-123")
+	_ = manager.SaveSession(session)
+
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		loaded, err := manager.LoadSession("session-123")
+		if err != nil || loaded == nil {
+			t.Fatalf("expected session to still exist after %d loads, got %v", i, err)
+		}
+	}
+}
+
+func TestManagerSlidingExpirationTouch(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	// Only the ExtendedStorage methods are visible, so Touch is used instead of GetAndRefresh
+	storage := struct{ ExtendedStorage }{inner}
+	config := DefaultConfig().
+		WithExpiration(50 * time.Millisecond).
+		WithSlidingExpiration(true)
+	manager := NewManager(storage, config)
+
+	_ = manager.SaveSession(manager.CreateSession("session-123"))
+
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		loaded, err := manager.LoadSession("session-123")
+		if err != nil || loaded == nil {
+			t.Fatalf("expected session to still exist after %d loads, got %v", i, err)
+		}
+	}
+}
+
+func TestManagerSlidingExpirationRedis(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	config := DefaultConfig().
+		WithExpiration(time.Hour).
+		WithSlidingExpiration(true)
+	manager := NewManager(storage, config)
+
+	_ = manager.SaveSession(manager.CreateSession("session-123"))
+	mr.FastForward(30 * time.Minute)
+
+	loaded, err := manager.LoadSession("session-123")
+	if err != nil || loaded == nil {
+		t.Fatalf("expected session, got %v", err)
+	}
+	if ttl := mr.TTL("test:session-123"); ttl != time.Hour {
+		t.Errorf("expected TTL to be refreshed to 1h, got %v", ttl)
+	}
+
+	if loaded, _ := manager.LoadSession("missing"); loaded != nil {
+		t.Error("expected nil for missing session")
+	}
+}
+
+func TestManagerSaveSessionKeepsTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewRedisStorage(client, "test:")
+	manager := NewManager(storage, DefaultConfig().WithExpiration(0))
+
+	session := NewSessionData("session-123", 0)
+	_ = storage.Set("session-123", []byte("{}"), time.Hour)
+
+	// Without any expiration, saving only updates the payload
+	session.SetValue("user", "alice")
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if ttl := mr.TTL("test:session-123"); ttl != time.Hour {
+		t.Errorf("expected existing TTL to be kept, got %v", ttl)
+	}
+	data, _ := storage.Get("session-123")
+	if !strings.Contains(string(data), "alice") {
+		t.Errorf("expected updated payload to be stored, got %s", string(data))
+	}
+}
+
+func TestManagerDeleteSessions(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	config := DefaultConfig().WithExpiration(time.Hour)
+
+	// Batch path via MemoryStorage, loop path via a wrapper without DeleteMany
+	for _, storage := range []Storage{inner, &plainStorage{Storage: inner}} {
+		manager := NewManager(storage, config)
+		for _, id := range []string{"a", "b", "c"} {
+			_ = manager.SaveSession(manager.CreateSession(id))
+		}
+
+		if err := manager.DeleteSessions(nil); err != nil {
+			t.Fatalf("expected no error for empty input, got %v", err)
+		}
+		if err := manager.DeleteSessions([]string{"a", "c"}); err != nil {
+			t.Fatalf("failed to delete sessions: %v", err)
+		}
+
+		if s, _ := manager.LoadSession("a"); s != nil {
+			t.Error("expected session a to be deleted")
+		}
+		if s, _ := manager.LoadSession("b"); s == nil {
+			t.Error("expected session b to survive")
+		}
+		_ = inner.Reset()
+	}
+}
+
+func TestManagerOnExpiredMemoryGC(t *testing.T) {
+	storage := NewMemoryStorage("test:", 10*time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	manager := NewManager(storage, DefaultConfig().WithExpiration(20*time.Millisecond))
+
+	got := make(chan string, 1)
+	if err := manager.OnExpired(func(id string) { got <- id }); err != nil {
+		t.Fatalf("failed to register hook: %v", err)
+	}
+	if err := manager.OnExpired(nil); err != nil {
+		t.Fatalf("expected nil hook to be ignored, got %v", err)
+	}
+
+	_ = manager.SaveSession(manager.CreateSession("session-123"))
+
+	// Nobody loads the session again; GC discovers the expiration
+	select {
+	case id := <-got:
+		if id != "session-123" {
+			t.Errorf("expected 'session-123', got %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnExpired hook to fire")
+	}
+}
+
+func TestManagerOnExpiredOnLoad(t *testing.T) {
+	inner := NewMemoryStorage("test:", 0)
+	defer func() { _ = inner.Close() }()
+
+	// plainStorage does not implement ExpiryNotifier
+	manager := NewManager(&plainStorage{Storage: inner}, DefaultConfig())
+
+	var expired []string
+	_ = manager.OnExpired(func(id string) { expired = append(expired, id) })
+	_ = manager.OnExpired(func(id string) { panic("boom") })
+
+	session := manager.CreateSession("session-123")
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(session)
+	_ = inner.Set("session-123", data, time.Hour)
+
+	if loaded, _ := manager.LoadSession("session-123"); loaded != nil {
+		t.Fatal("expected expired session to not be returned")
+	}
+	if len(expired) != 1 || expired[0] != "session-123" {
+		t.Errorf("expected hook to fire for session-123, got %v", expired)
+	}
+}
+
+func TestManagerOnOperation(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+
+	manager := NewManager(storage, DefaultConfig().WithExpiration(time.Hour))
+	if start := manager.opStart(); !start.IsZero() {
+		t.Errorf("expected no start time without a hook, got %v", start)
+	}
+
+	var ops []observedOp
+	manager.OnOperation(func(op, id string, d time.Duration, err error) {
+		if d < 0 {
+			t.Errorf("expected a non-negative duration for %s, got %v", op, d)
+		}
+		ops = append(ops, observedOp{op, id, err})
+	})
+	manager.OnOperation(func(string, string, time.Duration, error) { panic("boom") })
+	manager.OnOperation(nil)
+
+	session := manager.CreateSession("a")
+	_ = manager.SaveSession(session)
+	_, _ = manager.LoadSession("a")
+	_ = manager.DeleteSession("a")
+	_, _ = manager.LoadSession("a")
+
+	session = manager.CreateSession("b")
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(session)
+	_ = storage.Set("b", data, time.Hour)
+	_, _ = manager.LoadSessionStrict("b")
+	_ = manager.DeleteSessions([]string{"b", "c"})
+
+	want := []observedOp{
+		{"create", "a", nil},
+		{"save", "a", nil},
+		{"load", "a", nil},
+		{"delete", "a", nil},
+		{"load", "a", ErrSessionNotFound},
+		{"create", "b", nil},
+		{"load", "b", ErrSessionExpired},
+		{"delete", "b", nil},
+		{"delete", "c", nil},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("expected %d operations, got %v", len(want), ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("operation %d: expected %v, got %v", i, want[i], ops[i])
+		}
+	}
+}
+
 func TestManagerGetStorage(t *testing.T) {
 	storage := NewMemoryStorage("test:", 0)
 	defer func() { _ = storage.Close() }()
@@ -292,6 +779,71 @@ func TestCreateCookie(t *testing.T) {
 	}
 }
 
+func TestCreateCookieLifetime(t *testing.T) {
+	config := DefaultConfig().WithCookieName("sid").WithExpiration(2 * time.Hour)
+
+	t.Run("persistent", func(t *testing.T) {
+		cookie := CreateCookie(config, "session-123")
+		if cookie.MaxAge != 7200 || cookie.SessionOnly {
+			t.Errorf("expected Max-Age 7200, got %d (session only %v)", cookie.MaxAge, cookie.SessionOnly)
+		}
+		if d := time.Until(cookie.Expires); d < time.Hour || d > 2*time.Hour {
+			t.Errorf("expected Expires in 2h, got %v", cookie.Expires)
+		}
+	})
+
+	t.Run("session only", func(t *testing.T) {
+		cookie := CreateCookie(config.WithSessionOnlyCookie(true), "session-123")
+		if !cookie.SessionOnly || cookie.MaxAge != 0 || !cookie.Expires.IsZero() {
+			t.Errorf("expected a session-only cookie, got %+v", cookie)
+		}
+
+		manager := NewManager(NewMemoryStorage("test:", 0), config.WithSessionOnlyCookie(true))
+		defer func() { _ = manager.GetStorage().Close() }()
+		if !manager.FiberSessionConfig().CookieSessionOnly {
+			t.Error("expected CookieSessionOnly to be true")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cookie := CreateExpiredCookie(config.WithSessionOnlyCookie(true))
+		if cookie.Name != "sid" || cookie.Value != "" || cookie.MaxAge >= 0 || cookie.SessionOnly {
+			t.Errorf("expected a deleting cookie, got %+v", cookie)
+		}
+		if !cookie.Expires.Before(time.Now()) {
+			t.Errorf("expected Expires in the past, got %v", cookie.Expires)
+		}
+	})
+}
+
+func TestCreateCookiePrefix(t *testing.T) {
+	config := DefaultConfig().WithHostPrefix().WithSecure(false).WithCookiePath("/app").WithCookieDomain("example.com")
+
+	if _, err := CreateCookieValidated(config, "session-123"); err == nil {
+		t.Error("expected an error for a non-compliant __Host- cookie")
+	}
+	cookie, err := CreateCookieValidated(DefaultConfig().WithHostPrefix(), "session-123")
+	if err != nil || cookie.Name != "__Host-session_id" {
+		t.Fatalf("expected a valid cookie, got %v, %v", cookie, err)
+	}
+
+	cookie = CreateCookie(config, "session-123")
+	if !cookie.Secure || cookie.Path != "/" || cookie.Domain != "" {
+		t.Errorf("expected the __Host- attributes to be corrected, got %+v", cookie)
+	}
+	cookie = CreateCookie(config.WithCookieName("__Secure-sid"), "session-123")
+	if !cookie.Secure || cookie.Path != "/app" || cookie.Domain != "example.com" {
+		t.Errorf("expected only Secure to be corrected, got %+v", cookie)
+	}
+
+	manager := NewManager(NewMemoryStorage("test:", 0), config)
+	defer func() { _ = manager.GetStorage().Close() }()
+	fiberCfg := manager.FiberSessionConfig()
+	if !fiberCfg.CookieSecure || fiberCfg.CookiePath != "/" || fiberCfg.CookieDomain != "" {
+		t.Errorf("expected the fiber config to be corrected, got %+v", fiberCfg)
+	}
+}
+
 func TestCreateCookieSameSiteVariants(t *testing.T) {
 	tests := []struct {
 		sameSite string
@@ -696,41 +1248,6 @@ func TestUnauthenticateNilSession(t *testing.T) {
 	}
 }
 
-func TestManagerSaveSessionStorageError(t *testing.T) {
-	base := NewMemoryStorage("test:", 0)
-	defer func() { _ = base.Close() }()
-
-	storage := &failingStorage{
-		Storage: base,
-		setErr:  errors.New("storage set failed"),
-	}
-	config := DefaultConfig()
-	manager := NewManager(storage, config)
-
-	session := manager.CreateSession("session-123")
-	err := manager.SaveSession(session)
-	if err == nil {
-		t.Error("expected error when storage.Set fails")
-	}
-}
-
-func TestManagerLoadSessionStorageGetError(t *testing.T) {
-	base := NewMemoryStorage("test:", 0)
-	defer func() { _ = base.Close() }()
-
-	storage := &failingStorage{
-		Storage: base,
-		getErr:  errors.New("storage get failed"),
-	}
-	config := DefaultConfig()
-	manager := NewManager(storage, config)
-
-	_, err := manager.LoadSession("any-id")
-	if err == nil {
-		t.Error("expected error when storage.Get fails")
-	}
-}
-
 func TestManagerSaveSessionMarshalError(t *testing.T) {
 	storage := NewMemoryStorage("test:", 0)
 	defer func() { _ = storage.Close() }()
@@ -779,3 +1296,1042 @@ func TestFiberSessionConfigSameSiteNoneForcesSecure(t *testing.T) {
 		t.Error("expected CookieSecure to be true when SameSite is None")
 	}
 }
+
+func TestFiberSessionAuthTime(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/test", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if !GetAuthTime(sess).IsZero() {
+			return c.SendString("auth time should not be set")
+		}
+		// Sessions authenticated before the auth time was recorded fall
+		// back to the creation time
+		sess.Set(KeyCreatedAt, int64(1000))
+		if GetAuthTime(sess).Unix() != 1000 {
+			return c.SendString("auth time should fall back to created at")
+		}
+		if err := Authenticate(sess); err != nil {
+			return err
+		}
+		if !GetAuthTime(sess).Equal(GetCreatedAt(sess)) {
+			return c.SendString("authenticate should set the auth time")
+		}
+		SetAuthTime(sess, time.Unix(2000, 0))
+		if GetAuthTime(sess).Unix() != 2000 || GetCreatedAt(sess).Unix() == 2000 {
+			return c.SendString("set auth time should only change the auth time")
+		}
+		sess.Set(KeyAuthTime, "wrong")
+		if !GetAuthTime(sess).IsZero() {
+			return c.SendString("auth time of the wrong type should be zero")
+		}
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/test", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Errorf("expected ok, got %s", body)
+	}
+}
+
+func TestFiberSessionAuthenticateRegeneratesID(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/visit", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("cart", "book")
+		return sess.Save()
+	})
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		cart, _ := sess.Get("cart").(string)
+		return c.SendString(cart)
+	})
+
+	sessionCookie := func(resp *http.Response) *http.Cookie {
+		t.Helper()
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == "session_id" {
+				return cookie
+			}
+		}
+		t.Fatal("expected a session cookie")
+		return nil
+	}
+	do := func(path string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to test %s: %v", path, err)
+		}
+		return resp
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/visit", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	before := sessionCookie(resp)
+	after := sessionCookie(do("/login", before))
+
+	if after.Value == before.Value {
+		t.Fatal("expected login to change the session ID")
+	}
+	if data, _ := storage.Get(before.Value); data != nil {
+		t.Error("expected the pre-login session to be deleted")
+	}
+	if resp := do("/me", before); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("expected the old session ID to be dead, got %d", resp.StatusCode)
+	}
+	resp = do("/me", after)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || string(body) != "book" {
+		t.Errorf("expected the new session with the pre-login data, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestFiberSessionAuthenticateWithOptions(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if err := AuthenticateWithOptions(sess, AuthOptions{}); err == nil || IsAuthenticated(sess) {
+			return c.SendString("empty user id should be rejected")
+		}
+		return AuthenticateWithOptions(sess, AuthOptions{
+			UserID: "user-1",
+			Email:  "user@example.com",
+			Phone:  "+15550100",
+			AMR:    []string{"pwd", "otp"},
+			Scopes: []string{"read"},
+			Roles:  []string{"admin"},
+			TTL:    30 * 24 * time.Hour,
+		})
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if !IsAuthenticated(sess) || GetCreatedAt(sess).IsZero() || GetAuthTime(sess).IsZero() || GetLastAccess(sess).IsZero() {
+			return c.SendString("missing authentication keys")
+		}
+		return c.JSON(AuthOptions{
+			UserID: GetUserID(sess),
+			Email:  GetEmail(sess),
+			Phone:  GetPhone(sess),
+			AMR:    GetAMR(sess),
+			Scopes: GetScopes(sess),
+			Roles:  GetRoles(sess),
+		})
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+	if ttl, _ := storage.GetTTL(cookie.Value); ttl <= 29*24*time.Hour {
+		t.Errorf("expected the custom TTL, got %v", ttl)
+	}
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(cookie)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	var got AuthOptions
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode the session fields: %v", err)
+	}
+	if got.UserID != "user-1" || got.Email != "user@example.com" || got.Phone != "+15550100" ||
+		len(got.AMR) != 2 || got.AMR[1] != "otp" || len(got.Scopes) != 1 || got.Scopes[0] != "read" ||
+		len(got.Roles) != 1 || got.Roles[0] != "admin" {
+		t.Errorf("expected the fields back, got %+v", got)
+	}
+}
+
+func TestFiberSessionFlashes(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Post("/save", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if err := AddFlash(sess, "info", "saved"); err != nil {
+			return err
+		}
+		// AddFlash released the session, so load it again
+		if sess, err = store.Get(c); err != nil {
+			return err
+		}
+		if err := AddFlash(sess, "error", "quota almost reached"); err != nil {
+			return err
+		}
+		if sess, err = store.Get(c); err != nil {
+			return err
+		}
+		return AddFlash(sess, "info", "synced")
+	})
+	app.Get("/page", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		flashes, err := ConsumeFlashes(sess)
+		if err != nil {
+			return err
+		}
+		return c.JSON(flashes)
+	})
+
+	var cookie *http.Cookie
+	resp, err := app.Test(httptest.NewRequest("POST", "/save", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("failed to add flashes: %v", err)
+	}
+	for _, c := range resp.Cookies() {
+		if c.Name == "session_id" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	consume := func() map[string][]string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/page", nil)
+		req.AddCookie(cookie)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to test: %v", err)
+		}
+		var flashes map[string][]string
+		if err := json.NewDecoder(resp.Body).Decode(&flashes); err != nil {
+			t.Fatalf("failed to decode flashes: %v", err)
+		}
+		return flashes
+	}
+	flashes := consume()
+	if len(flashes) != 2 || strings.Join(flashes["info"], ",") != "saved,synced" ||
+		len(flashes["error"]) != 1 || flashes["error"][0] != "quota almost reached" {
+		t.Errorf("expected the flashes by category, got %v", flashes)
+	}
+	if flashes := consume(); len(flashes) != 0 {
+		t.Errorf("expected no flashes on the second read, got %v", flashes)
+	}
+}
+
+func TestStringSlice(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want []string
+		ok   bool
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, true},
+		{[]interface{}{"a", "b"}, []string{"a", "b"}, true},
+		{[]interface{}{"a", 1}, nil, false},
+		{"a", nil, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := stringSlice(tt.val)
+		if ok != tt.ok || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("stringSlice(%v) = %v, %v; want %v, %v", tt.val, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+type testCart struct {
+	Items []testCartItem `json:"items"`
+	Owner struct {
+		ID string `json:"id"`
+	} `json:"owner"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type testCartItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+func TestFiberSessionJSON(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	updatedAt := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	app.Get("/set", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		cart := testCart{Items: []testCartItem{{SKU: "book", Quantity: 2}}, UpdatedAt: updatedAt}
+		cart.Owner.ID = "user-1"
+		if err := SetJSON(sess, "cart", cart); err != nil {
+			return err
+		}
+		if err := SetJSON(sess, "bad", func() {}); err == nil {
+			return c.SendString("expected a marshal error")
+		}
+		sess.Set("string", `{"sku":"pen","quantity":1}`)
+		sess.Set("number", 42)
+		sess.Set("corrupt", []byte("{"))
+		return sess.Save()
+	})
+	app.Get("/get", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		cart, ok, err := GetJSON[testCart](sess, "cart")
+		if err != nil || !ok || len(cart.Items) != 1 || cart.Items[0].Quantity != 2 ||
+			cart.Owner.ID != "user-1" || !cart.UpdatedAt.Equal(updatedAt) {
+			return c.SendString(fmt.Sprintf("unexpected cart %+v, %v, %v", cart, ok, err))
+		}
+		if item, ok, err := GetJSON[testCartItem](sess, "string"); err != nil || !ok || item.SKU != "pen" {
+			return c.SendString(fmt.Sprintf("unexpected string value %+v, %v, %v", item, ok, err))
+		}
+		if _, ok, err := GetJSON[testCart](sess, "missing"); ok || err != nil {
+			return c.SendString("missing key should not be an error")
+		}
+		if _, _, err := GetJSON[testCart](sess, "number"); err == nil {
+			return c.SendString("expected a type error")
+		}
+		if _, _, err := GetJSON[testCart](sess, "corrupt"); err == nil {
+			return c.SendString("expected an unmarshal error")
+		}
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/set", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/get", nil)
+	for _, c := range resp.Cookies() {
+		req.AddCookie(c)
+	}
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Error(string(body))
+	}
+}
+
+// keyCase checks the typed accessors for one session key: absent, holding a
+// T, and holding a value of another type.
+type keyCase struct {
+	key   string
+	check func(sess *fibersession.Session) error
+}
+
+func typedKeyCase[T any](key string, value T, wrong any, getter func(*fibersession.Session) T) keyCase {
+	return keyCase{key: key, check: func(sess *fibersession.Session) error {
+		var zero T
+		sess.Delete(key)
+		if v, ok, err := LookupKey[T](sess, key); ok || err != nil || !reflect.DeepEqual(v, zero) {
+			return fmt.Errorf("absent: got %v, %v, %v", v, ok, err)
+		}
+		if _, ok := GetKey[T](sess, key); ok {
+			return fmt.Errorf("absent: GetKey reported the key present")
+		}
+
+		SetKey(sess, key, value)
+		if v, ok, err := LookupKey[T](sess, key); !ok || err != nil || !reflect.DeepEqual(v, value) {
+			return fmt.Errorf("set: got %v, %v, %v", v, ok, err)
+		}
+		if v, ok := GetKey[T](sess, key); !ok || !reflect.DeepEqual(v, value) {
+			return fmt.Errorf("set: GetKey got %v, %v", v, ok)
+		}
+		if getter != nil && !reflect.DeepEqual(getter(sess), value) {
+			return fmt.Errorf("set: getter got %v", getter(sess))
+		}
+
+		sess.Set(key, wrong)
+		if v, ok, err := LookupKey[T](sess, key); ok || !errors.Is(err, ErrSessionValueType) || !reflect.DeepEqual(v, zero) {
+			return fmt.Errorf("wrong type: got %v, %v, %v", v, ok, err)
+		}
+		if _, ok := GetKey[T](sess, key); ok {
+			return fmt.Errorf("wrong type: GetKey reported the key present")
+		}
+		if getter != nil && !reflect.DeepEqual(getter(sess), zero) {
+			return fmt.Errorf("wrong type: getter got %v", getter(sess))
+		}
+		return nil
+	}}
+}
+
+func TestFiberSessionTypedKeys(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	unix := func(get func(*fibersession.Session) time.Time) func(*fibersession.Session) int64 {
+		return func(sess *fibersession.Session) int64 {
+			if ts := get(sess); !ts.IsZero() {
+				return ts.Unix()
+			}
+			return 0
+		}
+	}
+	cases := []keyCase{
+		typedKeyCase(KeyAuthenticated, true, "yes", IsAuthenticated),
+		typedKeyCase(KeyUserID, "user-1", 1, GetUserID),
+		typedKeyCase(KeyEmail, "user@example.com", 2, GetEmail),
+		typedKeyCase(KeyPhone, "+15555550100", 3, GetPhone),
+		typedKeyCase(KeyAMR, []string{"pwd", "otp"}, "pwd", GetAMR),
+		typedKeyCase(KeyScopes, []string{"read"}, 4, GetScopes),
+		typedKeyCase(KeyCreatedAt, int64(1700000000), "then", unix(GetCreatedAt)),
+		typedKeyCase(KeyLastAccess, int64(1700000100), "now", unix(GetLastAccess)),
+		typedKeyCase(KeyAuthTime, int64(1700000200), 5.0, nil),
+		typedKeyCase("tenant", "acme", []byte("acme"), nil),
+	}
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		for _, tc := range cases {
+			if err := tc.check(sess); err != nil {
+				return c.SendString(tc.key + ": " + err.Error())
+			}
+		}
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Error(string(body))
+	}
+}
+
+func TestFiberSessionRoles(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if GetRoles(sess) != nil || HasRole(sess, "admin") {
+			return c.SendString("expected no roles")
+		}
+
+		AddRole(sess, "admin")
+		AddRole(sess, "editor")
+		AddRole(sess, "admin")
+		if roles := GetRoles(sess); len(roles) != 2 || roles[0] != "admin" || roles[1] != "editor" {
+			return c.SendString(fmt.Sprintf("unexpected roles %v", roles))
+		}
+		if !HasRole(sess, "editor") || HasRole(sess, "viewer") {
+			return c.SendString("unexpected HasRole result")
+		}
+
+		sess.Set(KeyRoles, []interface{}{"viewer", "editor"})
+		if roles := GetRoles(sess); len(roles) != 2 || !HasRole(sess, "viewer") {
+			return c.SendString(fmt.Sprintf("expected decoded roles, got %v", roles))
+		}
+		AddRole(sess, "admin")
+		if roles := GetRoles(sess); len(roles) != 3 || roles[2] != "admin" {
+			return c.SendString(fmt.Sprintf("unexpected roles after AddRole %v", roles))
+		}
+
+		for _, wrong := range []interface{}{"admin", 1, []interface{}{"admin", 2}} {
+			sess.Set(KeyRoles, wrong)
+			if GetRoles(sess) != nil || HasRole(sess, "admin") {
+				return c.SendString(fmt.Sprintf("expected no roles for %#v", wrong))
+			}
+		}
+
+		SetRoles(sess, []string{"admin"})
+		if err := Unauthenticate(sess); err != nil {
+			return err
+		}
+		if GetRoles(sess) != nil {
+			return c.SendString("expected Unauthenticate to clear the roles")
+		}
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Error(string(body))
+	}
+}
+
+func TestUnauthenticateAndRotate(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("locale", "fr")
+		sess.Set("csrf", "token-1")
+		return AuthenticateWithOptions(sess, AuthOptions{UserID: "user-1"})
+	})
+	app.Get("/logout", func(c *fiber.Ctx) error {
+		sess, err := UnauthenticateAndRotate(c, store, "locale", "csrf", "missing")
+		if err != nil {
+			return err
+		}
+		if IsAuthenticated(sess) || GetUserID(sess) != "" || sess.Get("locale") != "fr" {
+			return c.SendString("unexpected new session")
+		}
+		return AddFlash(sess, "info", "logged out")
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if IsAuthenticated(sess) || GetUserID(sess) != "" {
+			return c.SendString("expected an anonymous session")
+		}
+		if sess.Get("locale") != "fr" || sess.Get("csrf") != "token-1" || sess.Get("missing") != nil {
+			return c.SendString("expected the kept keys only")
+		}
+		flashes, err := ConsumeFlashes(sess)
+		if err != nil {
+			return err
+		}
+		if len(flashes["info"]) != 1 {
+			return c.SendString(fmt.Sprintf("expected the logout flash, got %v", flashes))
+		}
+		return c.SendString("ok")
+	})
+
+	sessionCookie := func(resp *http.Response) *http.Cookie {
+		for _, c := range resp.Cookies() {
+			if c.Name == "session_id" {
+				return c
+			}
+		}
+		t.Fatal("expected a session cookie")
+		return nil
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	oldCookie := sessionCookie(resp)
+
+	req := httptest.NewRequest("GET", "/logout", nil)
+	req.AddCookie(oldCookie)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to log out: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("unexpected logout response %d %s", resp.StatusCode, body)
+	}
+	newCookie := sessionCookie(resp)
+	if newCookie.Value == "" || newCookie.Value == oldCookie.Value || newCookie.MaxAge <= 0 {
+		t.Fatalf("expected a new live session cookie, got %+v", newCookie)
+	}
+	if data, _ := storage.Get(oldCookie.Value); data != nil {
+		t.Error("expected the old session to be deleted")
+	}
+
+	req = httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(newCookie)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Error(string(body))
+	}
+}
+
+func TestAuthenticateWithContext(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		SetUserID(sess, "user-1")
+		return AuthenticateWithContext(c, sess, c.QueryInt("proxies", 0))
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(FromFiberSession(sess))
+	})
+
+	remoteIP := func() string {
+		var ip string
+		app.Get("/ip", func(c *fiber.Ctx) error {
+			ip = c.IP()
+			return nil
+		})
+		if _, err := app.Test(httptest.NewRequest("GET", "/ip", nil)); err != nil {
+			t.Fatalf("failed to test: %v", err)
+		}
+		return ip
+	}()
+	if remoteIP == "" {
+		t.Fatal("expected the test connection to have a remote address")
+	}
+
+	tests := []struct {
+		name      string
+		proxies   int
+		forwarded string
+		wantIP    string
+	}{
+		{name: "without proxy", wantIP: remoteIP},
+		{name: "untrusted header", forwarded: "198.51.100.1", wantIP: remoteIP},
+		{name: "one proxy", proxies: 1, forwarded: "192.0.2.9, 198.51.100.1", wantIP: "198.51.100.1"},
+		{name: "two proxies", proxies: 2, forwarded: "192.0.2.9, 198.51.100.1", wantIP: "192.0.2.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", fmt.Sprintf("/login?proxies=%d", tt.proxies), nil)
+			req.Header.Set("User-Agent", "test-agent/1.0")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			resp, err := app.Test(req)
+			if err != nil || resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("failed to log in: %v", err)
+			}
+
+			req = httptest.NewRequest("GET", "/me", nil)
+			for _, c := range resp.Cookies() {
+				req.AddCookie(c)
+			}
+			resp, err = app.Test(req)
+			if err != nil {
+				t.Fatalf("failed to test: %v", err)
+			}
+			var data SessionData
+			if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+				t.Fatalf("failed to decode the session: %v", err)
+			}
+			if data.IPAddress != tt.wantIP || data.UserAgent != "test-agent/1.0" {
+				t.Errorf("expected %s and the user agent, got %q and %q", tt.wantIP, data.IPAddress, data.UserAgent)
+			}
+			if !data.Authenticated || data.UserID != "user-1" || data.ID == "" || data.CreatedAt.IsZero() {
+				t.Errorf("expected the session fields, got %+v", data)
+			}
+		})
+	}
+}
+
+func TestFiberSessionLenientValues(t *testing.T) {
+	app := fiber.New()
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	store := fibersession.New(fibersession.Config{Storage: storage, Expiration: time.Hour})
+
+	const unix = 1700000000
+	timestamps := []struct {
+		name  string
+		value interface{}
+		want  int64
+	}{
+		{name: "int64", value: int64(unix), want: unix},
+		{name: "int", value: unix, want: unix},
+		{name: "float64", value: float64(unix), want: unix},
+		{name: "fractional float64", value: unix + 0.75, want: unix},
+		{name: "json.Number", value: json.Number("1700000000"), want: unix},
+		{name: "float json.Number", value: json.Number("1.7e9"), want: unix},
+		{name: "NaN", value: math.NaN()},
+		{name: "infinity", value: math.Inf(1)},
+		{name: "out of range", value: 1e19},
+		{name: "invalid json.Number", value: json.Number("soon")},
+		{name: "string", value: "1700000000"},
+	}
+	flags := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{name: "true", value: true, want: true},
+		{name: "false", value: false},
+		{name: "string true", value: "true", want: true},
+		{name: "string 1", value: "1", want: true},
+		{name: "string false", value: "false"},
+		{name: "invalid string", value: "yes"},
+		{name: "int 1", value: 1, want: true},
+		{name: "int64 1", value: int64(1), want: true},
+		{name: "float64 1", value: 1.0, want: true},
+		{name: "json.Number 1", value: json.Number("1"), want: true},
+		{name: "int 0", value: 0},
+		{name: "int 2", value: 2},
+	}
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		var failures []string
+		for _, tt := range timestamps {
+			want := time.Time{}
+			if tt.want != 0 {
+				want = time.Unix(tt.want, 0)
+			}
+			for _, key := range []string{KeyCreatedAt, KeyLastAccess, KeyAuthTime} {
+				sess.Set(key, tt.value)
+			}
+			if got := GetCreatedAt(sess); !got.Equal(want) {
+				failures = append(failures, fmt.Sprintf("GetCreatedAt(%s) = %v", tt.name, got))
+			}
+			if got := GetLastAccess(sess); !got.Equal(want) {
+				failures = append(failures, fmt.Sprintf("GetLastAccess(%s) = %v", tt.name, got))
+			}
+			if got := GetAuthTime(sess); !got.Equal(want) {
+				failures = append(failures, fmt.Sprintf("GetAuthTime(%s) = %v", tt.name, got))
+			}
+		}
+		for _, tt := range flags {
+			sess.Set(KeyAuthenticated, tt.value)
+			if got := IsAuthenticated(sess); got != tt.want {
+				failures = append(failures, fmt.Sprintf("IsAuthenticated(%s) = %v", tt.name, got))
+			}
+		}
+		return c.SendString(strings.Join(failures, "\n"))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("failed to test: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); len(body) > 0 {
+		t.Error(string(body))
+	}
+}
+
+func TestManagerAnonymousExpiration(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().
+		WithExpiration(30*24*time.Hour).
+		WithAnonymousExpiration(2*time.Hour))
+
+	ttlOf := func(id string) time.Duration {
+		t.Helper()
+		ttl, err := storage.GetTTL(id)
+		if err != nil {
+			t.Fatalf("failed to get ttl: %v", err)
+		}
+		return ttl
+	}
+
+	session := manager.CreateSession("anon")
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if ttl := ttlOf("anon"); ttl > 2*time.Hour || ttl < time.Hour {
+		t.Fatalf("expected the anonymous expiration, got %v", ttl)
+	}
+
+	session.Authenticated = true
+	session.UserID = "user-1"
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if ttl := ttlOf("anon"); ttl < 29*24*time.Hour {
+		t.Errorf("expected login to extend the session, got %v", ttl)
+	}
+	if time.Until(session.ExpiresAt) < 29*24*time.Hour {
+		t.Errorf("expected ExpiresAt to be extended, got %v", session.ExpiresAt)
+	}
+
+	// Later saves of the authenticated session keep its expiration
+	session.ExpiresAt = time.Now().Add(10 * 24 * time.Hour)
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if ttl := ttlOf("anon"); ttl > 11*24*time.Hour {
+		t.Errorf("expected an authenticated save not to extend the session, got %v", ttl)
+	}
+
+	// Without AnonymousExpiration every session gets Expiration
+	single := NewManager(storage, DefaultConfig().WithExpiration(30*24*time.Hour))
+	if err := single.SaveSession(single.CreateSession("single")); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if ttl := ttlOf("single"); ttl < 29*24*time.Hour {
+		t.Errorf("expected the single expiration, got %v", ttl)
+	}
+}
+
+func TestManagerAnonymousExpirationAuthenticatedSave(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	manager := NewManager(NewInstrumentedStorage(memory, rec.observe), DefaultConfig().
+		WithExpiration(30*24*time.Hour).
+		WithAnonymousExpiration(2*time.Hour))
+
+	session := manager.CreateSession("session-123")
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	session.Authenticated = true
+	rec.reset()
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if n := rec.count("get"); n != 1 {
+		t.Errorf("expected the login save to read the stored session, got %d reads", n)
+	}
+
+	// The Manager knows the session is stored as authenticated
+	rec.reset()
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	loaded, err := manager.LoadSession("session-123")
+	if err != nil || loaded == nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if err := manager.SaveSession(loaded); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if n := rec.count("get"); n != 1 {
+		t.Errorf("expected only the load to read the stored session, got %d reads", n)
+	}
+
+	// Sessions the Manager did not load are still checked
+	rec.reset()
+	if err := manager.SaveSession(&SessionData{ID: "session-123", Authenticated: true}); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if n := rec.count("get"); n != 1 {
+		t.Errorf("expected a save of an unknown session to read it, got %d reads", n)
+	}
+}
+
+func TestManagerSaveFiberSessionAnonymousExpiration(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().
+		WithExpiration(30*24*time.Hour).
+		WithAnonymousExpiration(2*time.Hour))
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Get("/browse", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("cart", "book")
+		return manager.SaveFiberSession(sess)
+	})
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return Authenticate(sess)
+	})
+	app.Get("/touch", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		return manager.SaveFiberSession(sess)
+	})
+
+	request := func(path string, cookie *http.Cookie) *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("request %s failed: %v", path, err)
+		}
+		for _, c := range resp.Cookies() {
+			if c.Name == "session_id" {
+				return c
+			}
+		}
+		t.Fatalf("expected a session cookie from %s", path)
+		return nil
+	}
+
+	cookie := request("/browse", nil)
+	if ttl, _ := storage.GetTTL(cookie.Value); ttl > 2*time.Hour || ttl < time.Hour {
+		t.Fatalf("expected the anonymous expiration, got %v", ttl)
+	}
+	cookie = request("/login", cookie)
+	if ttl, _ := storage.GetTTL(cookie.Value); ttl < 29*24*time.Hour {
+		t.Errorf("expected login to extend the session, got %v", ttl)
+	}
+	cookie = request("/touch", cookie)
+	if ttl, _ := storage.GetTTL(cookie.Value); ttl < 29*24*time.Hour {
+		t.Errorf("expected an authenticated save to keep the full expiration, got %v", ttl)
+	}
+}
+
+func TestManagerTouchInterval(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	config := DefaultConfig().WithExpiration(time.Hour).WithTouchInterval(10 * time.Minute)
+	manager := NewManager(NewInstrumentedStorage(memory, rec.observe), config)
+
+	sess := manager.CreateSession("session-123")
+	if err := manager.SaveSession(sess); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	rec.reset()
+
+	expiresAt, lastAccess := sess.ExpiresAt, sess.LastAccessedAt
+	time.Sleep(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := manager.TouchSession(sess); err != nil {
+			t.Fatalf("failed to touch session: %v", err)
+		}
+	}
+	if n := rec.count("set") + rec.count("touch"); n != 0 {
+		t.Errorf("expected no write within the touch interval, got %d", n)
+	}
+	if !sess.LastAccessedAt.After(lastAccess) || !sess.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected only LastAccessedAt to be updated, got %+v", sess)
+	}
+
+	// As if the last write was 15 minutes ago
+	sess.ExpiresAt = time.Now().Add(45 * time.Minute)
+	if err := manager.TouchSession(sess); err != nil {
+		t.Fatalf("failed to touch session: %v", err)
+	}
+	if n := rec.count("set") + rec.count("touch"); n != 1 {
+		t.Errorf("expected a write past the touch interval, got %d", n)
+	}
+	if time.Until(sess.ExpiresAt) < 59*time.Minute {
+		t.Errorf("expected the expiration to be extended, got %v", sess.ExpiresAt)
+	}
+
+	if err := DefaultConfig().WithExpiration(time.Hour).WithTouchInterval(time.Hour).Validate(); err == nil {
+		t.Error("expected an error for a touch interval not lower than the expiration")
+	}
+	if err := config.WithAnonymousExpiration(5 * time.Minute).Validate(); err == nil {
+		t.Error("expected an error for a touch interval not lower than the anonymous expiration")
+	}
+}
+
+func TestManagerSlidingExpirationTouchInterval(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	config := DefaultConfig().
+		WithExpiration(100 * time.Millisecond).
+		WithTouchInterval(40 * time.Millisecond).
+		WithSlidingExpiration(true)
+	manager := NewManager(NewInstrumentedStorage(memory, rec.observe), config)
+
+	if err := manager.SaveSession(manager.CreateSession("session-123")); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	rec.reset()
+
+	// Skipped writes never let an active session expire: each request
+	// leaves it at least Expiration - TouchInterval to live
+	const loads = 10
+	for i := 0; i < loads; i++ {
+		time.Sleep(20 * time.Millisecond)
+		loaded, err := manager.LoadSession("session-123")
+		if err != nil || loaded == nil {
+			t.Fatalf("expected session to still exist after %d loads, got %v", i, err)
+		}
+	}
+	if n := rec.count("set") + rec.count("touch"); n == 0 || n >= loads {
+		t.Errorf("expected fewer writes than loads, got %d for %d loads", n, loads)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if loaded, _ := manager.LoadSession("session-123"); loaded != nil {
+		t.Error("expected idle session to expire")
+	}
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+// SessionWriter records changes to a fiber session for Apply.
+type SessionWriter struct {
+	changes []sessionChange
+}
+
+// sessionChange is a change recorded by SessionWriter. A nil value deletes
+// the key.
+type sessionChange struct {
+	key   string
+	value interface{}
+}
+
+// UserID records setting the user ID.
+func (w *SessionWriter) UserID(userID string) {
+	w.Set(KeyUserID, userID)
+}
+
+// Email records setting the email.
+func (w *SessionWriter) Email(email string) {
+	w.Set(KeyEmail, email)
+}
+
+// Phone records setting the phone.
+func (w *SessionWriter) Phone(phone string) {
+	w.Set(KeyPhone, phone)
+}
+
+// AMR records setting the authentication methods references.
+func (w *SessionWriter) AMR(amr []string) {
+	w.Set(KeyAMR, amr)
+}
+
+// Scopes records setting the authorization scopes.
+func (w *SessionWriter) Scopes(scopes []string) {
+	w.Set(KeyScopes, scopes)
+}
+
+// Roles records setting the roles of the user.
+func (w *SessionWriter) Roles(roles []string) {
+	w.Set(KeyRoles, roles)
+}
+
+// Set records setting key to value. A nil value deletes the key.
+func (w *SessionWriter) Set(key string, value interface{}) {
+	w.changes = append(w.changes, sessionChange{key: key, value: value})
+}
+
+// Delete records deleting key.
+func (w *SessionWriter) Delete(key string) {
+	w.Set(key, nil)
+}
+
+// Apply calls fn to record changes to a fiber session, then makes them in
+// order and saves the session once, returning the error of Save. Like Save,
+// it releases the session.
+//
+// The session is not modified while fn runs: if fn panics, none of its
+// changes are made, the session is not saved and the panic propagates, so
+// the session is never left half-written.
+func Apply(session *fibersession.Session, fn func(w *SessionWriter)) error {
+	var w SessionWriter
+	fn(&w)
+	for _, change := range w.changes {
+		if change.value == nil {
+			session.Delete(change.key)
+		} else {
+			session.Set(change.key, change.value)
+		}
+	}
+	return saveFiberSession(session)
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestApply(t *testing.T) {
+	memory := NewMemoryStorage("test:", 0)
+	defer func() { _ = memory.Close() }()
+	rec := &opRecorder{}
+	store := fibersession.New(fibersession.Config{Storage: NewInstrumentedStorage(memory, rec.observe), Expiration: time.Hour})
+
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("stale", "value")
+		return Apply(sess, func(w *SessionWriter) {
+			w.UserID("user-1")
+			w.Email("user@example.com")
+			w.Phone("+15550100")
+			w.AMR([]string{"pwd"})
+			w.Scopes([]string{"read"})
+			w.Roles([]string{"admin"})
+			w.Set("locale", "fr")
+			w.Delete("stale")
+		})
+	})
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		func() {
+			defer func() { _ = recover() }()
+			_ = Apply(sess, func(w *SessionWriter) {
+				w.UserID("intruder")
+				panic("boom")
+			})
+		}()
+		if GetUserID(sess) != "user-1" {
+			return c.SendString("expected the session to be unchanged after a panic")
+		}
+		return c.SendString("ok")
+	})
+	app.Get("/me", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		if sess.Get("stale") != nil || sess.Get("locale") != "fr" || !HasRole(sess, "admin") {
+			return c.SendString("unexpected session keys")
+		}
+		return c.SendString(GetUserID(sess) + " " + GetEmail(sess) + " " + GetPhone(sess) +
+			" " + strings.Join(GetAMR(sess), ",") + " " + strings.Join(GetScopes(sess), ","))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/login", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	if n := rec.count("set"); n != 1 {
+		t.Errorf("expected a single storage write, got %d", n)
+	}
+	cookies := resp.Cookies()
+
+	for path, want := range map[string]string{
+		"/me":    "user-1 user@example.com +15550100 pwd read",
+		"/panic": "ok",
+	} {
+		rec.reset()
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("failed to request %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != want {
+			t.Errorf("%s: expected %q, got %q", path, want, body)
+		}
+		if n := rec.count("set"); n != 0 {
+			t.Errorf("%s: expected no storage write, got %d", path, n)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package sessionmetrics exports session lifecycle metrics to Prometheus.
+// Collector counts created, loaded and deleted sessions, fed by
+// Manager.OnOperation and the KVManager observer, and records the duration
+// of storage operations labelled with the backend:
+//
+//	collector, err := sessionmetrics.NewCollector(prometheus.DefaultRegisterer, sessionprom.DefaultOptions())
+//	storage := session.NewInstrumentedStorage(redisStorage, collector.ObserveStorage("redis"))
+//	manager := session.NewManager(storage, config)
+//	manager.OnOperation(collector.ObserveManager)
+package sessionmetrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessionprom"
+)
+
+// Values of the result label of the loaded sessions counter.
+const (
+	LoadHit     = "hit"
+	LoadMiss    = "miss"
+	LoadExpired = "expired"
+)
+
+// Collector counts session lifecycle events and records the duration of
+// storage operations per backend:
+//
+//	<namespace>_sessions_created_total
+//	<namespace>_sessions_loaded_total{result="hit|miss|expired"}
+//	<namespace>_sessions_deleted_total
+//	<namespace>_storage_op_duration_seconds{op,backend}
+//
+// Its methods match the observers of Manager, KVManager and
+// InstrumentedStorage, so a single Collector can be shared by all of them:
+//
+//	collector, err := sessionmetrics.NewCollector(prometheus.DefaultRegisterer, sessionprom.DefaultOptions())
+//	storage := session.NewInstrumentedStorage(redisStorage, collector.ObserveStorage("redis"))
+//	manager := session.NewManager(storage, config)
+//	manager.OnOperation(collector.ObserveManager)
+type Collector struct {
+	created prometheus.Counter
+	loaded  *prometheus.CounterVec
+	deleted prometheus.Counter
+	storage *prometheus.HistogramVec
+}
+
+// NewCollector creates a Collector using the namespace and buckets of opts,
+// shared with sessionprom.Metrics, and registers its metrics with reg, or
+// prometheus.DefaultRegisterer if reg is nil.
+func NewCollector(reg prometheus.Registerer, opts sessionprom.Options) (*Collector, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	c := &Collector{
+		created: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "sessions_created_total",
+			Help:      "Number of sessions created.",
+		}),
+		loaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "sessions_loaded_total",
+			Help:      "Number of session loads by result.",
+		}, []string{"result"}),
+		deleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "sessions_deleted_total",
+			Help:      "Number of sessions deleted.",
+		}),
+		storage: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "storage_op_duration_seconds",
+			Help:      "Duration of session storage operations by backend.",
+			Buckets:   buckets,
+		}, []string{"op", "backend"}),
+	}
+	// Export every result from the start, so rates need no absent() guards
+	for _, result := range []string{LoadHit, LoadMiss, LoadExpired} {
+		c.loaded.WithLabelValues(result)
+	}
+	for _, collector := range []prometheus.Collector{c.created, c.loaded, c.deleted, c.storage} {
+		if err := reg.Register(collector); err != nil {
+			return nil, fmt.Errorf("register session collector: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// ObserveManager records a Manager operation. Its signature matches
+// session.Manager.OnOperation. Loads failing for other reasons than a
+// missing or expired session, and failed creates and deletes, are not
+// counted.
+func (c *Collector) ObserveManager(op, id string, d time.Duration, err error) {
+	switch op {
+	case "create":
+		c.count(c.created, err)
+	case "load":
+		c.observeLoad(err)
+	case "delete":
+		c.count(c.deleted, err)
+	}
+}
+
+// ObserveKV records a KVManager operation. Its signature matches
+// session.KVManagerOptions.Observer. Gets count as loads; KV stores do not
+// tell expired sessions from missing ones, so they count as misses.
+func (c *Collector) ObserveKV(op, id string, d time.Duration, err error) {
+	switch op {
+	case "create":
+		c.count(c.created, err)
+	case "get":
+		c.observeLoad(err)
+	case "delete":
+		c.count(c.deleted, err)
+	}
+}
+
+// ObserveStorage returns an observer recording the operations of a Storage
+// under the given backend label, e.g. "redis". It matches the observers of
+// session.NewInstrumentedStorage and RedisStorage.WithLatencyObserver.
+func (c *Collector) ObserveStorage(backend string) func(op string, d time.Duration, err error) {
+	return func(op string, d time.Duration, err error) {
+		c.storage.WithLabelValues(op, backend).Observe(d.Seconds())
+	}
+}
+
+// count increments counter if err is nil.
+func (c *Collector) count(counter prometheus.Counter, err error) {
+	if err == nil {
+		counter.Inc()
+	}
+}
+
+// observeLoad counts a load by its result.
+func (c *Collector) observeLoad(err error) {
+	switch {
+	case err == nil:
+		c.loaded.WithLabelValues(LoadHit).Inc()
+	case errors.Is(err, session.ErrSessionExpired):
+		c.loaded.WithLabelValues(LoadExpired).Inc()
+	case errors.Is(err, session.ErrSessionNotFound):
+		c.loaded.WithLabelValues(LoadMiss).Inc()
+	}
+}
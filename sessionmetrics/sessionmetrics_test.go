@@ -0,0 +1,114 @@
+package sessionmetrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessionmetrics"
+	"github.com/soulteary/session-kit/sessionprom"
+)
+
+func TestCollectorManager(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	collector, err := sessionmetrics.NewCollector(reg, sessionprom.DefaultOptions().WithNamespace("app"))
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+
+	inner := session.NewMemoryStorage("metrics:", 0)
+	defer func() { _ = inner.Close() }()
+	storage := session.NewInstrumentedStorage(inner, collector.ObserveStorage("memory"))
+	manager := session.NewManager(storage, session.DefaultConfig().WithExpiration(time.Hour))
+	manager.OnOperation(collector.ObserveManager)
+
+	// Two sessions, one of which has expired by the time it is loaded
+	_ = manager.SaveSession(manager.CreateSession("a"))
+	expired := manager.CreateSession("b")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(expired)
+	_ = inner.Set("b", data, time.Hour)
+
+	_, _ = manager.LoadSession("a")
+	_, _ = manager.LoadSession("a")
+	_, _ = manager.LoadSession("b")
+	_ = manager.DeleteSession("a")
+	_, _ = manager.LoadSession("a")
+
+	want := `
+# HELP app_sessions_created_total Number of sessions created.
+# TYPE app_sessions_created_total counter
+app_sessions_created_total 2
+# HELP app_sessions_deleted_total Number of sessions deleted.
+# TYPE app_sessions_deleted_total counter
+app_sessions_deleted_total 1
+# HELP app_sessions_loaded_total Number of session loads by result.
+# TYPE app_sessions_loaded_total counter
+app_sessions_loaded_total{result="expired"} 1
+app_sessions_loaded_total{result="hit"} 2
+app_sessions_loaded_total{result="miss"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"app_sessions_created_total", "app_sessions_deleted_total", "app_sessions_loaded_total"); err != nil {
+		t.Error(err)
+	}
+
+	// get, set and delete on the memory backend
+	if n, err := testutil.GatherAndCount(reg, "app_storage_op_duration_seconds"); err != nil || n != 3 {
+		t.Errorf("expected 3 storage series, got %d (%v)", n, err)
+	}
+
+	if _, err := sessionmetrics.NewCollector(reg, sessionprom.DefaultOptions().WithNamespace("app")); err == nil {
+		t.Error("expected an error registering the collector twice")
+	}
+}
+
+func TestCollectorKV(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	// The Collector and sessionprom.Metrics do not clash on the same registry
+	reg := prometheus.NewRegistry()
+	collector, err := sessionmetrics.NewCollector(reg, sessionprom.DefaultOptions())
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+	if _, err := sessionprom.New(reg); err != nil {
+		t.Fatalf("failed to register metrics next to the collector: %v", err)
+	}
+	mgr := session.NewKVManagerWithOptions(session.NewRedisStore(client, "metrics:"),
+		session.DefaultKVManagerOptions().WithObserver(collector.ObserveKV))
+
+	ctx := context.Background()
+	id, _ := mgr.Create(ctx, nil, time.Minute)
+	_, _ = mgr.Get(ctx, id)
+	_ = mgr.Delete(ctx, id)
+	_, _ = mgr.Get(ctx, id)
+
+	want := `
+# HELP session_sessions_created_total Number of sessions created.
+# TYPE session_sessions_created_total counter
+session_sessions_created_total 1
+# HELP session_sessions_deleted_total Number of sessions deleted.
+# TYPE session_sessions_deleted_total counter
+session_sessions_deleted_total 1
+# HELP session_sessions_loaded_total Number of session loads by result.
+# TYPE session_sessions_loaded_total counter
+session_sessions_loaded_total{result="expired"} 0
+session_sessions_loaded_total{result="hit"} 1
+session_sessions_loaded_total{result="miss"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"session_sessions_created_total", "session_sessions_deleted_total", "session_sessions_loaded_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package sessionotel traces the operations of this module with
+// OpenTelemetry. NewTracedStorage starts a span for every Storage operation
+// and NewTracedManager for every session loaded, saved or deleted through
+// it:
+//
+//	tracer := otel.Tracer("myapp")
+//	storage := sessionotel.NewTracedStorage(redisStorage, tracer)
+//	manager := sessionotel.NewTracedManager(session.NewManager(storage, config), tracer)
+//	sess, err := manager.LoadSession(c.UserContext(), id)
+//
+// Spans carry the backend and key prefix of the storage, whether a load
+// found the session and the size of the payloads read and written. Keys
+// are never recorded, since they usually contain session IDs. Manager
+// spans are children of the span in the context they are called with, and
+// the get, set and delete spans of the storage are children of the Manager
+// span.
+package sessionotel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// ScopeName is the instrumentation scope of the tracer used when none is
+// given.
+const ScopeName = "github.com/soulteary/session-kit/sessionotel"
+
+// Attribute keys of the spans.
+const (
+	// AttrBackend is the storage backend: memory, redis, file, sqlite, bolt
+	// or other.
+	AttrBackend = attribute.Key("session.storage.backend")
+	// AttrKeyPrefix is the key prefix of the storage, if it has one.
+	AttrKeyPrefix = attribute.Key("session.storage.key_prefix")
+	// AttrHit reports whether a get or load found the value or session.
+	AttrHit = attribute.Key("session.hit")
+	// AttrPayloadSize is the size in bytes of the value read or written.
+	AttrPayloadSize = attribute.Key("session.payload.size")
+)
+
+// Names of the spans of Manager operations. Storage operations are named
+// "session.storage." followed by the operation, e.g. "session.storage.get".
+const (
+	SpanLoad   = "session.load"
+	SpanSave   = "session.save"
+	SpanDelete = "session.delete"
+)
+
+// TracedManager wraps a Manager and starts a span for every session it
+// loads, saves or deletes, passing the context of the span to the Manager.
+// Load spans report whether the session was found; missing and expired
+// sessions are not errors. Payload sizes are recorded by the spans of a
+// TracedStorage.
+type TracedManager struct {
+	manager *session.Manager
+	tracer  trace.Tracer
+	attrs   []attribute.KeyValue
+}
+
+// NewTracedManager wraps m so that its operations are traced with tracer,
+// or a tracer of the global provider if tracer is nil.
+func NewTracedManager(m *session.Manager, tracer trace.Tracer) *TracedManager {
+	if tracer == nil {
+		tracer = otel.Tracer(ScopeName)
+	}
+	return &TracedManager{manager: m, tracer: tracer, attrs: storageAttributes(m.GetStorage())}
+}
+
+// Manager returns the wrapped Manager.
+func (t *TracedManager) Manager() *session.Manager {
+	return t.manager
+}
+
+// start starts the span of a Manager operation as a child of the span in
+// ctx.
+func (t *TracedManager) start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name, trace.WithAttributes(t.attrs...))
+}
+
+// LoadSession is Manager.LoadSession, traced as a child of the span in ctx.
+func (t *TracedManager) LoadSession(ctx context.Context, id string) (*session.SessionData, error) {
+	s, err := t.LoadSessionStrict(ctx, id)
+	if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired) {
+		return nil, nil
+	}
+	return s, err
+}
+
+// LoadSessionStrict is Manager.LoadSessionStrict, traced as a child of the
+// span in ctx.
+func (t *TracedManager) LoadSessionStrict(ctx context.Context, id string) (*session.SessionData, error) {
+	ctx, span := t.start(ctx, SpanLoad)
+	s, err := t.manager.LoadSessionStrictWithContext(ctx, id)
+	span.SetAttributes(AttrHit.Bool(err == nil))
+	if errors.Is(err, session.ErrSessionNotFound) || errors.Is(err, session.ErrSessionExpired) {
+		end(span, nil)
+	} else {
+		end(span, err)
+	}
+	return s, err
+}
+
+// SaveSession is Manager.SaveSession, traced as a child of the span in ctx.
+func (t *TracedManager) SaveSession(ctx context.Context, s *session.SessionData) error {
+	ctx, span := t.start(ctx, SpanSave)
+	err := t.manager.SaveSessionWithContext(ctx, s)
+	end(span, err)
+	return err
+}
+
+// DeleteSession is Manager.DeleteSession, traced as a child of the span in
+// ctx.
+func (t *TracedManager) DeleteSession(ctx context.Context, id string) error {
+	ctx, span := t.start(ctx, SpanDelete)
+	err := t.manager.DeleteSessionWithContext(ctx, id)
+	end(span, err)
+	return err
+}
+
+// storageAttributes returns the backend and key prefix attributes of s,
+// looking through wrappers that implement Unwrap.
+func storageAttributes(s session.Storage) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{AttrBackend.String(backend(s))}
+	for s != nil {
+		if p, ok := s.(interface{ GetKeyPrefix() string }); ok {
+			return append(attrs, AttrKeyPrefix.String(p.GetKeyPrefix()))
+		}
+		s = unwrap(s)
+	}
+	return attrs
+}
+
+// backend returns the name of the backend of s, looking through wrappers
+// that implement Unwrap.
+func backend(s session.Storage) string {
+	for s != nil {
+		switch s.(type) {
+		case *session.MemoryStorage:
+			return string(session.StorageTypeMemory)
+		case *session.RedisStorage, *session.RedisHashStorage:
+			return string(session.StorageTypeRedis)
+		case *session.FileStorage:
+			return string(session.StorageTypeFile)
+		case *session.SQLiteStorage:
+			return string(session.StorageTypeSQLite)
+		case *session.BoltStorage:
+			return string(session.StorageTypeBolt)
+		}
+		s = unwrap(s)
+	}
+	return "other"
+}
+
+// unwrap returns the storage wrapped by s, or nil.
+func unwrap(s session.Storage) session.Storage {
+	if w, ok := s.(interface{ Unwrap() session.Storage }); ok {
+		return w.Unwrap()
+	}
+	return nil
+}
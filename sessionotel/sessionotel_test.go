@@ -0,0 +1,322 @@
+package sessionotel_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessionotel"
+)
+
+// newRecorder returns a tracer whose ended spans are recorded.
+func newRecorder(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(t.Context()) })
+	return provider, recorder
+}
+
+// attrs returns the attributes of span keyed by name.
+func attrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+// failingStorage is a Storage whose Set fails.
+type failingStorage struct {
+	session.Storage
+}
+
+func (s *failingStorage) Set(string, []byte, time.Duration) error {
+	return errors.New("boom")
+}
+
+func TestTracedStorage(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	inner := session.NewMemoryStorage("otel:", 0)
+	storage := sessionotel.NewTracedStorage(
+		session.NewInstrumentedStorage(inner, func(string, time.Duration, error) {}),
+		provider.Tracer("test"))
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("k", []byte("value"), time.Minute)
+	_, _ = storage.Get("k")
+	_, _ = storage.Get("missing")
+	_ = storage.Delete("k")
+	_ = storage.(session.Pinger).Ping(t.Context())
+
+	spans := recorder.Ended()
+	names := []string{"session.storage.set", "session.storage.get", "session.storage.get", "session.storage.delete", "session.storage.ping"}
+	if len(spans) != len(names) {
+		t.Fatalf("expected %d spans, got %d", len(names), len(spans))
+	}
+	for i, name := range names {
+		if spans[i].Name() != name {
+			t.Errorf("span %d: expected %s, got %s", i, name, spans[i].Name())
+		}
+		a := attrs(spans[i])
+		if a[sessionotel.AttrBackend].AsString() != "memory" || a[sessionotel.AttrKeyPrefix].AsString() != "otel:" {
+			t.Errorf("span %d: expected the memory backend and its prefix, got %v", i, a)
+		}
+	}
+
+	set, hit, miss := attrs(spans[0]), attrs(spans[1]), attrs(spans[2])
+	if set[sessionotel.AttrPayloadSize].AsInt64() != 5 {
+		t.Errorf("expected a set payload of 5 bytes, got %v", set)
+	}
+	if !hit[sessionotel.AttrHit].AsBool() || hit[sessionotel.AttrPayloadSize].AsInt64() != 5 {
+		t.Errorf("expected a hit of 5 bytes, got %v", hit)
+	}
+	if miss[sessionotel.AttrHit].AsBool() || miss[sessionotel.AttrPayloadSize].AsInt64() != 0 {
+		t.Errorf("expected a miss, got %v", miss)
+	}
+}
+
+func TestTracedStorageError(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	inner := session.NewMemoryStorage("otel:", 0)
+	defer func() { _ = inner.Close() }()
+	storage := sessionotel.NewTracedStorage(&failingStorage{Storage: inner}, provider.Tracer("test"))
+
+	if err := storage.Set("k", []byte("v"), time.Minute); err == nil {
+		t.Fatal("expected the set to fail")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error || len(spans[0].Events()) != 1 {
+		t.Errorf("expected an error status and event, got %v, %v", spans[0].Status(), spans[0].Events())
+	}
+	// The embedded storage cannot be reached through Unwrap
+	if backend := attrs(spans[0])[sessionotel.AttrBackend].AsString(); backend != "other" {
+		t.Errorf("expected an unknown backend, got %q", backend)
+	}
+}
+
+func TestTracedStorageOptionalInterfaces(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	inner := session.NewMemoryStorage("otel:", 0)
+	defer func() { _ = inner.Close() }()
+	storage := sessionotel.NewTracedStorage(inner, provider.Tracer("test"))
+
+	for name, ok := range map[string]bool{
+		"ExtendedStorage": implements[session.ExtendedStorage](storage),
+		"ContextStorage":  implements[session.ContextStorage](storage),
+		"Pinger":          implements[session.Pinger](storage),
+	} {
+		if !ok {
+			t.Errorf("expected the traced memory storage to implement %s", name)
+		}
+	}
+	// Other optional interfaces are not forwarded
+	for name, ok := range map[string]bool{
+		"Refresher":      implements[session.Refresher](storage),
+		"TTLKeeper":      implements[session.TTLKeeper](storage),
+		"BatchDeleter":   implements[session.BatchDeleter](storage),
+		"ExpiryNotifier": implements[session.ExpiryNotifier](storage),
+	} {
+		if ok {
+			t.Errorf("expected the traced memory storage not to implement %s", name)
+		}
+	}
+	if implements[session.ExtendedStorage](sessionotel.NewTracedStorage(&failingStorage{Storage: inner}, nil)) {
+		t.Error("expected storages without ExtendedStorage to stay without it")
+	}
+
+	_ = storage.Set("k", []byte("value"), time.Minute)
+	found, _ := storage.(session.ExtendedStorage).Touch("k", time.Hour)
+	if !found {
+		t.Error("expected Touch to find the key")
+	}
+	if ttl, _ := inner.GetTTL("k"); ttl <= time.Minute {
+		t.Errorf("expected Touch to extend the TTL, got %v", ttl)
+	}
+
+	spans := recorder.Ended()
+	names := []string{"session.storage.set", "session.storage.touch"}
+	if len(spans) != len(names) {
+		t.Fatalf("expected %d spans, got %d", len(names), len(spans))
+	}
+	for i, name := range names {
+		if spans[i].Name() != name {
+			t.Errorf("span %d: expected %s, got %s", i, name, spans[i].Name())
+		}
+	}
+	if !attrs(spans[1])[sessionotel.AttrHit].AsBool() {
+		t.Errorf("expected a hit, got %v", attrs(spans[1]))
+	}
+
+	if storage.(interface{ Unwrap() session.Storage }).Unwrap() != session.Storage(inner) {
+		t.Error("expected Unwrap to return the inner storage")
+	}
+}
+
+// implements reports whether s implements T.
+func implements[T any](s session.Storage) bool {
+	_, ok := s.(T)
+	return ok
+}
+
+// contextStorage is a ContextStorage recording the span of the contexts it
+// is called with.
+type contextStorage struct {
+	session.Storage
+	spans []trace.SpanContext
+}
+
+func (s *contextStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	s.spans = append(s.spans, trace.SpanContextFromContext(ctx))
+	return s.Get(key)
+}
+
+func (s *contextStorage) SetCtx(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	s.spans = append(s.spans, trace.SpanContextFromContext(ctx))
+	return s.Set(key, val, exp)
+}
+
+func (s *contextStorage) DeleteCtx(ctx context.Context, key string) error {
+	s.spans = append(s.spans, trace.SpanContextFromContext(ctx))
+	return s.Delete(key)
+}
+
+func TestTracedStorageContext(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	memory := session.NewMemoryStorage("otel:", 0)
+	defer func() { _ = memory.Close() }()
+	inner := &contextStorage{Storage: memory}
+	storage := sessionotel.NewTracedStorage(inner, provider.Tracer("test")).(session.ContextStorage)
+
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "handler")
+	_ = storage.SetCtx(ctx, "k", []byte("v"), time.Minute)
+	_, _ = storage.GetCtx(ctx, "k")
+	_ = storage.DeleteCtx(ctx, "k")
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	for i, span := range spans[:3] {
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %d: expected the handler span as parent", i)
+		}
+		if inner.spans[i].SpanID() != span.SpanContext().SpanID() {
+			t.Errorf("span %d: expected the inner storage to get the context of the span", i)
+		}
+	}
+}
+
+func TestTracedManager(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	storage := session.NewMemoryStorage("otel:", 0)
+	defer func() { _ = storage.Close() }()
+
+	manager := sessionotel.NewTracedManager(
+		session.NewManager(storage, session.DefaultConfig().WithExpiration(time.Hour)),
+		provider.Tracer("test"))
+	ctx := t.Context()
+
+	_ = manager.SaveSession(ctx, manager.Manager().CreateSession("a"))
+	_, _ = manager.LoadSession(ctx, "a")
+	_ = manager.DeleteSession(ctx, "a")
+	_, _ = manager.LoadSession(ctx, "a")
+
+	expired := manager.Manager().CreateSession("b")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(expired)
+	_ = storage.Set("b", data, time.Hour)
+	_, _ = manager.LoadSession(ctx, "b")
+
+	spans := recorder.Ended()
+	want := []struct {
+		name string
+		hit  *bool
+	}{
+		{sessionotel.SpanSave, nil},
+		{sessionotel.SpanLoad, ptr(true)},
+		{sessionotel.SpanDelete, nil},
+		{sessionotel.SpanLoad, ptr(false)},
+		{sessionotel.SpanLoad, ptr(false)},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("expected %d spans, got %d", len(want), len(spans))
+	}
+	for i, w := range want {
+		span := spans[i]
+		if span.Name() != w.name {
+			t.Errorf("span %d: expected %s, got %s", i, w.name, span.Name())
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("span %d: expected no error, got %v", i, span.Status())
+		}
+		a := attrs(span)
+		if a[sessionotel.AttrBackend].AsString() != "memory" || a[sessionotel.AttrKeyPrefix].AsString() != "otel:" {
+			t.Errorf("span %d: expected the memory backend and its prefix, got %v", i, a)
+		}
+		hit, ok := a[sessionotel.AttrHit]
+		if w.hit == nil && ok || w.hit != nil && (!ok || hit.AsBool() != *w.hit) {
+			t.Errorf("span %d: expected hit %v, got %v", i, w.hit, a)
+		}
+	}
+}
+
+func TestTracedManagerParentsStorageSpans(t *testing.T) {
+	provider, recorder := newRecorder(t)
+	tracer := provider.Tracer("test")
+	memory := session.NewMemoryStorage("otel:", 0)
+	defer func() { _ = memory.Close() }()
+	storage := sessionotel.NewTracedStorage(memory, tracer)
+	manager := sessionotel.NewTracedManager(
+		session.NewManager(storage, session.DefaultConfig().WithExpiration(time.Hour)), tracer)
+
+	ctx, handler := tracer.Start(t.Context(), "handler")
+	_ = manager.SaveSession(ctx, manager.Manager().CreateSession("a"))
+	_, _ = manager.LoadSession(ctx, "a")
+	_ = manager.DeleteSession(ctx, "a")
+	handler.End()
+
+	spans := recorder.Ended()
+	names := []string{
+		"session.storage.set", sessionotel.SpanSave,
+		"session.storage.get", sessionotel.SpanLoad,
+		"session.storage.delete", sessionotel.SpanDelete,
+		"handler",
+	}
+	if len(spans) != len(names) {
+		t.Fatalf("expected %d spans, got %d", len(names), len(spans))
+	}
+	for i := 0; i < len(names)-1; i += 2 {
+		op, storageOp := spans[i+1], spans[i]
+		if op.Name() != names[i+1] || storageOp.Name() != names[i] {
+			t.Errorf("expected %s then %s, got %s then %s", names[i], names[i+1], storageOp.Name(), op.Name())
+			continue
+		}
+		if op.Parent().SpanID() != handler.SpanContext().SpanID() {
+			t.Errorf("%s: expected the handler span as parent", op.Name())
+		}
+		if storageOp.Parent().SpanID() != op.SpanContext().SpanID() {
+			t.Errorf("%s: expected the %s span as parent", storageOp.Name(), op.Name())
+		}
+		if op.StartTime().After(storageOp.StartTime()) {
+			t.Errorf("%s: expected to start before %s", op.Name(), storageOp.Name())
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
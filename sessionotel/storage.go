@@ -0,0 +1,190 @@
+package sessionotel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// TracedStorage wraps a Storage and starts a span for every operation,
+// recording errors on it. It always implements session.ContextStorage,
+// passing the context of the span to the inner storage if it implements
+// it too, and Pinger.
+//
+// Wrapping hides the concrete type of the inner storage. The wrapper
+// implements ExtendedStorage only if the inner storage does; other optional
+// interfaces are not forwarded, so Manager falls back to the operations of
+// Storage and ExtendedStorage. Use Unwrap to reach the inner storage.
+type TracedStorage struct {
+	inner  session.Storage
+	ctx    session.ContextStorage
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// tracedExtendedStorage is the TracedStorage returned for inner storages
+// implementing ExtendedStorage.
+type tracedExtendedStorage struct {
+	*TracedStorage
+	extended session.ExtendedStorage
+}
+
+// NewTracedStorage wraps inner so that every operation is traced with
+// tracer, or a tracer of the global provider if tracer is nil.
+func NewTracedStorage(inner session.Storage, tracer trace.Tracer) session.Storage {
+	if tracer == nil {
+		tracer = otel.Tracer(ScopeName)
+	}
+	s := &TracedStorage{inner: inner, tracer: tracer, attrs: storageAttributes(inner)}
+	s.ctx, _ = inner.(session.ContextStorage)
+	if extended, ok := inner.(session.ExtendedStorage); ok {
+		return &tracedExtendedStorage{TracedStorage: s, extended: extended}
+	}
+	return s
+}
+
+// Unwrap returns the inner storage.
+func (s *TracedStorage) Unwrap() session.Storage {
+	return s.inner
+}
+
+// start starts the span of the storage operation op as a child of the span
+// in ctx.
+func (s *TracedStorage) start(ctx context.Context, op string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "session.storage."+op, trace.WithAttributes(s.attrs...))
+}
+
+// Get retrieves the value for the given key.
+func (s *TracedStorage) Get(key string) ([]byte, error) {
+	return s.GetCtx(context.Background(), key)
+}
+
+// GetCtx is like Get but starts the span as a child of the span in ctx.
+func (s *TracedStorage) GetCtx(ctx context.Context, key string) ([]byte, error) {
+	ctx, span := s.start(ctx, "get")
+	var data []byte
+	var err error
+	if s.ctx != nil {
+		data, err = s.ctx.GetCtx(ctx, key)
+	} else {
+		data, err = s.inner.Get(key)
+	}
+	span.SetAttributes(AttrHit.Bool(data != nil), AttrPayloadSize.Int(len(data)))
+	end(span, err)
+	return data, err
+}
+
+// Set stores the given value for the given key.
+func (s *TracedStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.SetCtx(context.Background(), key, val, exp)
+}
+
+// SetCtx is like Set but starts the span as a child of the span in ctx.
+func (s *TracedStorage) SetCtx(ctx context.Context, key string, val []byte, exp time.Duration) error {
+	ctx, span := s.start(ctx, "set")
+	var err error
+	if s.ctx != nil {
+		err = s.ctx.SetCtx(ctx, key, val, exp)
+	} else {
+		err = s.inner.Set(key, val, exp)
+	}
+	span.SetAttributes(AttrPayloadSize.Int(len(val)))
+	end(span, err)
+	return err
+}
+
+// Delete removes the value for the given key.
+func (s *TracedStorage) Delete(key string) error {
+	return s.DeleteCtx(context.Background(), key)
+}
+
+// DeleteCtx is like Delete but starts the span as a child of the span in
+// ctx.
+func (s *TracedStorage) DeleteCtx(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "delete")
+	var err error
+	if s.ctx != nil {
+		err = s.ctx.DeleteCtx(ctx, key)
+	} else {
+		err = s.inner.Delete(key)
+	}
+	end(span, err)
+	return err
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *TracedStorage) Reset() error {
+	_, span := s.start(context.Background(), "reset")
+	err := s.inner.Reset()
+	end(span, err)
+	return err
+}
+
+// Ping checks the inner storage, as a child of the span in ctx. Storages
+// that do not implement Pinger are assumed to be healthy.
+func (s *TracedStorage) Ping(ctx context.Context) error {
+	ctx, span := s.start(ctx, "ping")
+	var err error
+	if pinger, ok := s.inner.(session.Pinger); ok {
+		err = pinger.Ping(ctx)
+	}
+	end(span, err)
+	return err
+}
+
+// Close closes the inner storage.
+func (s *TracedStorage) Close() error {
+	_, span := s.start(context.Background(), "close")
+	err := s.inner.Close()
+	end(span, err)
+	return err
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *tracedExtendedStorage) Exists(key string) (bool, error) {
+	_, span := s.start(context.Background(), "exists")
+	ok, err := s.extended.Exists(key)
+	span.SetAttributes(AttrHit.Bool(ok))
+	end(span, err)
+	return ok, err
+}
+
+// GetTTL returns the remaining TTL for the key.
+func (s *tracedExtendedStorage) GetTTL(key string) (time.Duration, error) {
+	_, span := s.start(context.Background(), "get_ttl")
+	ttl, err := s.extended.GetTTL(key)
+	end(span, err)
+	return ttl, err
+}
+
+// Expire sets a new expiration on the key.
+func (s *tracedExtendedStorage) Expire(key string, exp time.Duration) error {
+	_, span := s.start(context.Background(), "expire")
+	err := s.extended.Expire(key, exp)
+	end(span, err)
+	return err
+}
+
+// Touch sets a new expiration on the key and reports whether it existed.
+func (s *tracedExtendedStorage) Touch(key string, exp time.Duration) (bool, error) {
+	_, span := s.start(context.Background(), "touch")
+	ok, err := s.extended.Touch(key, exp)
+	span.SetAttributes(AttrHit.Bool(ok))
+	end(span, err)
+	return ok, err
+}
+
+// end records err on span, if any, and ends it.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
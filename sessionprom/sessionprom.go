@@ -0,0 +1,136 @@
+// Package sessionprom exports the operation metrics of this module to
+// Prometheus. Metrics provides observers for the Storage and KV session
+// paths, recording the duration of every operation in a histogram labelled
+// with the operation and its result:
+//
+//	metrics, err := sessionprom.New(prometheus.DefaultRegisterer)
+//	storage := session.NewInstrumentedStorage(redisStorage, metrics.ObserveStorage)
+//	kv := session.NewKVManagerWithOptions(store,
+//		session.DefaultKVManagerOptions().WithObserver(metrics.ObserveKV))
+//
+// The sessionmetrics package complements Metrics with counters of created,
+// loaded and deleted sessions.
+package sessionprom
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// Values of the result label.
+const (
+	ResultOK       = "ok"
+	ResultNotFound = "not_found"
+	ResultError    = "error"
+)
+
+// Options configures Metrics and sessionmetrics.Collector.
+type Options struct {
+	// Namespace prefixes the metric names.
+	// Default: "session"
+	Namespace string
+
+	// Buckets are the histogram buckets, in seconds.
+	// Default: prometheus.DefBuckets
+	Buckets []float64
+}
+
+// DefaultOptions returns Options with default values.
+func DefaultOptions() Options {
+	return Options{
+		Namespace: "session",
+		Buckets:   prometheus.DefBuckets,
+	}
+}
+
+// WithNamespace sets the metric namespace.
+func (o Options) WithNamespace(namespace string) Options {
+	o.Namespace = namespace
+	return o
+}
+
+// WithBuckets sets the histogram buckets.
+func (o Options) WithBuckets(buckets []float64) Options {
+	o.Buckets = buckets
+	return o
+}
+
+// Metrics records operation durations as Prometheus histograms:
+// <namespace>_storage_operation_duration_seconds for Storage operations and
+// <namespace>_kv_operation_duration_seconds for KVManager operations, both
+// labelled with op and result. Sample counts per result give error rates.
+type Metrics struct {
+	storage *prometheus.HistogramVec
+	kv      *prometheus.HistogramVec
+}
+
+// New creates Metrics with default options and registers them with reg, or
+// prometheus.DefaultRegisterer if reg is nil.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	return NewWithOptions(reg, DefaultOptions())
+}
+
+// NewWithOptions creates Metrics using options and registers them with
+// reg, or prometheus.DefaultRegisterer if reg is nil.
+func NewWithOptions(reg prometheus.Registerer, opts Options) (*Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	m := &Metrics{
+		storage: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of session storage operations.",
+			Buckets:   buckets,
+		}, []string{"op", "result"}),
+		kv: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: "kv",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of KV session operations.",
+			Buckets:   buckets,
+		}, []string{"op", "result"}),
+	}
+	for _, c := range []prometheus.Collector{m.storage, m.kv} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("register session metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// ObserveStorage records a Storage operation. Its signature matches the
+// observers of session.NewInstrumentedStorage and
+// RedisStorage.WithLatencyObserver.
+func (m *Metrics) ObserveStorage(op string, d time.Duration, err error) {
+	m.storage.WithLabelValues(op, result(err)).Observe(d.Seconds())
+}
+
+// ObserveKV records a KVManager operation. Its signature matches
+// session.KVManagerOptions.Observer; the session ID is not recorded.
+func (m *Metrics) ObserveKV(op, id string, d time.Duration, err error) {
+	m.kv.WithLabelValues(op, result(err)).Observe(d.Seconds())
+}
+
+// result returns the result label for err.
+func result(err error) string {
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, session.ErrSessionNotFound):
+		return ResultNotFound
+	default:
+		return ResultError
+	}
+}
@@ -0,0 +1,108 @@
+package sessionprom_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/redis/go-redis/v9"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessionprom"
+)
+
+// sampleCounts returns the sample count of every series of the histogram
+// name, keyed by "op/result".
+func sampleCounts(t *testing.T, reg *prometheus.Registry, name string) map[string]uint64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	counts := make(map[string]uint64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			counts[label(metric, "op")+"/"+label(metric, "result")] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return counts
+}
+
+func label(metric *dto.Metric, name string) string {
+	for _, pair := range metric.GetLabel() {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestObserveKV(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	reg := prometheus.NewRegistry()
+	metrics, err := sessionprom.New(reg)
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+	mgr := session.NewKVManagerWithOptions(session.NewRedisStore(client, "prom:"),
+		session.DefaultKVManagerOptions().WithObserver(metrics.ObserveKV))
+
+	ctx := context.Background()
+	id, _ := mgr.Create(ctx, nil, time.Minute)
+	_, _ = mgr.Get(ctx, id)
+	_ = mgr.Delete(ctx, id)
+	_ = mgr.Refresh(ctx, id, time.Minute)
+	mr.Close()
+	_, _ = mgr.Exists(ctx, id)
+
+	want := map[string]uint64{
+		"create/ok":         1,
+		"get/ok":            1,
+		"delete/ok":         1,
+		"refresh/not_found": 1,
+		"exists/error":      1,
+	}
+	got := sampleCounts(t, reg, "session_kv_operation_duration_seconds")
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for series, n := range want {
+		if got[series] != n {
+			t.Errorf("expected %d samples for %s, got %d", n, series, got[series])
+		}
+	}
+}
+
+func TestObserveStorage(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics, err := sessionprom.NewWithOptions(reg, sessionprom.DefaultOptions().WithNamespace("app"))
+	if err != nil {
+		t.Fatalf("failed to create metrics: %v", err)
+	}
+	storage := session.NewInstrumentedStorage(session.NewMemoryStorage("prom:", 0), metrics.ObserveStorage)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("k", []byte("v"), time.Minute)
+	_, _ = storage.Get("k")
+	_, _ = storage.Get("missing")
+	metrics.ObserveStorage("get", time.Millisecond, errors.New("boom"))
+
+	got := sampleCounts(t, reg, "app_storage_operation_duration_seconds")
+	if got["set/ok"] != 1 || got["get/ok"] != 2 || got["get/error"] != 1 {
+		t.Errorf("unexpected samples: %v", got)
+	}
+
+	if _, err := sessionprom.NewWithOptions(reg, sessionprom.DefaultOptions().WithNamespace("app")); err == nil {
+		t.Error("expected an error registering the metrics twice")
+	}
+}
@@ -0,0 +1,127 @@
+// Package sessiontest provides fakes of session.Storage and session.Store
+// for testing code built on this module: in-memory implementations with
+// per-method error injection, call recording and a controllable clock.
+//
+//	storage := sessiontest.NewFakeStorage()
+//	storage.FailNextSet(errors.New("boom"))
+//	manager := session.NewManager(storage, session.DefaultConfig())
+//	err := manager.SaveSession(manager.CreateSession("id")) // boom
+package sessiontest
+
+import (
+	"sync"
+	"time"
+)
+
+// Call records one method call on a fake.
+type Call struct {
+	// Method is the name of the method, such as "Get" or "Set".
+	Method string
+
+	// Key is the key, or session ID for FakeStore, empty for methods
+	// without one.
+	Key string
+
+	// TTL is the expiration passed to the call, if any.
+	TTL time.Duration
+
+	// Err is the error returned by the call.
+	Err error
+}
+
+// Clock is a controllable clock deciding when the entries of the fakes
+// expire. It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set sets the clock to now.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
+
+// recorder injects errors into and records the calls of a fake.
+type recorder struct {
+	mu    sync.Mutex
+	errs  map[string]error
+	next  map[string][]error
+	calls []Call
+}
+
+// fail makes every call of method return err until it is called with nil.
+func (r *recorder) fail(method string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.errs == nil {
+		r.errs = make(map[string]error)
+	}
+	if err == nil {
+		delete(r.errs, method)
+		return
+	}
+	r.errs[method] = err
+}
+
+// failNext makes the next call of method return err. Errors queue up.
+func (r *recorder) failNext(method string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next == nil {
+		r.next = make(map[string][]error)
+	}
+	r.next[method] = append(r.next[method], err)
+}
+
+// injected returns the error to inject into a call of method, if any.
+func (r *recorder) injected(method string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if queue := r.next[method]; len(queue) > 0 {
+		r.next[method] = queue[1:]
+		return queue[0]
+	}
+	return r.errs[method]
+}
+
+// record records a call.
+func (r *recorder) record(call Call) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+// snapshot returns a copy of the recorded calls.
+func (r *recorder) snapshot() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// clear forgets the recorded calls.
+func (r *recorder) clear() {
+	r.mu.Lock()
+	r.calls = nil
+	r.mu.Unlock()
+}
@@ -0,0 +1,408 @@
+package sessiontest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessiontest"
+	"github.com/soulteary/session-kit/storagetest"
+)
+
+var (
+	_ session.Storage = (*sessiontest.FakeStorage)(nil)
+	_ session.Store   = (*sessiontest.FakeStore)(nil)
+)
+
+func TestFakeStorageConformance(t *testing.T) {
+	clock := sessiontest.NewClock(time.Now())
+	storagetest.TestStorageWithOptions(t, func() session.Storage {
+		return sessiontest.NewFakeStorage().WithClock(clock)
+	}, storagetest.DefaultOptions().WithAdvance(clock.Advance))
+}
+
+func TestFakeStorageFailures(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	errSet := errors.New("set failed")
+	errGet := errors.New("get failed")
+
+	storage.FailNextSet(errSet)
+	if err := storage.Set("key", []byte("value"), time.Minute); !errors.Is(err, errSet) {
+		t.Errorf("expected the injected error, got %v", err)
+	}
+	if err := storage.Set("key", []byte("value"), time.Minute); err != nil {
+		t.Errorf("expected FailNextSet to fail a single call, got %v", err)
+	}
+
+	storage.Fail("Get", errGet)
+	for i := 0; i < 2; i++ {
+		if _, err := storage.Get("key"); !errors.Is(err, errGet) {
+			t.Errorf("expected Get to keep failing, got %v", err)
+		}
+	}
+	storage.Fail("Get", nil)
+	if got, err := storage.Get("key"); string(got) != "value" || err != nil {
+		t.Errorf("expected Get to recover, got %q (%v)", got, err)
+	}
+
+	_ = storage.Close()
+	if _, err := storage.Get("key"); !errors.Is(err, session.ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestFakeStorageCalls(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	errDelete := errors.New("delete failed")
+	storage.FailNextDelete(errDelete)
+
+	_ = storage.Set("key", []byte("value"), time.Minute)
+	_, _ = storage.Get("key")
+	_ = storage.Delete("key")
+
+	want := []sessiontest.Call{
+		{Method: "Set", Key: "key", TTL: time.Minute},
+		{Method: "Get", Key: "key"},
+		{Method: "Delete", Key: "key", Err: errDelete},
+	}
+	calls := storage.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("expected %d calls, got %v", len(want), calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d: expected %+v, got %+v", i, want[i], calls[i])
+		}
+	}
+
+	storage.ClearCalls()
+	if calls := storage.Calls(); len(calls) != 0 {
+		t.Errorf("expected no calls after ClearCalls, got %v", calls)
+	}
+}
+
+func TestFakeStorageClock(t *testing.T) {
+	storage := sessiontest.NewFakeStorage()
+	_ = storage.Set("key", []byte("value"), time.Hour)
+
+	storage.Clock().Advance(59 * time.Minute)
+	if storage.Len() != 1 {
+		t.Error("expected the entry to survive until its expiration")
+	}
+	storage.Clock().Advance(time.Minute)
+	if got, _ := storage.Get("key"); got != nil {
+		t.Errorf("expected the entry to expire, got %q", got)
+	}
+}
+
+func TestFakeStore(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+
+	id, err := store.Create(ctx, map[string]interface{}{"user": "alice"}, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	rec, err := store.Get(ctx, id)
+	if err != nil || rec == nil || rec.Data["user"] != "alice" {
+		t.Fatalf("expected the record, got %+v (%v)", rec, err)
+	}
+	createdAt := rec.CreatedAt
+
+	// The returned record is a copy
+	rec.Data["user"] = "mallory"
+
+	store.Clock().Advance(time.Minute)
+	if err := store.Set(ctx, id, map[string]interface{}{"role": "admin"}, time.Hour); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	rec, _ = store.Get(ctx, id)
+	if !rec.CreatedAt.Equal(createdAt) || rec.Data["role"] != "admin" {
+		t.Errorf("expected Set to keep CreatedAt and replace the data, got %+v", rec)
+	}
+
+	store.Clock().Advance(time.Hour + time.Second)
+	if exists, _ := store.Exists(ctx, id); exists {
+		t.Error("expected the session to expire")
+	}
+	if rec, err := store.Get(ctx, id); rec != nil || err != nil {
+		t.Errorf("expected nil, nil for an expired session, got %+v (%v)", rec, err)
+	}
+}
+
+func TestFakeStoreFailures(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	errCreate := errors.New("create failed")
+
+	store.FailNextCreate(errCreate)
+	if _, err := store.Create(ctx, nil, time.Hour); !errors.Is(err, errCreate) {
+		t.Errorf("expected the injected error, got %v", err)
+	}
+	id, err := store.Create(ctx, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+
+	store.Fail("Exists", errCreate)
+	if _, err := store.Exists(ctx, id); !errors.Is(err, errCreate) {
+		t.Errorf("expected the injected error, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := store.Get(cancelled, id); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+
+	calls := store.Calls()
+	if len(calls) != 4 || calls[1].Method != "Create" || calls[1].Err != nil || calls[3].Key != id {
+		t.Errorf("unexpected calls %+v", calls)
+	}
+}
+
+func TestFakeStoreUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, _ := store.Create(ctx, map[string]interface{}{"n": 1}, time.Hour)
+
+	err := store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["n"] = 2
+		return data, nil
+	}, 0)
+	if err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	rec, _ := store.Get(ctx, id)
+	if rec.Data["n"] != 2 {
+		t.Errorf("expected the update to be stored, got %v", rec.Data)
+	}
+
+	store.Clock().Advance(time.Hour + time.Second)
+	keep := func(data map[string]interface{}) (map[string]interface{}, error) { return data, nil }
+	if err := store.Update(ctx, id, keep, 0); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound once expired, got %v", err)
+	}
+}
+
+func TestFakeStoreTouch(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, _ := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
+
+	if err := store.Touch(ctx, id, time.Hour); err != nil {
+		t.Fatalf("failed to touch: %v", err)
+	}
+	store.Clock().Advance(30 * time.Minute)
+	if exists, _ := store.Exists(ctx, id); !exists {
+		t.Error("expected Touch to extend the session")
+	}
+	if err := store.Touch(ctx, "missing", time.Hour); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFakeStoreGetAndRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	mgr := session.NewKVManager(store, time.Minute)
+	id, _ := mgr.Create(ctx, map[string]interface{}{"k": "v"}, 0)
+
+	store.Clock().Advance(30 * time.Second)
+	rec, err := mgr.GetAndRefresh(ctx, id, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to get and refresh: %v", err)
+	}
+	if !rec.ExpiresAt.Equal(store.Clock().Now().Add(time.Hour)) || rec.Data["k"] != "v" {
+		t.Errorf("expected the refreshed record, got %+v", rec)
+	}
+	if ttl, _ := store.GetTTL(ctx, id); ttl != time.Hour {
+		t.Errorf("expected the stored TTL to match, got %v", ttl)
+	}
+	if calls := store.Calls(); calls[len(calls)-2].Method != "GetAndTouch" {
+		t.Errorf("expected GetAndTouch to be used, got %v", calls)
+	}
+	if _, err := mgr.GetAndRefresh(ctx, "missing", 0); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFakeStoreCreateWithMeta(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, err := store.CreateWithMeta(ctx, nil, map[string]string{session.MetaIP: "192.0.2.1"}, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if err := store.Set(ctx, id, map[string]interface{}{"k": "v"}, time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	rec, _ := store.Get(ctx, id)
+	if rec.Meta[session.MetaIP] != "192.0.2.1" {
+		t.Errorf("expected Set to preserve the metadata, got %v", rec.Meta)
+	}
+}
+
+func TestFakeStoreSetKeepTTL(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, _ := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
+	before, _ := store.Get(ctx, id)
+
+	store.Clock().Advance(30 * time.Second)
+	if err := store.Set(ctx, id, map[string]interface{}{"k": "w"}, 0); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	rec, _ := store.Get(ctx, id)
+	if rec.Data["k"] != "w" || !rec.ExpiresAt.Equal(before.ExpiresAt) {
+		t.Errorf("expected new data with the same expiration, got %+v", rec)
+	}
+
+	if err := store.Set(ctx, "missing", map[string]interface{}{"k": "v"}, 0); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err := store.Create(ctx, nil, 0); err == nil {
+		t.Error("expected error for Create with a zero TTL")
+	}
+}
+
+func TestFakeStoreCreateWithID(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	if err := store.CreateWithID(ctx, "external", map[string]interface{}{"k": "v"}, time.Minute); err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if rec, _ := store.Get(ctx, "external"); rec == nil || rec.Data["k"] != "v" {
+		t.Errorf("expected the adopted session, got %+v", rec)
+	}
+	if err := store.CreateWithID(ctx, "external", nil, time.Minute); !errors.Is(err, session.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	store.Clock().Advance(2 * time.Minute)
+	if err := store.CreateWithID(ctx, "external", nil, time.Minute); err != nil {
+		t.Errorf("expected an expired ID to be reusable, got %v", err)
+	}
+}
+
+func TestFakeStoreGetTTL(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, _ := store.Create(ctx, nil, time.Minute)
+
+	store.Clock().Advance(20 * time.Second)
+	if ttl, err := store.GetTTL(ctx, id); err != nil || ttl != 40*time.Second {
+		t.Errorf("expected 40s, got %v, %v", ttl, err)
+	}
+	store.Clock().Advance(time.Minute)
+	if _, err := store.GetTTL(ctx, id); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFakeStoreList(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	for i := 0; i < 5; i++ {
+		_, _ = store.Create(ctx, nil, time.Minute)
+	}
+
+	var ids []string
+	cursor := ""
+	for {
+		page, next, err := store.List(ctx, cursor, 2)
+		if err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+		ids = append(ids, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(ids) != 5 {
+		t.Errorf("expected 5 ids, got %v", ids)
+	}
+
+	store.Clock().Advance(2 * time.Minute)
+	if ids, _, _ := store.List(ctx, "", 0); len(ids) != 0 {
+		t.Errorf("expected expired sessions to be skipped, got %v", ids)
+	}
+}
+
+func TestFakeStoreSetCAS(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	id, _ := store.Create(ctx, nil, time.Minute)
+
+	if err := store.SetCAS(ctx, id, map[string]interface{}{"k": "v"}, 0, 1); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if err := store.SetCAS(ctx, id, map[string]interface{}{"k": "w"}, 0, 1); !errors.Is(err, session.ErrRevisionMismatch) {
+		t.Errorf("expected ErrRevisionMismatch, got %v", err)
+	}
+	if rec, _ := store.Get(ctx, id); rec.Revision != 2 || rec.Data["k"] != "v" {
+		t.Errorf("expected the first write at revision 2, got %+v", rec)
+	}
+}
+
+func TestFakeStoreMulti(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	a, _ := store.Create(ctx, map[string]interface{}{"n": "a"}, time.Minute)
+	b, _ := store.Create(ctx, nil, time.Second)
+	store.Clock().Advance(2 * time.Second)
+
+	records, err := store.GetMulti(ctx, []string{a, b, "missing"})
+	if err != nil || len(records) != 1 || records[a] == nil {
+		t.Errorf("expected only the live session, got %v, %v", records, err)
+	}
+	if err := store.DeleteMulti(ctx, []string{a, "missing"}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if exists, _ := store.Exists(ctx, a); exists {
+		t.Error("expected the session to be deleted")
+	}
+}
+
+func TestFakeStoreIndexer(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	mgr := session.NewKVManager(store, time.Minute)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := mgr.CreateScoped(ctx, "user", nil, 0, 2)
+		if err != nil {
+			t.Fatalf("failed to create: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	indexed, _ := store.ListIndex(ctx, "user")
+	if len(indexed) != 2 || indexed[0] != ids[1] || indexed[1] != ids[2] {
+		t.Errorf("expected the two newest sessions, got %v", indexed)
+	}
+	if exists, _ := store.Exists(ctx, ids[0]); exists {
+		t.Error("expected the oldest session to be evicted")
+	}
+}
+
+func TestFakeStoreLookupIndexer(t *testing.T) {
+	ctx := context.Background()
+	store := sessiontest.NewFakeStore()
+	mgr := session.NewKVManager(store, time.Minute)
+
+	id, err := mgr.CreateIndexed(ctx, "+15550100", nil, 0)
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if rec, _ := mgr.GetByIndex(ctx, "+15550100"); rec == nil || rec.ID != id {
+		t.Errorf("expected the indexed session, got %+v", rec)
+	}
+	_ = mgr.Delete(ctx, id)
+	if lookup, _ := store.GetLookup(ctx, "+15550100"); lookup != "" {
+		t.Errorf("expected Delete to remove the lookup, got %q", lookup)
+	}
+}
@@ -0,0 +1,192 @@
+package sessiontest
+
+import (
+	"sync"
+	"time"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// fakeEntry is a value stored by FakeStorage.
+type fakeEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// FakeStorage is an in-memory session.Storage for tests. It follows the
+// Storage contract checked by the storagetest package, implements none of
+// the optional interfaces, and:
+//   - expires entries according to its Clock rather than the wall clock
+//   - returns the errors injected with Fail and FailNext instead of running
+//     the call
+//   - records every call, see Calls
+//
+// Method names are those of session.Storage: "Get", "Set", "Delete",
+// "Reset" and "Close". After Close, calls return session.ErrClosed.
+type FakeStorage struct {
+	rec   recorder
+	clock *Clock
+
+	mu     sync.Mutex
+	data   map[string]fakeEntry
+	closed bool
+}
+
+// NewFakeStorage returns an empty FakeStorage with a clock set to the
+// current time.
+func NewFakeStorage() *FakeStorage {
+	return &FakeStorage{
+		clock: NewClock(time.Now()),
+		data:  make(map[string]fakeEntry),
+	}
+}
+
+// WithClock sets the clock of the storage, e.g. to share one with a FakeStore.
+func (s *FakeStorage) WithClock(clock *Clock) *FakeStorage {
+	s.clock = clock
+	return s
+}
+
+// Clock returns the clock of the storage.
+func (s *FakeStorage) Clock() *Clock {
+	return s.clock
+}
+
+// Fail makes every call of method return err, until Fail is called again
+// with a nil error.
+func (s *FakeStorage) Fail(method string, err error) {
+	s.rec.fail(method, err)
+}
+
+// FailNext makes the next call of method return err. Calling it several
+// times fails as many calls, in order.
+func (s *FakeStorage) FailNext(method string, err error) {
+	s.rec.failNext(method, err)
+}
+
+// FailNextGet makes the next Get return err.
+func (s *FakeStorage) FailNextGet(err error) {
+	s.FailNext("Get", err)
+}
+
+// FailNextSet makes the next Set return err.
+func (s *FakeStorage) FailNextSet(err error) {
+	s.FailNext("Set", err)
+}
+
+// FailNextDelete makes the next Delete return err.
+func (s *FakeStorage) FailNextDelete(err error) {
+	s.FailNext("Delete", err)
+}
+
+// Calls returns the calls made so far, in order.
+func (s *FakeStorage) Calls() []Call {
+	return s.rec.snapshot()
+}
+
+// ClearCalls forgets the calls made so far.
+func (s *FakeStorage) ClearCalls() {
+	s.rec.clear()
+}
+
+// Len returns the number of entries that have not expired.
+func (s *FakeStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	n := 0
+	for _, e := range s.data {
+		if !e.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// expired reports whether the entry has expired at now.
+func (e fakeEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// call runs fn unless an error is injected into method or the storage is
+// closed, and records the call.
+func (s *FakeStorage) call(method, key string, ttl time.Duration, fn func() error) error {
+	err := s.rec.injected(method)
+	if err == nil {
+		s.mu.Lock()
+		if s.closed {
+			err = session.ErrClosed
+		} else {
+			err = fn()
+		}
+		s.mu.Unlock()
+	}
+	s.rec.record(Call{Method: method, Key: key, TTL: ttl, Err: err})
+	return err
+}
+
+// Get retrieves a copy of the value for the given key.
+// Returns nil, nil if the key does not exist or has expired.
+func (s *FakeStorage) Get(key string) ([]byte, error) {
+	var out []byte
+	err := s.call("Get", key, 0, func() error {
+		e, ok := s.data[key]
+		if !ok {
+			return nil
+		}
+		if e.expired(s.clock.Now()) {
+			delete(s.data, key)
+			return nil
+		}
+		out = append([]byte(nil), e.value...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Set stores a copy of the value for the given key. If exp is 0, the value
+// never expires. Empty key or value will be ignored without an error.
+func (s *FakeStorage) Set(key string, val []byte, exp time.Duration) error {
+	return s.call("Set", key, exp, func() error {
+		if key == "" || len(val) == 0 {
+			return nil
+		}
+		e := fakeEntry{value: append([]byte(nil), val...)}
+		if exp > 0 {
+			e.expiresAt = s.clock.Now().Add(exp)
+		}
+		s.data[key] = e
+		return nil
+	})
+}
+
+// Delete removes the value for the given key.
+func (s *FakeStorage) Delete(key string) error {
+	return s.call("Delete", key, 0, func() error {
+		delete(s.data, key)
+		return nil
+	})
+}
+
+// Reset removes all values.
+func (s *FakeStorage) Reset() error {
+	return s.call("Reset", "", 0, func() error {
+		clear(s.data)
+		return nil
+	})
+}
+
+// Close closes the storage. Close is idempotent.
+func (s *FakeStorage) Close() error {
+	err := s.rec.injected("Close")
+	if err == nil {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+	}
+	s.rec.record(Call{Method: "Close", Err: err})
+	return err
+}
@@ -0,0 +1,494 @@
+package sessiontest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// FakeStore is an in-memory session.Store for tests, with the same knobs as
+// FakeStorage: a Clock, error injection and call recording. It behaves like
+// RedisStore: Get returns nil, nil for missing or expired sessions, and Set
+// keeps the CreatedAt of an existing session, and its expiration if ttl is
+// 0. Created IDs are sequential, "sess_1", "sess_2" and so on. It also
+// implements session.Indexer and session.LookupIndexer, with indexes and
+// lookups that never expire.
+//
+// Method names are those of session.Store, session.Updater,
+// session.Indexer and session.LookupIndexer, such as "Create", "Get", "Set"
+// or "AddToIndex".
+type FakeStore struct {
+	rec   recorder
+	clock *Clock
+
+	mu      sync.Mutex
+	records map[string]session.KVSessionRecord
+	nextID  int
+	indexes map[string]map[string]int
+	nextSeq int
+	lookups map[string]string
+}
+
+// NewFakeStore returns an empty FakeStore with a clock set to the current
+// time.
+func NewFakeStore() *FakeStore {
+	return &FakeStore{
+		clock:   NewClock(time.Now()),
+		records: make(map[string]session.KVSessionRecord),
+		indexes: make(map[string]map[string]int),
+		lookups: make(map[string]string),
+	}
+}
+
+// WithClock sets the clock of the store, e.g. to share one with a FakeStorage.
+func (s *FakeStore) WithClock(clock *Clock) *FakeStore {
+	s.clock = clock
+	return s
+}
+
+// Clock returns the clock of the store.
+func (s *FakeStore) Clock() *Clock {
+	return s.clock
+}
+
+// Fail makes every call of method return err, until Fail is called again
+// with a nil error.
+func (s *FakeStore) Fail(method string, err error) {
+	s.rec.fail(method, err)
+}
+
+// FailNext makes the next call of method return err. Calling it several
+// times fails as many calls, in order.
+func (s *FakeStore) FailNext(method string, err error) {
+	s.rec.failNext(method, err)
+}
+
+// FailNextCreate makes the next Create return err.
+func (s *FakeStore) FailNextCreate(err error) {
+	s.FailNext("Create", err)
+}
+
+// FailNextGet makes the next Get return err.
+func (s *FakeStore) FailNextGet(err error) {
+	s.FailNext("Get", err)
+}
+
+// FailNextSet makes the next Set return err.
+func (s *FakeStore) FailNextSet(err error) {
+	s.FailNext("Set", err)
+}
+
+// FailNextDelete makes the next Delete return err.
+func (s *FakeStore) FailNextDelete(err error) {
+	s.FailNext("Delete", err)
+}
+
+// Calls returns the calls made so far, in order.
+func (s *FakeStore) Calls() []Call {
+	return s.rec.snapshot()
+}
+
+// ClearCalls forgets the calls made so far.
+func (s *FakeStore) ClearCalls() {
+	s.rec.clear()
+}
+
+// call runs fn unless an error is injected into method or ctx is done, and
+// records the call.
+func (s *FakeStore) call(ctx context.Context, method, id string, ttl time.Duration, fn func() error) error {
+	err := s.rec.injected(method)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		s.mu.Lock()
+		err = fn()
+		s.mu.Unlock()
+	}
+	s.rec.record(Call{Method: method, Key: id, TTL: ttl, Err: err})
+	return err
+}
+
+// lookup returns the record for id if it exists and has not expired.
+// s.mu must be held.
+func (s *FakeStore) lookup(id string) (session.KVSessionRecord, bool) {
+	rec, ok := s.records[id]
+	if !ok {
+		return rec, false
+	}
+	if s.clock.Now().After(rec.ExpiresAt) {
+		delete(s.records, id)
+		return rec, false
+	}
+	return rec, true
+}
+
+// clonedData returns a copy of data, empty rather than nil like the data
+// written by RedisStore.
+func clonedData(data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return make(map[string]interface{})
+	}
+	return maps.Clone(data)
+}
+
+// store stores data for id. s.mu must be held.
+func (s *FakeStore) store(id string, data map[string]interface{}, ttl time.Duration) {
+	now := s.clock.Now()
+	rec := session.KVSessionRecord{
+		Revision:  1,
+		ID:        id,
+		Data:      clonedData(data),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if existing, ok := s.lookup(id); ok {
+		rec.Revision = existing.Revision + 1
+		rec.CreatedAt = existing.CreatedAt
+		rec.Meta = existing.Meta
+	}
+	s.records[id] = rec
+}
+
+// Create creates a new session and returns its ID. ttl must be positive.
+func (s *FakeStore) Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (string, error) {
+	return s.create(ctx, "Create", data, nil, ttl)
+}
+
+// CreateWithMeta is Create recording meta in the session.
+func (s *FakeStore) CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error) {
+	return s.create(ctx, "CreateWithMeta", data, meta, ttl)
+}
+
+// create implements Create and CreateWithMeta, recording the call as method.
+func (s *FakeStore) create(ctx context.Context, method string, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error) {
+	var id string
+	err := s.call(ctx, method, "", ttl, func() error {
+		if ttl <= 0 {
+			return fmt.Errorf("session ttl must be > 0")
+		}
+		s.nextID++
+		id = fmt.Sprintf("sess_%d", s.nextID)
+		s.store(id, data, ttl)
+		rec := s.records[id]
+		rec.Meta = maps.Clone(meta)
+		s.records[id] = rec
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// CreateWithID creates a session with the given ID. Returns
+// session.ErrAlreadyExists if a session with the ID exists.
+func (s *FakeStore) CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	return s.call(ctx, "CreateWithID", id, ttl, func() error {
+		if err := session.ValidateSessionID(id); err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("session ttl must be > 0")
+		}
+		if _, ok := s.lookup(id); ok {
+			return session.ErrAlreadyExists
+		}
+		s.store(id, data, ttl)
+		return nil
+	})
+}
+
+// Get returns a copy of the session for the given ID.
+// Returns nil, nil if the session does not exist or has expired.
+func (s *FakeStore) Get(ctx context.Context, id string) (*session.KVSessionRecord, error) {
+	var out *session.KVSessionRecord
+	err := s.call(ctx, "Get", id, 0, func() error {
+		if rec, ok := s.lookup(id); ok {
+			rec.Data = maps.Clone(rec.Data)
+			rec.Meta = maps.Clone(rec.Meta)
+			out = &rec
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Set stores or updates the session for the given ID with the given ttl.
+// When updating an existing session, CreatedAt is preserved. If ttl is 0,
+// an existing session keeps its expiration and a missing one is not
+// created: session.ErrSessionNotFound is returned.
+func (s *FakeStore) Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	return s.call(ctx, "Set", id, ttl, func() error {
+		if ttl <= 0 {
+			rec, ok := s.lookup(id)
+			if !ok {
+				return fmt.Errorf("cannot create a session without a ttl: %w", session.ErrSessionNotFound)
+			}
+			ttl = rec.ExpiresAt.Sub(s.clock.Now())
+		}
+		s.store(id, data, ttl)
+		return nil
+	})
+}
+
+// Update replaces the data of the session with the result of fn, called
+// with a copy of the current data, atomically. If ttl is 0, the session
+// keeps its expiration. Returns session.ErrSessionNotFound if the session
+// does not exist or has expired.
+func (s *FakeStore) Update(ctx context.Context, id string, fn func(data map[string]interface{}) (map[string]interface{}, error), ttl time.Duration) error {
+	return s.call(ctx, "Update", id, ttl, func() error {
+		rec, ok := s.lookup(id)
+		if !ok {
+			return session.ErrSessionNotFound
+		}
+		data := maps.Clone(rec.Data)
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data, err := fn(data)
+		if err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			ttl = rec.ExpiresAt.Sub(s.clock.Now())
+		}
+		s.store(id, data, ttl)
+		return nil
+	})
+}
+
+// Touch sets the expiration of the session to ttl from now.
+// Returns session.ErrSessionNotFound if the session does not exist or has
+// expired.
+func (s *FakeStore) Touch(ctx context.Context, id string, ttl time.Duration) error {
+	return s.call(ctx, "Touch", id, ttl, func() error {
+		rec, ok := s.lookup(id)
+		if !ok {
+			return session.ErrSessionNotFound
+		}
+		rec.ExpiresAt = s.clock.Now().Add(ttl)
+		s.records[id] = rec
+		return nil
+	})
+}
+
+// GetAndTouch sets the expiration of the session to ttl from now and
+// returns the updated record.
+func (s *FakeStore) GetAndTouch(ctx context.Context, id string, ttl time.Duration) (*session.KVSessionRecord, error) {
+	var out *session.KVSessionRecord
+	err := s.call(ctx, "GetAndTouch", id, ttl, func() error {
+		rec, ok := s.lookup(id)
+		if !ok {
+			return session.ErrSessionNotFound
+		}
+		rec.ExpiresAt = s.clock.Now().Add(ttl)
+		s.records[id] = rec
+		rec.Data = maps.Clone(rec.Data)
+		rec.Meta = maps.Clone(rec.Meta)
+		out = &rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetCAS is Set if the revision of the session is expectedRevision, and
+// returns session.ErrRevisionMismatch otherwise.
+func (s *FakeStore) SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error {
+	return s.call(ctx, "SetCAS", id, ttl, func() error {
+		rec, ok := s.lookup(id)
+		if !ok {
+			return session.ErrSessionNotFound
+		}
+		if rec.Revision != expectedRevision {
+			return session.ErrRevisionMismatch
+		}
+		if ttl <= 0 {
+			ttl = rec.ExpiresAt.Sub(s.clock.Now())
+		}
+		s.store(id, data, ttl)
+		return nil
+	})
+}
+
+// GetTTL returns the time left before the session expires on the Clock.
+// Returns session.ErrSessionNotFound if the session does not exist or has
+// expired.
+func (s *FakeStore) GetTTL(ctx context.Context, id string) (time.Duration, error) {
+	var ttl time.Duration
+	err := s.call(ctx, "GetTTL", id, 0, func() error {
+		rec, ok := s.lookup(id)
+		if ok {
+			ttl = rec.ExpiresAt.Sub(s.clock.Now())
+		}
+		if ttl <= 0 {
+			return session.ErrSessionNotFound
+		}
+		return nil
+	})
+	return ttl, err
+}
+
+// List returns a page of at most limit session IDs in sorted order, or all
+// of them if limit is not positive. The cursor is the offset of the page.
+func (s *FakeStore) List(ctx context.Context, cursor string, limit int) ([]string, string, error) {
+	var page []string
+	var next string
+	err := s.call(ctx, "List", cursor, 0, func() error {
+		start := 0
+		if cursor != "" {
+			n, err := strconv.Atoi(cursor)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid list cursor %q", cursor)
+			}
+			start = n
+		}
+		var ids []string
+		for id := range s.records {
+			if _, ok := s.lookup(id); ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if start > len(ids) {
+			start = len(ids)
+		}
+		end := len(ids)
+		if limit > 0 && start+limit < end {
+			end = start + limit
+			next = strconv.Itoa(end)
+		}
+		page = ids[start:end]
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return page, next, nil
+}
+
+// GetMulti returns copies of the sessions for the given IDs, leaving out
+// missing and expired ones.
+func (s *FakeStore) GetMulti(ctx context.Context, ids []string) (map[string]*session.KVSessionRecord, error) {
+	records := make(map[string]*session.KVSessionRecord, len(ids))
+	err := s.call(ctx, "GetMulti", strings.Join(ids, ","), 0, func() error {
+		for _, id := range ids {
+			if rec, ok := s.lookup(id); ok {
+				rec.Data = maps.Clone(rec.Data)
+				rec.Meta = maps.Clone(rec.Meta)
+				records[id] = &rec
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// DeleteMulti removes the sessions for the given IDs.
+func (s *FakeStore) DeleteMulti(ctx context.Context, ids []string) error {
+	return s.call(ctx, "DeleteMulti", strings.Join(ids, ","), 0, func() error {
+		for _, id := range ids {
+			delete(s.records, id)
+		}
+		return nil
+	})
+}
+
+// Delete removes the session for the given ID.
+func (s *FakeStore) Delete(ctx context.Context, id string) error {
+	return s.call(ctx, "Delete", id, 0, func() error {
+		delete(s.records, id)
+		return nil
+	})
+}
+
+// Exists reports whether a session exists for the given ID.
+func (s *FakeStore) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := s.call(ctx, "Exists", id, 0, func() error {
+		_, exists = s.lookup(id)
+		return nil
+	})
+	return exists, err
+}
+
+// AddToIndex adds id to the index.
+func (s *FakeStore) AddToIndex(ctx context.Context, index, id string, ttl time.Duration) error {
+	return s.call(ctx, "AddToIndex", id, ttl, func() error {
+		if s.indexes[index] == nil {
+			s.indexes[index] = make(map[string]int)
+		}
+		s.nextSeq++
+		s.indexes[index][id] = s.nextSeq
+		return nil
+	})
+}
+
+// RemoveFromIndex removes ids from the index.
+func (s *FakeStore) RemoveFromIndex(ctx context.Context, index string, ids ...string) error {
+	return s.call(ctx, "RemoveFromIndex", strings.Join(ids, ","), 0, func() error {
+		for _, id := range ids {
+			delete(s.indexes[index], id)
+		}
+		return nil
+	})
+}
+
+// ListIndex returns the IDs in the index, oldest first.
+func (s *FakeStore) ListIndex(ctx context.Context, index string) ([]string, error) {
+	var ids []string
+	err := s.call(ctx, "ListIndex", index, 0, func() error {
+		for id := range s.indexes[index] {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return s.indexes[index][ids[i]] < s.indexes[index][ids[j]]
+		})
+		return nil
+	})
+	return ids, err
+}
+
+// SetLookup maps key to id.
+func (s *FakeStore) SetLookup(ctx context.Context, key, id string, ttl time.Duration) error {
+	return s.call(ctx, "SetLookup", key, ttl, func() error {
+		s.lookups[key] = id
+		return nil
+	})
+}
+
+// GetLookup returns the ID mapped to key, or "" if there is none.
+func (s *FakeStore) GetLookup(ctx context.Context, key string) (string, error) {
+	var id string
+	err := s.call(ctx, "GetLookup", key, 0, func() error {
+		id = s.lookups[key]
+		return nil
+	})
+	return id, err
+}
+
+// DeleteLookup removes the mapping of key if it still maps to id.
+func (s *FakeStore) DeleteLookup(ctx context.Context, key, id string) error {
+	return s.call(ctx, "DeleteLookup", key, 0, func() error {
+		if s.lookups[key] == id {
+			delete(s.lookups, key)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,395 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sqliteSchema creates the sessions table. expires_at holds Unix
+// milliseconds, 0 meaning never.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS sessions_expires_at ON sessions (expires_at);
+`
+
+// SQLiteStorageConfig represents configuration for SQLiteStorage.
+type SQLiteStorageConfig struct {
+	// DB is an existing database handle. If provided, DSN and DriverName are
+	// ignored and Close leaves the handle open.
+	DB *sql.DB
+
+	// DSN is the data source name of the database to open, such as
+	// "file:sessions.db?_busy_timeout=5000".
+	DSN string
+
+	// DriverName is the database/sql driver used to open DSN. Building with
+	// the sqlite tag registers github.com/mattn/go-sqlite3 under "sqlite3";
+	// any other SQLite driver can be imported instead and named here.
+	// Default: "sqlite3"
+	DriverName string
+
+	// KeyPrefix is the prefix for session keys.
+	// Default: "session:"
+	KeyPrefix string
+
+	// GCInterval is how often expired rows are deleted.
+	// Set to 0 to disable GC; expired rows are still never returned.
+	// Default: 10 minutes
+	GCInterval time.Duration
+}
+
+// DefaultSQLiteStorageConfig returns a SQLiteStorageConfig with default values.
+func DefaultSQLiteStorageConfig() SQLiteStorageConfig {
+	return SQLiteStorageConfig{
+		DriverName: "sqlite3",
+		KeyPrefix:  "session:",
+		GCInterval: 10 * time.Minute,
+	}
+}
+
+// WithDB sets an existing database handle.
+func (c SQLiteStorageConfig) WithDB(db *sql.DB) SQLiteStorageConfig {
+	c.DB = db
+	return c
+}
+
+// WithDSN sets the data source name.
+func (c SQLiteStorageConfig) WithDSN(dsn string) SQLiteStorageConfig {
+	c.DSN = dsn
+	return c
+}
+
+// WithDriverName sets the database/sql driver name.
+func (c SQLiteStorageConfig) WithDriverName(name string) SQLiteStorageConfig {
+	c.DriverName = name
+	return c
+}
+
+// WithKeyPrefix sets the key prefix.
+func (c SQLiteStorageConfig) WithKeyPrefix(prefix string) SQLiteStorageConfig {
+	c.KeyPrefix = prefix
+	return c
+}
+
+// WithGCInterval sets the garbage collection interval.
+func (c SQLiteStorageConfig) WithGCInterval(interval time.Duration) SQLiteStorageConfig {
+	c.GCInterval = interval
+	return c
+}
+
+// SQLiteStorage implements Storage interface over a SQLite database, for
+// small deployments that already keep their data in one.
+//
+// Sessions live in a "sessions" table created on first use, keyed by the
+// prefixed key like the other backends. Expired rows are never returned and
+// are deleted periodically. SQLiteStorage only uses database/sql, so a
+// SQLite driver must be registered, either by building with the sqlite tag
+// or by importing one.
+type SQLiteStorage struct {
+	db        *sql.DB
+	ownsDB    bool
+	keyPrefix string
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+	gcStop    chan struct{}
+	gcDone    chan struct{}
+}
+
+// NewSQLiteStorage creates a new SQLite storage using an existing database
+// handle, which Close leaves open. If gcInterval is 0, the periodic deletion
+// of expired rows is disabled.
+func NewSQLiteStorage(db *sql.DB, keyPrefix string, gcInterval time.Duration) (*SQLiteStorage, error) {
+	cfg := DefaultSQLiteStorageConfig().
+		WithDB(db).
+		WithKeyPrefix(keyPrefix).
+		WithGCInterval(gcInterval)
+	return NewSQLiteStorageWithConfig(cfg)
+}
+
+// NewSQLiteStorageWithConfig creates a new SQLite storage using configuration,
+// opening the database unless cfg.DB is set, and creates the sessions table
+// if it does not exist.
+func NewSQLiteStorageWithConfig(cfg SQLiteStorageConfig) (*SQLiteStorage, error) {
+	db, ownsDB := cfg.DB, false
+	if db == nil {
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("sqlite dsn cannot be empty")
+		}
+		driverName := cfg.DriverName
+		if driverName == "" {
+			driverName = DefaultSQLiteStorageConfig().DriverName
+		}
+		var err error
+		db, err = sql.Open(driverName, cfg.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+		}
+		ownsDB = true
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		if ownsDB {
+			_ = db.Close()
+		}
+		return nil, fmt.Errorf("failed to create sqlite sessions table: %w", err)
+	}
+
+	keyPrefix := cfg.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = "session:"
+	} else if keyPrefix[len(keyPrefix)-1] != ':' {
+		keyPrefix += ":"
+	}
+
+	s := &SQLiteStorage{
+		db:        db,
+		ownsDB:    ownsDB,
+		keyPrefix: keyPrefix,
+	}
+
+	if cfg.GCInterval > 0 {
+		s.gcStop = make(chan struct{})
+		s.gcDone = make(chan struct{})
+		go s.runGC(cfg.GCInterval)
+	}
+
+	return s, nil
+}
+
+// GetKeyPrefix returns the key prefix used by this storage.
+func (s *SQLiteStorage) GetKeyPrefix() string {
+	return s.keyPrefix
+}
+
+// buildKey constructs the full key with prefix.
+func (s *SQLiteStorage) buildKey(key string) string {
+	return s.keyPrefix + key
+}
+
+// sqliteExpiresAt returns the expires_at value for a TTL of exp.
+func sqliteExpiresAt(exp time.Duration) int64 {
+	if exp <= 0 {
+		return 0
+	}
+	return time.Now().Add(exp).UnixMilli()
+}
+
+// Get retrieves the value for the given key.
+// Returns nil, nil if the key does not exist or has expired.
+func (s *SQLiteStorage) Get(key string) ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	var data []byte
+	err := s.db.QueryRow(
+		`SELECT value FROM sessions WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		s.buildKey(key), time.Now().UnixMilli(),
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from sqlite: %w", err)
+	}
+	return data, nil
+}
+
+// Set stores the given value for the given key along with an expiration value.
+// If expiration is 0, the value never expires.
+// Empty key or value will be ignored without an error.
+func (s *SQLiteStorage) Set(key string, val []byte, exp time.Duration) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO sessions (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		s.buildKey(key), val, sqliteExpiresAt(exp),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set in sqlite: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent stores the given value for the given key unless the key
+// exists and has not expired, and reports whether it was stored. If exp is
+// 0, the value never expires.
+func (s *SQLiteStorage) SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+	if key == "" || len(val) == 0 {
+		return false, nil
+	}
+
+	// Expired rows are replaced; live ones leave no row affected
+	res, err := s.db.Exec(
+		`INSERT INTO sessions (key, value, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at
+		WHERE sessions.expires_at != 0 AND sessions.expires_at <= ?`,
+		s.buildKey(key), val, sqliteExpiresAt(exp), time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to set in sqlite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to set in sqlite: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Delete removes the value for the given key.
+// It returns no error if the storage does not contain the key.
+func (s *SQLiteStorage) Delete(key string) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE key = ?`, s.buildKey(key)); err != nil {
+		return fmt.Errorf("failed to delete from sqlite: %w", err)
+	}
+	return nil
+}
+
+// Reset removes all keys with the configured prefix.
+func (s *SQLiteStorage) Reset() error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	// The prefix ends with ':', so incrementing its last byte gives the
+	// smallest string greater than every key with the prefix
+	upper := s.keyPrefix[:len(s.keyPrefix)-1] + string(rune(s.keyPrefix[len(s.keyPrefix)-1]+1))
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE key >= ? AND key < ?`, s.keyPrefix, upper); err != nil {
+		return fmt.Errorf("failed to reset sqlite: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether the key exists and has not expired.
+func (s *SQLiteStorage) Exists(key string) (bool, error) {
+	data, err := s.Get(key)
+	return data != nil, err
+}
+
+// GetTTL returns the remaining TTL for a key.
+// Returns -2 if the key does not exist, -1 if the key has no expiration.
+func (s *SQLiteStorage) GetTTL(key string) (time.Duration, error) {
+	if s.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	var expiresAt int64
+	err := s.db.QueryRow(`SELECT expires_at FROM sessions WHERE key = ?`, s.buildKey(key)).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return -2, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get ttl from sqlite: %w", err)
+	}
+
+	if expiresAt == 0 {
+		return -1, nil
+	}
+	ttl := time.Until(time.UnixMilli(expiresAt))
+	if ttl <= 0 {
+		return -2, nil
+	}
+	return ttl, nil
+}
+
+// Expire sets a new expiration on a key.
+// If exp is 0, the expiration is removed.
+func (s *SQLiteStorage) Expire(key string, exp time.Duration) error {
+	_, err := s.Touch(key, exp)
+	return err
+}
+
+// Touch sets a new expiration on a key without rewriting its value and
+// reports whether the key existed. If exp is 0, the expiration is removed.
+func (s *SQLiteStorage) Touch(key string, exp time.Duration) (bool, error) {
+	if s.closed.Load() {
+		return false, ErrClosed
+	}
+
+	res, err := s.db.Exec(
+		`UPDATE sessions SET expires_at = ? WHERE key = ? AND (expires_at = 0 OR expires_at > ?)`,
+		sqliteExpiresAt(exp), s.buildKey(key), time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to set expiration in sqlite: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to set expiration in sqlite: %w", err)
+	}
+	return n > 0, nil
+}
+
+// runGC deletes expired rows periodically until Close.
+func (s *SQLiteStorage) runGC(interval time.Duration) {
+	defer close(s.gcDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = s.gc()
+		case <-s.gcStop:
+			return
+		}
+	}
+}
+
+// gc deletes expired rows of every prefix and returns how many it deleted.
+func (s *SQLiteStorage) gc() (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at != 0 AND expires_at <= ?`, time.Now().UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions from sqlite: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Ping checks that the database is reachable.
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	return s.db.PingContext(ctx)
+}
+
+// Close stops garbage collection and closes the database if the storage
+// opened it. Close is idempotent.
+func (s *SQLiteStorage) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+		if s.gcStop != nil {
+			close(s.gcStop)
+			<-s.gcDone
+		}
+		if s.ownsDB {
+			err = s.db.Close()
+		}
+	})
+	return err
+}
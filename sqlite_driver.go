@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package session
+
+// Building with the sqlite tag registers the cgo SQLite driver under
+// "sqlite3", the default SQLiteStorageConfig.DriverName.
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,194 @@
+//go:build sqlite
+
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStorage(t *testing.T, dsn, prefix string) *SQLiteStorage {
+	t.Helper()
+	storage, err := NewSQLiteStorageWithConfig(DefaultSQLiteStorageConfig().
+		WithDSN(dsn).
+		WithKeyPrefix(prefix).
+		WithGCInterval(0))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestSQLiteStorageBasic(t *testing.T) {
+	storage := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "sessions.db"), "test")
+
+	if storage.GetKeyPrefix() != "test:" {
+		t.Errorf("expected prefix 'test:', got %s", storage.GetKeyPrefix())
+	}
+	if got, err := storage.Get("key"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing key, got %q (%v)", got, err)
+	}
+
+	_ = storage.Set("key", []byte("value1"), time.Hour)
+	_ = storage.Set("key", []byte("value2"), time.Hour)
+	if got, _ := storage.Get("key"); string(got) != "value2" {
+		t.Errorf("expected the upsert to replace the value, got %q", got)
+	}
+
+	// Empty key or value is ignored
+	_ = storage.Set("", []byte("value"), 0)
+	_ = storage.Set("empty", nil, 0)
+	if ok, _ := storage.Exists("empty"); ok {
+		t.Error("expected empty value to be ignored")
+	}
+
+	if err := storage.Delete("key"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if ok, _ := storage.Exists("key"); ok {
+		t.Error("expected key to be deleted")
+	}
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error on ping: %v", err)
+	}
+}
+
+func TestSQLiteStorageTTL(t *testing.T) {
+	storage := newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "sessions.db"), "test:")
+
+	_ = storage.Set("forever", []byte("value"), 0)
+	_ = storage.Set("hour", []byte("value"), time.Hour)
+	_ = storage.Set("short", []byte("value"), 20*time.Millisecond)
+
+	if ttl, _ := storage.GetTTL("forever"); ttl != -1 {
+		t.Errorf("expected -1 for no expiration, got %v", ttl)
+	}
+	if ttl, _ := storage.GetTTL("hour"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("expected about an hour, got %v", ttl)
+	}
+	if ttl, _ := storage.GetTTL("missing"); ttl != -2 {
+		t.Errorf("expected -2 for a missing key, got %v", ttl)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := storage.Get("short"); got != nil {
+		t.Error("expected expired key to be filtered out")
+	}
+	if ttl, _ := storage.GetTTL("short"); ttl != -2 {
+		t.Errorf("expected -2 for an expired key, got %v", ttl)
+	}
+	if ok, _ := storage.Touch("short", time.Hour); ok {
+		t.Error("expected Touch not to revive an expired key")
+	}
+
+	if ok, err := storage.Touch("forever", time.Hour); !ok || err != nil {
+		t.Errorf("expected Touch to succeed, got %v (%v)", ok, err)
+	}
+	if ttl, _ := storage.GetTTL("forever"); ttl <= 0 {
+		t.Errorf("expected an expiration after Touch, got %v", ttl)
+	}
+	if err := storage.Expire("forever", 0); err != nil {
+		t.Fatalf("failed to expire: %v", err)
+	}
+	if ttl, _ := storage.GetTTL("forever"); ttl != -1 {
+		t.Errorf("expected Expire(0) to remove the expiration, got %v", ttl)
+	}
+
+	if n, err := storage.gc(); n != 1 || err != nil {
+		t.Errorf("expected 1 expired row deleted, got %d (%v)", n, err)
+	}
+}
+
+func TestSQLiteStorageSetIfAbsent(t *testing.T) {
+	testSetIfAbsent(t, newTestSQLiteStorage(t, filepath.Join(t.TempDir(), "sessions.db"), "test"))
+}
+
+func TestSQLiteStorageResetAndSharedDB(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	storage, err := NewSQLiteStorage(db, "a:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	other, err := NewSQLiteStorage(db, "b:", 0)
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	_ = storage.Set("key1", []byte("value"), time.Hour)
+	_ = storage.Set("key2", []byte("value"), 0)
+	_ = other.Set("key1", []byte("other"), time.Hour)
+
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if ok, _ := storage.Exists("key1"); ok {
+		t.Error("expected key1 to be reset")
+	}
+	if ok, _ := storage.Exists("key2"); ok {
+		t.Error("expected key2 to be reset")
+	}
+	if got, _ := other.Get("key1"); string(got) != "other" {
+		t.Errorf("expected other prefix to survive reset, got %q", got)
+	}
+
+	// Closing a storage built on a shared handle leaves it open
+	_ = storage.Close()
+	if _, err := storage.Get("key1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("expected the shared database to stay open, got %v", err)
+	}
+	_ = other.Close()
+}
+
+func TestSQLiteStoragePersistsAcrossRestart(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "sessions.db")
+
+	storage, err := NewStorage(DefaultStorageConfig().WithType(StorageTypeSQLite).WithSQLiteDSN(dsn).WithKeyPrefix("test:"))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Close()
+
+	reopened := newTestSQLiteStorage(t, dsn, "test:")
+	if got, _ := reopened.Get("key"); string(got) != "value" {
+		t.Errorf("expected key to survive the restart, got %q", got)
+	}
+}
+
+func TestSQLiteStorageGCLoop(t *testing.T) {
+	storage, err := NewSQLiteStorageWithConfig(DefaultSQLiteStorageConfig().
+		WithDSN(filepath.Join(t.TempDir(), "sessions.db")).
+		WithGCInterval(10 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 5*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var n int
+		_ = storage.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&n)
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected GC to delete the expired row")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"time"
 )
 
@@ -27,6 +28,144 @@ type Storage interface {
 	Close() error
 }
 
+// ExtendedStorage is implemented by storages that support TTL inspection and
+// manipulation in addition to the basic Storage operations.
+// Both MemoryStorage and RedisStorage implement it.
+type ExtendedStorage interface {
+	Storage
+
+	// Exists reports whether the key exists and has not expired.
+	Exists(key string) (bool, error)
+
+	// GetTTL returns the remaining TTL for the key.
+	// Returns -2 if the key does not exist, -1 if the key has no expiration.
+	GetTTL(key string) (time.Duration, error)
+
+	// Expire sets a new expiration on the key.
+	Expire(key string, exp time.Duration) error
+
+	// Touch sets a new expiration on the key without rewriting its value and
+	// reports whether the key existed. If exp is 0, the expiration is removed.
+	Touch(key string, exp time.Duration) (bool, error)
+}
+
+// BatchDeleter is implemented by storages that can remove several keys in
+// one operation. Manager uses it when available.
+type BatchDeleter interface {
+	// DeleteMany removes the values for the given keys.
+	// Missing keys are ignored and an empty slice is a no-op.
+	DeleteMany(keys []string) error
+}
+
+// PatternDeleter is implemented by storages that can remove the keys
+// matching a Redis glob pattern, such as MemoryStorage and RedisStorage.
+// NamespacedStorage uses it to reset a single namespace.
+type PatternDeleter interface {
+	// DeleteByPattern removes the entries whose key (without prefix) matches
+	// the pattern and returns how many were removed. Use EscapePattern for
+	// user-provided fragments.
+	DeleteByPattern(pattern string) (int, error)
+}
+
+// TTLKeeper is implemented by storages that can update a value without
+// touching its expiration. Manager uses it so that saving a session without
+// an expiration does not make an expiring key immortal.
+type TTLKeeper interface {
+	// SetKeepTTL stores the value, keeping the key's current expiration.
+	// If the key does not exist, the value is stored without an expiration.
+	SetKeepTTL(key string, val []byte) error
+}
+
+// AbsentSetter is implemented by storages that can store a value only if
+// the key does not exist, in one atomic step, such as MemoryStorage,
+// RedisStorage, SQLiteStorage and BoltStorage. RedeemRememberToken requires
+// it so that each token is redeemed once.
+type AbsentSetter interface {
+	// SetIfAbsent stores the value unless the key exists and has not
+	// expired, and reports whether it was stored. If exp is 0, the value
+	// never expires.
+	SetIfAbsent(key string, val []byte, exp time.Duration) (bool, error)
+}
+
+// Refresher is implemented by storages that can read a value and extend its
+// expiration in one atomic step. Manager prefers it for sliding expiration.
+type Refresher interface {
+	// GetAndRefresh returns the value for the key and sets a new expiration
+	// on it. If exp is 0, the expiration is removed.
+	// Returns nil, nil if the key does not exist.
+	GetAndRefresh(key string, exp time.Duration) ([]byte, error)
+}
+
+// BatchStorage is implemented by storages that can read and write several
+// keys in one round trip.
+type BatchStorage interface {
+	// GetMulti retrieves the values for the given keys. Missing keys are
+	// absent from the returned map and an empty slice is a no-op.
+	GetMulti(keys []string) (map[string][]byte, error)
+
+	// SetMulti stores all items with the same expiration. Entries with an
+	// empty key or value are skipped, like Set does; an empty map is a no-op.
+	SetMulti(items map[string][]byte, exp time.Duration) error
+}
+
+// ExpiryNotifier is implemented by storages that can report keys expiring
+// in the background, without anyone reading them. Manager uses it to fire
+// its OnExpired hooks.
+type ExpiryNotifier interface {
+	// NotifyExpired registers fn to be called with the key (without prefix)
+	// of every entry that expires. Registering again replaces the previous function.
+	NotifyExpired(fn func(key string)) error
+}
+
+// TTLGetter is implemented by storages that can read a value together with
+// its remaining TTL in one step. Manager.LoadSessionWithTTL uses it.
+type TTLGetter interface {
+	// GetWithTTL returns the value for the key and its remaining TTL.
+	// Returns nil, 0, nil if the key does not exist and a TTL of -1 if the
+	// key has no expiration.
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+// FieldUpdater is implemented by storages that store sessions field by
+// field, such as RedisHashStorage. Manager.TouchSession uses it to update
+// the access and expiration times without rewriting the whole session.
+type FieldUpdater interface {
+	// UpdateFields sets the given top-level session fields, named by their
+	// JSON names, keeping the others and the key's expiration. It reports
+	// whether the session existed; missing sessions are not created.
+	UpdateFields(key string, fields map[string]string) (bool, error)
+}
+
+// ContextStorage is implemented by storages whose basic operations accept a
+// context, so they can be cancelled or bounded by a deadline. Manager uses it
+// to apply Config.StorageTimeout.
+type ContextStorage interface {
+	// GetCtx is like Storage.Get but uses the given context.
+	GetCtx(ctx context.Context, key string) ([]byte, error)
+
+	// SetCtx is like Storage.Set but uses the given context.
+	SetCtx(ctx context.Context, key string, val []byte, exp time.Duration) error
+
+	// DeleteCtx is like Storage.Delete but uses the given context.
+	DeleteCtx(ctx context.Context, key string) error
+}
+
+// Pinger is implemented by storages that can check whether their backend is
+// reachable. Manager.HealthCheck uses it, for example in readiness probes.
+type Pinger interface {
+	// Ping returns an error if the storage cannot serve requests.
+	Ping(ctx context.Context) error
+}
+
+// pingStorage pings s if it implements Pinger. Storages that cannot be
+// pinged are assumed to be healthy.
+func pingStorage(ctx context.Context, s Storage) error {
+	if pinger, ok := s.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
 // SessionData represents the data stored in a session.
 type SessionData struct {
 	// ID is the unique session identifier.
@@ -61,6 +200,17 @@ type SessionData struct {
 
 	// Scopes are the authorization scopes for this session.
 	Scopes []string `json:"scopes,omitempty"`
+
+	// IPAddress is the IP address of the client that authenticated.
+	IPAddress string `json:"ip_address,omitempty"`
+
+	// UserAgent is the User-Agent of the client that authenticated.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// storedAuthenticated records that the session was authenticated when
+	// the Manager last loaded or saved it, so saving it again does not
+	// have to read the stored copy to detect a login.
+	storedAuthenticated bool
 }
 
 // NewSessionData creates a new SessionData with the given ID and expiration.
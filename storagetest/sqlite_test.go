@@ -0,0 +1,22 @@
+//go:build sqlite
+
+package storagetest_test
+
+import (
+	"testing"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/storagetest"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	storagetest.TestStorage(t, func() session.Storage {
+		storage, err := session.NewSQLiteStorageWithConfig(session.DefaultSQLiteStorageConfig().
+			WithDSN(t.TempDir() + "/s.db").
+			WithGCInterval(0))
+		if err != nil {
+			t.Fatalf("failed to create sqlite storage: %v", err)
+		}
+		return storage
+	})
+}
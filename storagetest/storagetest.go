@@ -0,0 +1,374 @@
+// Package storagetest provides a conformance test suite for implementations
+// of session.Storage, so that custom backends can check that they honor the
+// same contract as the built-in ones with a single call:
+//
+//	func TestMyStorage(t *testing.T) {
+//		storagetest.TestStorage(t, func() session.Storage {
+//			return NewMyStorage(...)
+//		})
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	session "github.com/soulteary/session-kit"
+)
+
+// Options configures the conformance suite.
+type Options struct {
+	// Advance lets time pass for the storage under test, for the expiration
+	// tests. Storages backed by a fake clock, such as miniredis, can move it
+	// forward instead of sleeping.
+	// Default: time.Sleep
+	Advance func(d time.Duration)
+
+	// TTL is the expiration used by the expiration tests. Advance is called
+	// with twice this value to let entries expire.
+	// Default: 100 milliseconds
+	TTL time.Duration
+}
+
+// DefaultOptions returns Options with default values.
+func DefaultOptions() Options {
+	return Options{
+		Advance: time.Sleep,
+		TTL:     100 * time.Millisecond,
+	}
+}
+
+// WithAdvance sets the function letting time pass for the storage.
+func (o Options) WithAdvance(fn func(d time.Duration)) Options {
+	o.Advance = fn
+	return o
+}
+
+// WithTTL sets the expiration used by the expiration tests.
+func (o Options) WithTTL(ttl time.Duration) Options {
+	o.TTL = ttl
+	return o
+}
+
+// TestStorage runs the conformance suite against the storages returned by
+// factory, with default options. See TestStorageWithOptions.
+func TestStorage(t *testing.T, factory func() session.Storage) {
+	t.Helper()
+	TestStorageWithOptions(t, factory, DefaultOptions())
+}
+
+// TestStorageWithOptions runs the conformance suite against the storages
+// returned by factory. Every subtest calls factory once and closes the
+// storage when done, so factory must return a new, empty storage each time.
+//
+// The suite checks that:
+//   - Get returns nil, nil for missing keys
+//   - Set stores, overwrites and Delete removes values; deleting a missing
+//     key is not an error
+//   - empty keys and empty values are ignored by Set without an error
+//   - entries expire after their TTL, and never with a TTL of 0
+//   - Reset removes every entry
+//   - stored and returned values do not share memory with the caller
+//   - concurrent use is safe, when run with -race
+//   - Close can be called more than once
+//   - ExtendedStorage methods, if implemented, follow their documentation
+func TestStorageWithOptions(t *testing.T, factory func() session.Storage, opts Options) {
+	t.Helper()
+	if opts.Advance == nil {
+		opts.Advance = DefaultOptions().Advance
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultOptions().TTL
+	}
+
+	s := &suite{factory: factory, opts: opts}
+	t.Run("GetMissing", s.testGetMissing)
+	t.Run("SetGet", s.testSetGet)
+	t.Run("Overwrite", s.testOverwrite)
+	t.Run("Delete", s.testDelete)
+	t.Run("EmptyKeyAndValue", s.testEmptyKeyAndValue)
+	t.Run("Expiration", s.testExpiration)
+	t.Run("ZeroTTL", s.testZeroTTL)
+	t.Run("Reset", s.testReset)
+	t.Run("Isolation", s.testIsolation)
+	t.Run("Concurrency", s.testConcurrency)
+	t.Run("CloseIdempotent", s.testCloseIdempotent)
+	t.Run("Extended", s.testExtended)
+}
+
+// suite holds the state shared by the conformance tests.
+type suite struct {
+	factory func() session.Storage
+	opts    Options
+}
+
+// newStorage returns a storage from the factory, closed at the end of t.
+func (s *suite) newStorage(t *testing.T) session.Storage {
+	t.Helper()
+	storage := s.factory()
+	if storage == nil {
+		t.Fatal("factory returned a nil storage")
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+// mustSet stores val for key and fails the test on error.
+func mustSet(t *testing.T, storage session.Storage, key string, val []byte, exp time.Duration) {
+	t.Helper()
+	if err := storage.Set(key, val, exp); err != nil {
+		t.Fatalf("Set(%q) failed: %v", key, err)
+	}
+}
+
+// expectValue checks that Get returns want for key, nil meaning missing.
+func expectValue(t *testing.T, storage session.Storage, key string, want []byte) {
+	t.Helper()
+	got, err := storage.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q) failed: %v", key, err)
+	}
+	if want == nil && got != nil {
+		t.Fatalf("Get(%q) = %q, expected nil", key, got)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Get(%q) = %q, expected %q", key, got, want)
+	}
+}
+
+func (s *suite) testGetMissing(t *testing.T) {
+	storage := s.newStorage(t)
+	expectValue(t, storage, "missing", nil)
+}
+
+func (s *suite) testSetGet(t *testing.T) {
+	storage := s.newStorage(t)
+	mustSet(t, storage, "key", []byte("value"), time.Hour)
+	mustSet(t, storage, "binary", []byte{0, 1, 2, 0xff}, time.Hour)
+	mustSet(t, storage, "with:colon/and space", []byte("other"), time.Hour)
+
+	expectValue(t, storage, "key", []byte("value"))
+	expectValue(t, storage, "binary", []byte{0, 1, 2, 0xff})
+	expectValue(t, storage, "with:colon/and space", []byte("other"))
+}
+
+func (s *suite) testOverwrite(t *testing.T) {
+	storage := s.newStorage(t)
+	mustSet(t, storage, "key", []byte("first"), time.Hour)
+	mustSet(t, storage, "key", []byte("second"), time.Hour)
+	expectValue(t, storage, "key", []byte("second"))
+}
+
+func (s *suite) testDelete(t *testing.T) {
+	storage := s.newStorage(t)
+	mustSet(t, storage, "key", []byte("value"), time.Hour)
+	mustSet(t, storage, "other", []byte("value"), time.Hour)
+
+	if err := storage.Delete("key"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	expectValue(t, storage, "key", nil)
+	expectValue(t, storage, "other", []byte("value"))
+
+	if err := storage.Delete("missing"); err != nil {
+		t.Errorf("Delete of a missing key returned %v, expected nil", err)
+	}
+}
+
+func (s *suite) testEmptyKeyAndValue(t *testing.T) {
+	storage := s.newStorage(t)
+	if err := storage.Set("", []byte("value"), time.Hour); err != nil {
+		t.Errorf("Set with an empty key returned %v, expected nil", err)
+	}
+	expectValue(t, storage, "", nil)
+
+	if err := storage.Set("nil", nil, time.Hour); err != nil {
+		t.Errorf("Set with a nil value returned %v, expected nil", err)
+	}
+	expectValue(t, storage, "nil", nil)
+
+	if err := storage.Set("empty", []byte{}, time.Hour); err != nil {
+		t.Errorf("Set with an empty value returned %v, expected nil", err)
+	}
+	expectValue(t, storage, "empty", nil)
+}
+
+func (s *suite) testExpiration(t *testing.T) {
+	storage := s.newStorage(t)
+	mustSet(t, storage, "short", []byte("value"), s.opts.TTL)
+	mustSet(t, storage, "long", []byte("value"), time.Hour)
+	expectValue(t, storage, "short", []byte("value"))
+
+	s.opts.Advance(2 * s.opts.TTL)
+	expectValue(t, storage, "short", nil)
+	expectValue(t, storage, "long", []byte("value"))
+
+	// An expired key can be set again
+	mustSet(t, storage, "short", []byte("again"), time.Hour)
+	expectValue(t, storage, "short", []byte("again"))
+}
+
+func (s *suite) testZeroTTL(t *testing.T) {
+	storage := s.newStorage(t)
+	mustSet(t, storage, "key", []byte("value"), 0)
+	s.opts.Advance(2 * s.opts.TTL)
+	expectValue(t, storage, "key", []byte("value"))
+}
+
+func (s *suite) testReset(t *testing.T) {
+	storage := s.newStorage(t)
+	for i := 0; i < 10; i++ {
+		mustSet(t, storage, fmt.Sprintf("key-%d", i), []byte("value"), time.Hour)
+	}
+	mustSet(t, storage, "persistent", []byte("value"), 0)
+
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		expectValue(t, storage, fmt.Sprintf("key-%d", i), nil)
+	}
+	expectValue(t, storage, "persistent", nil)
+
+	// The storage is still usable
+	mustSet(t, storage, "key", []byte("value"), time.Hour)
+	expectValue(t, storage, "key", []byte("value"))
+}
+
+func (s *suite) testIsolation(t *testing.T) {
+	storage := s.newStorage(t)
+	val := []byte("value")
+	mustSet(t, storage, "key", val, time.Hour)
+
+	// Modifying the slice passed to Set must not change the stored value
+	val[0] = 'X'
+	expectValue(t, storage, "key", []byte("value"))
+
+	// Nor must modifying a slice returned by Get
+	got, err := storage.Get("key")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	got[0] = 'Y'
+	expectValue(t, storage, "key", []byte("value"))
+}
+
+func (s *suite) testConcurrency(t *testing.T) {
+	storage := s.newStorage(t)
+	const workers, ops = 8, 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < ops; i++ {
+				own := fmt.Sprintf("worker-%d-%d", w, i)
+				val := []byte(own)
+				if err := storage.Set(own, val, time.Hour); err != nil {
+					errs <- fmt.Errorf("Set(%q): %w", own, err)
+					return
+				}
+				got, err := storage.Get(own)
+				if err != nil {
+					errs <- fmt.Errorf("Get(%q): %w", own, err)
+					return
+				}
+				if !bytes.Equal(got, val) {
+					errs <- fmt.Errorf("Get(%q) = %q, expected %q", own, got, val)
+					return
+				}
+
+				// All workers also race on a shared key
+				if err := storage.Set("shared", val, time.Hour); err != nil {
+					errs <- fmt.Errorf("Set(shared): %w", err)
+					return
+				}
+				if _, err := storage.Get("shared"); err != nil {
+					errs <- fmt.Errorf("Get(shared): %w", err)
+					return
+				}
+				if i%10 == 0 {
+					if err := storage.Delete(own); err != nil {
+						errs <- fmt.Errorf("Delete(%q): %w", own, err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func (s *suite) testCloseIdempotent(t *testing.T) {
+	storage := s.factory()
+	if storage == nil {
+		t.Fatal("factory returned a nil storage")
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Errorf("second Close returned %v, expected nil", err)
+	}
+}
+
+func (s *suite) testExtended(t *testing.T) {
+	storage, ok := s.newStorage(t).(session.ExtendedStorage)
+	if !ok {
+		t.Skip("storage does not implement session.ExtendedStorage")
+	}
+
+	mustSet(t, storage, "key", []byte("value"), time.Hour)
+	mustSet(t, storage, "persistent", []byte("value"), 0)
+
+	if exists, err := storage.Exists("key"); !exists || err != nil {
+		t.Errorf("Exists(key) = %v, %v, expected true", exists, err)
+	}
+	if exists, err := storage.Exists("missing"); exists || err != nil {
+		t.Errorf("Exists(missing) = %v, %v, expected false", exists, err)
+	}
+
+	if ttl, err := storage.GetTTL("key"); ttl <= 0 || ttl > time.Hour || err != nil {
+		t.Errorf("GetTTL(key) = %v, %v, expected at most an hour", ttl, err)
+	}
+	if ttl, err := storage.GetTTL("persistent"); ttl != -1 || err != nil {
+		t.Errorf("GetTTL(persistent) = %v, %v, expected -1", ttl, err)
+	}
+	if ttl, err := storage.GetTTL("missing"); ttl != -2 || err != nil {
+		t.Errorf("GetTTL(missing) = %v, %v, expected -2", ttl, err)
+	}
+
+	if err := storage.Expire("key", 2*time.Hour); err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl <= time.Hour {
+		t.Errorf("GetTTL after Expire = %v, expected more than an hour", ttl)
+	}
+
+	if found, err := storage.Touch("key", 3*time.Hour); !found || err != nil {
+		t.Errorf("Touch(key) = %v, %v, expected true", found, err)
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl <= 2*time.Hour {
+		t.Errorf("GetTTL after Touch = %v, expected more than two hours", ttl)
+	}
+	if found, err := storage.Touch("key", 0); !found || err != nil {
+		t.Errorf("Touch(key, 0) = %v, %v, expected true", found, err)
+	}
+	if ttl, _ := storage.GetTTL("key"); ttl != -1 {
+		t.Errorf("GetTTL after Touch(key, 0) = %v, expected -1", ttl)
+	}
+	if found, err := storage.Touch("missing", time.Hour); found || err != nil {
+		t.Errorf("Touch(missing) = %v, %v, expected false", found, err)
+	}
+	expectValue(t, storage, "key", []byte("value"))
+}
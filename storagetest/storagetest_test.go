@@ -0,0 +1,76 @@
+package storagetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	session "github.com/soulteary/session-kit"
+	"github.com/soulteary/session-kit/sessiontest"
+	"github.com/soulteary/session-kit/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.TestStorage(t, func() session.Storage {
+		return session.NewMemoryStorage("test:", 0)
+	})
+}
+
+func TestRedisStorage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opts := storagetest.DefaultOptions().WithAdvance(mr.FastForward)
+
+	storagetest.TestStorageWithOptions(t, func() session.Storage {
+		mr.FlushAll()
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		return session.NewRedisStorage(client, "test:")
+	}, opts)
+}
+
+func TestFileStorage(t *testing.T) {
+	storagetest.TestStorage(t, func() session.Storage {
+		storage, err := session.NewFileStorage(t.TempDir(), "test:", 0)
+		if err != nil {
+			t.Fatalf("failed to create file storage: %v", err)
+		}
+		return storage
+	})
+}
+
+func TestBoltStorage(t *testing.T) {
+	storagetest.TestStorage(t, func() session.Storage {
+		storage, err := session.NewBoltStorageWithConfig(session.DefaultBoltStorageConfig().
+			WithPath(t.TempDir() + "/s.db").
+			WithGCInterval(0))
+		if err != nil {
+			t.Fatalf("failed to create bolt storage: %v", err)
+		}
+		return storage
+	})
+}
+
+func TestStoreStorage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	opts := storagetest.DefaultOptions().WithAdvance(mr.FastForward)
+
+	storagetest.TestStorageWithOptions(t, func() session.Storage {
+		mr.FlushAll()
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		return session.NewStorageFromStore(session.NewRedisStore(client, "test:"), time.Hour)
+	}, opts)
+}
+
+func TestStoreStorageFakeStore(t *testing.T) {
+	var store *sessiontest.FakeStore
+	opts := storagetest.DefaultOptions().WithAdvance(func(d time.Duration) {
+		store.Clock().Advance(d)
+	})
+
+	storagetest.TestStorageWithOptions(t, func() session.Storage {
+		store = sessiontest.NewFakeStore()
+		return session.NewStorageFromStore(store, time.Hour)
+	}, opts)
+}
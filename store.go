@@ -2,82 +2,732 @@ package session
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
 	"time"
 )
 
+// kvManagerPageSize is the page size ForEach asks List for.
+const kvManagerPageSize = 100
+
 // KVSessionRecord is a generic key-value session record for server-side sessions (e.g. Herald).
 // It is not tied to Fiber; use SessionData and Storage for Fiber session backends.
 type KVSessionRecord struct {
+	// Revision is incremented by every write of the session, starting at 1
+	// on creation; records written before revisions existed have revision
+	// 0. It is the first field so stores can read it without decoding the
+	// record.
+	Revision  int64                  `json:"revision"`
 	ID        string                 `json:"id"`
 	Data      map[string]interface{} `json:"data"`
 	CreatedAt time.Time              `json:"created_at"`
-	ExpiresAt time.Time              `json:"expires_at"`
+
+	// Meta describes the client that created the session, such as its IP
+	// address and user agent (see MetaFromRequest). It is set by
+	// CreateWithMeta and preserved by later writes.
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// ExpiresAt is the last field so stores can update it in place.
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-// Store is a generic KV session store for server-side sessions.
-// Used by services like Herald that need Create/Get/Set/Delete/Exists with TTL.
-type Store interface {
+// BasicStore is the part of Store that every KV session store implements.
+// Stores implementing only BasicStore can be used as a Store through
+// AdaptStore.
+type BasicStore interface {
 	Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (id string, err error)
 	Get(ctx context.Context, id string) (*KVSessionRecord, error)
+
+	// Set stores or updates the session, preserving the CreatedAt and Meta
+	// of an existing one. If ttl is 0, an existing session keeps its expiration
+	// and a missing one is not created.
 	Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error
+
 	Delete(ctx context.Context, id string) error
 	Exists(ctx context.Context, id string) (bool, error)
 }
 
+// Store is a generic KV session store for server-side sessions.
+// Used by services like Herald that need Create/Get/Set/Delete/Exists with TTL.
+type Store interface {
+	BasicStore
+
+	// Touch sets the expiration of the session to ttl from now, both the
+	// record's ExpiresAt and the storage TTL, without rewriting its data.
+	// Returns ErrSessionNotFound if the session does not exist or has expired.
+	Touch(ctx context.Context, id string, ttl time.Duration) error
+
+	// CreateWithID creates a session with the given ID, such as an
+	// externally issued identifier, instead of generating one. ttl must be
+	// positive. Returns ErrAlreadyExists if a session with the ID exists.
+	CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error
+
+	// GetTTL returns the time left before the session expires. Returns
+	// ErrSessionNotFound if the session does not exist or has expired.
+	GetTTL(ctx context.Context, id string) (time.Duration, error)
+
+	// List returns a page of session IDs, starting at cursor, "" for the
+	// first page, and the cursor of the next page, "" after the last one.
+	// limit is a hint: pages may be shorter or longer, or even empty before
+	// the last one. IDs may include sessions that have just expired.
+	List(ctx context.Context, cursor string, limit int) (ids []string, nextCursor string, err error)
+
+	// SetCAS is Set if the revision of the session is expectedRevision, and
+	// returns ErrRevisionMismatch otherwise. Returns ErrSessionNotFound if
+	// the session does not exist or has expired.
+	SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error
+
+	// GetMulti returns the sessions for the given IDs, keyed by ID, leaving
+	// out missing and expired ones. Sessions that cannot be decoded are
+	// reported in a *GetMultiError, returned along with the others.
+	GetMulti(ctx context.Context, ids []string) (map[string]*KVSessionRecord, error)
+
+	// DeleteMulti removes the sessions for the given IDs. Missing sessions
+	// are not an error.
+	DeleteMulti(ctx context.Context, ids []string) error
+	// CreateWithMeta is Create recording meta, information about the client
+	// creating the session, in the record.
+	CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (id string, err error)
+}
+
+// GetMultiError is returned by Store.GetMulti along with the sessions it
+// could read, holding the error of each session it could not.
+type GetMultiError struct {
+	Errors map[string]error
+}
+
+// Error lists the failed session IDs and their errors.
+func (e *GetMultiError) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("%s: %v", id, e.Errors[id])
+	}
+	return fmt.Sprintf("failed to read %d sessions: %s", len(ids), strings.Join(msgs, "; "))
+}
+
+// ValidateSessionID checks that id can be used as a session ID: it must not
+// be empty or contain the ':' key separator.
+func ValidateSessionID(id string) error {
+	if id == "" {
+		return fmt.Errorf("session id cannot be empty")
+	}
+	if strings.Contains(id, ":") {
+		return fmt.Errorf("session id %q cannot contain ':'", id)
+	}
+	return nil
+}
+
+// AdaptStore returns s as a Store. If s lacks Touch, Touch is implemented
+// by reading the session and writing it back with the new TTL, atomically
+// if s implements Updater. If s lacks CreateWithID, it is implemented with
+// Exists and Set, so concurrent creations of the same ID may both succeed.
+// Stores that already implement Store are returned as is.
+func AdaptStore(s BasicStore) Store {
+	if store, ok := s.(Store); ok {
+		return store
+	}
+	if updater, ok := s.(Updater); ok {
+		return &adaptedUpdaterStore{adaptedStore: adaptedStore{s}, updater: updater}
+	}
+	return &adaptedStore{s}
+}
+
+// adaptedStore adds the missing Store methods to a BasicStore.
+type adaptedStore struct {
+	BasicStore
+}
+
+// storeToucher is the Touch method of Store.
+type storeToucher interface {
+	Touch(ctx context.Context, id string, ttl time.Duration) error
+}
+
+// ttlGetter is the GetTTL method of Store.
+type ttlGetter interface {
+	GetTTL(ctx context.Context, id string) (time.Duration, error)
+}
+
+// lister is the List method of Store.
+type lister interface {
+	List(ctx context.Context, cursor string, limit int) ([]string, string, error)
+}
+
+// casSetter is the SetCAS method of Store.
+type casSetter interface {
+	SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error
+}
+
+// multiStore is the GetMulti and DeleteMulti methods of Store.
+type multiStore interface {
+	GetMulti(ctx context.Context, ids []string) (map[string]*KVSessionRecord, error)
+	DeleteMulti(ctx context.Context, ids []string) error
+}
+
+// metaCreator is the CreateWithMeta method of Store.
+type metaCreator interface {
+	CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error)
+}
+
+// CreateWithMeta forwards to the adapted store if it implements
+// CreateWithMeta, and otherwise returns an error: Set would not preserve
+// the metadata.
+func (s *adaptedStore) CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error) {
+	if creator, ok := s.BasicStore.(metaCreator); ok {
+		return creator.CreateWithMeta(ctx, data, meta, ttl)
+	}
+	return "", fmt.Errorf("%T does not support session metadata", s.BasicStore)
+}
+
+// idCreator is the CreateWithID method of Store.
+type idCreator interface {
+	CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error
+}
+
+// CreateWithID forwards to the adapted store if it implements CreateWithID,
+// and otherwise checks that the ID is free with Exists and creates the
+// session with Set.
+func (s *adaptedStore) CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	if creator, ok := s.BasicStore.(idCreator); ok {
+		return creator.CreateWithID(ctx, id, data, ttl)
+	}
+	if err := ValidateSessionID(id); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("session ttl must be > 0")
+	}
+	exists, err := s.Exists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAlreadyExists
+	}
+	return s.Set(ctx, id, data, ttl)
+}
+
+// SetCAS forwards to the adapted store if it implements SetCAS, and
+// otherwise compares the revision read with Get before writing with Set.
+func (s *adaptedStore) SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error {
+	if setter, ok := s.BasicStore.(casSetter); ok {
+		return setter.SetCAS(ctx, id, data, ttl, expectedRevision)
+	}
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return ErrSessionNotFound
+	}
+	if rec.Revision != expectedRevision {
+		return ErrRevisionMismatch
+	}
+	return s.Set(ctx, id, data, ttl)
+}
+
+// GetMulti forwards to the adapted store if it implements GetMulti, and
+// otherwise calls Get for each ID.
+func (s *adaptedStore) GetMulti(ctx context.Context, ids []string) (map[string]*KVSessionRecord, error) {
+	if m, ok := s.BasicStore.(multiStore); ok {
+		return m.GetMulti(ctx, ids)
+	}
+	records := make(map[string]*KVSessionRecord, len(ids))
+	for _, id := range ids {
+		rec, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			records[id] = rec
+		}
+	}
+	return records, nil
+}
+
+// DeleteMulti forwards to the adapted store if it implements DeleteMulti,
+// and otherwise calls Delete for each ID.
+func (s *adaptedStore) DeleteMulti(ctx context.Context, ids []string) error {
+	if m, ok := s.BasicStore.(multiStore); ok {
+		return m.DeleteMulti(ctx, ids)
+	}
+	for _, id := range ids {
+		if err := s.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List forwards to the adapted store if it implements List, and otherwise
+// returns an error: sessions cannot be enumerated through BasicStore.
+func (s *adaptedStore) List(ctx context.Context, cursor string, limit int) ([]string, string, error) {
+	if l, ok := s.BasicStore.(lister); ok {
+		return l.List(ctx, cursor, limit)
+	}
+	return nil, "", fmt.Errorf("%T does not support listing sessions", s.BasicStore)
+}
+
+// GetTTL forwards to the adapted store if it implements GetTTL, and
+// otherwise computes the TTL from the ExpiresAt of the session.
+func (s *adaptedStore) GetTTL(ctx context.Context, id string) (time.Duration, error) {
+	if getter, ok := s.BasicStore.(ttlGetter); ok {
+		return getter.GetTTL(ctx, id)
+	}
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if rec == nil {
+		return 0, ErrSessionNotFound
+	}
+	ttl := time.Until(rec.ExpiresAt)
+	if ttl <= 0 {
+		return 0, ErrSessionNotFound
+	}
+	return ttl, nil
+}
+
+// Touch forwards to the adapted store if it implements Touch, and otherwise
+// sets the expiration of the session by writing it back.
+func (s *adaptedStore) Touch(ctx context.Context, id string, ttl time.Duration) error {
+	if toucher, ok := s.BasicStore.(storeToucher); ok {
+		return toucher.Touch(ctx, id, ttl)
+	}
+	rec, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return ErrSessionNotFound
+	}
+	return s.Set(ctx, id, rec.Data, ttl)
+}
+
+// adaptedUpdaterStore is the adaptedStore returned for stores implementing
+// Updater, which it keeps exposing.
+type adaptedUpdaterStore struct {
+	adaptedStore
+	updater Updater
+}
+
+// Touch forwards to the adapted store if it implements Touch, and otherwise
+// sets the expiration of the session with an atomic update.
+func (s *adaptedUpdaterStore) Touch(ctx context.Context, id string, ttl time.Duration) error {
+	if toucher, ok := s.BasicStore.(storeToucher); ok {
+		return toucher.Touch(ctx, id, ttl)
+	}
+	return s.updater.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+		return data, nil
+	}, ttl)
+}
+
+// Update forwards to the adapted store.
+func (s *adaptedUpdaterStore) Update(ctx context.Context, id string, fn func(data map[string]interface{}) (map[string]interface{}, error), ttl time.Duration) error {
+	return s.updater.Update(ctx, id, fn, ttl)
+}
+
+// Updater is implemented by stores that can update a session atomically.
+// KVManager.Update uses it when available.
+type Updater interface {
+	// Update replaces the data of the session with the result of fn, called
+	// with the current data, without losing concurrent writes. fn may be
+	// called more than once and must not have side effects. If ttl is 0, the
+	// session keeps its expiration. Returns ErrSessionNotFound if the
+	// session does not exist or has expired.
+	Update(ctx context.Context, id string, fn func(data map[string]interface{}) (map[string]interface{}, error), ttl time.Duration) error
+}
+
+// StoreRefresher is implemented by stores that can read a session and
+// extend its expiration in a single operation, the Store counterpart of
+// Refresher. KVManager.GetAndRefresh uses it when available.
+type StoreRefresher interface {
+	// GetAndTouch sets the expiration of the session to ttl from now, like
+	// Touch, and returns the record with the new ExpiresAt. ttl must be
+	// positive. Returns ErrSessionNotFound if the session does not exist or
+	// has expired.
+	GetAndTouch(ctx context.Context, id string, ttl time.Duration) (*KVSessionRecord, error)
+}
+
+// KVManagerOptions configures KVManager.
+type KVManagerOptions struct {
+	// DefaultTTL is the TTL used when a method is called with a ttl of 0.
+	// Default: 24 hours
+	DefaultTTL time.Duration
+
+	// ScopeLimitPolicy decides what CreateScoped does when a subject
+	// already has the maximum number of sessions.
+	// Default: ScopeEvictOldest
+	ScopeLimitPolicy ScopeLimitPolicy
+
+	// TTLJitter randomizes the TTL of each session written by the Create
+	// methods, Set, SetCAS and Update by up to ±TTLJitter (a fraction of the
+	// TTL), so sessions created in bulk don't all expire at the same
+	// instant. The jittered TTL is what the store records in ExpiresAt.
+	// Default: 0 (no jitter)
+	TTLJitter float64
+
+	// Observer, if set, is called after every Create, Get, Set, Delete,
+	// Exists and Refresh with the operation name ("create", "get", "set",
+	// "delete", "exists" or "refresh"), the session ID, the duration and the
+	// error returned. Create reports the new ID, or "" if it failed, and Get
+	// reports ErrSessionNotFound for a missing session although it returns
+	// no error. It runs on the calling goroutine, so it should be fast.
+	// Default: nil
+	Observer func(op, id string, d time.Duration, err error)
+}
+
+// DefaultKVManagerOptions returns KVManagerOptions with default values.
+func DefaultKVManagerOptions() KVManagerOptions {
+	return KVManagerOptions{
+		DefaultTTL:       24 * time.Hour,
+		ScopeLimitPolicy: ScopeEvictOldest,
+	}
+}
+
+// WithDefaultTTL sets the default TTL.
+func (o KVManagerOptions) WithDefaultTTL(ttl time.Duration) KVManagerOptions {
+	o.DefaultTTL = ttl
+	return o
+}
+
+// WithScopeLimitPolicy sets the policy of CreateScoped.
+func (o KVManagerOptions) WithScopeLimitPolicy(policy ScopeLimitPolicy) KVManagerOptions {
+	o.ScopeLimitPolicy = policy
+	return o
+}
+
+// WithTTLJitter sets the TTL jitter fraction.
+func (o KVManagerOptions) WithTTLJitter(fraction float64) KVManagerOptions {
+	o.TTLJitter = fraction
+	return o
+}
+
+// WithObserver sets the operation observer.
+func (o KVManagerOptions) WithObserver(fn func(op, id string, d time.Duration, err error)) KVManagerOptions {
+	o.Observer = fn
+	return o
+}
+
 // KVManager wraps a Store and provides default TTL and a high-level API.
 // Use NewKVManager(store, defaultTTL) then Create/Get/Set/Delete/Exists/Refresh.
 type KVManager struct {
-	store      Store
-	defaultTTL time.Duration
+	store       Store
+	defaultTTL  time.Duration
+	scopePolicy ScopeLimitPolicy
+	ttlJitter   float64
+	observer    func(op, id string, d time.Duration, err error)
 }
 
 // NewKVManager returns a KVManager that uses the given store and default TTL.
 func NewKVManager(store Store, defaultTTL time.Duration) *KVManager {
+	return NewKVManagerWithOptions(store, DefaultKVManagerOptions().WithDefaultTTL(defaultTTL))
+}
+
+// NewKVManagerWithOptions returns a KVManager that uses the given store and
+// options.
+func NewKVManagerWithOptions(store Store, opts KVManagerOptions) *KVManager {
 	return &KVManager{
-		store:      store,
-		defaultTTL: defaultTTL,
+		store:       store,
+		defaultTTL:  opts.DefaultTTL,
+		scopePolicy: opts.ScopeLimitPolicy,
+		ttlJitter:   opts.TTLJitter,
+		observer:    opts.Observer,
 	}
 }
 
-// Create creates a new session and returns its ID.
-func (m *KVManager) Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (string, error) {
+// opStart returns the start time of an operation, or the zero time if no
+// observer is set, so the clock is not read for nothing.
+func (m *KVManager) opStart() time.Time {
+	if m.observer == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe reports an operation that started at start to the observer.
+func (m *KVManager) observe(op, id string, start time.Time, err error) {
+	if m.observer == nil || start.IsZero() {
+		return
+	}
+	m.observer(op, id, time.Since(start), err)
+}
+
+// writeTTL returns the TTL for writing a session with ttl: the default TTL
+// if ttl is 0, with the configured jitter applied.
+func (m *KVManager) writeTTL(ttl time.Duration) time.Duration {
 	if ttl <= 0 {
 		ttl = m.defaultTTL
 	}
-	return m.store.Create(ctx, data, ttl)
+	if m.ttlJitter <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * m.ttlJitter
+	jittered := time.Duration(float64(ttl) + (rand.Float64()*2-1)*spread)
+	if jittered <= 0 {
+		jittered = ttl
+	}
+	return jittered
+}
+
+// Create creates a new session and returns its ID.
+func (m *KVManager) Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (string, error) {
+	start := m.opStart()
+	id, err := m.store.Create(ctx, data, m.writeTTL(ttl))
+	m.observe("create", id, start, err)
+	return id, err
+}
+
+// CreateWithMeta creates a new session recording meta, such as the result
+// of MetaFromRequest, and returns its ID. If ttl is 0, the default TTL is
+// used.
+func (m *KVManager) CreateWithMeta(ctx context.Context, data map[string]interface{}, meta map[string]string, ttl time.Duration) (string, error) {
+	ttl = m.writeTTL(ttl)
+	return m.store.CreateWithMeta(ctx, data, meta, ttl)
+}
+
+// CreateWithID creates a session with the given ID instead of generating
+// one. If ttl is 0, the default TTL is used. Returns ErrAlreadyExists if a
+// session with the ID exists.
+func (m *KVManager) CreateWithID(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	ttl = m.writeTTL(ttl)
+	return m.store.CreateWithID(ctx, id, data, ttl)
 }
 
 // Get returns the session for the given ID, or an error if not found/expired.
 func (m *KVManager) Get(ctx context.Context, id string) (*KVSessionRecord, error) {
-	return m.store.Get(ctx, id)
+	start := m.opStart()
+	rec, err := m.store.Get(ctx, id)
+	if rec == nil && err == nil {
+		m.observe("get", id, start, ErrSessionNotFound)
+	} else {
+		m.observe("get", id, start, err)
+	}
+	return rec, err
 }
 
-// Set updates the session for the given ID. If ttl is 0, the implementation may keep existing TTL or use default.
-func (m *KVManager) Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
-	if ttl <= 0 {
-		ttl = m.defaultTTL
+// GetStrict is Get returning ErrSessionNotFound instead of a nil record
+// when the session does not exist or has expired.
+func (m *KVManager) GetStrict(ctx context.Context, id string) (*KVSessionRecord, error) {
+	rec, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	return m.store.Set(ctx, id, data, ttl)
+	if rec == nil {
+		return nil, ErrSessionNotFound
+	}
+	return rec, nil
+}
+
+// GetData returns the data of the session for the given ID, never nil for
+// an existing session, even one created with nil data. Returns
+// ErrSessionNotFound if the session does not exist or has expired.
+func (m *KVManager) GetData(ctx context.Context, id string) (map[string]interface{}, error) {
+	data, _, err := m.GetWithRevision(ctx, id)
+	return data, err
+}
+
+// GetWithRevision returns the data and revision of the session for the
+// given ID, to be passed to SetCAS. Returns ErrSessionNotFound if the
+// session does not exist or has expired.
+func (m *KVManager) GetWithRevision(ctx context.Context, id string) (map[string]interface{}, int64, error) {
+	rec, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rec == nil {
+		return nil, 0, ErrSessionNotFound
+	}
+	if rec.Data == nil {
+		rec.Data = make(map[string]interface{})
+	}
+	return rec.Data, rec.Revision, nil
 }
 
-// Delete removes the session for the given ID.
+// SetCAS updates the session for the given ID if its revision is still
+// expectedRevision, and returns ErrRevisionMismatch otherwise. If ttl is 0,
+// the default TTL is used.
+func (m *KVManager) SetCAS(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration, expectedRevision int64) error {
+	ttl = m.writeTTL(ttl)
+	return m.store.SetCAS(ctx, id, data, ttl, expectedRevision)
+}
+
+// Set updates the session for the given ID. If ttl is 0, the default TTL is used.
+func (m *KVManager) Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
+	start := m.opStart()
+	err := m.store.Set(ctx, id, data, m.writeTTL(ttl))
+	m.observe("set", id, start, err)
+	return err
+}
+
+// Delete removes the session for the given ID. If the store implements
+// Indexer or LookupIndexer, a session created with CreateScoped or
+// CreateIndexed is also removed from the index of its subject or its lookup
+// key, at the cost of reading the session first.
 func (m *KVManager) Delete(ctx context.Context, id string) error {
-	return m.store.Delete(ctx, id)
+	start := m.opStart()
+	err := m.delete(ctx, id)
+	m.observe("delete", id, start, err)
+	return err
+}
+
+// delete implements Delete.
+func (m *KVManager) delete(ctx context.Context, id string) error {
+	indexer, isIndexer := m.store.(Indexer)
+	lookups, isLookupIndexer := m.store.(LookupIndexer)
+	if !isIndexer && !isLookupIndexer {
+		return m.store.Delete(ctx, id)
+	}
+	rec, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.store.Delete(ctx, id); err != nil {
+		return err
+	}
+	if subject, ok := scopeSubject(rec); ok && isIndexer {
+		if err := indexer.RemoveFromIndex(ctx, subject, id); err != nil {
+			return err
+		}
+	}
+	if key, ok := lookupKey(rec); ok && isLookupIndexer {
+		return lookups.DeleteLookup(ctx, key, id)
+	}
+	return nil
+}
+
+// GetMulti returns the sessions for the given IDs; see Store.GetMulti.
+func (m *KVManager) GetMulti(ctx context.Context, ids []string) (map[string]*KVSessionRecord, error) {
+	return m.store.GetMulti(ctx, ids)
+}
+
+// DeleteMulti removes the sessions for the given IDs.
+func (m *KVManager) DeleteMulti(ctx context.Context, ids []string) error {
+	return m.store.DeleteMulti(ctx, ids)
 }
 
 // Exists reports whether a session exists for the given ID.
 func (m *KVManager) Exists(ctx context.Context, id string) (bool, error) {
-	return m.store.Exists(ctx, id)
+	start := m.opStart()
+	ok, err := m.store.Exists(ctx, id)
+	m.observe("exists", id, start, err)
+	return ok, err
 }
 
-// Refresh extends the expiration of the session by setting it again with the given ttl.
-func (m *KVManager) Refresh(ctx context.Context, id string, ttl time.Duration) error {
+// GetTTL returns the time left before the session expires. Returns
+// ErrSessionNotFound if the session does not exist or has expired.
+func (m *KVManager) GetTTL(ctx context.Context, id string) (time.Duration, error) {
+	return m.store.GetTTL(ctx, id)
+}
+
+// List returns a page of session IDs; see Store.List.
+func (m *KVManager) List(ctx context.Context, cursor string, limit int) ([]string, string, error) {
+	return m.store.List(ctx, cursor, limit)
+}
+
+// ForEach calls fn with every session, paging through List and loading each
+// session with Get. Sessions that expire or are deleted in the meantime are
+// skipped, and sessions created in the meantime may or may not be visited.
+// ForEach stops at the first error, from fn or the store, and returns it.
+func (m *KVManager) ForEach(ctx context.Context, fn func(*KVSessionRecord) error) error {
+	cursor := ""
+	for {
+		ids, next, err := m.store.List(ctx, cursor, kvManagerPageSize)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			rec, err := m.store.Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			if rec == nil {
+				continue
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Update replaces the data of the session with the result of fn, called
+// with the current data. If the store implements Updater the update is
+// atomic and fn may be called more than once; otherwise the session is read
+// and written back with Get and Set, and concurrent writes may be lost.
+// If ttl is 0, the default TTL is used. Returns ErrSessionNotFound if the
+// session does not exist or has expired, and errors from fn as is.
+func (m *KVManager) Update(ctx context.Context, id string, fn func(data map[string]interface{}) (map[string]interface{}, error), ttl time.Duration) error {
+	ttl = m.writeTTL(ttl)
+	if updater, ok := m.store.(Updater); ok {
+		return updater.Update(ctx, id, fn, ttl)
+	}
+
 	rec, err := m.store.Get(ctx, id)
-	if err != nil || rec == nil {
+	if err != nil {
 		return err
 	}
+	if rec == nil {
+		return ErrSessionNotFound
+	}
+	if rec.Data == nil {
+		rec.Data = make(map[string]interface{})
+	}
+	data, err := fn(rec.Data)
+	if err != nil {
+		return err
+	}
+	return m.store.Set(ctx, id, data, ttl)
+}
+
+// Refresh extends the expiration of the session to ttl from now with
+// Store.Touch, without rewriting its data. If ttl is 0, the default TTL is
+// used. Returns ErrSessionNotFound if the session does not exist or has
+// expired.
+func (m *KVManager) Refresh(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = m.defaultTTL
+	}
+	start := m.opStart()
+	err := m.store.Touch(ctx, id, ttl)
+	m.observe("refresh", id, start, err)
+	return err
+}
+
+// GetAndRefresh extends the expiration of the session to ttl from now and
+// returns it with the new ExpiresAt. If the store implements StoreRefresher
+// this takes a single operation; otherwise the session is read with Get and
+// extended with Touch. If ttl is 0, the default TTL is used. Returns
+// ErrSessionNotFound if the session does not exist or has expired.
+func (m *KVManager) GetAndRefresh(ctx context.Context, id string, ttl time.Duration) (*KVSessionRecord, error) {
 	if ttl <= 0 {
 		ttl = m.defaultTTL
 	}
-	return m.store.Set(ctx, id, rec.Data, ttl)
+	if refresher, ok := m.store.(StoreRefresher); ok {
+		return refresher.GetAndTouch(ctx, id, ttl)
+	}
+
+	rec, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrSessionNotFound
+	}
+	expiresAt := time.Now().Add(ttl)
+	if err := m.store.Touch(ctx, id, ttl); err != nil {
+		return nil, err
+	}
+	rec.ExpiresAt = expiresAt
+	return rec, nil
 }
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// KVLookupKey is the key of the record Data under which CreateIndexed
+// stores the lookup key of a session, so that Delete can remove the lookup.
+const KVLookupKey = "_lookup"
+
+// LookupIndexer is implemented by stores that can map lookup keys, such as
+// a phone number, to session IDs, used by KVManager.CreateIndexed and
+// GetByIndex.
+type LookupIndexer interface {
+	// SetLookup maps key to id for ttl, replacing any previous mapping.
+	SetLookup(ctx context.Context, key, id string, ttl time.Duration) error
+
+	// GetLookup returns the ID mapped to key, or "" if there is none.
+	GetLookup(ctx context.Context, key string) (string, error)
+
+	// DeleteLookup removes the mapping of key if it still maps to id, so
+	// that a newer mapping of the same key is kept.
+	DeleteLookup(ctx context.Context, key, id string) error
+}
+
+// CreateIndexed creates a new session that can be found by lookupKey with
+// GetByIndex, such as the pending verification session of a phone number.
+// The lookup expires with the session. Creating another session with the
+// same lookupKey points the lookup at the new session; the previous one is
+// kept but no longer found by the key. The store must implement
+// LookupIndexer. If ttl is 0, the default TTL is used.
+//
+// The lookup key is stored in the session data under KVLookupKey.
+func (m *KVManager) CreateIndexed(ctx context.Context, lookupKey string, data map[string]interface{}, ttl time.Duration) (string, error) {
+	lookups, ok := m.store.(LookupIndexer)
+	if !ok {
+		return "", fmt.Errorf("%T does not support session lookups", m.store)
+	}
+	if lookupKey == "" {
+		return "", fmt.Errorf("session lookup key cannot be empty")
+	}
+	ttl = m.writeTTL(ttl)
+
+	indexed := maps.Clone(data)
+	if indexed == nil {
+		indexed = make(map[string]interface{})
+	}
+	indexed[KVLookupKey] = lookupKey
+	id, err := m.store.Create(ctx, indexed, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := lookups.SetLookup(ctx, lookupKey, id, ttl); err != nil {
+		_ = m.store.Delete(ctx, id)
+		return "", err
+	}
+	return id, nil
+}
+
+// GetByIndex returns the session that lookupKey maps to, or nil and no
+// error if there is none or it has expired. A lookup pointing at a missing
+// session is removed.
+func (m *KVManager) GetByIndex(ctx context.Context, lookupKey string) (*KVSessionRecord, error) {
+	lookups, ok := m.store.(LookupIndexer)
+	if !ok {
+		return nil, fmt.Errorf("%T does not support session lookups", m.store)
+	}
+	id, err := lookups.GetLookup(ctx, lookupKey)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	rec, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, lookups.DeleteLookup(ctx, lookupKey, id)
+	}
+	return rec, nil
+}
+
+// lookupKey returns the lookup key stored in rec by CreateIndexed.
+func lookupKey(rec *KVSessionRecord) (string, bool) {
+	if rec == nil {
+		return "", false
+	}
+	key, ok := rec.Data[KVLookupKey].(string)
+	return key, ok && key != ""
+}
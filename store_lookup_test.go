@@ -0,0 +1,73 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKVManager_CreateIndexed(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "lookup:")
+	mgr := NewKVManager(store, time.Minute)
+
+	first, err := mgr.CreateIndexed(ctx, "+15550100", map[string]interface{}{"step": "sent"}, 0)
+	if err != nil {
+		t.Fatalf("CreateIndexed: %v", err)
+	}
+	rec, err := mgr.GetByIndex(ctx, "+15550100")
+	if err != nil || rec == nil || rec.ID != first || rec.Data["step"] != "sent" {
+		t.Fatalf("expected the indexed session, got %+v, %v", rec, err)
+	}
+	if ttl := mr.TTL("lookup:idx:+15550100"); ttl != time.Minute {
+		t.Errorf("expected the lookup to expire with the session, got %v", ttl)
+	}
+	if rec, err := mgr.GetByIndex(ctx, "+15550199"); rec != nil || err != nil {
+		t.Errorf("expected nil, nil for an unknown key, got %+v, %v", rec, err)
+	}
+
+	// A new session for the same key takes over the lookup
+	second, _ := mgr.CreateIndexed(ctx, "+15550100", nil, 0)
+	if rec, _ := mgr.GetByIndex(ctx, "+15550100"); rec == nil || rec.ID != second {
+		t.Errorf("expected the lookup to point at the new session, got %+v", rec)
+	}
+
+	// Deleting the previous session keeps the newer lookup
+	if err := mgr.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rec, _ := mgr.GetByIndex(ctx, "+15550100"); rec == nil || rec.ID != second {
+		t.Errorf("expected the newer lookup to be kept, got %+v", rec)
+	}
+	if err := mgr.Delete(ctx, second); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if mr.Exists("lookup:idx:+15550100") {
+		t.Error("expected Delete to remove the lookup")
+	}
+
+	// Lookups to sessions gone without Delete are removed on read
+	id, _ := mgr.CreateIndexed(ctx, "stale", nil, 0)
+	mr.Del("lookup:" + id)
+	if rec, err := mgr.GetByIndex(ctx, "stale"); rec != nil || err != nil {
+		t.Errorf("expected nil, nil for a stale lookup, got %+v, %v", rec, err)
+	}
+	if mr.Exists("lookup:idx:stale") {
+		t.Error("expected the stale lookup to be removed")
+	}
+
+	if _, err := mgr.CreateIndexed(ctx, "", nil, 0); err == nil {
+		t.Error("expected error for an empty lookup key")
+	}
+	plain := NewKVManager(AdaptStore(basicStore{store}), time.Minute)
+	if _, err := plain.CreateIndexed(ctx, "key", nil, 0); err == nil {
+		t.Error("expected error for a store without lookups")
+	}
+	if _, err := plain.GetByIndex(ctx, "key"); err == nil {
+		t.Error("expected error for a store without lookups")
+	}
+}
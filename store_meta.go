@@ -0,0 +1,61 @@
+package session
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Keys of the metadata returned by MetaFromRequest.
+const (
+	// MetaIP is the IP address of the client.
+	MetaIP = "ip"
+
+	// MetaUserAgent is the User-Agent header of the request.
+	MetaUserAgent = "user_agent"
+)
+
+// MetaFromRequest returns metadata about the client of r, for
+// CreateWithMeta: its IP address under MetaIP and its user agent under
+// MetaUserAgent, if not empty.
+//
+// The IP address is the host of r.RemoteAddr unless trustedProxies is
+// positive, meaning the request went through that many reverse proxies that
+// each append the address they received the request from to
+// X-Forwarded-For. The IP address is then the entry added by the outermost
+// trusted proxy; entries before it may be forged by the client. If the
+// header has fewer entries, its first one is used.
+func MetaFromRequest(r *http.Request, trustedProxies int) map[string]string {
+	meta := make(map[string]string, 2)
+	if ip := clientIP(r.Header.Values("X-Forwarded-For"), r.RemoteAddr, trustedProxies); ip != "" {
+		meta[MetaIP] = ip
+	}
+	if ua := r.UserAgent(); ua != "" {
+		meta[MetaUserAgent] = ua
+	}
+	return meta
+}
+
+// clientIP returns the IP address of the client of a request from
+// remoteAddr with the given X-Forwarded-For headers, skipping trustedProxies
+// entries from their end.
+func clientIP(forwardedFor []string, remoteAddr string, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var hops []string
+		for _, header := range forwardedFor {
+			for _, hop := range strings.Split(header, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return hops[max(len(hops)-trustedProxies, 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetaFromRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwardedFor   []string
+		trustedProxies int
+		want           string
+	}{
+		{name: "remote addr", remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		{name: "ipv6 remote addr", remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		{name: "remote addr without port", remoteAddr: "192.0.2.1", want: "192.0.2.1"},
+		{name: "untrusted header", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"203.0.113.9"}, want: "10.0.0.1"},
+		{name: "one proxy", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.7, 203.0.113.9"}, trustedProxies: 1, want: "203.0.113.9"},
+		{name: "two proxies", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"198.51.100.7, 203.0.113.9", "10.0.0.2"}, trustedProxies: 2, want: "203.0.113.9"},
+		{name: "fewer entries than proxies", remoteAddr: "10.0.0.1:1234", forwardedFor: []string{"203.0.113.9"}, trustedProxies: 3, want: "203.0.113.9"},
+		{name: "missing header", remoteAddr: "10.0.0.1:1234", trustedProxies: 1, want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			r.Header.Set("User-Agent", "test-agent")
+			meta := MetaFromRequest(r, tt.trustedProxies)
+			if meta[MetaIP] != tt.want || meta[MetaUserAgent] != "test-agent" {
+				t.Errorf("expected ip %q and the user agent, got %v", tt.want, meta)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = ""
+	if meta := MetaFromRequest(r, 0); len(meta) != 0 {
+		t.Errorf("expected no metadata for an anonymous request, got %v", meta)
+	}
+}
+
+func TestKVManager_CreateWithMeta(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "meta:")
+	mgr := NewKVManager(store, time.Minute)
+	meta := map[string]string{MetaIP: "192.0.2.1", MetaUserAgent: "test-agent"}
+
+	id, err := mgr.CreateWithMeta(ctx, map[string]interface{}{"k": "v"}, meta, 0)
+	if err != nil {
+		t.Fatalf("CreateWithMeta: %v", err)
+	}
+	check := func(op string) {
+		t.Helper()
+		rec, err := mgr.Get(ctx, id)
+		if err != nil || rec == nil || rec.Meta[MetaIP] != "192.0.2.1" || rec.Meta[MetaUserAgent] != "test-agent" {
+			t.Errorf("expected the metadata after %s, got %+v, %v", op, rec, err)
+		}
+	}
+	check("CreateWithMeta")
+
+	_ = mgr.Set(ctx, id, map[string]interface{}{"k": "v2"}, 0)
+	check("Set")
+	_ = store.Set(ctx, id, map[string]interface{}{"k": "v3"}, 0)
+	check("Set keeping the TTL")
+	_ = mgr.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) { return data, nil }, 0)
+	check("Update")
+	_ = mgr.Refresh(ctx, id, time.Hour)
+	check("Refresh")
+	_, rev, _ := mgr.GetWithRevision(ctx, id)
+	_ = mgr.SetCAS(ctx, id, map[string]interface{}{"k": "v4"}, 0, rev)
+	check("SetCAS")
+
+	// Records without metadata, including ones written before it existed,
+	// decode with a nil Meta
+	plain, _ := mgr.Create(ctx, nil, 0)
+	if raw, _ := mr.Get("meta:" + plain); strings.Contains(raw, `"meta"`) {
+		t.Errorf("expected no meta field for a session without metadata, got %s", raw)
+	}
+	legacy := `{"id":"legacy","data":{},"created_at":"2024-01-01T00:00:00Z","expires_at":"` +
+		time.Now().Add(time.Minute).UTC().Format(time.RFC3339Nano) + `"}`
+	_ = mr.Set("meta:legacy", legacy)
+	if rec, err := mgr.Get(ctx, "legacy"); err != nil || rec == nil || rec.Meta != nil {
+		t.Errorf("expected the legacy record to decode without metadata, got %+v, %v", rec, err)
+	}
+
+	if _, err := NewKVManager(AdaptStore(basicStore{store}), time.Minute).CreateWithMeta(ctx, nil, meta, 0); err == nil {
+		t.Error("expected an error from a store without metadata support")
+	}
+}
@@ -0,0 +1,149 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// KVSubjectKey is the key of the record Data under which CreateScoped
+// stores the subject of a session, so that Delete can remove the session
+// from the subject's index.
+const KVSubjectKey = "_subject"
+
+// ScopeLimitPolicy decides what KVManager.CreateScoped does when a subject
+// already has the maximum number of sessions.
+type ScopeLimitPolicy int
+
+const (
+	// ScopeEvictOldest deletes the oldest sessions of the subject to make
+	// room for the new one.
+	ScopeEvictOldest ScopeLimitPolicy = iota
+	// ScopeReject fails with ErrTooManySessions.
+	ScopeReject
+)
+
+// String returns a human-readable name for the policy.
+func (p ScopeLimitPolicy) String() string {
+	switch p {
+	case ScopeEvictOldest:
+		return "evict-oldest"
+	case ScopeReject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// Indexer is implemented by stores that can keep named indexes of session
+// IDs, used by KVManager.CreateScoped to track the sessions of a subject.
+// Indexes may keep IDs of sessions that have since expired or been deleted;
+// callers prune them.
+type Indexer interface {
+	// AddToIndex adds id to the index, which is kept for at least ttl.
+	AddToIndex(ctx context.Context, index, id string, ttl time.Duration) error
+
+	// RemoveFromIndex removes ids from the index. Missing IDs are not an
+	// error.
+	RemoveFromIndex(ctx context.Context, index string, ids ...string) error
+
+	// ListIndex returns the IDs in the index, oldest first.
+	ListIndex(ctx context.Context, index string) ([]string, error)
+}
+
+// CreateScoped creates a new session for subject, such as a phone number,
+// allowing at most maxPerSubject live sessions per subject. When the limit
+// is reached, the oldest sessions of the subject are deleted, or
+// ErrTooManySessions is returned under the ScopeReject policy. The store
+// must implement Indexer. If ttl is 0, the default TTL is used.
+//
+// The subject is stored in the session data under KVSubjectKey. IDs of
+// sessions that expired or were deleted without Delete are pruned from the
+// index here. The limit is not enforced atomically: concurrent calls for the
+// same subject may briefly exceed it.
+func (m *KVManager) CreateScoped(ctx context.Context, subject string, data map[string]interface{}, ttl time.Duration, maxPerSubject int) (string, error) {
+	indexer, ok := m.store.(Indexer)
+	if !ok {
+		return "", fmt.Errorf("%T does not support session indexes", m.store)
+	}
+	if subject == "" {
+		return "", fmt.Errorf("session subject cannot be empty")
+	}
+	if maxPerSubject <= 0 {
+		return "", fmt.Errorf("max sessions per subject must be > 0")
+	}
+	ttl = m.writeTTL(ttl)
+
+	live, err := m.liveScoped(ctx, indexer, subject)
+	if err != nil {
+		return "", err
+	}
+	if excess := len(live) - maxPerSubject + 1; excess > 0 {
+		if m.scopePolicy == ScopeReject {
+			return "", ErrTooManySessions
+		}
+		evicted := live[:excess]
+		if err := m.store.DeleteMulti(ctx, evicted); err != nil {
+			return "", err
+		}
+		if err := indexer.RemoveFromIndex(ctx, subject, evicted...); err != nil {
+			return "", err
+		}
+	}
+
+	scoped := maps.Clone(data)
+	if scoped == nil {
+		scoped = make(map[string]interface{})
+	}
+	scoped[KVSubjectKey] = subject
+	id, err := m.store.Create(ctx, scoped, ttl)
+	if err != nil {
+		return "", err
+	}
+	if err := indexer.AddToIndex(ctx, subject, id, ttl); err != nil {
+		_ = m.store.Delete(ctx, id)
+		return "", err
+	}
+	return id, nil
+}
+
+// liveScoped returns the IDs of the live sessions of subject, oldest first,
+// and removes the others from its index.
+func (m *KVManager) liveScoped(ctx context.Context, indexer Indexer, subject string) ([]string, error) {
+	ids, err := indexer.ListIndex(ctx, subject)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	records, err := m.store.GetMulti(ctx, ids)
+	var multiErr *GetMultiError
+	if err != nil && !errors.As(err, &multiErr) {
+		return nil, err
+	}
+
+	var live, stale []string
+	for _, id := range ids {
+		// Sessions that cannot be read still count against the limit
+		if records[id] != nil || (multiErr != nil && multiErr.Errors[id] != nil) {
+			live = append(live, id)
+		} else {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		if err := indexer.RemoveFromIndex(ctx, subject, stale...); err != nil {
+			return nil, err
+		}
+	}
+	return live, nil
+}
+
+// scopeSubject returns the subject stored in rec by CreateScoped.
+func scopeSubject(rec *KVSessionRecord) (string, bool) {
+	if rec == nil {
+		return "", false
+	}
+	subject, ok := rec.Data[KVSubjectKey].(string)
+	return subject, ok && subject != ""
+}
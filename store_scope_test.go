@@ -0,0 +1,125 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKVManager_CreateScopedEvictOldest(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "scope:")
+	mgr := NewKVManager(store, time.Minute)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := mgr.CreateScoped(ctx, "+15550100", map[string]interface{}{"n": i}, 0, 3)
+		if err != nil {
+			t.Fatalf("CreateScoped: %v", err)
+		}
+		ids = append(ids, id)
+		time.Sleep(time.Millisecond)
+	}
+
+	for i, id := range ids {
+		exists, _ := mgr.Exists(ctx, id)
+		if want := i >= 2; exists != want {
+			t.Errorf("session %d: expected exists=%v, got %v", i, want, exists)
+		}
+	}
+	indexed, _ := store.ListIndex(ctx, "+15550100")
+	if len(indexed) != 3 || indexed[0] != ids[2] || indexed[2] != ids[4] {
+		t.Errorf("expected the three newest sessions in the index, got %v", indexed)
+	}
+	rec, _ := mgr.Get(ctx, ids[4])
+	if rec.Data[KVSubjectKey] != "+15550100" || rec.Data["n"] != float64(4) {
+		t.Errorf("expected the subject in the data, got %v", rec.Data)
+	}
+	if ttl := mr.TTL("scope:index:+15550100"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the index to expire with the sessions, got %v", ttl)
+	}
+
+	// Other subjects are not affected
+	if _, err := mgr.CreateScoped(ctx, "+15550199", nil, 0, 3); err != nil {
+		t.Fatalf("CreateScoped: %v", err)
+	}
+	if indexed, _ := store.ListIndex(ctx, "+15550100"); len(indexed) != 3 {
+		t.Errorf("expected the first subject to keep three sessions, got %v", indexed)
+	}
+
+	// Index keys are not listed as sessions
+	listed, _, _ := store.List(ctx, "", 100)
+	if len(listed) != 4 {
+		t.Errorf("expected four sessions listed, got %v", listed)
+	}
+}
+
+func TestKVManager_CreateScopedReject(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "scope:")
+	mgr := NewKVManagerWithOptions(store, DefaultKVManagerOptions().
+		WithDefaultTTL(time.Minute).
+		WithScopeLimitPolicy(ScopeReject))
+
+	first, _ := mgr.CreateScoped(ctx, "user", nil, 0, 2)
+	second, _ := mgr.CreateScoped(ctx, "user", nil, 0, 2)
+	if _, err := mgr.CreateScoped(ctx, "user", nil, 0, 2); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("expected ErrTooManySessions, got %v", err)
+	}
+	if exists, _ := mgr.Exists(ctx, first); !exists {
+		t.Error("expected the existing sessions to be kept")
+	}
+
+	// Delete frees a slot and cleans the index
+	if err := mgr.Delete(ctx, first); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if indexed, _ := store.ListIndex(ctx, "user"); len(indexed) != 1 || indexed[0] != second {
+		t.Errorf("expected Delete to remove the session from the index, got %v", indexed)
+	}
+	if _, err := mgr.CreateScoped(ctx, "user", nil, 0, 2); err != nil {
+		t.Fatalf("expected a free slot after Delete, got %v", err)
+	}
+
+	// Expired sessions lingering in the index are pruned
+	_ = store.AddToIndex(ctx, "user", "expired", time.Hour)
+	mr.FastForward(2 * time.Minute)
+	if _, err := mgr.CreateScoped(ctx, "user", nil, 0, 2); err != nil {
+		t.Fatalf("expected expired sessions not to count, got %v", err)
+	}
+	if indexed, _ := store.ListIndex(ctx, "user"); len(indexed) != 1 {
+		t.Errorf("expected the expired IDs to be pruned, got %v", indexed)
+	}
+}
+
+func TestKVManager_CreateScopedErrors(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	mgr := NewKVManager(NewRedisStore(client, "scope:"), time.Minute)
+	if _, err := mgr.CreateScoped(ctx, "", nil, 0, 1); err == nil {
+		t.Error("expected error for an empty subject")
+	}
+	if _, err := mgr.CreateScoped(ctx, "user", nil, 0, 0); err == nil {
+		t.Error("expected error for a zero limit")
+	}
+	plain := NewKVManager(AdaptStore(basicStore{NewRedisStore(client, "scope:")}), time.Minute)
+	if _, err := plain.CreateScoped(ctx, "user", nil, 0, 1); err == nil {
+		t.Error("expected error for a store without indexes")
+	}
+
+	if ScopeEvictOldest.String() != "evict-oldest" || ScopeReject.String() != "reject" || ScopeLimitPolicy(9).String() != "unknown" {
+		t.Error("unexpected policy names")
+	}
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StoreValueKey is the key of the record Data under which StoreStorage
+// keeps the value of a key, encoded in standard base64.
+const StoreValueKey = "_value"
+
+// StoreStorage adapts a KV Store to the Storage interface, so that services
+// built on Store can back FiberSessionConfig. Each storage key is used as a
+// session ID, with '%' and ':' escaped as "%25" and "%3A" so that any key is
+// a valid ID; the IDs generated by Fiber are used as is. A Set with an expiration of 0 uses the default TTL, since Store
+// sessions always expire, and Reset deletes every session of the store
+// through Store.List, not only those written through the adapter.
+//
+// StoreStorage does not implement ExtendedStorage. Close does not close the
+// store.
+type StoreStorage struct {
+	store      Store
+	defaultTTL time.Duration
+}
+
+// storeKeyEscaper turns storage keys into valid session IDs.
+var storeKeyEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// NewStorageFromStore returns a Storage that keeps its values in store,
+// using defaultTTL for values set without an expiration.
+func NewStorageFromStore(store Store, defaultTTL time.Duration) Storage {
+	return &StoreStorage{store: store, defaultTTL: defaultTTL}
+}
+
+// Store returns the adapted store.
+func (s *StoreStorage) Store() Store {
+	return s.store
+}
+
+// Get retrieves the value for the given key.
+// Returns nil, nil if the key does not exist or has expired.
+func (s *StoreStorage) Get(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+	rec, err := s.store.Get(context.Background(), storeKeyEscaper.Replace(key))
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	encoded, ok := rec.Data[StoreValueKey].(string)
+	if !ok {
+		return nil, fmt.Errorf("session %s has no storage value", key)
+	}
+	val, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode storage value of session %s: %w", key, err)
+	}
+	return val, nil
+}
+
+// Set stores the given value for the given key along with an expiration
+// value. If expiration is 0, the default TTL is used.
+// Empty key or value will be ignored without an error.
+func (s *StoreStorage) Set(key string, val []byte, exp time.Duration) error {
+	if key == "" || len(val) == 0 {
+		return nil
+	}
+	if exp <= 0 {
+		exp = s.defaultTTL
+	}
+	data := map[string]interface{}{StoreValueKey: base64.StdEncoding.EncodeToString(val)}
+	return s.store.Set(context.Background(), storeKeyEscaper.Replace(key), data, exp)
+}
+
+// Delete removes the value for the given key.
+// It returns no error if the storage does not contain the key.
+func (s *StoreStorage) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	return s.store.Delete(context.Background(), storeKeyEscaper.Replace(key))
+}
+
+// Reset deletes every session of the store, page by page.
+func (s *StoreStorage) Reset() error {
+	ctx := context.Background()
+	cursor := ""
+	for {
+		ids, next, err := s.store.List(ctx, cursor, kvManagerPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if err := s.store.DeleteMulti(ctx, ids); err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Close does nothing: the store is owned by the caller.
+func (s *StoreStorage) Close() error {
+	return nil
+}
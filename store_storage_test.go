@@ -0,0 +1,108 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fibersession "github.com/gofiber/fiber/v2/middleware/session"
+)
+
+func TestStoreStorage(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	store := NewRedisStore(client, "kv:")
+	storage := NewStorageFromStore(store, time.Hour)
+	defer func() { _ = storage.Close() }()
+
+	if err := storage.Set("abc", []byte{0, 1, 2, 255}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, err := storage.Get("abc"); err != nil || !bytes.Equal(got, []byte{0, 1, 2, 255}) {
+		t.Errorf("expected binary values to round trip, got %v, %v", got, err)
+	}
+	if ttl := mr.TTL("kv:abc"); ttl != time.Hour {
+		t.Errorf("expected the default TTL for an expiration of 0, got %v", ttl)
+	}
+
+	// Keys are escaped into valid session IDs
+	if err := storage.Set("a:b%", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if !mr.Exists("kv:a%3Ab%25") {
+		t.Errorf("expected an escaped session ID, got keys %v", mr.Keys())
+	}
+	if got, _ := storage.Get("a:b%"); string(got) != "value" {
+		t.Errorf("expected the value back, got %q", got)
+	}
+
+	// Sessions written through the Store directly are not storage values
+	_ = store.CreateWithID(context.Background(), "kv-only", map[string]interface{}{"k": "v"}, time.Minute)
+	if _, err := storage.Get("kv-only"); err == nil || !strings.Contains(err.Error(), "no storage value") {
+		t.Errorf("expected an error for a session without a storage value, got %v", err)
+	}
+	if storage.(*StoreStorage).Store() != Store(store) {
+		t.Error("expected Store to return the adapted store")
+	}
+}
+
+func TestStoreStorageFiberSession(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	storage := NewStorageFromStore(NewRedisStore(client, "kv:"), time.Hour)
+	manager := NewManager(storage, DefaultConfig().WithSecure(false))
+	store := fibersession.New(manager.FiberSessionConfig())
+
+	app := fiber.New()
+	app.Get("/set", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("user", "alice")
+		return sess.Save()
+	})
+	app.Get("/get", func(c *fiber.Ctx) error {
+		sess, err := store.Get(c)
+		if err != nil {
+			return err
+		}
+		user, _ := sess.Get("user").(string)
+		return c.SendString(user)
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/set", nil))
+	if err != nil {
+		t.Fatalf("failed to set session: %v", err)
+	}
+	var sessionID string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			sessionID = cookie.Value
+		}
+	}
+	if sessionID == "" || !mr.Exists("kv:"+sessionID) {
+		t.Fatalf("expected the session %q in the store", sessionID)
+	}
+
+	req := httptest.NewRequest("GET", "/get", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	body := new(bytes.Buffer)
+	_, _ = body.ReadFrom(resp.Body)
+	if body.String() != "alice" {
+		t.Errorf("expected the session to round-trip, got %q", body.String())
+	}
+}
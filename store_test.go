@@ -1,11 +1,21 @@
 package session
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func TestRedisStore_CreateGetSetDeleteExists(t *testing.T) {
@@ -189,10 +199,9 @@ func TestKVManager_RefreshNotFound(t *testing.T) {
 	store := NewRedisStore(client, "refresh:")
 	mgr := NewKVManager(store, 5*time.Minute)
 
-	// Refresh on non-existent id: Get returns (nil, nil), so Refresh returns nil (no error)
 	err := mgr.Refresh(ctx, "nonexistent-id", 10*time.Minute)
-	if err != nil {
-		t.Errorf("Refresh on non-existent id returns nil in current impl: %v", err)
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for a non-existent id, got %v", err)
 	}
 }
 
@@ -347,48 +356,1247 @@ func TestRedisStore_EmptyKeyPrefix(t *testing.T) {
 	}
 }
 
-// failingStore implements Store and returns configurable errors for testing.
-type failingStore struct {
-	getErr error
-	Store  Store
+func TestRedisStoreUniversal(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	defer func() { _ = cluster.Close() }()
+
+	store := NewRedisStoreUniversal(cluster, "kv:")
+	ctx := context.Background()
+
+	id, err := store.Create(ctx, map[string]interface{}{"user": "alice"}, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	rec, err := store.Get(ctx, id)
+	if err != nil || rec == nil {
+		t.Fatalf("expected record, got %v (%v)", rec, err)
+	}
+	if err := store.Delete(ctx, id); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+}
+
+// plainStore hides the optional interfaces of the embedded store.
+type plainStore struct {
+	Store
+}
+
+func TestRedisStore_UpdateInterleaved(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "update:")
+	id, err := store.Create(ctx, map[string]interface{}{"a": "0", "b": "0"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	created, _ := store.Get(ctx, id)
+
+	// The first update runs a second one between its read and its write,
+	// so its first write is discarded and it runs again on the new data
+	calls := 0
+	err = store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+		calls++
+		if calls == 1 {
+			err := store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+				data["b"] = "1"
+				return data, nil
+			}, 0)
+			if err != nil {
+				t.Errorf("inner Update: %v", err)
+			}
+		}
+		data["a"] = "1"
+		return data, nil
+	}, 0)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the conflicting update to run twice, got %d", calls)
+	}
+
+	rec, _ := store.Get(ctx, id)
+	if rec.Data["a"] != "1" || rec.Data["b"] != "1" {
+		t.Errorf("expected both updates to be kept, got %v", rec.Data)
+	}
+	if !rec.CreatedAt.Equal(created.CreatedAt) || !rec.ExpiresAt.Equal(created.ExpiresAt) {
+		t.Errorf("expected CreatedAt and ExpiresAt to be kept, got %+v", rec)
+	}
+	if ttl := mr.TTL("update:" + id); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("expected the key TTL to be kept, got %v", ttl)
+	}
 }
 
-func (f *failingStore) Create(ctx context.Context, data map[string]interface{}, ttl time.Duration) (string, error) {
-	return f.Store.Create(ctx, data, ttl)
+func TestRedisStore_UpdateConcurrent(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "update:")
+	id, _ := store.Create(ctx, map[string]interface{}{}, time.Hour)
+
+	const workers = 4
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			errs <- store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+				data[fmt.Sprintf("worker-%d", w)] = true
+				return data, nil
+			}, time.Hour)
+		}(w)
+	}
+	for w := 0; w < workers; w++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Update: %v", err)
+		}
+	}
+
+	rec, _ := store.Get(ctx, id)
+	if len(rec.Data) != workers {
+		t.Errorf("expected every update to be kept, got %v", rec.Data)
+	}
 }
 
-func (f *failingStore) Get(ctx context.Context, id string) (*KVSessionRecord, error) {
-	if f.getErr != nil {
-		return nil, f.getErr
+func TestRedisStore_UpdateErrors(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "update:")
+	keep := func(data map[string]interface{}) (map[string]interface{}, error) { return data, nil }
+
+	if err := store.Update(ctx, "missing", keep, time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	id, _ := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Hour)
+	errFn := errors.New("rejected")
+	err := store.Update(ctx, id, func(map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errFn
+	}, time.Hour)
+	if !errors.Is(err, errFn) {
+		t.Errorf("expected the function error, got %v", err)
+	}
+	if rec, _ := store.Get(ctx, id); rec.Data["k"] != "v" {
+		t.Errorf("expected a failed update to change nothing, got %v", rec.Data)
+	}
+
+	if err := NewRedisStore(nil, "kv:").Update(ctx, id, keep, time.Hour); err == nil {
+		t.Error("expected error for nil client on Update")
 	}
-	return f.Store.Get(ctx, id)
 }
 
-func (f *failingStore) Set(ctx context.Context, id string, data map[string]interface{}, ttl time.Duration) error {
-	return f.Store.Set(ctx, id, data, ttl)
+func TestKVManager_Update(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	redisStore := NewRedisStore(client, "update:")
+	increment := func(data map[string]interface{}) (map[string]interface{}, error) {
+		n, _ := data["n"].(float64)
+		data["n"] = n + 1
+		return data, nil
+	}
+
+	// Atomic with RedisStore, read-modify-write with any other store
+	for _, store := range []Store{redisStore, plainStore{redisStore}} {
+		mgr := NewKVManager(store, 5*time.Minute)
+		id, _ := mgr.Create(ctx, map[string]interface{}{"n": 1}, 0)
+		if err := mgr.Update(ctx, id, increment, 0); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		rec, _ := mgr.Get(ctx, id)
+		if rec.Data["n"] != float64(2) {
+			t.Errorf("expected n=2, got %v", rec.Data["n"])
+		}
+		if ttl := mr.TTL("update:" + id); ttl != 5*time.Minute {
+			t.Errorf("expected the default TTL, got %v", ttl)
+		}
+		if err := mgr.Update(ctx, "missing", increment, 0); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+	}
 }
 
-func (f *failingStore) Delete(ctx context.Context, id string) error {
-	return f.Store.Delete(ctx, id)
+func TestRedisStore_Touch(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "touch:")
+	data := map[string]interface{}{"expires_at": "data field", "list": []interface{}{}}
+	id, err := store.Create(ctx, data, time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	before, _ := store.Get(ctx, id)
+	body, _ := client.Get(ctx, "touch:"+id).Result()
+
+	if err := store.Touch(ctx, id, time.Hour); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+
+	rec, _ := store.Get(ctx, id)
+	if d := time.Until(rec.ExpiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expected ExpiresAt an hour from now, got %v", rec.ExpiresAt)
+	}
+	if ttl := mr.TTL("touch:" + id); ttl != time.Hour {
+		t.Errorf("expected the key TTL to be an hour, got %v", ttl)
+	}
+	if !rec.CreatedAt.Equal(before.CreatedAt) || rec.Data["expires_at"] != "data field" {
+		t.Errorf("expected the rest of the record to be kept, got %+v", rec)
+	}
+
+	// Only the expiration changed in the stored JSON
+	touched, _ := client.Get(ctx, "touch:"+id).Result()
+	prefix := body[:strings.LastIndex(body, `"expires_at"`)]
+	if !strings.HasPrefix(touched, prefix) {
+		t.Errorf("expected the data to be kept verbatim, got %s", touched)
+	}
+
+	if err := store.Touch(ctx, "missing", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if err := store.Touch(ctx, id, 0); err == nil {
+		t.Error("expected error for a zero TTL")
+	}
+	if err := NewRedisStore(nil, "kv:").Touch(ctx, id, time.Hour); err == nil {
+		t.Error("expected error for nil client on Touch")
+	}
 }
 
-func (f *failingStore) Exists(ctx context.Context, id string) (bool, error) {
-	return f.Store.Exists(ctx, id)
+func TestRedisStore_SetKeepTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "keep:")
+	id, err := store.Create(ctx, map[string]interface{}{"a": "1"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	before, _ := store.Get(ctx, id)
+	mr.FastForward(20 * time.Second)
+
+	if err := store.Set(ctx, id, map[string]interface{}{"a": "2"}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	rec, _ := store.Get(ctx, id)
+	if rec.Data["a"] != "2" {
+		t.Errorf("expected the data to be replaced, got %v", rec.Data)
+	}
+	if !rec.ExpiresAt.Equal(before.ExpiresAt) || !rec.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("expected the timestamps to be kept, got %+v", rec)
+	}
+	if ttl := mr.TTL("keep:" + id); ttl != 40*time.Second {
+		t.Errorf("expected the key TTL to be kept, got %v", ttl)
+	}
+
+	if err := store.Set(ctx, "missing", map[string]interface{}{"a": "1"}, 0); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if mr.Exists("keep:missing") {
+		t.Error("expected Set with a zero TTL not to create a session")
+	}
+	if _, err := store.Create(ctx, map[string]interface{}{"a": "1"}, 0); err == nil {
+		t.Error("expected error for Create with a zero TTL")
+	}
 }
 
-func TestKVManager_RefreshGetError(t *testing.T) {
+func TestKVManager_RefreshTouches(t *testing.T) {
 	mr, client := setupMiniRedis(t)
 	defer mr.Close()
 	defer func() { _ = client.Close() }()
 
 	ctx := context.Background()
-	base := NewRedisStore(client, "refresh-err:")
-	wrapped := &failingStore{Store: base, getErr: errors.New("get failed")}
-	mgr := NewKVManager(wrapped, 5*time.Minute)
+	mgr := NewKVManager(NewRedisStore(client, "refresh:"), 5*time.Minute)
+	id, _ := mgr.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
 
-	// Refresh when Get returns error should propagate error
-	err := mgr.Refresh(ctx, "any-id", 10*time.Minute)
-	if err == nil {
-		t.Error("expected error when Get fails in Refresh")
+	if err := mgr.Refresh(ctx, id, 0); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	rec, _ := mgr.Get(ctx, id)
+	if d := time.Until(rec.ExpiresAt); d < 4*time.Minute {
+		t.Errorf("expected ExpiresAt to move to the default TTL, got %v", d)
+	}
+	if ttl := mr.TTL("refresh:" + id); ttl != 5*time.Minute {
+		t.Errorf("expected the key TTL to move to the default TTL, got %v", ttl)
+	}
+}
+
+// basicStore only implements BasicStore.
+type basicStore struct {
+	BasicStore
+}
+
+// basicUpdaterStore implements BasicStore and Updater.
+type basicUpdaterStore struct {
+	BasicStore
+	Updater
+}
+
+func TestAdaptStore(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	redisStore := NewRedisStore(client, "adapt:")
+	if AdaptStore(redisStore) != Store(redisStore) {
+		t.Error("expected a Store to be returned as is")
+	}
+
+	for _, inner := range []BasicStore{basicStore{redisStore}, basicUpdaterStore{redisStore, redisStore}} {
+		store := AdaptStore(inner)
+		if _, ok := inner.(Updater); ok {
+			if _, ok := store.(Updater); !ok {
+				t.Errorf("expected the adapter of %T to keep Updater", inner)
+			}
+		}
+
+		id, _ := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
+		if err := store.Touch(ctx, id, time.Hour); err != nil {
+			t.Fatalf("Touch: %v", err)
+		}
+		rec, _ := store.Get(ctx, id)
+		if d := time.Until(rec.ExpiresAt); d < 59*time.Minute || rec.Data["k"] != "v" {
+			t.Errorf("expected Touch to extend the session, got %+v", rec)
+		}
+		if ttl := mr.TTL("adapt:" + id); ttl != time.Hour {
+			t.Errorf("expected the key TTL to be an hour, got %v", ttl)
+		}
+		if err := store.Touch(ctx, "missing", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound, got %v", err)
+		}
+
+		if err := store.CreateWithID(ctx, "adopted", map[string]interface{}{"k": "v"}, time.Minute); err != nil {
+			t.Fatalf("CreateWithID: %v", err)
+		}
+		if err := store.CreateWithID(ctx, "adopted", nil, time.Minute); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("expected ErrAlreadyExists, got %v", err)
+		}
+		if err := store.CreateWithID(ctx, "a:b", nil, time.Minute); err == nil {
+			t.Error("expected error for an invalid ID")
+		}
+		_ = store.Delete(ctx, "adopted")
+	}
+}
+
+func TestRedisStore_CreateWithID(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "adopt:")
+	if err := store.CreateWithID(ctx, "oauth-state-123", map[string]interface{}{"next": "/home"}, time.Minute); err != nil {
+		t.Fatalf("CreateWithID: %v", err)
+	}
+	rec, err := store.Get(ctx, "oauth-state-123")
+	if err != nil || rec == nil {
+		t.Fatalf("expected the adopted session, got %v, %v", rec, err)
+	}
+	if rec.ID != "oauth-state-123" || rec.Data["next"] != "/home" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if ttl := mr.TTL("adopt:oauth-state-123"); ttl != time.Minute {
+		t.Errorf("expected the key TTL to be a minute, got %v", ttl)
+	}
+
+	err = store.CreateWithID(ctx, "oauth-state-123", map[string]interface{}{"next": "/evil"}, time.Minute)
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	if rec, _ := store.Get(ctx, "oauth-state-123"); rec.Data["next"] != "/home" {
+		t.Errorf("expected the existing session to be kept, got %v", rec.Data)
+	}
+
+	// Only one of concurrent creations of the same ID succeeds
+	var wg sync.WaitGroup
+	var created atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.CreateWithID(ctx, "race", nil, time.Minute) == nil {
+				created.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := created.Load(); n != 1 {
+		t.Errorf("expected exactly one creation to succeed, got %d", n)
+	}
+
+	for _, id := range []string{"", "a:b"} {
+		if err := store.CreateWithID(ctx, id, nil, time.Minute); err == nil {
+			t.Errorf("expected error for ID %q", id)
+		}
+	}
+	if err := store.CreateWithID(ctx, "zero", nil, 0); err == nil {
+		t.Error("expected error for a zero TTL")
+	}
+	if err := NewRedisStore(nil, "kv:").CreateWithID(ctx, "id", nil, time.Minute); err == nil {
+		t.Error("expected error for nil client on CreateWithID")
+	}
+}
+
+func TestKVManager_CreateWithID(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	mgr := NewKVManager(NewRedisStore(client, "kv:"), 2*time.Minute)
+	if err := mgr.CreateWithID(ctx, "external", map[string]interface{}{"a": "1"}, 0); err != nil {
+		t.Fatalf("CreateWithID: %v", err)
+	}
+	if ttl := mr.TTL("kv:external"); ttl != 2*time.Minute {
+		t.Errorf("expected the default TTL, got %v", ttl)
+	}
+	if err := mgr.CreateWithID(ctx, "external", nil, 0); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+}
+
+func TestRedisStore_IDGenerator(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().
+		WithKeyPrefix("gen").
+		WithIDGenerator(NewHexIDGenerator("auth_", 32)))
+	id, err := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(id, "auth_") || len(id) != len("auth_")+64 {
+		t.Errorf("expected a generated hex id, got %q", id)
+	}
+	if !mr.Exists("gen:" + id) {
+		t.Errorf("expected key gen:%s", id)
+	}
+
+	// Generator failures and invalid ids are reported
+	store = NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().WithIDGenerator(func() (string, error) {
+		return "", fmt.Errorf("entropy exhausted")
+	}))
+	if _, err := store.Create(ctx, nil, time.Minute); err == nil || !strings.Contains(err.Error(), "entropy exhausted") {
+		t.Errorf("expected the generator error, got %v", err)
+	}
+	store = NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().WithIDGenerator(func() (string, error) {
+		return "a:b", nil
+	}))
+	if _, err := store.Create(ctx, nil, time.Minute); err == nil {
+		t.Error("expected error for an invalid generated id")
+	}
+}
+
+func TestRedisStore_CreateCollision(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	ids := []string{"taken", "taken", "free"}
+	var calls int
+	rigged := func() (string, error) {
+		id := ids[calls%len(ids)]
+		calls++
+		return id, nil
+	}
+	store := NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().
+		WithKeyPrefix("gen:").
+		WithIDGenerator(rigged))
+	if err := store.CreateWithID(ctx, "taken", map[string]interface{}{"owner": "first"}, time.Minute); err != nil {
+		t.Fatalf("CreateWithID: %v", err)
+	}
+
+	// Colliding ids are regenerated without touching the existing session
+	id, err := store.Create(ctx, map[string]interface{}{"owner": "second"}, time.Minute)
+	if err != nil || id != "free" || calls != 3 {
+		t.Fatalf("expected the third id after two collisions, got %q, %v after %d calls", id, err, calls)
+	}
+	if rec, _ := store.Get(ctx, "taken"); rec.Data["owner"] != "first" {
+		t.Errorf("expected the colliding session to be kept, got %v", rec.Data)
+	}
+
+	// Giving up after too many collisions
+	ids, calls = []string{"taken"}, 0
+	if _, err := store.Create(ctx, nil, time.Minute); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	if calls != redisStoreCreateAttempts {
+		t.Errorf("expected %d attempts, got %d", redisStoreCreateAttempts, calls)
+	}
+
+	// Set keeps overwriting existing sessions
+	if err := store.Set(ctx, "taken", map[string]interface{}{"owner": "update"}, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if rec, _ := store.Get(ctx, "taken"); rec.Data["owner"] != "update" {
+		t.Errorf("expected Set to overwrite the session, got %v", rec.Data)
+	}
+}
+
+func TestRedisStore_GetTTL(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "ttl:")
+	id, err := store.Create(ctx, map[string]interface{}{"k": "v"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	ttl, err := store.GetTTL(ctx, id)
+	if err != nil || ttl < 59*time.Second || ttl > time.Minute {
+		t.Errorf("expected about a minute for a fresh session, got %v, %v", ttl, err)
+	}
+
+	// The key TTL wins when it is sooner than ExpiresAt
+	mr.FastForward(57 * time.Second)
+	ttl, err = store.GetTTL(ctx, id)
+	if err != nil || ttl != 3*time.Second {
+		t.Errorf("expected 3s for a session near expiry, got %v, %v", ttl, err)
+	}
+
+	if _, err := store.GetTTL(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for a missing session, got %v", err)
+	}
+
+	// A key with a TTL left but an expired record is not found
+	body, _ := json.Marshal(KVSessionRecord{ID: "stale", ExpiresAt: time.Now().Add(-time.Second)})
+	if err := client.Set(ctx, "ttl:stale", body, time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetTTL(ctx, "stale"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for an expired record, got %v", err)
+	}
+
+	// A key without a TTL falls back to ExpiresAt
+	body, _ = json.Marshal(KVSessionRecord{ID: "persist", ExpiresAt: time.Now().Add(time.Hour)})
+	if err := client.Set(ctx, "ttl:persist", body, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := store.GetTTL(ctx, "persist"); err != nil || ttl < 59*time.Minute {
+		t.Errorf("expected about an hour from ExpiresAt, got %v, %v", ttl, err)
+	}
+
+	if _, err := NewRedisStore(nil, "kv:").GetTTL(ctx, id); err == nil {
+		t.Error("expected error for nil client on GetTTL")
+	}
+
+	mgr := NewKVManager(store, time.Minute)
+	if _, err := mgr.GetTTL(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound from KVManager, got %v", err)
+	}
+	if ttl, err := AdaptStore(basicStore{store}).GetTTL(ctx, "persist"); err != nil || ttl < 59*time.Minute {
+		t.Errorf("expected the adapter to compute the TTL from ExpiresAt, got %v, %v", ttl, err)
+	}
+}
+
+// pagedScanHook pages SCAN replies by COUNT, which miniredis ignores, using
+// the cursor as an offset into the sorted matching keys.
+type pagedScanHook struct{}
+
+func (pagedScanHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (pagedScanHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		scan, ok := cmd.(*redis.ScanCmd)
+		if !ok {
+			return next(ctx, cmd)
+		}
+		args := scan.Args()
+		cursor, _ := strconv.Atoi(fmt.Sprint(args[1]))
+		all := redis.NewScanCmd(ctx, nil, "scan", 0, args[2], args[3])
+		if err := next(ctx, all); err != nil {
+			return err
+		}
+		keys, _ := all.Val()
+		sort.Strings(keys)
+
+		end := len(keys)
+		if len(args) > 5 {
+			if count, _ := strconv.Atoi(fmt.Sprint(args[5])); cursor+count < end {
+				end = cursor + count
+			}
+		}
+		next := uint64(end)
+		if end == len(keys) {
+			next = 0
+		}
+		scan.SetVal(keys[cursor:end], next)
+		return nil
+	}
+}
+
+func (pagedScanHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisStore_List(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+	client.AddHook(pagedScanHook{})
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "list:")
+	want := make(map[string]bool)
+	for i := 0; i < 250; i++ {
+		id, err := store.Create(ctx, map[string]interface{}{"n": i}, time.Minute)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		want[id] = true
+	}
+	if err := client.Set(ctx, "other:key", "x", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	cursor, pages := "", 0
+	for {
+		ids, next, err := store.List(ctx, cursor, 20)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		pages++
+		for _, id := range ids {
+			if got[id] {
+				t.Errorf("id %q listed twice", id)
+			}
+			got[id] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if pages < 2 {
+		t.Errorf("expected several pages, got %d", pages)
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d ids, got %d", len(want), len(got))
+	}
+	for id := range want {
+		if !got[id] {
+			t.Errorf("id %q not listed", id)
+		}
+	}
+
+	if _, _, err := store.List(ctx, "not-a-cursor", 10); err == nil {
+		t.Error("expected error for an invalid cursor")
+	}
+	if _, _, err := NewRedisStore(nil, "kv:").List(ctx, "", 10); err == nil {
+		t.Error("expected error for nil client on List")
+	}
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	defer func() { _ = cluster.Close() }()
+	if _, _, err := NewRedisStoreUniversal(cluster, "kv:").List(ctx, "", 10); err == nil {
+		t.Error("expected error for a cluster client")
+	}
+	if _, _, err := AdaptStore(basicStore{store}).List(ctx, "", 10); err == nil {
+		t.Error("expected error listing through a BasicStore")
+	}
+}
+
+func TestKVManager_ForEach(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+	client.AddHook(pagedScanHook{})
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "each:")
+	mgr := NewKVManager(store, time.Minute)
+	for i := 0; i < 2*kvManagerPageSize+5; i++ {
+		if _, err := mgr.Create(ctx, map[string]interface{}{"n": i}, 0); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	// Expired records whose key is still there are skipped
+	body, _ := json.Marshal(KVSessionRecord{ID: "stale", ExpiresAt: time.Now().Add(-time.Second)})
+	if err := client.Set(ctx, "each:stale", body, time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[float64]bool)
+	err := mgr.ForEach(ctx, func(rec *KVSessionRecord) error {
+		if rec.ID == "stale" {
+			t.Error("expected the expired session to be skipped")
+		}
+		seen[rec.Data["n"].(float64)] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach: %v", err)
+	}
+	if len(seen) != 2*kvManagerPageSize+5 {
+		t.Errorf("expected %d sessions, got %d", 2*kvManagerPageSize+5, len(seen))
+	}
+
+	stop := errors.New("stop")
+	var calls int
+	err = mgr.ForEach(ctx, func(*KVSessionRecord) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected ForEach to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRedisStore_Revision(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "rev:")
+	id, _ := store.Create(ctx, map[string]interface{}{"n": 0}, time.Minute)
+	revision := func() int64 {
+		rec, _ := store.Get(ctx, id)
+		return rec.Revision
+	}
+	if r := revision(); r != 1 {
+		t.Errorf("expected revision 1 after Create, got %d", r)
+	}
+	_ = store.Set(ctx, id, map[string]interface{}{"n": 1}, time.Minute)
+	_ = store.Set(ctx, id, map[string]interface{}{"n": 2}, 0)
+	_ = store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) { return data, nil }, 0)
+	if r := revision(); r != 4 {
+		t.Errorf("expected revision 4 after three writes, got %d", r)
+	}
+	_ = store.Touch(ctx, id, time.Hour)
+	if r := revision(); r != 4 {
+		t.Errorf("expected Touch to keep the revision, got %d", r)
+	}
+}
+
+func TestRedisStore_SetCASInterleaved(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "cas:")
+	mgr := NewKVManager(store, time.Minute)
+	id, _ := mgr.Create(ctx, map[string]interface{}{"step": "start"}, 0)
+
+	// Two instances read the same revision and write in turn
+	_, revA, err := mgr.GetWithRevision(ctx, id)
+	if err != nil {
+		t.Fatalf("GetWithRevision: %v", err)
+	}
+	_, revB, _ := mgr.GetWithRevision(ctx, id)
+	if err := mgr.SetCAS(ctx, id, map[string]interface{}{"step": "a"}, 0, revA); err != nil {
+		t.Fatalf("SetCAS: %v", err)
+	}
+	if err := mgr.SetCAS(ctx, id, map[string]interface{}{"step": "b"}, 0, revB); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("expected ErrRevisionMismatch, got %v", err)
+	}
+	data, rev, _ := mgr.GetWithRevision(ctx, id)
+	if data["step"] != "a" || rev != revA+1 {
+		t.Errorf("expected the first write at revision %d, got %v at %d", revA+1, data, rev)
+	}
+
+	// The loser retries with the new revision
+	if err := mgr.SetCAS(ctx, id, map[string]interface{}{"step": "b"}, 0, rev); err != nil {
+		t.Errorf("expected the retry to succeed, got %v", err)
+	}
+
+	// A write landing between the check and the script is caught by the script
+	rec, _ := store.Get(ctx, id)
+	_ = store.Set(ctx, id, map[string]interface{}{"step": "c"}, time.Minute)
+	body, _ := json.Marshal(KVSessionRecord{Revision: rec.Revision + 1, ID: id, ExpiresAt: time.Now().Add(time.Minute)})
+	n, err := setCASScript.Run(ctx, client, []string{"cas:" + id}, rec.Revision, string(body), 0).Int()
+	if err != nil || n != 0 {
+		t.Errorf("expected the script to report a mismatch, got %d, %v", n, err)
+	}
+
+	if err := mgr.SetCAS(ctx, "missing", nil, 0, 0); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if _, _, err := mgr.GetWithRevision(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRedisStore_SetCASConcurrent(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "cas:")
+	id, _ := store.Create(ctx, nil, time.Minute)
+
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if store.SetCAS(ctx, id, map[string]interface{}{"winner": i}, time.Minute, 1) == nil {
+				won.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := won.Load(); n != 1 {
+		t.Errorf("expected exactly one SetCAS to succeed, got %d", n)
+	}
+}
+
+func TestRedisStore_SetCASLegacyRecord(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "cas:")
+	legacy := `{"id":"old","data":{"k":"v"},"created_at":"2024-01-01T00:00:00Z","expires_at":"` +
+		time.Now().Add(time.Minute).UTC().Format(time.RFC3339Nano) + `"}`
+	if err := client.Set(ctx, "cas:old", legacy, time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, _ := store.Get(ctx, "old")
+	if rec == nil || rec.Revision != 0 {
+		t.Fatalf("expected a legacy record at revision 0, got %+v", rec)
+	}
+	if err := store.SetCAS(ctx, "old", map[string]interface{}{"k": "w"}, 0, 1); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("expected ErrRevisionMismatch, got %v", err)
+	}
+	if err := store.SetCAS(ctx, "old", map[string]interface{}{"k": "w"}, 0, 0); err != nil {
+		t.Fatalf("SetCAS: %v", err)
+	}
+	rec, _ = store.Get(ctx, "old")
+	if rec.Revision != 1 || rec.Data["k"] != "w" || rec.CreatedAt.Year() != 2024 {
+		t.Errorf("expected the legacy record to move to revision 1, got %+v", rec)
+	}
+	if ttl := mr.TTL("cas:old"); ttl != time.Minute {
+		t.Errorf("expected SetCAS with a zero TTL to keep the key TTL, got %v", ttl)
+	}
+}
+
+func TestKVManager_GetData(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "data:")
+	mgr := NewKVManager(store, time.Minute)
+
+	id, err := mgr.Create(ctx, nil, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	body, _ := client.Get(ctx, "data:"+id).Result()
+	if !strings.Contains(body, `"data":{}`) {
+		t.Errorf("expected nil data to be written as an empty object, got %s", body)
+	}
+	data, err := mgr.GetData(ctx, id)
+	if err != nil || data == nil {
+		t.Fatalf("expected an empty map, got %v, %v", data, err)
+	}
+	data["k"] = "v" // must not panic
+
+	if err := mgr.Set(ctx, id, nil, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if body, _ := client.Get(ctx, "data:"+id).Result(); !strings.Contains(body, `"data":{}`) {
+		t.Errorf("expected Set to write nil data as an empty object, got %s", body)
+	}
+
+	// Records written with null data before normalization still work
+	legacy, _ := json.Marshal(map[string]interface{}{"id": "old", "data": nil, "expires_at": time.Now().Add(time.Minute)})
+	_ = client.Set(ctx, "data:old", legacy, time.Minute).Err()
+	if data, err := mgr.GetData(ctx, "old"); err != nil || data == nil {
+		t.Errorf("expected an empty map for null data, got %v, %v", data, err)
+	}
+
+	if _, err := mgr.GetData(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestRedisStore_GetMultiDeleteMulti(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	defer func() { _ = cluster.Close() }()
+
+	for name, store := range map[string]*RedisStore{
+		"single":  NewRedisStore(client, "multi:"),
+		"cluster": NewRedisStoreUniversal(cluster, "multi:"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mr.FlushAll()
+			a, _ := store.Create(ctx, map[string]interface{}{"n": "a"}, time.Minute)
+			b, _ := store.Create(ctx, map[string]interface{}{"n": "b"}, time.Minute)
+			expired, _ := json.Marshal(KVSessionRecord{ID: "expired", ExpiresAt: time.Now().Add(-time.Second)})
+			_ = client.Set(ctx, "multi:expired", expired, time.Minute).Err()
+			_ = client.Set(ctx, "multi:corrupt", "not json", time.Minute).Err()
+
+			records, err := store.GetMulti(ctx, []string{a, "missing", "expired", "corrupt", b})
+			var multiErr *GetMultiError
+			if !errors.As(err, &multiErr) || len(multiErr.Errors) != 1 || multiErr.Errors["corrupt"] == nil {
+				t.Errorf("expected a GetMultiError for the corrupt session only, got %v", err)
+			}
+			if len(records) != 2 || records[a].Data["n"] != "a" || records[b].Data["n"] != "b" {
+				t.Errorf("expected the two live sessions, got %v", records)
+			}
+
+			if err := store.DeleteMulti(ctx, []string{a, "missing", b}); err != nil {
+				t.Fatalf("DeleteMulti: %v", err)
+			}
+			if mr.Exists("multi:"+a) || mr.Exists("multi:"+b) {
+				t.Error("expected the sessions to be deleted")
+			}
+			if records, err := store.GetMulti(ctx, nil); err != nil || len(records) != 0 {
+				t.Errorf("expected an empty result for no IDs, got %v, %v", records, err)
+			}
+			if err := store.DeleteMulti(ctx, nil); err != nil {
+				t.Errorf("expected no error for no IDs, got %v", err)
+			}
+		})
+	}
+
+	// Servers without UNLINK fall back to DEL
+	client.AddHook(&noUnlinkHook{})
+	store := NewRedisStore(client, "multi:")
+	id, _ := store.Create(ctx, nil, time.Minute)
+	if err := store.DeleteMulti(ctx, []string{id}); err != nil || mr.Exists("multi:"+id) {
+		t.Errorf("expected DeleteMulti to fall back to DEL, got %v", err)
+	}
+
+	if _, err := NewRedisStore(nil, "kv:").GetMulti(ctx, []string{"a"}); err == nil {
+		t.Error("expected error for nil client on GetMulti")
+	}
+	if err := NewRedisStore(nil, "kv:").DeleteMulti(ctx, []string{"a"}); err == nil {
+		t.Error("expected error for nil client on DeleteMulti")
+	}
+
+	mgr := NewKVManager(AdaptStore(basicStore{store}), time.Minute)
+	id, _ = mgr.Create(ctx, nil, 0)
+	if records, err := mgr.GetMulti(ctx, []string{id, "missing"}); err != nil || len(records) != 1 {
+		t.Errorf("expected the adapter to loop over Get, got %v, %v", records, err)
+	}
+	if err := mgr.DeleteMulti(ctx, []string{id}); err != nil || mr.Exists("multi:"+id) {
+		t.Errorf("expected the adapter to loop over Delete, got %v", err)
+	}
+}
+
+func benchmarkRedisStoreMulti(b *testing.B, batch bool) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		b.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "bench:")
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i], _ = store.Create(ctx, map[string]interface{}{"n": i}, time.Hour)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			_, _ = store.GetMulti(ctx, ids)
+			continue
+		}
+		for _, id := range ids {
+			_, _ = store.Get(ctx, id)
+		}
+	}
+}
+
+func BenchmarkRedisStoreGetLoop(b *testing.B) {
+	benchmarkRedisStoreMulti(b, false)
+}
+
+func BenchmarkRedisStoreGetMulti(b *testing.B) {
+	benchmarkRedisStoreMulti(b, true)
+}
+
+func TestRedisStore_Encryption(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+	opts := DefaultRedisStoreOptions().WithKeyPrefix("enc:")
+	store := NewRedisStoreWithOptions(client, opts.WithEncryption([][]byte{oldKey}))
+
+	id, err := store.Create(ctx, map[string]interface{}{"phone": "+15550100"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	raw, _ := mr.Get("enc:" + id)
+	if strings.Contains(raw, "+15550100") || strings.Contains(raw, "expires_at") {
+		t.Errorf("expected the stored record to be encrypted, got %q", raw)
+	}
+	rec, err := store.Get(ctx, id)
+	if err != nil || rec == nil || rec.Data["phone"] != "+15550100" {
+		t.Fatalf("expected the record to decrypt, got %+v, %v", rec, err)
+	}
+
+	// Writes through every path keep the record readable
+	if err := store.Set(ctx, id, map[string]interface{}{"phone": "+15550101"}, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+		data["otp"] = "hash"
+		return data, nil
+	}, 0); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := store.Touch(ctx, id, 2*time.Minute); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if ttl, err := store.GetTTL(ctx, id); err != nil || ttl <= time.Minute {
+		t.Errorf("expected Touch to extend the TTL, got %v, %v", ttl, err)
+	}
+	if err := store.SetCAS(ctx, id, map[string]interface{}{"phone": "+15550102"}, 0, 1); !errors.Is(err, ErrRevisionMismatch) {
+		t.Errorf("expected ErrRevisionMismatch, got %v", err)
+	}
+	if err := store.SetCAS(ctx, id, map[string]interface{}{"phone": "+15550102"}, 0, 3); err != nil {
+		t.Fatalf("SetCAS: %v", err)
+	}
+	rec, err = store.Get(ctx, id)
+	if err != nil || rec.Data["phone"] != "+15550102" || rec.Revision != 4 {
+		t.Errorf("expected revision 4 with the new data, got %+v, %v", rec, err)
+	}
+	if ttl := mr.TTL("enc:" + id); ttl <= time.Minute {
+		t.Errorf("expected SetCAS to keep the TTL, got %v", ttl)
+	}
+	if err := store.Touch(ctx, "missing", time.Minute); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound from Touch, got %v", err)
+	}
+
+	// Rotation: old records still decrypt, new ones use the first key
+	rotated := NewRedisStoreWithOptions(client, opts.WithEncryption([][]byte{newKey, oldKey}))
+	if rec, err := rotated.Get(ctx, id); err != nil || rec.Data["phone"] != "+15550102" {
+		t.Fatalf("expected the rotated store to read old records, got %+v, %v", rec, err)
+	}
+	newID, _ := rotated.Create(ctx, map[string]interface{}{"n": 1}, time.Minute)
+	if _, err := store.Get(ctx, newID); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected the old key not to read new records, got %v", err)
+	}
+
+	// Exists does not decrypt
+	if ok, err := store.Exists(ctx, newID); err != nil || !ok {
+		t.Errorf("expected Exists without decryption, got %v, %v", ok, err)
+	}
+
+	// Tampered records and records moved to another key fail
+	b := []byte(raw)
+	b[len(b)/2] ^= 0x01
+	_ = mr.Set("enc:"+id, string(b))
+	if _, err := store.Get(ctx, id); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for a tampered record, got %v", err)
+	}
+	raw, _ = mr.Get("enc:" + newID)
+	_ = mr.Set("enc:moved", raw)
+	if _, err := rotated.Get(ctx, "moved"); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for a moved record, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an invalid key")
+		}
+	}()
+	NewRedisStoreWithOptions(client, opts.WithEncryption([][]byte{[]byte("short")}))
+}
+
+func TestKVManager_GetAndRefresh(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	redisStore := NewRedisStore(client, "refresh:")
+	encrypted := NewRedisStoreWithOptions(client, DefaultRedisStoreOptions().
+		WithKeyPrefix("refresh:").
+		WithEncryption([][]byte{bytes.Repeat([]byte{1}, 32)}))
+
+	for name, store := range map[string]Store{
+		"atomic":    redisStore,
+		"encrypted": encrypted,
+		"fallback":  plainStore{redisStore},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mgr := NewKVManager(store, time.Minute)
+			id, _ := mgr.Create(ctx, map[string]interface{}{"k": "v"}, 0)
+
+			rec, err := mgr.GetAndRefresh(ctx, id, time.Hour)
+			if err != nil {
+				t.Fatalf("GetAndRefresh: %v", err)
+			}
+			if rec.ID != id || rec.Data["k"] != "v" || rec.Revision != 1 {
+				t.Errorf("expected the session record, got %+v", rec)
+			}
+			keyTTL := mr.TTL("refresh:" + id)
+			if diff := time.Until(rec.ExpiresAt) - keyTTL; keyTTL <= time.Minute || diff < -time.Second || diff > time.Second {
+				t.Errorf("expected ExpiresAt to match the key TTL %v, got %v", keyTTL, rec.ExpiresAt)
+			}
+			stored, _ := mgr.Get(ctx, id)
+			if diff := stored.ExpiresAt.Sub(rec.ExpiresAt); diff < -time.Second || diff > time.Second {
+				t.Errorf("expected the returned ExpiresAt %v to be stored, got %v", rec.ExpiresAt, stored.ExpiresAt)
+			}
+
+			if rec, err := mgr.GetAndRefresh(ctx, id, 0); err != nil || mr.TTL("refresh:"+id) > time.Minute {
+				t.Errorf("expected the default TTL, got %+v, %v", rec, err)
+			}
+			if _, err := mgr.GetAndRefresh(ctx, "missing", time.Hour); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("expected ErrSessionNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKVManager_TTLJitter(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	store := NewRedisStore(client, "jitter:")
+	const ttl = 100 * time.Second
+
+	lifetimes := func(mgr *KVManager, n int) []time.Duration {
+		out := make([]time.Duration, n)
+		for i := range out {
+			id, err := mgr.Create(ctx, nil, 0)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			rec, _ := mgr.Get(ctx, id)
+			out[i] = rec.ExpiresAt.Sub(rec.CreatedAt)
+			if keyTTL := mr.TTL("jitter:" + id); (keyTTL - out[i]).Abs() > time.Millisecond {
+				t.Fatalf("expected the key TTL %v to match ExpiresAt %v", keyTTL, out[i])
+			}
+		}
+		return out
+	}
+
+	mgr := NewKVManagerWithOptions(store, DefaultKVManagerOptions().WithDefaultTTL(ttl).WithTTLJitter(0.1))
+	got := lifetimes(mgr, 500)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if got[0] < 90*time.Second || got[len(got)-1] > 110*time.Second {
+		t.Errorf("expected TTLs within ±10%%, got %v to %v", got[0], got[len(got)-1])
+	}
+	if got[0] > 95*time.Second || got[len(got)-1] < 105*time.Second {
+		t.Errorf("expected TTLs spread over ±10%%, got %v to %v", got[0], got[len(got)-1])
+	}
+	if median := got[len(got)/2]; median < 97*time.Second || median > 103*time.Second {
+		t.Errorf("expected TTLs centered on %v, got a median of %v", ttl, median)
+	}
+
+	for _, d := range lifetimes(NewKVManager(store, ttl), 20) {
+		if d != ttl {
+			t.Fatalf("expected no jitter by default, got %v", d)
+		}
+	}
+
+	// Jitter never produces a non-positive TTL
+	mgr = NewKVManagerWithOptions(store, DefaultKVManagerOptions().WithTTLJitter(5))
+	for i := 0; i < 100; i++ {
+		if d := mgr.writeTTL(time.Second); d <= 0 {
+			t.Fatalf("expected a positive TTL, got %v", d)
+		}
+	}
+}
+
+type observedOp struct {
+	op, id string
+	err    error
+}
+
+func TestKVManager_Observer(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	var ops []observedOp
+	opts := DefaultKVManagerOptions().WithObserver(func(op, id string, d time.Duration, err error) {
+		if d < 0 {
+			t.Errorf("expected a non-negative duration for %s, got %v", op, d)
+		}
+		ops = append(ops, observedOp{op, id, err})
+	})
+	mgr := NewKVManagerWithOptions(NewRedisStore(client, "obs:"), opts)
+
+	id, _ := mgr.Create(ctx, map[string]interface{}{"k": "v"}, 0)
+	_, _ = mgr.Get(ctx, id)
+	_ = mgr.Set(ctx, id, map[string]interface{}{"k": "v2"}, 0)
+	_, _ = mgr.Exists(ctx, id)
+	_ = mgr.Refresh(ctx, id, time.Hour)
+	_ = mgr.Delete(ctx, id)
+	_, _ = mgr.Get(ctx, id)
+	refreshErr := mgr.Refresh(ctx, id, time.Hour)
+	_, createErr := NewKVManagerWithOptions(NewRedisStore(nil, "obs:"), opts).Create(ctx, nil, 0)
+	_, _ = mgr.GetData(ctx, id) // not reported
+
+	if !errors.Is(refreshErr, ErrSessionNotFound) || createErr == nil {
+		t.Fatalf("expected the last refresh and create to fail, got %v, %v", refreshErr, createErr)
+	}
+	want := []observedOp{
+		{"create", id, nil},
+		{"get", id, nil},
+		{"set", id, nil},
+		{"exists", id, nil},
+		{"refresh", id, nil},
+		{"delete", id, nil},
+		{"get", id, ErrSessionNotFound},
+		{"refresh", id, refreshErr},
+		{"create", "", createErr},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("expected %d operations, got %v", len(want), ops)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("operation %d: expected %v, got %v", i, want[i], ops[i])
+		}
+	}
+
+	// Without an observer the clock is not read
+	if start := NewKVManager(NewRedisStore(client, "obs:"), time.Minute).opStart(); !start.IsZero() {
+		t.Errorf("expected no start time without an observer, got %v", start)
 	}
 }
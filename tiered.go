@@ -0,0 +1,132 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTieredCacheTTL is the cache TTL used by TieredStorage when none is given.
+const DefaultTieredCacheTTL = 5 * time.Second
+
+// TieredStats is a point-in-time view of TieredStorage usage.
+type TieredStats struct {
+	// Hits is the number of Get calls served by the cache.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of Get calls that fell through to the backing storage.
+	Misses uint64 `json:"misses"`
+}
+
+// HitRatio returns the fraction of Get calls served by the cache,
+// or 0 if there were none.
+func (st TieredStats) HitRatio() float64 {
+	total := st.Hits + st.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(st.Hits) / float64(total)
+}
+
+// TieredStorage serves reads from a local cache, usually a MemoryStorage,
+// in front of a backing storage, usually Redis, which stays the source of
+// truth.
+//
+// Get checks the cache first and falls through to the backing storage on a
+// miss, filling the cache for cacheTTL. Set and Delete write through to both,
+// and Delete evicts the cache entry before returning, so a logout is
+// immediate on the node that handled it. Other nodes may keep serving their
+// cached copy of a changed or deleted session for up to cacheTTL, and a read
+// racing with a Delete on the same node may refill the cache with the old
+// value for as long; keep cacheTTL short.
+//
+// Cache errors are ignored: the cache is only an optimization.
+type TieredStorage struct {
+	cache    Storage
+	backing  Storage
+	cacheTTL time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewTieredStorage creates a TieredStorage. A cacheTTL <= 0 falls back to
+// DefaultTieredCacheTTL, since cached entries must expire to bound stale reads.
+func NewTieredStorage(cache, backing Storage, cacheTTL time.Duration) Storage {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultTieredCacheTTL
+	}
+	return &TieredStorage{
+		cache:    cache,
+		backing:  backing,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Stats returns the cache hit and miss counters.
+func (s *TieredStorage) Stats() TieredStats {
+	return TieredStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+	}
+}
+
+// cacheExpiration returns the TTL to cache a value stored with exp: the
+// cache TTL, or exp if the value expires sooner.
+func (s *TieredStorage) cacheExpiration(exp time.Duration) time.Duration {
+	if exp > 0 && exp < s.cacheTTL {
+		return exp
+	}
+	return s.cacheTTL
+}
+
+// Get retrieves the value for the given key from the cache, or from the
+// backing storage on a miss, caching the result.
+func (s *TieredStorage) Get(key string) ([]byte, error) {
+	if data, err := s.cache.Get(key); err == nil && data != nil {
+		s.hits.Add(1)
+		return data, nil
+	}
+	s.misses.Add(1)
+
+	data, err := s.backing.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	_ = s.cache.Set(key, data, s.cacheTTL)
+	return data, nil
+}
+
+// Set stores the given value in the backing storage, then in the cache.
+// If the backing storage fails, the cache entry is evicted instead.
+func (s *TieredStorage) Set(key string, val []byte, exp time.Duration) error {
+	if err := s.backing.Set(key, val, exp); err != nil {
+		_ = s.cache.Delete(key)
+		return err
+	}
+	_ = s.cache.Set(key, val, s.cacheExpiration(exp))
+	return nil
+}
+
+// Delete removes the value from the backing storage and evicts it from the
+// cache, even if the backing storage fails.
+func (s *TieredStorage) Delete(key string) error {
+	err := s.backing.Delete(key)
+	_ = s.cache.Delete(key)
+	return err
+}
+
+// Reset removes all keys from both storages.
+func (s *TieredStorage) Reset() error {
+	return errors.Join(s.backing.Reset(), s.cache.Reset())
+}
+
+// Ping checks the backing storage, as the cache alone cannot serve sessions.
+func (s *TieredStorage) Ping(ctx context.Context) error {
+	return pingStorage(ctx, s.backing)
+}
+
+// Close closes both storages.
+func (s *TieredStorage) Close() error {
+	return errors.Join(s.backing.Close(), s.cache.Close())
+}
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestTieredStorage(cacheTTL time.Duration) (*TieredStorage, *MemoryStorage, *switchStorage) {
+	cache := NewMemoryStorage("cache:", 0)
+	backing := &switchStorage{Storage: NewMemoryStorage("backing:", 0)}
+	return NewTieredStorage(cache, backing, cacheTTL).(*TieredStorage), cache, backing
+}
+
+func TestTieredStorageFill(t *testing.T) {
+	storage, cache, backing := newTestTieredStorage(time.Minute)
+	defer func() { _ = storage.Close() }()
+
+	_ = backing.Storage.Set("key", []byte("value"), time.Hour)
+
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Fatalf("expected value from backing storage, got %q", got)
+	}
+	if got, _ := cache.Get("key"); string(got) != "value" {
+		t.Errorf("expected the cache to be filled, got %q", got)
+	}
+	if ttl, _ := cache.GetTTL("key"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the cache TTL to apply, got %v", ttl)
+	}
+
+	calls := backing.calls.Load()
+	if got, _ := storage.Get("key"); string(got) != "value" {
+		t.Fatalf("expected cached value, got %q", got)
+	}
+	if backing.calls.Load() != calls {
+		t.Error("expected a cache hit not to reach the backing storage")
+	}
+
+	if got, err := storage.Get("missing"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing key, got %q (%v)", got, err)
+	}
+	if st := storage.Stats(); st.Hits != 1 || st.Misses != 2 || st.HitRatio() != 1.0/3 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestTieredStorageWriteThrough(t *testing.T) {
+	storage, cache, backing := newTestTieredStorage(time.Minute)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), 10*time.Second)
+	if got, _ := backing.Get("key"); string(got) != "value" {
+		t.Errorf("expected backing storage to be written, got %q", got)
+	}
+	if ttl, _ := cache.GetTTL("key"); ttl <= 0 || ttl > 10*time.Second {
+		t.Errorf("expected the cache entry not to outlive the value, got %v", ttl)
+	}
+
+	// A failed write must not leave the old value cached
+	backing.down.Store(true)
+	if err := storage.Set("key", []byte("new"), time.Hour); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected backing error, got %v", err)
+	}
+	if got, _ := cache.Get("key"); got != nil {
+		t.Errorf("expected the cache entry to be evicted, got %q", got)
+	}
+}
+
+func TestTieredStorageInvalidation(t *testing.T) {
+	storage, cache, backing := newTestTieredStorage(time.Minute)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	_ = storage.Set("other", []byte("value"), time.Hour)
+
+	if err := storage.Delete("key"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if got, _ := storage.Get("key"); got != nil {
+		t.Errorf("expected delete to be visible immediately, got %q", got)
+	}
+
+	// The cache entry is evicted even if the backing storage fails
+	backing.down.Store(true)
+	if err := storage.Delete("other"); err == nil {
+		t.Error("expected backing error")
+	}
+	if got, _ := cache.Get("other"); got != nil {
+		t.Errorf("expected the cache entry to be evicted, got %q", got)
+	}
+	backing.down.Store(false)
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	if err := storage.Reset(); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Error("expected the cache to be reset")
+	}
+	if got, _ := backing.Get("key"); got != nil {
+		t.Error("expected the backing storage to be reset")
+	}
+}
+
+func TestTieredStorageStaleReadsBounded(t *testing.T) {
+	storage, _, backing := newTestTieredStorage(30 * time.Millisecond)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("old"), time.Hour)
+
+	// Another node changes the session behind this node's cache
+	_ = backing.Storage.Set("key", []byte("new"), time.Hour)
+	if got, _ := storage.Get("key"); string(got) != "old" {
+		t.Errorf("expected the cached value within the cache TTL, got %q", got)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := storage.Get("key"); string(got) != "new" {
+		t.Errorf("expected the new value after the cache TTL, got %q", got)
+	}
+}
+
+func TestTieredStorageBackingFailureWhileCached(t *testing.T) {
+	storage, _, backing := newTestTieredStorage(time.Minute)
+	defer func() { _ = storage.Close() }()
+
+	_ = storage.Set("key", []byte("value"), time.Hour)
+	backing.down.Store(true)
+
+	if got, err := storage.Get("key"); string(got) != "value" || err != nil {
+		t.Errorf("expected the cached value while the backing storage is down, got %q (%v)", got, err)
+	}
+	if _, err := storage.Get("uncached"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("expected backing error for an uncached key, got %v", err)
+	}
+	if err := storage.Ping(context.Background()); err != nil {
+		t.Errorf("expected ping of the backing storage to succeed, got %v", err)
+	}
+}
+
+func TestTieredStorageDefaultTTL(t *testing.T) {
+	storage, _, _ := newTestTieredStorage(0)
+	defer func() { _ = storage.Close() }()
+
+	if storage.cacheTTL != DefaultTieredCacheTTL {
+		t.Errorf("expected default cache TTL, got %v", storage.cacheTTL)
+	}
+}
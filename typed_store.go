@@ -0,0 +1,110 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TypedKVManager wraps a KVManager to store values of type T instead of
+// maps. T is encoded with encoding/json, so its JSON tags apply, and must
+// encode as a JSON object: its fields become the keys of the record's Data,
+// so the sessions stay readable through the untyped KVManager.
+//
+// Decoding fails with an error naming T if the stored data does not match
+// T, including when it has fields that T does not.
+type TypedKVManager[T any] struct {
+	mgr *KVManager
+}
+
+// NewTypedKVManager returns a TypedKVManager storing values of type T
+// through mgr.
+func NewTypedKVManager[T any](mgr *KVManager) *TypedKVManager[T] {
+	return &TypedKVManager[T]{mgr: mgr}
+}
+
+// KVManager returns the underlying KVManager.
+func (m *TypedKVManager[T]) KVManager() *KVManager {
+	return m.mgr
+}
+
+// Create creates a new session holding value and returns its ID. If ttl is
+// 0, the default TTL is used.
+func (m *TypedKVManager[T]) Create(ctx context.Context, value T, ttl time.Duration) (string, error) {
+	data, err := m.encode(value)
+	if err != nil {
+		return "", err
+	}
+	return m.mgr.Create(ctx, data, ttl)
+}
+
+// Get returns the value of the session for the given ID, or nil and no
+// error if the session does not exist or has expired.
+func (m *TypedKVManager[T]) Get(ctx context.Context, id string) (*T, error) {
+	rec, err := m.mgr.Get(ctx, id)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return m.decode(id, rec.Data)
+}
+
+// Set stores value in the session for the given ID. If ttl is 0, the
+// default TTL is used.
+func (m *TypedKVManager[T]) Set(ctx context.Context, id string, value T, ttl time.Duration) error {
+	data, err := m.encode(value)
+	if err != nil {
+		return err
+	}
+	return m.mgr.Set(ctx, id, data, ttl)
+}
+
+// Update modifies the value of the session in place with fn; see
+// KVManager.Update. fn may be called more than once, each time with a
+// freshly decoded value. If ttl is 0, the default TTL is used.
+func (m *TypedKVManager[T]) Update(ctx context.Context, id string, fn func(value *T) error, ttl time.Duration) error {
+	return m.mgr.Update(ctx, id, func(data map[string]interface{}) (map[string]interface{}, error) {
+		value, err := m.decode(id, data)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(value); err != nil {
+			return nil, err
+		}
+		return m.encode(*value)
+	}, ttl)
+}
+
+// Delete removes the session for the given ID.
+func (m *TypedKVManager[T]) Delete(ctx context.Context, id string) error {
+	return m.mgr.Delete(ctx, id)
+}
+
+// encode converts value to record data.
+func (m *TypedKVManager[T]) encode(value T) (map[string]interface{}, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode %T session: %w", value, err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil || data == nil {
+		return nil, fmt.Errorf("encode %T session: value must encode as a JSON object", value)
+	}
+	return data, nil
+}
+
+// decode converts record data to a value.
+func (m *TypedKVManager[T]) decode(id string, data map[string]interface{}) (*T, error) {
+	value := new(T)
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode session %s into %T: %w", id, *value, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(value); err != nil {
+		return nil, fmt.Errorf("decode session %s into %T: %w", id, *value, err)
+	}
+	return value, nil
+}
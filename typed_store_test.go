@@ -0,0 +1,125 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type verification struct {
+	Phone    string    `json:"phone"`
+	Attempts int       `json:"attempts"`
+	State    string    `json:"state"`
+	SentAt   time.Time `json:"sent_at"`
+	Codes    [][]int   `json:"codes,omitempty"`
+}
+
+type otherSession struct {
+	UserID int64 `json:"user_id"`
+}
+
+func TestTypedKVManager(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	mgr := NewKVManager(NewRedisStore(client, "typed:"), time.Minute)
+	typed := NewTypedKVManager[verification](mgr)
+
+	sentAt := time.Date(2024, 5, 1, 12, 30, 0, 123000000, time.UTC)
+	id, err := typed.Create(ctx, verification{
+		Phone:  "+15550100",
+		State:  "pending",
+		SentAt: sentAt,
+		Codes:  [][]int{{1, 2}, {3}},
+	}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := typed.Get(ctx, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Phone != "+15550100" || got.State != "pending" || !got.SentAt.Equal(sentAt) {
+		t.Errorf("unexpected value %+v", got)
+	}
+	if len(got.Codes) != 2 || got.Codes[0][1] != 2 || got.Codes[1][0] != 3 {
+		t.Errorf("expected nested slices to round trip, got %v", got.Codes)
+	}
+
+	// The fields are readable through the untyped manager
+	rec, _ := mgr.Get(ctx, id)
+	if rec.Data["phone"] != "+15550100" {
+		t.Errorf("expected the fields in Data, got %v", rec.Data)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := typed.Update(ctx, id, func(v *verification) error {
+			v.Attempts++
+			return nil
+		}, 0)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+	if got, _ := typed.Get(ctx, id); got.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", got.Attempts)
+	}
+
+	got.State = "verified"
+	if err := typed.Set(ctx, id, *got, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _ := typed.Get(ctx, id); got.State != "verified" {
+		t.Errorf("expected the new state, got %q", got.State)
+	}
+
+	if got, err := typed.Get(ctx, "missing"); got != nil || err != nil {
+		t.Errorf("expected nil, nil for a missing session, got %v, %v", got, err)
+	}
+	if err := typed.Update(ctx, "missing", func(*verification) error { return nil }, 0); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+	if err := typed.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if typed.KVManager() != mgr {
+		t.Error("expected KVManager to return the wrapped manager")
+	}
+}
+
+func TestTypedKVManagerDecodeErrors(t *testing.T) {
+	mr, client := setupMiniRedis(t)
+	defer mr.Close()
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	mgr := NewKVManager(NewRedisStore(client, "typed:"), time.Minute)
+	id, err := NewTypedKVManager[verification](mgr).Create(ctx, verification{Phone: "+15550100"}, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Decoding into the wrong type names it
+	_, err = NewTypedKVManager[otherSession](mgr).Get(ctx, id)
+	if err == nil || !strings.Contains(err.Error(), "session.otherSession") {
+		t.Errorf("expected an error naming the type, got %v", err)
+	}
+
+	// Mismatched field types are reported too
+	if err := mgr.Set(ctx, id, map[string]interface{}{"phone": 42}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTypedKVManager[verification](mgr).Get(ctx, id); err == nil {
+		t.Error("expected error for a mismatched field type")
+	}
+
+	// Values must encode as JSON objects
+	if _, err := NewTypedKVManager[[]string](mgr).Create(ctx, []string{"a"}, 0); err == nil {
+		t.Error("expected error for a value that is not a JSON object")
+	}
+}
@@ -0,0 +1,230 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// userIndexPrefix prefixes the storage key of the index of the sessions of
+// a user, kept by Manager.SaveSession when Config.MaxSessionsPerUser is set.
+// Manager rejects session IDs containing ':', so they never collide.
+const userIndexPrefix = "user-sessions:"
+
+// SessionLimitPolicy decides what Manager.SaveSession does when a user
+// already has Config.MaxSessionsPerUser sessions.
+type SessionLimitPolicy int
+
+const (
+	// SessionLimitEvictOldest deletes the sessions of the user that were
+	// indexed first, i.e. the oldest logins, to make room for the new one.
+	SessionLimitEvictOldest SessionLimitPolicy = iota
+	// SessionLimitReject fails with ErrTooManySessions.
+	SessionLimitReject
+)
+
+// String returns a human-readable name for the policy.
+func (p SessionLimitPolicy) String() string {
+	switch p {
+	case SessionLimitEvictOldest:
+		return "evict-oldest"
+	case SessionLimitReject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// userIndexKey returns the storage key of the index of the sessions of
+// userID.
+func userIndexKey(userID string) string {
+	return userIndexPrefix + userID
+}
+
+// OnDeleted registers fn to be called with the ID of every session Manager
+// deletes: through DeleteSession and DeleteSessions, and when SaveSession
+// evicts the oldest sessions of a user beyond
+// Config.MaxSessionsPerUser. Hooks must be safe for concurrent use; panics
+// are recovered.
+func (m *Manager) OnDeleted(fn func(id string)) {
+	if fn == nil {
+		return
+	}
+	m.hooksMu.Lock()
+	m.onDeleted = append(m.onDeleted, fn)
+	m.hooksMu.Unlock()
+}
+
+// fireDeleted invokes the OnDeleted hooks for each of ids.
+func (m *Manager) fireDeleted(ids ...string) {
+	m.hooksMu.RLock()
+	hooks := m.onDeleted
+	m.hooksMu.RUnlock()
+
+	for _, id := range ids {
+		for _, fn := range hooks {
+			func() {
+				defer func() {
+					_ = recover()
+				}()
+				fn(id)
+			}()
+		}
+	}
+}
+
+// UserSessions returns the IDs of the sessions of userID indexed by
+// SaveSession under Config.MaxSessionsPerUser, oldest first. It may include
+// sessions that have since expired or been deleted.
+func (m *Manager) UserSessions(userID string) ([]string, error) {
+	return m.userSessions(context.Background(), userID)
+}
+
+// userSessions implements UserSessions.
+func (m *Manager) userSessions(ctx context.Context, userID string) ([]string, error) {
+	data, err := m.get(ctx, userIndexKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user sessions: %w", err)
+	}
+	return ids, nil
+}
+
+// indexUserSession adds session to the index of its user, if it has one,
+// enforcing Config.MaxSessionsPerUser: the sessions of the user indexed
+// first beyond the limit are deleted, or ErrTooManySessions is returned
+// under the SessionLimitReject policy. Sessions are evicted in the order
+// they logged in rather than by last access, which touches and sliding
+// expiration do not write to storage. Sessions that expired or were deleted are
+// pruned from the index. If session is already indexed, the index is only
+// written again to extend its TTL along with the session's.
+//
+// Index updates are serialized within the Manager, so concurrent logins of
+// a user never exceed the limit; Managers in other processes sharing the
+// storage may briefly exceed it.
+func (m *Manager) indexUserSession(ctx context.Context, session *SessionData) error {
+	if m.config.MaxSessionsPerUser <= 0 || session.UserID == "" {
+		return nil
+	}
+	m.userMu.Lock()
+	defer m.userMu.Unlock()
+
+	ids, err := m.userSessions(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(ids, session.ID) {
+		// Extend the index with the session it lists
+		return m.saveUserIndex(ctx, session.UserID, ids)
+	}
+
+	live, err := m.liveUserSessions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if excess := len(live) - m.config.MaxSessionsPerUser + 1; excess > 0 {
+		if m.config.MaxSessionsPolicy == SessionLimitReject {
+			return ErrTooManySessions
+		}
+		// The index lists the sessions oldest first
+		evicted := make(map[string]bool, excess)
+		for _, s := range live[:excess] {
+			if err := m.del(ctx, s.ID); err != nil {
+				return fmt.Errorf("failed to evict session %s: %w", s.ID, err)
+			}
+			evicted[s.ID] = true
+			m.fireDeleted(s.ID)
+		}
+		live = slices.DeleteFunc(live, func(s *SessionData) bool { return evicted[s.ID] })
+	}
+
+	indexed := make([]string, 0, len(live)+1)
+	for _, s := range live {
+		indexed = append(indexed, s.ID)
+	}
+	indexed = append(indexed, session.ID)
+	return m.saveUserIndex(ctx, session.UserID, indexed)
+}
+
+// userIndexTTL returns the TTL of the indexes of the sessions of users. It
+// is written again whenever the expiration of a listed session is
+// extended, so the index outlives every session it lists.
+func (m *Manager) userIndexTTL() time.Duration {
+	return max(m.config.Expiration, m.config.AnonymousExpiration)
+}
+
+// saveUserIndex writes the index of the sessions of userID.
+func (m *Manager) saveUserIndex(ctx context.Context, userID string, ids []string) error {
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user sessions: %w", err)
+	}
+	if err := m.set(ctx, userIndexKey(userID), data, m.userIndexTTL()); err != nil {
+		return fmt.Errorf("failed to save user sessions: %w", err)
+	}
+	return nil
+}
+
+// refreshUserIndex extends the TTL of the index of the sessions of the user
+// of session, after the expiration of session was extended without going
+// through SaveSession. If the index is gone, session is indexed again.
+func (m *Manager) refreshUserIndex(ctx context.Context, session *SessionData) error {
+	if m.config.MaxSessionsPerUser <= 0 || session.UserID == "" {
+		return nil
+	}
+	if ext, ok := m.storage.(ExtendedStorage); ok {
+		found, err := bounded(ctx, m, func() (bool, error) {
+			return ext.Touch(userIndexKey(session.UserID), m.userIndexTTL())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to extend user sessions: %w", err)
+		}
+		if found {
+			return nil
+		}
+	}
+	return m.indexUserSession(ctx, session)
+}
+
+// liveUserSessions returns the sessions of ids that still exist, in order.
+func (m *Manager) liveUserSessions(ctx context.Context, ids []string) ([]*SessionData, error) {
+	var live []*SessionData
+	for _, id := range ids {
+		data, err := m.get(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		if data == nil {
+			continue
+		}
+		var session SessionData
+		if err := json.Unmarshal(data, &session); err != nil {
+			// Sessions that cannot be read still count against the limit
+			session = SessionData{ID: id}
+		} else if session.IsExpired() && !m.storageSlides() {
+			continue
+		}
+		live = append(live, &session)
+	}
+	return live, nil
+}
+
+// storageSlides reports whether sliding expiration extends the storage TTL
+// of sessions in place, leaving their stored ExpiresAt behind, so that the
+// storage TTL is authoritative.
+func (m *Manager) storageSlides() bool {
+	if !m.config.SlidingExpiration || m.config.Expiration <= 0 {
+		return false
+	}
+	_, refresher := m.storage.(Refresher)
+	_, ext := m.storage.(ExtendedStorage)
+	return refresher || ext
+}
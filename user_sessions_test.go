@@ -0,0 +1,182 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newUserSession(t *testing.T, manager *Manager, id, userID string) *SessionData {
+	t.Helper()
+	session := manager.CreateSession(id)
+	session.UserID = userID
+	session.Authenticated = true
+	if err := manager.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session %s: %v", id, err)
+	}
+	return session
+}
+
+func TestManagerMaxSessionsPerUser(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithMaxSessionsPerUser(3))
+
+	var deleted []string
+	manager.OnDeleted(func(id string) { deleted = append(deleted, id) })
+
+	for i := 1; i <= 3; i++ {
+		newUserSession(t, manager, fmt.Sprintf("s%d", i), "user-1")
+		time.Sleep(time.Millisecond)
+	}
+	// s1 is used again, but remains the oldest login
+	s1, _ := manager.LoadSession("s1")
+	if err := manager.TouchSession(s1); err != nil {
+		t.Fatalf("failed to touch session: %v", err)
+	}
+	newUserSession(t, manager, "other", "user-2")
+
+	newUserSession(t, manager, "s4", "user-1")
+	if !slices.Equal(deleted, []string{"s1"}) {
+		t.Errorf("expected s1 to be evicted, got %v", deleted)
+	}
+	if loaded, _ := manager.LoadSession("s1"); loaded != nil {
+		t.Error("expected the evicted session to be deleted")
+	}
+	ids, err := manager.UserSessions("user-1")
+	if err != nil || !slices.Equal(ids, []string{"s2", "s3", "s4"}) {
+		t.Errorf("expected s2, s3 and s4 to be indexed, got %v (%v)", ids, err)
+	}
+	if loaded, _ := manager.LoadSession("other"); loaded == nil {
+		t.Error("expected the session of another user to be kept")
+	}
+
+	// Saving an indexed session again does not count it twice
+	s3, _ := manager.LoadSession("s3")
+	if err := manager.SaveSession(s3); err != nil || len(deleted) != 1 {
+		t.Errorf("expected no eviction when saving an indexed session, got %v (%v)", deleted, err)
+	}
+
+	// Deleted sessions are pruned instead of evicting live ones
+	if err := manager.DeleteSession("s2"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	newUserSession(t, manager, "s5", "user-1")
+	if !slices.Equal(deleted, []string{"s1", "s2"}) {
+		t.Errorf("expected no eviction after a deletion, got %v", deleted)
+	}
+}
+
+func TestManagerMaxSessionsPerUserReject(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithMaxSessionsPerUser(2).WithMaxSessionsPolicy(SessionLimitReject))
+
+	newUserSession(t, manager, "s1", "user-1")
+	newUserSession(t, manager, "s2", "user-1")
+
+	session := manager.CreateSession("s3")
+	session.UserID = "user-1"
+	if err := manager.SaveSession(session); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("expected ErrTooManySessions, got %v", err)
+	}
+	if loaded, _ := manager.LoadSession("s3"); loaded != nil {
+		t.Error("expected the rejected session not to be saved")
+	}
+	if loaded, _ := manager.LoadSession("s1"); loaded == nil {
+		t.Error("expected the existing sessions to be kept")
+	}
+
+	if err := DefaultConfig().WithMaxSessionsPerUser(-1).Validate(); err == nil {
+		t.Error("expected an error for a negative limit")
+	}
+}
+
+func TestManagerMaxSessionsPerUserConcurrent(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithMaxSessionsPerUser(5))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := manager.CreateSession(fmt.Sprintf("s%d", i))
+			session.UserID = "user-1"
+			if err := manager.SaveSession(session); err != nil {
+				t.Errorf("failed to save session: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	live := 0
+	for i := 0; i < 20; i++ {
+		if loaded, _ := manager.LoadSession(fmt.Sprintf("s%d", i)); loaded != nil {
+			live++
+		}
+	}
+	if live != 5 {
+		t.Errorf("expected 5 live sessions, got %d", live)
+	}
+	if ids, _ := manager.UserSessions("user-1"); len(ids) != 5 {
+		t.Errorf("expected 5 indexed sessions, got %v", ids)
+	}
+}
+
+func TestManagerMaxSessionsPerUserOutlivesIndexTTL(t *testing.T) {
+	for _, sliding := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sliding=%v", sliding), func(t *testing.T) {
+			storage := NewMemoryStorage("test:", 0)
+			defer func() { _ = storage.Close() }()
+			config := DefaultConfig().
+				WithExpiration(150 * time.Millisecond).
+				WithSlidingExpiration(sliding).
+				WithMaxSessionsPerUser(1).
+				WithMaxSessionsPolicy(SessionLimitReject)
+			manager := NewManager(storage, config)
+
+			session := newUserSession(t, manager, "s1", "user-1")
+			// Keep s1 alive well past the TTL the index was first written with
+			for range 6 {
+				time.Sleep(50 * time.Millisecond)
+				if sliding {
+					if session, _ = manager.LoadSession("s1"); session == nil {
+						t.Fatal("expected the session to be kept alive")
+					}
+				} else if err := manager.TouchSession(session); err != nil {
+					t.Fatalf("failed to touch session: %v", err)
+				}
+			}
+
+			second := manager.CreateSession("s2")
+			second.UserID = "user-1"
+			if err := manager.SaveSession(second); !errors.Is(err, ErrTooManySessions) {
+				t.Errorf("expected ErrTooManySessions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestManagerUserIndexKeyspace(t *testing.T) {
+	storage := NewMemoryStorage("test:", 0)
+	defer func() { _ = storage.Close() }()
+	manager := NewManager(storage, DefaultConfig().WithMaxSessionsPerUser(2))
+	newUserSession(t, manager, "s1", "user-1")
+
+	// Session IDs cannot name the index, so it can be neither read nor
+	// overwritten as a session
+	if err := manager.SaveSession(manager.CreateSession(userIndexKey("user-1"))); err == nil {
+		t.Error("expected a session ID containing ':' to be rejected")
+	}
+	if loaded, err := manager.LoadSession(userIndexKey("user-1")); loaded != nil || err != nil {
+		t.Errorf("expected the index not to load as a session, got %v, %v", loaded, err)
+	}
+	if ids, err := manager.UserSessions("user-1"); err != nil || !slices.Equal(ids, []string{"s1"}) {
+		t.Errorf("expected the index to be intact, got %v (%v)", ids, err)
+	}
+}